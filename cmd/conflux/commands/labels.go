@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/spf13/cobra"
+
+	"conflux/internal/confluence"
+	"conflux/internal/content"
+	"conflux/pkg/logger"
+)
+
+var (
+	labelsSpace   string
+	labelsProject string
+	labelsPage    string
+	labelsAdd     []string
+	labelsRemove  []string
+)
+
+var labelsCmd = &cobra.Command{
+	Use:   "labels",
+	Short: "List, add, or remove page labels",
+	Long: `List the labels on a Confluence page, or add and remove labels without
+pulling the page.
+
+Space may be selected through --space, --project, CONFLUX_SPACE_KEY,
+confluence.space_key, or the first configured project.
+
+Then specify either a numeric page ID or a page title with --page. The page's
+labels are printed after any changes, one per line. Adding a label the page
+already has, or removing one it does not have, changes nothing.`,
+	Example: `  conflux labels -s DOCS -p "Release Checklist"
+  conflux labels -s DOCS -p 123456789 --add runbook,ops --remove draft`,
+	RunE: runLabels,
+}
+
+// labelClient reads and changes page labels.
+type labelClient interface {
+	GetLabels(ctx context.Context, pageID string) ([]string, error)
+	AddLabels(ctx context.Context, pageID string, labels []string) error
+	RemoveLabel(ctx context.Context, pageID, label string) error
+}
+
+func runLabels(cmd *cobra.Command, args []string) error {
+	if labelsPage == "" {
+		return fmt.Errorf("page flag is required for labels command")
+	}
+	for _, label := range append(slices.Clone(labelsAdd), labelsRemove...) {
+		if err := content.ValidateLabel(label); err != nil {
+			return err
+		}
+	}
+	for _, label := range labelsAdd {
+		if slices.Contains(labelsRemove, label) {
+			return fmt.Errorf("label %q is both added and removed", label)
+		}
+	}
+
+	log := logger.New(verbose)
+	runtime, err := resolveRuntimeConfig(labelsSpace, labelsProject)
+	if err != nil {
+		return err
+	}
+	effectiveSpace := runtime.Confluence.SpaceKey
+	client := newConfluenceClient(runtime.Confluence.BaseURL, runtime.Confluence.Username, runtime.Confluence.APIToken, log)
+	labeler, ok := client.(labelClient)
+	if !ok {
+		return fmt.Errorf("confluence adapter does not support page labels")
+	}
+
+	var page *confluence.Page
+	if isNumeric(labelsPage) {
+		page, err = client.GetPage(labelsPage)
+		if err != nil {
+			log.Debug("failed to get page by ID: %v", err)
+			page = nil
+		}
+	}
+	if page == nil {
+		page, err = client.FindPageByTitle(effectiveSpace, labelsPage)
+		if err != nil {
+			return fmt.Errorf("failed to find page by title: %w", err)
+		}
+	}
+	if page == nil {
+		return fmt.Errorf("page '%s' not found in space '%s'", labelsPage, effectiveSpace)
+	}
+
+	ctx := cmd.Context()
+	labels, err := labeler.GetLabels(ctx, page.ID)
+	if err != nil {
+		return fmt.Errorf("get page labels: %w", err)
+	}
+	var added []string
+	for _, label := range labelsAdd {
+		if !slices.Contains(labels, label) && !slices.Contains(added, label) {
+			added = append(added, label)
+		}
+	}
+	if err := labeler.AddLabels(ctx, page.ID, added); err != nil {
+		return fmt.Errorf("add page labels: %w", err)
+	}
+	labels = append(labels, added...)
+	for _, label := range labelsRemove {
+		if !slices.Contains(labels, label) {
+			continue
+		}
+		if err := labeler.RemoveLabel(ctx, page.ID, label); err != nil {
+			return fmt.Errorf("remove page label: %w", err)
+		}
+		labels = slices.DeleteFunc(labels, func(existing string) bool { return existing == label })
+	}
+
+	out := cmd.OutOrStdout()
+	if len(labels) == 0 {
+		fmt.Fprintf(out, "Page '%s' (ID: %s) has no labels\n", page.Title, page.ID)
+		return nil
+	}
+	for _, label := range labels {
+		fmt.Fprintln(out, label)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(labelsCmd)
+
+	labelsCmd.Flags().StringVarP(&labelsSpace, "space", "s", "", "Confluence space key (can be inferred from --project)")
+	labelsCmd.Flags().StringVarP(&labelsPage, "page", "p", "", "Page title or ID (required)")
+	labelsCmd.Flags().StringVarP(&labelsProject, "project", "P", "", "Project name defined in config to infer space")
+	labelsCmd.Flags().StringSliceVar(&labelsAdd, "add", nil, "Comma-separated labels to add")
+	labelsCmd.Flags().StringSliceVar(&labelsRemove, "remove", nil, "Comma-separated labels to remove")
+}
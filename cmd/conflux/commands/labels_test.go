@@ -0,0 +1,133 @@
+package commands
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"conflux/internal/confluence"
+	"conflux/pkg/logger"
+)
+
+func resetLabelsFlags() {
+	labelsSpace = ""
+	labelsProject = ""
+	labelsPage = ""
+	labelsAdd = nil
+	labelsRemove = nil
+}
+
+func configureLabelsTest(t *testing.T, mock *confluence.MockClient) *bytes.Buffer {
+	t.Helper()
+	resetLabelsFlags()
+	configFile = writePagesTestConfig(t, pagesTestConfigYAML)
+	verbose = false
+	newConfluenceClient = func(baseURL, username, apiToken string, log *logger.Logger) confluence.ConfluenceClient { return mock }
+	var out bytes.Buffer
+	labelsCmd.SetOut(&out)
+	t.Cleanup(func() {
+		resetLabelsFlags()
+		labelsCmd.SetOut(nil)
+	})
+	return &out
+}
+
+func labelsTestMock() *confluence.MockClient {
+	mock := confluence.NewMockClient()
+	page := &confluence.Page{ID: "123", Title: "Runbook"}
+	mock.Pages[page.ID] = page
+	mock.PagesByTitle["DOCS:Runbook"] = page
+	mock.Labels[page.ID] = []string{"ops", "draft"}
+	return mock
+}
+
+func TestRunLabelsListsPageLabelsByTitle(t *testing.T) {
+	mock := labelsTestMock()
+	out := configureLabelsTest(t, mock)
+	labelsPage = "Runbook"
+
+	if err := runLabels(labelsCmd, nil); err != nil {
+		t.Fatalf("runLabels returned error: %v", err)
+	}
+	if out.String() != "ops\ndraft\n" {
+		t.Fatalf("output = %q", out.String())
+	}
+}
+
+func TestRunLabelsAddsAndRemovesLabelsByID(t *testing.T) {
+	mock := labelsTestMock()
+	out := configureLabelsTest(t, mock)
+	labelsPage = "123"
+	labelsAdd = []string{"runbook", "ops"}
+	labelsRemove = []string{"draft", "missing"}
+
+	if err := runLabels(labelsCmd, nil); err != nil {
+		t.Fatalf("runLabels returned error: %v", err)
+	}
+	if labels := mock.Labels["123"]; !slices.Equal(labels, []string{"ops", "runbook"}) {
+		t.Fatalf("labels = %v, want [ops runbook]", labels)
+	}
+	if out.String() != "ops\nrunbook\n" {
+		t.Fatalf("output = %q", out.String())
+	}
+}
+
+func TestRunLabelsReportsPageWithoutLabels(t *testing.T) {
+	mock := labelsTestMock()
+	out := configureLabelsTest(t, mock)
+	labelsPage = "123"
+	labelsRemove = []string{"ops", "draft"}
+
+	if err := runLabels(labelsCmd, nil); err != nil {
+		t.Fatalf("runLabels returned error: %v", err)
+	}
+	if out.String() != "Page 'Runbook' (ID: 123) has no labels\n" {
+		t.Fatalf("output = %q", out.String())
+	}
+}
+
+func TestRunLabelsRequiresLabelSupport(t *testing.T) {
+	mock := labelsTestMock()
+	configureLabelsTest(t, mock)
+	newConfluenceClient = func(baseURL, username, apiToken string, log *logger.Logger) confluence.ConfluenceClient {
+		return struct{ confluence.ConfluenceClient }{mock}
+	}
+	labelsPage = "Runbook"
+
+	err := runLabels(labelsCmd, nil)
+	if err == nil || !strings.Contains(err.Error(), "does not support page labels") {
+		t.Fatalf("error = %v", err)
+	}
+}
+
+func TestRunLabelsRejectsInvalidRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		page   string
+		add    []string
+		remove []string
+		want   string
+	}{
+		{name: "missing page flag", want: "page flag is required"},
+		{name: "invalid label", page: "123", add: []string{"release notes"}, want: "is invalid"},
+		{name: "uppercase label", page: "123", remove: []string{"Ops"}, want: "must be lowercase"},
+		{name: "added and removed", page: "123", add: []string{"ops"}, remove: []string{"ops"}, want: "both added and removed"},
+		{name: "unknown page", page: "Nowhere", want: "not found in space 'DOCS'"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mock := labelsTestMock()
+			configureLabelsTest(t, mock)
+			labelsPage, labelsAdd, labelsRemove = test.page, test.add, test.remove
+
+			err := runLabels(labelsCmd, nil)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("error = %v, want %q", err, test.want)
+			}
+			if labels := mock.Labels["123"]; !slices.Equal(labels, []string{"ops", "draft"}) {
+				t.Fatalf("labels changed to %v", labels)
+			}
+		})
+	}
+}
@@ -18,12 +18,13 @@ import (
 )
 
 var (
-	pullSpace     string
-	pullIDOrTitle string
-	pullFormat    string
-	pullProject   string
-	pullOutput    string
-	pullForce     bool
+	pullSpace          string
+	pullIDOrTitle      string
+	pullFormat         string
+	pullProject        string
+	pullOutput         string
+	pullForce          bool
+	pullRepresentation string
 )
 
 type attachmentDownloader interface {
@@ -57,6 +58,15 @@ func runPull(cmd *cobra.Command, args []string) error {
 	default:
 		return fmt.Errorf("unsupported format: %s", pullFormat)
 	}
+	switch pullRepresentation {
+	case "", "storage":
+	case "adf":
+		if pullOutput == "" {
+			return fmt.Errorf("--representation adf requires --output")
+		}
+	default:
+		return fmt.Errorf("unsupported representation: %s", pullRepresentation)
+	}
 
 	log := logger.New(verbose)
 
@@ -100,7 +110,7 @@ func runPull(cmd *cobra.Command, args []string) error {
 				return fmt.Errorf("page '%s' disappeared while preparing editable artifact", pullIDOrTitle)
 			}
 		}
-		return pullEditableArtifact(cmd.Context(), cmd.OutOrStdout(), client, page, effectiveSpace, pullOutput, pullForce)
+		return pullEditableArtifact(cmd.Context(), cmd.OutOrStdout(), client, page, effectiveSpace, pullOutput, pullRepresentation == "adf", pullForce)
 	}
 
 	// Print header then the requested format
@@ -228,9 +238,9 @@ func preprocessConfluenceMacros(htmlContent string) string {
 		return "\n> " + strings.ReplaceAll(content, "\n", "\n> ") + "\n"
 	})
 
-	// Handle Inline Comment Marker macro (remove it)
-	inlineCommentRe := regexp.MustCompile(`(?s)<ac:inline-comment-marker.*?>.*?</ac:inline-comment-marker>`)
-	htmlContent = inlineCommentRe.ReplaceAllString(htmlContent, "")
+	// Handle Inline Comment Marker macro (keep the anchored text)
+	inlineCommentRe := regexp.MustCompile(`(?s)<ac:inline-comment-marker.*?>(.*?)</ac:inline-comment-marker>`)
+	htmlContent = inlineCommentRe.ReplaceAllString(htmlContent, "$1")
 
 	// Handle View File macro
 	viewFileRe := regexp.MustCompile(`(?s)<ac:structured-macro ac:name="view-file".*?><ac:parameter ac:name="name"><ri:attachment ri:filename="(.*?)".*? /></ac:parameter></ac:structured-macro>`)
@@ -328,6 +338,7 @@ func init() {
 	pullCmd.Flags().StringVarP(&pullProject, "project", "P", "", "Project name defined in config to infer space")
 	pullCmd.Flags().StringVarP(&pullOutput, "output", "o", "", "Write an editable page artifact to this .md file")
 	pullCmd.Flags().BoolVar(&pullForce, "force", false, "Replace an existing output artifact")
+	pullCmd.Flags().StringVar(&pullRepresentation, "representation", "storage", "Page body pulled into --output: storage|adf")
 
 	if err := pullCmd.MarkFlagRequired("page"); err != nil {
 		panic(fmt.Sprintf("Failed to mark page flag as required: %v", err))
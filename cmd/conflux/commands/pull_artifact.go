@@ -13,7 +13,20 @@ import (
 	"conflux/internal/content"
 )
 
-func pullEditableArtifact(ctx context.Context, outputWriter io.Writer, client confluence.ConfluenceClient, page *confluence.Page, spaceKey, output string, force bool) error {
+// adfPageReader and adfPageWriter read and update page bodies in Atlas
+// Document Format.
+type adfPageReader interface {
+	GetPageADF(ctx context.Context, pageID string) (*confluence.Page, error)
+}
+
+type adfPageWriter interface {
+	UpdatePageADFAtVersion(pageID, title, document, parentID string, baseVersion int) (*confluence.Page, error)
+}
+
+// pullEditableArtifact writes a page as an editable artifact. With adf the
+// page body is read, and later pushed, as Atlas Document Format rather than
+// storage.
+func pullEditableArtifact(ctx context.Context, outputWriter io.Writer, client confluence.ConfluenceClient, page *confluence.Page, spaceKey, output string, adf, force bool) error {
 	paths, err := content.PathsFor(output)
 	if err != nil {
 		return fmt.Errorf("resolve output artifact: %w", err)
@@ -36,13 +49,55 @@ func pullEditableArtifact(ctx context.Context, outputWriter io.Writer, client co
 			ID: attachment.ID, Filename: attachment.Title, MediaType: attachment.MediaType,
 		})
 	}
-	artifact, err := content.RenderStorage(content.StoragePage{
-		ID: page.ID, SpaceKey: spaceKey, Title: page.Title, BaseVersion: page.Version.Number,
-		Storage: page.Body.Storage.Value, AttachmentDirectory: filepath.Base(paths.AttachmentsDir),
-		Attachments: attachmentMetadata,
-	})
+	var artifact content.EditableArtifact
+	if adf {
+		reader, ok := client.(adfPageReader)
+		if !ok {
+			return fmt.Errorf("confluence adapter does not support Atlas Document Format pages")
+		}
+		page, err = reader.GetPageADF(ctx, page.ID)
+		if err != nil {
+			return fmt.Errorf("get page in Atlas Document Format: %w", err)
+		}
+		document := page.Body.AtlasDocFormat.Value
+		users, err := mentionedUsers(ctx, client, content.ADFMentionedAccountIDs(document))
+		if err != nil {
+			return err
+		}
+		artifact, err = content.RenderADF(content.ADFPage{
+			ID: page.ID, SpaceKey: spaceKey, Title: page.Title, BaseVersion: page.Version.Number,
+			Document: document, Attachments: attachmentMetadata, Users: users,
+		})
+		if err != nil {
+			return fmt.Errorf("render editable artifact: %w", err)
+		}
+	} else {
+		users, err := mentionedUsers(ctx, client, content.MentionedAccountIDs(page.Body.Storage.Value))
+		if err != nil {
+			return err
+		}
+		artifact, err = content.RenderStorage(content.StoragePage{
+			ID: page.ID, SpaceKey: spaceKey, Title: page.Title, BaseVersion: page.Version.Number,
+			Storage: page.Body.Storage.Value, AttachmentDirectory: filepath.Base(paths.AttachmentsDir),
+			Attachments: attachmentMetadata, Users: users,
+		})
+		if err != nil {
+			return fmt.Errorf("render editable artifact: %w", err)
+		}
+	}
+
+	front, err := pageFrontMatter(ctx, client, page)
+	if err != nil {
+		return err
+	}
+	artifact.Metadata.Page.Labels = front.Labels
+	artifact.Metadata.Page.Width = front.Width
+	if front.Status != nil {
+		artifact.Metadata.Page.Status = *front.Status
+	}
+	markdown, err := content.WithFrontMatter(artifact.Markdown, front)
 	if err != nil {
-		return fmt.Errorf("render editable artifact: %w", err)
+		return fmt.Errorf("write front matter: %w", err)
 	}
 
 	if err := os.MkdirAll(paths.MarkdownDir, 0o755); err != nil {
@@ -70,7 +125,7 @@ func pullEditableArtifact(ctx context.Context, outputWriter io.Writer, client co
 			}
 		}
 	}
-	if err := os.WriteFile(stageMarkdown, []byte(artifact.Markdown), 0o600); err != nil {
+	if err := os.WriteFile(stageMarkdown, []byte(markdown), 0o600); err != nil {
 		return fmt.Errorf("write staged Markdown: %w", err)
 	}
 	if err := content.SaveMetadata(filepath.Join(stageAttachments, "metadata.json"), artifact.Metadata); err != nil {
@@ -83,6 +138,80 @@ func pullEditableArtifact(ctx context.Context, outputWriter io.Writer, client co
 	return nil
 }
 
+// pageFrontMatter collects the page options written to an artifact's front
+// matter. The parent is named by title unless the title reads as a page ID.
+// Adapters without status or width lookups leave those options out.
+func pageFrontMatter(ctx context.Context, client confluence.ConfluenceClient, page *confluence.Page) (content.FrontMatter, error) {
+	front := content.FrontMatter{Title: page.Title}
+	ancestors, err := client.GetPageAncestors(page.ID)
+	if err != nil {
+		return content.FrontMatter{}, fmt.Errorf("get page ancestors: %w", err)
+	}
+	if len(ancestors) > 0 {
+		parent := ancestors[len(ancestors)-1]
+		front.Parent = parent.Title
+		if parent.Title == "" || isNumeric(parent.Title) {
+			front.Parent = parent.ID
+		}
+	}
+
+	if labeler, ok := client.(labelClient); ok {
+		labels, err := labeler.GetLabels(ctx, page.ID)
+		if err != nil {
+			return content.FrontMatter{}, fmt.Errorf("get page labels: %w", err)
+		}
+		if len(labels) > 0 {
+			front.Labels = labels
+		}
+	}
+
+	options, ok := client.(pageOptionsClient)
+	if !ok {
+		return front, nil
+	}
+	status, err := options.GetPageStatus(ctx, page.ID)
+	if err != nil {
+		return content.FrontMatter{}, err
+	}
+	if status != "" {
+		front.Status = &status
+	}
+	width, err := options.GetPageWidth(ctx, page.ID)
+	if err != nil {
+		return content.FrontMatter{}, fmt.Errorf("get page width: %w", err)
+	}
+	if content.IsPageWidth(width) {
+		front.Width = width
+	}
+	return front, nil
+}
+
+type userResolver interface {
+	GetUser(ctx context.Context, accountID string) (*confluence.User, error)
+}
+
+// mentionedUsers looks up display names for the mentioned users. Adapters
+// without user lookup, and users that no longer exist, leave mentions
+// labelled with their account ID.
+func mentionedUsers(ctx context.Context, client confluence.ConfluenceClient, accountIDs []string) (map[string]string, error) {
+	resolver, ok := client.(userResolver)
+	if !ok {
+		return nil, nil
+	}
+	users := make(map[string]string)
+	for _, accountID := range accountIDs {
+		user, err := resolver.GetUser(ctx, accountID)
+		if confluence.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("look up mentioned user %q: %w", accountID, err)
+		}
+		users[accountID] = user.DisplayName
+	}
+	return users, nil
+}
+
 func downloadAttachment(ctx context.Context, downloader attachmentDownloader, pageID string, download content.AttachmentDownload, directory string) (string, error) {
 	body, err := downloader.DownloadAttachment(ctx, pageID, download.ID)
 	if err != nil {
@@ -9,6 +9,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
@@ -28,7 +29,7 @@ func TestPullEditableArtifactWritesPairedArtifact(t *testing.T) {
 	mock.AttachmentBodies["att-1"] = []byte("image bytes")
 
 	var stdout bytes.Buffer
-	err := pullEditableArtifact(context.Background(), &stdout, mock, page, "DOCS", output, false)
+	err := pullEditableArtifact(context.Background(), &stdout, mock, page, "DOCS", output, false, false)
 	if err != nil {
 		t.Fatalf("pullEditableArtifact returned error: %v", err)
 	}
@@ -59,6 +60,35 @@ func TestPullEditableArtifactWritesPairedArtifact(t *testing.T) {
 	}
 }
 
+func TestPullEditableArtifactReadsADFPage(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "runbook.md")
+	page := artifactPlainTestPage()
+	mock := confluence.NewMockClient()
+	mock.Users["557058:abc"] = confluence.User{AccountID: "557058:abc", DisplayName: "Ada Lovelace"}
+	adfPage := *page
+	adfPage.Version.Number = 9
+	adfPage.Body.AtlasDocFormat.Value = `{"version":1,"type":"doc","content":[{"type":"paragraph","content":[{"type":"text","text":"Ask "},{"type":"mention","attrs":{"id":"557058:abc"}}]}]}`
+	mock.Pages[page.ID] = &adfPage
+
+	if err := pullEditableArtifact(context.Background(), &bytes.Buffer{}, mock, page, "DOCS", output, true, false); err != nil {
+		t.Fatalf("pullEditableArtifact returned error: %v", err)
+	}
+	markdown, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("read Markdown: %v", err)
+	}
+	if !strings.Contains(string(markdown), "Ask @[Ada Lovelace](user:557058:abc)") {
+		t.Fatalf("Markdown = %s", markdown)
+	}
+	metadata, err := content.LoadArtifactMetadata(output)
+	if err != nil {
+		t.Fatalf("load metadata: %v", err)
+	}
+	if metadata.Page.Representation != content.RepresentationADF || metadata.Page.BaseVersion != 9 {
+		t.Fatalf("page metadata = %#v", metadata.Page)
+	}
+}
+
 func TestPullEditableArtifactRefusesOverwrite(t *testing.T) {
 	directory := t.TempDir()
 	output := filepath.Join(directory, "page.md")
@@ -68,7 +98,7 @@ func TestPullEditableArtifactRefusesOverwrite(t *testing.T) {
 	page := artifactTestPage()
 	mock := confluence.NewMockClient()
 
-	err := pullEditableArtifact(context.Background(), &bytes.Buffer{}, mock, page, "DOCS", output, false)
+	err := pullEditableArtifact(context.Background(), &bytes.Buffer{}, mock, page, "DOCS", output, false, false)
 	if err == nil || !strings.Contains(err.Error(), "--force") {
 		t.Fatalf("error = %v, want overwrite refusal", err)
 	}
@@ -84,7 +114,7 @@ func TestPullEditableArtifactDownloadFailureLeavesNoArtifact(t *testing.T) {
 	mock := confluence.NewMockClient()
 	mock.Attachments[page.ID] = []confluence.Attachment{attachment("att-1", "diagram.png", "image/png")}
 
-	err := pullEditableArtifact(context.Background(), &bytes.Buffer{}, mock, page, "DOCS", output, false)
+	err := pullEditableArtifact(context.Background(), &bytes.Buffer{}, mock, page, "DOCS", output, false, false)
 	if err == nil || !strings.Contains(err.Error(), "download attachment") {
 		t.Fatalf("error = %v, want download failure", err)
 	}
@@ -108,7 +138,7 @@ func TestPullEditableArtifactForceReplacesPair(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	err := pullEditableArtifact(context.Background(), &bytes.Buffer{}, confluence.NewMockClient(), artifactPlainTestPage(), "DOCS", output, true)
+	err := pullEditableArtifact(context.Background(), &bytes.Buffer{}, confluence.NewMockClient(), artifactPlainTestPage(), "DOCS", output, false, true)
 	if err != nil {
 		t.Fatalf("forced pull returned error: %v", err)
 	}
@@ -118,7 +148,7 @@ func TestPullEditableArtifactForceReplacesPair(t *testing.T) {
 }
 
 func TestPullEditableArtifactRejectsInvalidOutput(t *testing.T) {
-	err := pullEditableArtifact(context.Background(), &bytes.Buffer{}, confluence.NewMockClient(), artifactPlainTestPage(), "DOCS", "page.txt", false)
+	err := pullEditableArtifact(context.Background(), &bytes.Buffer{}, confluence.NewMockClient(), artifactPlainTestPage(), "DOCS", "page.txt", false, false)
 	if err == nil || !strings.Contains(err.Error(), "resolve output artifact") {
 		t.Fatalf("error = %v, want invalid output error", err)
 	}
@@ -126,7 +156,7 @@ func TestPullEditableArtifactRejectsInvalidOutput(t *testing.T) {
 
 func TestPullEditableArtifactRequiresDownloader(t *testing.T) {
 	client := &clientWithoutDownloader{ConfluenceClient: confluence.NewMockClient()}
-	err := pullEditableArtifact(context.Background(), &bytes.Buffer{}, client, artifactPlainTestPage(), "DOCS", filepath.Join(t.TempDir(), "page.md"), false)
+	err := pullEditableArtifact(context.Background(), &bytes.Buffer{}, client, artifactPlainTestPage(), "DOCS", filepath.Join(t.TempDir(), "page.md"), false, false)
 	if err == nil || !strings.Contains(err.Error(), "does not support attachment downloads") {
 		t.Fatalf("error = %v, want downloader error", err)
 	}
@@ -134,14 +164,14 @@ func TestPullEditableArtifactRequiresDownloader(t *testing.T) {
 
 func TestPullEditableArtifactReportsListFailure(t *testing.T) {
 	client := &failingAttachmentClient{ConfluenceClient: confluence.NewMockClient(), err: errors.New("list failed")}
-	err := pullEditableArtifact(context.Background(), &bytes.Buffer{}, client, artifactPlainTestPage(), "DOCS", filepath.Join(t.TempDir(), "page.md"), false)
+	err := pullEditableArtifact(context.Background(), &bytes.Buffer{}, client, artifactPlainTestPage(), "DOCS", filepath.Join(t.TempDir(), "page.md"), false, false)
 	if err == nil || !strings.Contains(err.Error(), "list page attachments") {
 		t.Fatalf("error = %v, want list error", err)
 	}
 }
 
 func TestPullEditableArtifactReportsRenderFailure(t *testing.T) {
-	err := pullEditableArtifact(context.Background(), &bytes.Buffer{}, confluence.NewMockClient(), artifactPlainTestPage(), "", filepath.Join(t.TempDir(), "page.md"), false)
+	err := pullEditableArtifact(context.Background(), &bytes.Buffer{}, confluence.NewMockClient(), artifactPlainTestPage(), "", filepath.Join(t.TempDir(), "page.md"), false, false)
 	if err == nil || !strings.Contains(err.Error(), "render editable artifact") {
 		t.Fatalf("error = %v, want render error", err)
 	}
@@ -264,3 +294,50 @@ func (d errorDownloader) DownloadAttachment(context.Context, string, string) (io
 type errorReader struct{}
 
 func (errorReader) Read([]byte) (int, error) { return 0, errors.New("read failed") }
+
+func TestPullEditableArtifactLabelsMentionsWithDisplayNames(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "page.md")
+	page := artifactPlainTestPage()
+	page.Body.Storage.Value = `<p>Owner: <ac:link><ri:user ri:account-id="557058:abc" /></ac:link>, <ac:link><ri:user ri:account-id="557058:gone" /></ac:link></p>`
+	mock := confluence.NewMockClient()
+	mock.Users["557058:abc"] = confluence.User{AccountID: "557058:abc", DisplayName: "Ana Lima"}
+
+	if err := pullEditableArtifact(context.Background(), &bytes.Buffer{}, mock, page, "DOCS", output, false, false); err != nil {
+		t.Fatalf("pullEditableArtifact returned error: %v", err)
+	}
+	markdown, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("read Markdown: %v", err)
+	}
+	want := "---\ntitle: Deployment\n---\n\nOwner: @[Ana Lima](user:557058:abc), @[557058:gone](user:557058:gone)\n"
+	if string(markdown) != want {
+		t.Fatalf("Markdown = %q, want %q", markdown, want)
+	}
+}
+
+func TestPullEditableArtifactWritesPageOptionsAsFrontMatter(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "page.md")
+	page := artifactPlainTestPage()
+	mock := &pageOptionsTestClient{pushTestClient: &pushTestClient{MockClient: confluence.NewMockClient()}, status: "In progress", width: "full-width"}
+	mock.Ancestors[page.ID] = []confluence.PageInfo{{ID: "1", Title: "Home"}, {ID: "42", Title: "Runbooks"}}
+	mock.Labels[page.ID] = []string{"ops", "runbook"}
+
+	if err := pullEditableArtifact(context.Background(), &bytes.Buffer{}, mock, page, "DOCS", output, false, false); err != nil {
+		t.Fatalf("pullEditableArtifact returned error: %v", err)
+	}
+	markdown, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("read Markdown: %v", err)
+	}
+	want := "---\ntitle: Deployment\nparent: Runbooks\nlabels: [ops, runbook]\nstatus: In progress\nwidth: full-width\n---\n\n"
+	if !strings.HasPrefix(string(markdown), want) {
+		t.Fatalf("Markdown = %q, want front matter %q", markdown, want)
+	}
+	metadata, err := content.LoadArtifactMetadata(output)
+	if err != nil {
+		t.Fatalf("load metadata: %v", err)
+	}
+	if !slices.Equal(metadata.Page.Labels, []string{"ops", "runbook"}) || metadata.Page.Status != "In progress" || metadata.Page.Width != "full-width" {
+		t.Fatalf("metadata page = %+v", metadata.Page)
+	}
+}
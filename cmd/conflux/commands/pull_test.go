@@ -45,6 +45,7 @@ func resetPullFlags() {
 	pullProject = ""
 	pullOutput = ""
 	pullForce = false
+	pullRepresentation = ""
 }
 
 // --- runPull tests ---
@@ -304,10 +305,10 @@ func TestPreprocessConfluenceMacros_InfoNoteToBlockquote(t *testing.T) {
 	}
 }
 
-func TestPreprocessConfluenceMacros_InlineCommentRemoval(t *testing.T) {
-	input := `<p>Text<ac:inline-comment-marker>comment</ac:inline-comment-marker>more</p>`
+func TestPreprocessConfluenceMacros_InlineCommentKeepsText(t *testing.T) {
+	input := `<p>Text <ac:inline-comment-marker ac:ref="abc">anchored</ac:inline-comment-marker> more</p>`
 	result := preprocessConfluenceMacros(input)
-	expected := `<p>Textmore</p>`
+	expected := `<p>Text anchored more</p>`
 	if result != expected {
 		t.Fatalf("got %q, want %q", result, expected)
 	}
@@ -1,11 +1,16 @@
 package commands
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"io"
 	"mime"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -42,8 +47,10 @@ Parent resolution:
   - Otherwise it is resolved as a title in the target space.
 
 If a matching .attachments/metadata.json exists, the recorded page is updated
-with optimistic version checks. Otherwise a page with the markdown title is
-updated or created.`,
+with optimistic version checks, along with any title, parent, labels, status,
+or width set in the Markdown's front matter. Otherwise a page with the markdown title is
+updated or created, and local files referenced by relative image and file links
+are uploaded to it as attachments.`,
 	RunE: runPush,
 }
 
@@ -88,7 +95,7 @@ func runPush(cmd *cobra.Command, args []string) error {
 
 	client := newConfluenceClient(runtime.Confluence.BaseURL, runtime.Confluence.Username, runtime.Confluence.APIToken, log)
 	if artifact {
-		return pushEditableArtifact(client, pushFile, metadata, pushForce)
+		return pushEditableArtifact(cmd.Context(), client, pushFile, metadata, pushForce)
 	}
 
 	// Parse markdown file
@@ -98,8 +105,12 @@ func runPush(cmd *cobra.Command, args []string) error {
 	}
 	log.Debug("Parsed markdown file: title=%s", doc.Title)
 
-	// Convert standalone markdown to Confluence storage format.
-	content := markdown.ConvertToConfluenceFormat(doc.Content)
+	// Convert standalone markdown to Confluence storage format, referencing
+	// local images and files as attachments uploaded once the page exists.
+	content, localFiles, err := markdown.ConvertWithLocalFiles(doc.Content, filepath.Dir(pushFile))
+	if err != nil {
+		return fmt.Errorf("failed to resolve local files: %w", err)
+	}
 
 	// Resolve parent ID if provided
 	var parentID string
@@ -148,9 +159,82 @@ func runPush(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Created page '%s' (ID: %s) in space '%s'\n", page.Title, page.ID, effectiveSpace)
 	}
 
+	return uploadLocalFiles(cmd.Context(), client, page.ID, localFiles, log)
+}
+
+type attachmentVersionUploader interface {
+	UploadAttachmentVersion(pageID, attachmentID, filePath string) (*confluence.Attachment, error)
+}
+
+// uploadLocalFiles attaches the files a standalone page references. A file
+// whose name the page already has an attachment for is skipped when the
+// contents match, and otherwise uploaded as a new version of it where the
+// adapter allows.
+func uploadLocalFiles(ctx context.Context, client confluence.ConfluenceClient, pageID string, files []markdown.LocalFile, log *logger.Logger) error {
+	if len(files) == 0 {
+		return nil
+	}
+	existing, err := client.ListAttachments(pageID)
+	if err != nil {
+		return fmt.Errorf("failed to list page attachments: %w", err)
+	}
+	versioner, canVersion := client.(attachmentVersionUploader)
+	downloader, canDownload := client.(attachmentDownloader)
+	uploaded := 0
+	for _, file := range files {
+		attachmentID := ""
+		for _, attachment := range existing {
+			if strings.EqualFold(attachment.Title, file.Filename) {
+				attachmentID = attachment.ID
+			}
+		}
+		if attachmentID != "" && canDownload {
+			same, err := sameAttachmentContent(ctx, downloader, pageID, attachmentID, file.Path)
+			if err != nil {
+				log.Debug("Could not compare attachment %s, uploading it: %v", file.Filename, err)
+			} else if same {
+				log.Debug("Attachment %s is unchanged", file.Filename)
+				continue
+			}
+		}
+		if attachmentID != "" && canVersion {
+			log.Debug("Uploading new version of attachment %s", file.Filename)
+			_, err = versioner.UploadAttachmentVersion(pageID, attachmentID, file.Path)
+		} else {
+			log.Debug("Uploading attachment %s", file.Filename)
+			_, err = client.UploadAttachment(pageID, file.Path)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to upload attachment %q: %w", file.Filename, err)
+		}
+		uploaded++
+	}
+	if uploaded > 0 {
+		fmt.Printf("Uploaded %d attachment(s) to page ID %s\n", uploaded, pageID)
+	}
 	return nil
 }
 
+// sameAttachmentContent reports whether a page attachment has the same
+// SHA-256 digest as a local file.
+func sameAttachmentContent(ctx context.Context, downloader attachmentDownloader, pageID, attachmentID, path string) (bool, error) {
+	local, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	body, err := downloader.DownloadAttachment(ctx, pageID, attachmentID)
+	if err != nil {
+		return false, err
+	}
+	defer body.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, body); err != nil {
+		return false, err
+	}
+	digest := sha256.Sum256(local)
+	return bytes.Equal(hash.Sum(nil), digest[:]), nil
+}
+
 func init() {
 	rootCmd.AddCommand(pushCmd)
 
@@ -178,13 +262,23 @@ func loadPushArtifact(markdownPath string) (artifactcontent.Metadata, bool, erro
 
 type artifactPushClient interface {
 	GetPage(pageID string) (*confluence.Page, error)
-	UpdatePageAtVersion(pageID, title, content string, baseVersion int) (*confluence.Page, error)
+	FindPageByTitle(spaceKey, title string) (*confluence.Page, error)
+	UpdatePageAtVersionWithParent(pageID, title, content, parentID string, baseVersion int) (*confluence.Page, error)
 	UploadAttachment(pageID, filePath string) (*confluence.Attachment, error)
 	UploadAttachmentVersion(pageID, attachmentID, filePath string) (*confluence.Attachment, error)
 	ListAttachments(pageID string) ([]confluence.Attachment, error)
 }
 
-func pushEditableArtifact(client confluence.ConfluenceClient, markdownPath string, metadata artifactcontent.Metadata, force bool) error {
+// pageOptionsClient reads and changes the page status and width that artifact
+// front matter may set.
+type pageOptionsClient interface {
+	GetPageStatus(ctx context.Context, pageID string) (string, error)
+	SetPageStatus(ctx context.Context, pageID, name string) error
+	GetPageWidth(ctx context.Context, pageID string) (string, error)
+	SetPageWidth(ctx context.Context, pageID, width string) error
+}
+
+func pushEditableArtifact(ctx context.Context, client confluence.ConfluenceClient, markdownPath string, metadata artifactcontent.Metadata, force bool) error {
 	pusher, ok := client.(artifactPushClient)
 	if !ok {
 		return fmt.Errorf("confluence adapter does not support safe artifact pushes")
@@ -205,6 +299,26 @@ func pushEditableArtifact(client confluence.ConfluenceClient, markdownPath strin
 	if err != nil {
 		return fmt.Errorf("render editable artifact: %w", err)
 	}
+	for _, warning := range rendered.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	labeler, ok := client.(labelClient)
+	if !ok && rendered.Labels != nil {
+		return fmt.Errorf("confluence adapter does not support page labels")
+	}
+	options, ok := client.(pageOptionsClient)
+	if !ok && (rendered.Status != nil || rendered.Width != "") {
+		return fmt.Errorf("confluence adapter does not support page status or width")
+	}
+	update := pusher.UpdatePageAtVersionWithParent
+	body := rendered.Storage
+	if rendered.ADF != "" {
+		writer, ok := client.(adfPageWriter)
+		if !ok {
+			return fmt.Errorf("confluence adapter does not support Atlas Document Format pages")
+		}
+		update, body = writer.UpdatePageADFAtVersion, rendered.ADF
+	}
 
 	remote, err := pusher.GetPage(rendered.PageID)
 	if err != nil {
@@ -225,7 +339,11 @@ func pushEditableArtifact(client confluence.ConfluenceClient, markdownPath strin
 	if err != nil {
 		return err
 	}
-	page, err := pusher.UpdatePageAtVersion(rendered.PageID, rendered.Title, rendered.Storage, updateBase)
+	parentID, err := resolveArtifactParent(pusher, rendered)
+	if err != nil {
+		return err
+	}
+	page, err := update(rendered.PageID, rendered.Title, body, parentID, updateBase)
 	if err != nil {
 		return fmt.Errorf("update page at version %d: %w", updateBase, err)
 	}
@@ -235,6 +353,7 @@ func pushEditableArtifact(client confluence.ConfluenceClient, markdownPath strin
 
 	updatedMetadata := metadata
 	updatedMetadata.Page.BaseVersion = page.Version.Number
+	updatedMetadata.Page.Title = rendered.Title
 	for _, upload := range rendered.Uploads {
 		path := attachmentPaths[strings.ToLower(upload.Filename)]
 		attachmentID := remoteAttachmentIDs[strings.ToLower(upload.Filename)]
@@ -253,6 +372,12 @@ func pushEditableArtifact(client confluence.ConfluenceClient, markdownPath strin
 		}
 		mergeAttachmentMetadata(&updatedMetadata, upload, attachment)
 	}
+	if err := applyPageOptions(ctx, client, labeler, options, rendered, &updatedMetadata); err != nil {
+		if saveErr := artifactcontent.SaveArtifactMetadata(markdownPath, updatedMetadata); saveErr != nil {
+			return fmt.Errorf("%v; preserve updated page version in metadata: %w", err, saveErr)
+		}
+		return err
+	}
 	if err := artifactcontent.SaveArtifactMetadata(markdownPath, updatedMetadata); err != nil {
 		return fmt.Errorf("save updated artifact metadata: %w", err)
 	}
@@ -260,6 +385,112 @@ func pushEditableArtifact(client confluence.ConfluenceClient, markdownPath strin
 	return nil
 }
 
+// resolveArtifactParent returns the ID of the parent named in front matter, or
+// an empty ID when the artifact leaves the parent unchanged. As with --parent,
+// a numeric parent is a page ID and anything else a title in the page's space.
+func resolveArtifactParent(client artifactPushClient, rendered artifactcontent.PushArtifact) (string, error) {
+	if rendered.Parent == "" {
+		return "", nil
+	}
+	parentID := rendered.Parent
+	if !isNumeric(parentID) {
+		parent, err := client.FindPageByTitle(rendered.SpaceKey, rendered.Parent)
+		if err != nil {
+			return "", fmt.Errorf("resolve parent page %q: %w", rendered.Parent, err)
+		}
+		if parent == nil {
+			return "", fmt.Errorf("parent page %q not found in space %q", rendered.Parent, rendered.SpaceKey)
+		}
+		parentID = parent.ID
+	}
+	if parentID == rendered.PageID {
+		return "", fmt.Errorf("page %s cannot be its own parent", rendered.PageID)
+	}
+	return parentID, nil
+}
+
+// applyPageOptions brings the page's labels, status, and width in line with
+// the artifact's front matter, leaving options it does not set untouched. As
+// with labels, a status or width is only sent when it differs from the value
+// recorded at the pull, so a change made in Confluence since is kept.
+// Confluence publishes a status change as a new page version, which is
+// recorded in metadata so the next push is not mistaken for a conflict. A
+// page that moved further than that was also edited by someone else, so its
+// base version is kept and the next push reports the conflict.
+func applyPageOptions(ctx context.Context, client confluence.ConfluenceClient, labeler labelClient, options pageOptionsClient, rendered artifactcontent.PushArtifact, metadata *artifactcontent.Metadata) error {
+	if rendered.Labels != nil {
+		labels, err := applyLabelChanges(ctx, labeler, rendered.PageID, metadata.Page.Labels, rendered.Labels)
+		if err != nil {
+			return fmt.Errorf("update page labels: %w", err)
+		}
+		metadata.Page.Labels = labels
+	}
+	if rendered.Status != nil && !strings.EqualFold(*rendered.Status, metadata.Page.Status) {
+		current, err := options.GetPageStatus(ctx, rendered.PageID)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(current, *rendered.Status) {
+			if err := options.SetPageStatus(ctx, rendered.PageID, *rendered.Status); err != nil {
+				return err
+			}
+			page, err := client.GetPage(rendered.PageID)
+			if err != nil {
+				return fmt.Errorf("get page version after status change: %w", err)
+			}
+			if page != nil && page.Version.Number == metadata.Page.BaseVersion+1 {
+				metadata.Page.BaseVersion = page.Version.Number
+			} else {
+				fmt.Fprintf(os.Stderr, "Warning: page %s changed by someone else while its status was updated; the next push will report a conflict\n", rendered.PageID)
+			}
+		}
+		metadata.Page.Status = *rendered.Status
+	}
+	if rendered.Width != "" && rendered.Width != metadata.Page.Width {
+		current, err := options.GetPageWidth(ctx, rendered.PageID)
+		if err != nil {
+			return fmt.Errorf("get page width: %w", err)
+		}
+		if current != rendered.Width {
+			if err := options.SetPageWidth(ctx, rendered.PageID, rendered.Width); err != nil {
+				return fmt.Errorf("update page width: %w", err)
+			}
+		}
+		metadata.Page.Width = rendered.Width
+	}
+	return nil
+}
+
+// applyLabelChanges adds the wanted labels that the page did not have when it
+// was pulled and removes the pulled labels that are no longer wanted, keeping
+// labels changed in Confluence since. It returns the page's resulting labels.
+func applyLabelChanges(ctx context.Context, client labelClient, pageID string, pulled, wanted []string) ([]string, error) {
+	current, err := client.GetLabels(ctx, pageID)
+	if err != nil {
+		return nil, err
+	}
+	var added []string
+	for _, label := range wanted {
+		if !slices.Contains(pulled, label) && !slices.Contains(current, label) {
+			added = append(added, label)
+		}
+	}
+	if err := client.AddLabels(ctx, pageID, added); err != nil {
+		return nil, err
+	}
+	labels := append(current, added...)
+	for _, label := range pulled {
+		if slices.Contains(wanted, label) || !slices.Contains(labels, label) {
+			continue
+		}
+		if err := client.RemoveLabel(ctx, pageID, label); err != nil {
+			return nil, err
+		}
+		labels = slices.DeleteFunc(labels, func(existing string) bool { return existing == label })
+	}
+	return labels, nil
+}
+
 func resolveRemoteAttachmentIDs(client artifactPushClient, pageID string, metadata artifactcontent.Metadata, uploads []artifactcontent.AttachmentUpload) (map[string]string, error) {
 	ids := make(map[string]string, len(uploads))
 	needsRemoteLookup := false
@@ -1,11 +1,13 @@
 package commands
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
@@ -17,6 +19,7 @@ import (
 type pushTestClient struct {
 	*confluence.MockClient
 	expectedVersions []int
+	parentIDs        []string
 	lastAttachmentID string
 	failGet          bool
 	failUpdate       bool
@@ -39,8 +42,9 @@ func (m *pushTestClient) GetPage(pageID string) (*confluence.Page, error) {
 	return m.MockClient.GetPage(pageID)
 }
 
-func (m *pushTestClient) UpdatePageAtVersion(pageID, title, body string, baseVersion int) (*confluence.Page, error) {
+func (m *pushTestClient) UpdatePageAtVersionWithParent(pageID, title, body, parentID string, baseVersion int) (*confluence.Page, error) {
 	m.expectedVersions = append(m.expectedVersions, baseVersion)
+	m.parentIDs = append(m.parentIDs, parentID)
 	if m.failUpdate {
 		return nil, fmt.Errorf("configured update failure")
 	}
@@ -74,6 +78,36 @@ func (m *pushTestClient) UploadAttachmentVersion(pageID, attachmentID, filePath
 	return &confluence.Attachment{ID: attachmentID, Title: filepath.Base(filePath)}, nil
 }
 
+// pageOptionsTestClient adds the status and width operations used by artifact
+// front matter.
+type pageOptionsTestClient struct {
+	*pushTestClient
+	status string
+	width  string
+	// otherEdits is the number of versions someone else publishes while the
+	// status changes.
+	otherEdits int
+}
+
+func (m *pageOptionsTestClient) GetPageStatus(context.Context, string) (string, error) {
+	return m.status, nil
+}
+
+func (m *pageOptionsTestClient) SetPageStatus(_ context.Context, pageID, name string) error {
+	m.status = name
+	m.Pages[pageID].Version.Number += 1 + m.otherEdits
+	return nil
+}
+
+func (m *pageOptionsTestClient) GetPageWidth(context.Context, string) (string, error) {
+	return m.width, nil
+}
+
+func (m *pageOptionsTestClient) SetPageWidth(_ context.Context, _ string, width string) error {
+	m.width = width
+	return nil
+}
+
 const pushTestConfigYAML = `confluence:
   base_url: http://example
   username: u
@@ -437,6 +471,234 @@ func TestPushEditableArtifactPreservesMetadataOnRemoteFailures(t *testing.T) {
 	}
 }
 
+func TestPushEditableArtifactAppliesFrontMatter(t *testing.T) {
+	file, metadata := writePushArtifact(t, "---\ntitle: Renamed\nparent: Runbooks\nlabels: [ops, runbook]\nstatus: Verified\nwidth: full-width\n---\n\nBody.\n", nil)
+	metadata.Page.Labels = []string{"legacy", "ops"}
+	if err := content.SaveArtifactMetadata(file, metadata); err != nil {
+		t.Fatal(err)
+	}
+	mock := &pageOptionsTestClient{pushTestClient: artifactPushMock(metadata)}
+	mock.Labels[metadata.Page.ID] = []string{"legacy", "ops"}
+	mock.PagesByTitle["DOCS:Runbooks"] = &confluence.Page{ID: "42", Title: "Runbooks"}
+	configurePushTest(t, file, mock)
+
+	if err := runPush(pushCmd, nil); err != nil {
+		t.Fatalf("runPush returned error: %v", err)
+	}
+	if len(mock.UpdateCalls) != 1 || mock.UpdateCalls[0] != "Renamed" || mock.parentIDs[0] != "42" {
+		t.Fatalf("updates = %v parents = %v, want Renamed under 42", mock.UpdateCalls, mock.parentIDs)
+	}
+	if mock.Pages[metadata.Page.ID].Body.Storage.Value != "<p>Body.</p>" {
+		t.Fatalf("storage = %q", mock.Pages[metadata.Page.ID].Body.Storage.Value)
+	}
+	if labels := mock.Labels[metadata.Page.ID]; !slices.Equal(labels, []string{"ops", "runbook"}) || mock.status != "Verified" || mock.width != "full-width" {
+		t.Fatalf("labels = %v status = %q width = %q", labels, mock.status, mock.width)
+	}
+	updated, err := content.LoadArtifactMetadata(file)
+	if err != nil {
+		t.Fatalf("load updated metadata: %v", err)
+	}
+	if updated.Page.Title != "Renamed" || updated.Page.BaseVersion != 9 || !slices.Equal(updated.Page.Labels, []string{"ops", "runbook"}) || updated.Page.Status != "Verified" || updated.Page.Width != "full-width" {
+		t.Fatalf("metadata page = %+v, want Renamed at version 9", updated.Page)
+	}
+}
+
+func TestPushEditableArtifactLeavesOmittedOptionsAlone(t *testing.T) {
+	file, metadata := writePushArtifact(t, "---\ntitle: Page\n---\n\nBody.\n", nil)
+	mock := &pageOptionsTestClient{pushTestClient: artifactPushMock(metadata), status: "In progress", width: "fixed"}
+	mock.Labels[metadata.Page.ID] = []string{"ops"}
+	configurePushTest(t, file, mock)
+
+	if err := runPush(pushCmd, nil); err != nil {
+		t.Fatalf("runPush returned error: %v", err)
+	}
+	if labels := mock.Labels[metadata.Page.ID]; mock.parentIDs[0] != "" || !slices.Equal(labels, []string{"ops"}) || mock.status != "In progress" || mock.width != "fixed" {
+		t.Fatalf("parent = %q labels = %v status = %q width = %q", mock.parentIDs[0], labels, mock.status, mock.width)
+	}
+}
+
+func TestPushEditableArtifactKeepsOptionsChangedRemotelySincePull(t *testing.T) {
+	file, metadata := writePushArtifact(t, "---\nstatus: In progress\nwidth: fixed\n---\n\nBody.\n", nil)
+	metadata.Page.Status = "In progress"
+	metadata.Page.Width = "fixed"
+	if err := content.SaveArtifactMetadata(file, metadata); err != nil {
+		t.Fatal(err)
+	}
+	mock := &pageOptionsTestClient{pushTestClient: artifactPushMock(metadata), status: "Verified", width: "full-width"}
+	configurePushTest(t, file, mock)
+
+	if err := runPush(pushCmd, nil); err != nil {
+		t.Fatalf("runPush returned error: %v", err)
+	}
+	if mock.status != "Verified" || mock.width != "full-width" {
+		t.Fatalf("status = %q width = %q, want the remote changes kept", mock.status, mock.width)
+	}
+}
+
+func TestPushEditableArtifactKeepsBaseVersionWhenStatusChangeRaces(t *testing.T) {
+	file, metadata := writePushArtifact(t, "---\nstatus: Verified\n---\n\nBody.\n", nil)
+	mock := &pageOptionsTestClient{pushTestClient: artifactPushMock(metadata), otherEdits: 1}
+	configurePushTest(t, file, mock)
+
+	if err := runPush(pushCmd, nil); err != nil {
+		t.Fatalf("runPush returned error: %v", err)
+	}
+	if version := mock.Pages[metadata.Page.ID].Version.Number; version != 10 {
+		t.Fatalf("page version = %d, want 10", version)
+	}
+	updated, err := content.LoadArtifactMetadata(file)
+	if err != nil {
+		t.Fatalf("load updated metadata: %v", err)
+	}
+	if updated.Page.BaseVersion != 8 {
+		t.Fatalf("base version = %d, want 8 from the body update", updated.Page.BaseVersion)
+	}
+}
+
+func TestPushEditableArtifactKeepsLabelsChangedRemotelySincePull(t *testing.T) {
+	file, metadata := writePushArtifact(t, "---\nlabels: [ops, draft]\n---\n\nBody.\n", nil)
+	metadata.Page.Labels = []string{"ops", "stale", "retired"}
+	if err := content.SaveArtifactMetadata(file, metadata); err != nil {
+		t.Fatal(err)
+	}
+	mock := artifactPushMock(metadata)
+	// Since the pull, someone added "urgent" and removed "retired".
+	mock.Labels[metadata.Page.ID] = []string{"ops", "stale", "urgent"}
+	configurePushTest(t, file, mock)
+
+	if err := runPush(pushCmd, nil); err != nil {
+		t.Fatalf("runPush returned error: %v", err)
+	}
+	want := []string{"ops", "urgent", "draft"}
+	if labels := mock.Labels[metadata.Page.ID]; !slices.Equal(labels, want) {
+		t.Fatalf("labels = %v, want %v", labels, want)
+	}
+	updated, _ := content.LoadArtifactMetadata(file)
+	if !slices.Equal(updated.Page.Labels, want) {
+		t.Fatalf("metadata labels = %v, want %v", updated.Page.Labels, want)
+	}
+}
+
+func TestPushEditableArtifactRejectsFrontMatterBeforeUpdate(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{
+			name:     "unsupported adapter",
+			markdown: "---\nstatus: Verified\n---\n\nBody.\n",
+			want:     "does not support page status",
+		},
+		{
+			name:     "missing parent",
+			markdown: "---\nparent: Nowhere\n---\n\nBody.\n",
+			want:     `parent page "Nowhere" not found`,
+		},
+		{
+			name:     "unknown field",
+			markdown: "---\nowner: ana\n---\n\nBody.\n",
+			want:     "field owner not found",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			file, metadata := writePushArtifact(t, test.markdown, nil)
+			mock := artifactPushMock(metadata)
+			configurePushTest(t, file, mock)
+
+			err := runPush(pushCmd, nil)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("error = %v, want %q", err, test.want)
+			}
+			if len(mock.UpdateCalls) != 0 {
+				t.Fatal("page was updated despite invalid front matter")
+			}
+		})
+	}
+}
+
+// unlabelledPushClient is a push test client for an adapter without page
+// label operations.
+type unlabelledPushClient struct {
+	confluence.ConfluenceClient
+	pusher *pushTestClient
+}
+
+func (c unlabelledPushClient) UpdatePageAtVersionWithParent(pageID, title, content, parentID string, baseVersion int) (*confluence.Page, error) {
+	return c.pusher.UpdatePageAtVersionWithParent(pageID, title, content, parentID, baseVersion)
+}
+
+func (c unlabelledPushClient) UploadAttachmentVersion(pageID, attachmentID, filePath string) (*confluence.Attachment, error) {
+	return c.pusher.UploadAttachmentVersion(pageID, attachmentID, filePath)
+}
+
+func TestPushEditableArtifactRejectsLabelsWithoutLabelSupport(t *testing.T) {
+	file, metadata := writePushArtifact(t, "---\nlabels: [ops]\n---\n\nBody.\n", nil)
+	mock := artifactPushMock(metadata)
+	configurePushTest(t, file, unlabelledPushClient{ConfluenceClient: mock, pusher: mock})
+
+	err := runPush(pushCmd, nil)
+	if err == nil || !strings.Contains(err.Error(), "does not support page labels") {
+		t.Fatalf("error = %v", err)
+	}
+	if len(mock.UpdateCalls) != 0 {
+		t.Fatal("page was updated despite unsupported labels")
+	}
+}
+
+type adfPushTestClient struct {
+	*pushTestClient
+	documents []string
+}
+
+func (m *adfPushTestClient) UpdatePageADFAtVersion(pageID, title, document, parentID string, baseVersion int) (*confluence.Page, error) {
+	m.documents = append(m.documents, document)
+	return m.UpdatePageAtVersionWithParent(pageID, title, "", parentID, baseVersion)
+}
+
+func TestPushEditableArtifactSendsADFPage(t *testing.T) {
+	file, metadata := writePushArtifact(t, "# Runbook\n", nil)
+	metadata.Page.Representation = content.RepresentationADF
+	if err := content.SaveArtifactMetadata(file, metadata); err != nil {
+		t.Fatal(err)
+	}
+	mock := &adfPushTestClient{pushTestClient: artifactPushMock(metadata)}
+	configurePushTest(t, file, mock)
+
+	if err := runPush(pushCmd, nil); err != nil {
+		t.Fatalf("runPush returned error: %v", err)
+	}
+	want := `{"version":1,"type":"doc","content":[{"type":"heading","attrs":{"level":1},"content":[{"type":"text","text":"Runbook"}]}]}`
+	if len(mock.documents) != 1 || mock.documents[0] != want {
+		t.Fatalf("documents = %v", mock.documents)
+	}
+	updated, err := content.LoadArtifactMetadata(file)
+	if err != nil {
+		t.Fatalf("load updated metadata: %v", err)
+	}
+	if updated.Page.BaseVersion != 8 || updated.Page.Representation != content.RepresentationADF {
+		t.Fatalf("page metadata = %#v", updated.Page)
+	}
+}
+
+func TestPushEditableArtifactRequiresADFAdapter(t *testing.T) {
+	file, metadata := writePushArtifact(t, "Body.\n", nil)
+	metadata.Page.Representation = content.RepresentationADF
+	if err := content.SaveArtifactMetadata(file, metadata); err != nil {
+		t.Fatal(err)
+	}
+	mock := artifactPushMock(metadata)
+	configurePushTest(t, file, mock)
+
+	err := runPush(pushCmd, nil)
+	if err == nil || !strings.Contains(err.Error(), "does not support Atlas Document Format") {
+		t.Fatalf("error = %v", err)
+	}
+	if len(mock.expectedVersions) != 0 {
+		t.Fatalf("page was updated: %v", mock.expectedVersions)
+	}
+}
+
 func TestReadLocalAttachmentsRejectsNonRegularEntry(t *testing.T) {
 	directory := t.TempDir()
 	if err := os.Mkdir(filepath.Join(directory, "nested"), 0o700); err != nil {
@@ -479,6 +741,7 @@ func configurePushTest(t *testing.T, file string, mock confluence.ConfluenceClie
 	configFile = writePushTempConfig(t)
 	verbose = false
 	pushFile = file
+	pushCmd.SetContext(t.Context())
 	newConfluenceClient = func(baseURL, username, apiToken string, log *logger.Logger) confluence.ConfluenceClient { return mock }
 }
 
@@ -579,6 +842,110 @@ func TestPushUpdatesExistingPage(t *testing.T) {
 	}
 }
 
+func TestPushUploadsLocalImagesForNewPage(t *testing.T) {
+	dir := t.TempDir()
+	image := filepath.Join(dir, "img", "diagram.png")
+	if err := os.MkdirAll(filepath.Dir(image), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(image, []byte("png"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "release.md")
+	markdown := "# Release\n\n![diagram](./img/diagram.png)\n\n![badge](https://ci.example.com/badge.svg)\n"
+	if err := os.WriteFile(file, []byte(markdown), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mock := confluence.NewMockClient()
+	configurePushTest(t, file, mock)
+	pushSpace = "DOCS"
+
+	if err := runPush(pushCmd, nil); err != nil {
+		t.Fatalf("runPush returned error: %v", err)
+	}
+	page := mock.PagesByTitle["DOCS:Release"]
+	if page == nil {
+		t.Fatal("page was not created")
+	}
+	for _, expected := range []string{`<ri:attachment ri:filename="diagram.png"/>`, `<ri:url ri:value="https://ci.example.com/badge.svg"/>`} {
+		if !strings.Contains(page.Body.Storage.Value, expected) {
+			t.Fatalf("storage does not contain %q: %s", expected, page.Body.Storage.Value)
+		}
+	}
+	if attachments := mock.Attachments[page.ID]; len(attachments) != 1 || attachments[0].Title != image {
+		t.Fatalf("attachments = %#v", attachments)
+	}
+}
+
+func TestPushVersionsExistingAttachmentOfStandalonePage(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "diagram.png"), []byte("png"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "page.md")
+	if err := os.WriteFile(file, []byte("# Existing Page\n\n![diagram](diagram.png)\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mock := &pushTestClient{MockClient: confluence.NewMockClient()}
+	page, _ := mock.CreatePage("DOCS", "Existing Page", "old content")
+	mock.Attachments[page.ID] = []confluence.Attachment{{ID: "att-9", Title: "diagram.png"}}
+	configurePushTest(t, file, mock)
+	pushSpace = "DOCS"
+
+	if err := runPush(pushCmd, nil); err != nil {
+		t.Fatalf("runPush returned error: %v", err)
+	}
+	if mock.lastAttachmentID != "att-9" || len(mock.Attachments[page.ID]) != 1 {
+		t.Fatalf("attachment ID = %q, attachments = %#v", mock.lastAttachmentID, mock.Attachments[page.ID])
+	}
+}
+
+func TestPushSkipsUnchangedAttachmentOfStandalonePage(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "diagram.png"), []byte("png"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("new notes"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "page.md")
+	if err := os.WriteFile(file, []byte("# Existing Page\n\n[notes](notes.txt) ![diagram](diagram.png)\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mock := &pushTestClient{MockClient: confluence.NewMockClient()}
+	page, _ := mock.CreatePage("DOCS", "Existing Page", "old content")
+	mock.Attachments[page.ID] = []confluence.Attachment{{ID: "att-8", Title: "diagram.png"}, {ID: "att-9", Title: "notes.txt"}}
+	mock.AttachmentBodies["att-8"] = []byte("png")
+	mock.AttachmentBodies["att-9"] = []byte("old notes")
+	configurePushTest(t, file, mock)
+	pushSpace = "DOCS"
+
+	if err := runPush(pushCmd, nil); err != nil {
+		t.Fatalf("runPush returned error: %v", err)
+	}
+	if mock.lastAttachmentID != "att-9" || mock.LastUploadedFile != filepath.Join(dir, "notes.txt") {
+		t.Fatalf("attachment ID = %q, uploaded file = %q, want only notes.txt versioned", mock.lastAttachmentID, mock.LastUploadedFile)
+	}
+}
+
+func TestPushRejectsMissingLocalImageBeforeCreatingPage(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "page.md")
+	if err := os.WriteFile(file, []byte("# New Page\n\n![diagram](img/missing.png)\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mock := confluence.NewMockClient()
+	configurePushTest(t, file, mock)
+	pushSpace = "DOCS"
+
+	err := runPush(pushCmd, nil)
+	if err == nil || !strings.Contains(err.Error(), `local image "img/missing.png"`) {
+		t.Fatalf("error = %v", err)
+	}
+	if len(mock.CreateCalls) != 0 {
+		t.Fatalf("pages created: %v", mock.CreateCalls)
+	}
+}
+
 func TestPushParentResolutionNumeric(t *testing.T) {
 	mock := confluence.NewMockClient()
 
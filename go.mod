@@ -5,11 +5,15 @@ go 1.24.4
 require (
 	github.com/JohannesKaufmann/html-to-markdown/v2 v2.4.0
 	github.com/spf13/cobra v1.10.1
+	github.com/yuin/goldmark v1.7.13
 	golang.org/x/text v0.29.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
-require github.com/AlecAivazis/survey/v2 v2.3.7
+require (
+	github.com/AlecAivazis/survey/v2 v2.3.7
+	golang.org/x/net v0.43.0
+)
 
 require (
 	github.com/JohannesKaufmann/dom v0.2.0 // indirect
@@ -19,7 +23,6 @@ require (
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/mgutz/ansi v0.0.0-20170206155736-9520e82c474b // indirect
 	github.com/spf13/pflag v1.0.10 // indirect
-	golang.org/x/net v0.43.0 // indirect
 	golang.org/x/sys v0.35.0 // indirect
 	golang.org/x/term v0.34.0 // indirect
 )
@@ -0,0 +1,23 @@
+package confluence
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// GetPageADF returns a page with its body in Atlas Document Format, the
+// representation the Confluence editor saves, in Body.AtlasDocFormat.
+func (c *Client) GetPageADF(ctx context.Context, pageID string) (*Page, error) {
+	var page Page
+	if err := c.doJSON(ctx, http.MethodGet, "/rest/api/content/"+pageID+"?expand=version,space,body.atlas_doc_format", nil, &page); err != nil {
+		return nil, fmt.Errorf("get page in ADF: %w", err)
+	}
+	return &page, nil
+}
+
+// UpdatePageADFAtVersion is UpdatePageAtVersionWithParent for a body given as
+// an ADF document.
+func (c *Client) UpdatePageADFAtVersion(pageID, title, document, parentID string, baseVersion int) (*Page, error) {
+	return c.updatePageAtVersion(pageID, title, "atlas_doc_format", document, parentID, baseVersion)
+}
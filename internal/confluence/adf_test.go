@@ -0,0 +1,59 @@
+package confluence
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetPageADFRequestsADFBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/content/123" || r.URL.Query().Get("expand") != "version,space,body.atlas_doc_format" {
+			t.Fatalf("unexpected request: %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"id":"123","title":"Runbook","version":{"number":4},"space":{"key":"DOCS"},
+			"body":{"atlas_doc_format":{"value":"{\"version\":1,\"type\":\"doc\",\"content\":[]}","representation":"atlas_doc_format"}}}`)
+	}))
+	defer server.Close()
+
+	client := NewWithHTTPClient(server.URL, "user", "token", nil, server.Client())
+	page, err := client.GetPageADF(context.Background(), "123")
+	if err != nil {
+		t.Fatalf("GetPageADF returned error: %v", err)
+	}
+	if page.Version.Number != 4 || page.Space.Key != "DOCS" || page.Body.AtlasDocFormat.Value != `{"version":1,"type":"doc","content":[]}` {
+		t.Fatalf("page = %#v", page)
+	}
+}
+
+func TestUpdatePageADFAtVersionSendsADFBody(t *testing.T) {
+	client, mockTransport := createTestClient()
+	updatedPage := Page{ID: "123456", Title: "Runbook"}
+	updatedPage.Version.Number = 8
+	mockTransport.addResponse("PUT", "/wiki/rest/api/content/123456", http.StatusOK, updatedPage)
+
+	document := `{"version":1,"type":"doc","content":[]}`
+	if _, err := client.UpdatePageADFAtVersion("123456", "Runbook", document, "", 7); err != nil {
+		t.Fatalf("UpdatePageADFAtVersion returned error: %v", err)
+	}
+	var payload struct {
+		Body map[string]struct {
+			Value          string `json:"value"`
+			Representation string `json:"representation"`
+		} `json:"body"`
+		Version struct {
+			Number int `json:"number"`
+		} `json:"version"`
+	}
+	if err := json.NewDecoder(mockTransport.getLastRequest().Body).Decode(&payload); err != nil {
+		t.Fatalf("decode update request: %v", err)
+	}
+	body, ok := payload.Body["atlas_doc_format"]
+	if !ok || len(payload.Body) != 1 || body.Value != document || body.Representation != "atlas_doc_format" || payload.Version.Number != 8 {
+		t.Fatalf("payload = %#v", payload)
+	}
+}
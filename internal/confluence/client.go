@@ -55,6 +55,9 @@ type Page struct {
 		View struct {
 			Value string `json:"value"`
 		} `json:"view"`
+		AtlasDocFormat struct {
+			Value string `json:"value"`
+		} `json:"atlas_doc_format"`
 	} `json:"body,omitempty"`
 	Space struct {
 		Key string `json:"key"`
@@ -225,6 +228,19 @@ func (c *Client) UpdatePage(pageID, title, content string) (*Page, error) {
 // Confluence API rejects the PUT if another writer has advanced the page in the
 // meantime, closing the race between conflict detection and the update.
 func (c *Client) UpdatePageAtVersion(pageID, title, content string, baseVersion int) (*Page, error) {
+	return c.UpdatePageAtVersionWithParent(pageID, title, content, "", baseVersion)
+}
+
+// UpdatePageAtVersionWithParent is UpdatePageAtVersion that also moves the page
+// under parentID in the same guarded update. An empty parentID leaves the page
+// where it is.
+func (c *Client) UpdatePageAtVersionWithParent(pageID, title, content, parentID string, baseVersion int) (*Page, error) {
+	return c.updatePageAtVersion(pageID, title, "storage", content, parentID, baseVersion)
+}
+
+// updatePageAtVersion replaces the page body, given in the named
+// representation, as a guarded update from baseVersion.
+func (c *Client) updatePageAtVersion(pageID, title, representation, content, parentID string, baseVersion int) (*Page, error) {
 	if baseVersion < 1 {
 		return nil, fmt.Errorf("base version must be positive")
 	}
@@ -236,15 +252,20 @@ func (c *Client) UpdatePageAtVersion(pageID, title, content string, baseVersion
 		"type":  "page",
 		"title": title,
 		"body": map[string]interface{}{
-			"storage": map[string]interface{}{
+			representation: map[string]interface{}{
 				"value":          content,
-				"representation": "storage",
+				"representation": representation,
 			},
 		},
 		"version": map[string]interface{}{
 			"number": newVersion,
 		},
 	}
+	if parentID != "" {
+		page["ancestors"] = []map[string]string{
+			{"id": parentID},
+		}
+	}
 
 	data, err := json.Marshal(page)
 	if err != nil {
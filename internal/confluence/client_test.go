@@ -340,6 +340,28 @@ func TestUpdatePageAtVersionUsesExpectedVersionWithoutRefetch(t *testing.T) {
 	}
 }
 
+func TestUpdatePageAtVersionWithParentSendsAncestor(t *testing.T) {
+	client, mockTransport := createTestClient()
+	updatedPage := Page{ID: "123456", Title: "Moved Page"}
+	updatedPage.Version.Number = 8
+	mockTransport.addResponse("PUT", "/wiki/rest/api/content/123456", http.StatusOK, updatedPage)
+
+	if _, err := client.UpdatePageAtVersionWithParent("123456", "Moved Page", "<p>body</p>", "42", 7); err != nil {
+		t.Fatalf("UpdatePageAtVersionWithParent returned error: %v", err)
+	}
+	var payload struct {
+		Ancestors []struct {
+			ID string `json:"id"`
+		} `json:"ancestors"`
+	}
+	if err := json.NewDecoder(mockTransport.getLastRequest().Body).Decode(&payload); err != nil {
+		t.Fatalf("decode update request: %v", err)
+	}
+	if len(payload.Ancestors) != 1 || payload.Ancestors[0].ID != "42" {
+		t.Fatalf("ancestors = %#v, want parent 42", payload.Ancestors)
+	}
+}
+
 func TestUpdatePageAtVersionRejectsInvalidBaseVersion(t *testing.T) {
 	client, mockTransport := createTestClient()
 	page, err := client.UpdatePageAtVersion("123456", "Page", "<p>body</p>", 0)
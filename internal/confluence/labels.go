@@ -0,0 +1,99 @@
+package confluence
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const globalLabelPrefix = "global"
+
+type Label struct {
+	ID     string `json:"id,omitempty"`
+	Prefix string `json:"prefix"`
+	Name   string `json:"name"`
+}
+
+// GetLabels returns the names of a page's global labels, the labels users add
+// and search by. Labels with other prefixes, such as personal labels, are
+// skipped.
+func (c *Client) GetLabels(ctx context.Context, pageID string) ([]string, error) {
+	params := url.Values{}
+	params.Set("prefix", globalLabelPrefix)
+	nextURL := c.baseURL + "/rest/api/content/" + url.PathEscape(pageID) + "/label?" + params.Encode()
+	var labels []string
+	for nextURL != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, nextURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("create label list request: %w", err)
+		}
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := c.doAuthenticated(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := responseError(resp)
+			_ = resp.Body.Close()
+			return nil, err
+		}
+
+		var result struct {
+			Results []Label `json:"results"`
+			Links   struct {
+				Next string `json:"next"`
+			} `json:"_links"`
+		}
+		decodeErr := json.NewDecoder(resp.Body).Decode(&result)
+		closeErr := resp.Body.Close()
+		if decodeErr != nil {
+			return nil, fmt.Errorf("decode label list response: %w", decodeErr)
+		}
+		if closeErr != nil {
+			return nil, fmt.Errorf("close label list response: %w", closeErr)
+		}
+		for _, label := range result.Results {
+			if label.Prefix == globalLabelPrefix {
+				labels = append(labels, label.Name)
+			}
+		}
+		nextURL = ""
+		if result.Links.Next != "" {
+			nextURL, err = c.resolveURL(result.Links.Next)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return labels, nil
+}
+
+// AddLabels adds global labels to a page. Labels the page already has are left
+// as they are.
+func (c *Client) AddLabels(ctx context.Context, pageID string, labels []string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+	body := make([]Label, 0, len(labels))
+	for _, name := range labels {
+		body = append(body, Label{Prefix: globalLabelPrefix, Name: name})
+	}
+	if err := c.doJSON(ctx, http.MethodPost, "/rest/api/content/"+url.PathEscape(pageID)+"/label", body, nil); err != nil {
+		return fmt.Errorf("add labels: %w", err)
+	}
+	return nil
+}
+
+// RemoveLabel removes a global label from a page. The label name is sent as a
+// query parameter so names containing a slash are removed correctly.
+func (c *Client) RemoveLabel(ctx context.Context, pageID, label string) error {
+	params := url.Values{}
+	params.Set("name", label)
+	if err := c.doJSON(ctx, http.MethodDelete, "/rest/api/content/"+url.PathEscape(pageID)+"/label?"+params.Encode(), nil, nil); err != nil {
+		return fmt.Errorf("remove label %q: %w", label, err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package confluence
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestGetLabelsFollowsPagesAndSkipsOtherPrefixes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/content/123/label" || r.URL.Query().Get("prefix") != "global" {
+			t.Fatalf("unexpected request: %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("start") == "" {
+			_, _ = io.WriteString(w, `{"results":[{"prefix":"global","name":"runbook"},{"prefix":"my","name":"favourite"}],"_links":{"next":"/rest/api/content/123/label?prefix=global&start=2"}}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"results":[{"prefix":"global","name":"ops"}],"_links":{}}`)
+	}))
+	defer server.Close()
+
+	client := NewWithHTTPClient(server.URL, "user", "token", nil, server.Client())
+	labels, err := client.GetLabels(context.Background(), "123")
+	if err != nil {
+		t.Fatalf("GetLabels returned error: %v", err)
+	}
+	if want := []string{"runbook", "ops"}; !reflect.DeepEqual(labels, want) {
+		t.Fatalf("labels = %v, want %v", labels, want)
+	}
+}
+
+func TestAddLabelsPostsGlobalLabels(t *testing.T) {
+	var posted []Label
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/rest/api/content/123/label" {
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&posted); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"results":[]}`)
+	}))
+	defer server.Close()
+
+	client := NewWithHTTPClient(server.URL, "user", "token", nil, server.Client())
+	if err := client.AddLabels(context.Background(), "123", []string{"runbook", "ops"}); err != nil {
+		t.Fatalf("AddLabels returned error: %v", err)
+	}
+	want := []Label{{Prefix: "global", Name: "runbook"}, {Prefix: "global", Name: "ops"}}
+	if !reflect.DeepEqual(posted, want) {
+		t.Fatalf("posted = %#v, want %#v", posted, want)
+	}
+}
+
+func TestRemoveLabelSendsNameAsQuery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.URL.Path != "/rest/api/content/123/label" || r.URL.Query().Get("name") != "team/ops" {
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := NewWithHTTPClient(server.URL, "user", "token", nil, server.Client())
+	if err := client.RemoveLabel(context.Background(), "123", "team/ops"); err != nil {
+		t.Fatalf("RemoveLabel returned error: %v", err)
+	}
+}
+
+func TestRemoveLabelReturnsAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"No content"}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewWithHTTPClient(server.URL, "user", "token", nil, server.Client())
+	if err := client.RemoveLabel(context.Background(), "123", "ops"); !IsNotFound(err) {
+		t.Fatalf("error = %v, want not found", err)
+	}
+}
+
+func TestAddLabelsSkipsEmptyList(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatalf("unexpected request: %s %s", r.Method, r.URL)
+	}))
+	defer server.Close()
+
+	client := NewWithHTTPClient(server.URL, "user", "token", nil, server.Client())
+	if err := client.AddLabels(context.Background(), "123", nil); err != nil {
+		t.Fatalf("AddLabels returned error: %v", err)
+	}
+}
+
+func TestGetLabelsReturnsAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"No content with id 123"}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewWithHTTPClient(server.URL, "user", "token", nil, server.Client())
+	if _, err := client.GetLabels(context.Background(), "123"); !IsNotFound(err) {
+		t.Fatalf("error = %v, want not found", err)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
+	"slices"
 )
 
 // MockClient is an in-memory implementation of ConfluenceClient for tests.
@@ -16,6 +18,8 @@ type MockClient struct {
 	SpaceHierarchies map[string][]PageInfo   // spaceKey -> root pages (fully nested)
 	Attachments      map[string][]Attachment // pageID -> attachments
 	AttachmentBodies map[string][]byte       // attachmentID -> downloaded body
+	Users            map[string]User         // accountID -> user
+	Labels           map[string][]string     // pageID -> global labels
 	CreateCalls      []string                // titles created (for assertions)
 	UpdateCalls      []string                // titles updated
 	LastUploadedFile string
@@ -31,6 +35,8 @@ func NewMockClient() *MockClient {
 		SpaceHierarchies: make(map[string][]PageInfo),
 		Attachments:      make(map[string][]Attachment),
 		AttachmentBodies: make(map[string][]byte),
+		Users:            make(map[string]User),
+		Labels:           make(map[string][]string),
 	}
 }
 
@@ -124,4 +130,41 @@ func (m *MockClient) DownloadAttachment(ctx context.Context, pageID, attachmentI
 	return io.NopCloser(bytes.NewReader(body)), nil
 }
 
+func (m *MockClient) GetUser(ctx context.Context, accountID string) (*User, error) {
+	user, ok := m.Users[accountID]
+	if !ok {
+		return nil, &APIError{StatusCode: http.StatusNotFound, Method: http.MethodGet, URL: "/rest/api/user?accountId=" + accountID}
+	}
+	return &user, nil
+}
+
+func (m *MockClient) GetLabels(ctx context.Context, pageID string) ([]string, error) {
+	return append([]string(nil), m.Labels[pageID]...), nil
+}
+
+func (m *MockClient) AddLabels(ctx context.Context, pageID string, labels []string) error {
+	for _, label := range labels {
+		if !slices.Contains(m.Labels[pageID], label) {
+			m.Labels[pageID] = append(m.Labels[pageID], label)
+		}
+	}
+	return nil
+}
+
+func (m *MockClient) RemoveLabel(ctx context.Context, pageID, label string) error {
+	if !slices.Contains(m.Labels[pageID], label) {
+		return &APIError{StatusCode: http.StatusNotFound, Method: http.MethodDelete, URL: "/rest/api/content/" + pageID + "/label?name=" + label}
+	}
+	m.Labels[pageID] = slices.DeleteFunc(m.Labels[pageID], func(existing string) bool { return existing == label })
+	return nil
+}
+
 var _ ConfluenceClient = (*MockClient)(nil)
+
+func (m *MockClient) GetPageADF(ctx context.Context, pageID string) (*Page, error) {
+	page, ok := m.Pages[pageID]
+	if !ok {
+		return nil, &APIError{StatusCode: http.StatusNotFound, Method: http.MethodGet, URL: "/rest/api/content/" + pageID}
+	}
+	return page, nil
+}
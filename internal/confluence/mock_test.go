@@ -1,6 +1,10 @@
 package confluence
 
-import "testing"
+import (
+	"context"
+	"reflect"
+	"testing"
+)
 
 func TestMockClientUpdatePageReturnsErrorForUnknownPage(t *testing.T) {
 	mock := NewMockClient()
@@ -9,3 +13,20 @@ func TestMockClientUpdatePageReturnsErrorForUnknownPage(t *testing.T) {
 		t.Fatalf("page=%v error=%v, want not-found error", page, err)
 	}
 }
+
+func TestMockClientLabels(t *testing.T) {
+	mock := NewMockClient()
+	ctx := context.Background()
+	if err := mock.AddLabels(ctx, "1", []string{"ops", "runbook", "ops"}); err != nil {
+		t.Fatalf("AddLabels returned error: %v", err)
+	}
+	if err := mock.RemoveLabel(ctx, "1", "ops"); err != nil {
+		t.Fatalf("RemoveLabel returned error: %v", err)
+	}
+	if err := mock.RemoveLabel(ctx, "1", "ops"); !IsNotFound(err) {
+		t.Fatalf("second RemoveLabel error = %v, want not found", err)
+	}
+	if labels, _ := mock.GetLabels(ctx, "1"); !reflect.DeepEqual(labels, []string{"runbook"}) {
+		t.Fatalf("labels = %v, want [runbook]", labels)
+	}
+}
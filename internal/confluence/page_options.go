@@ -0,0 +1,173 @@
+package confluence
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Confluence keeps the page width in a pair of content properties, one read by
+// the editor and one by the published page.
+var pageWidthProperties = []string{"content-appearance-draft", "content-appearance-published"}
+
+type ContentState struct {
+	ID    int64  `json:"id,omitempty"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+type contentProperty struct {
+	Key     string          `json:"key"`
+	Value   json.RawMessage `json:"value"`
+	Version struct {
+		Number int `json:"number"`
+	} `json:"version"`
+}
+
+// GetPageStatus returns the name of the published page's status, such as
+// "In progress", or an empty string when the page has none.
+func (c *Client) GetPageStatus(ctx context.Context, pageID string) (string, error) {
+	var result struct {
+		ContentState *ContentState `json:"contentState"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, c.pageStatePath(pageID), nil, &result); err != nil {
+		return "", fmt.Errorf("get page status: %w", err)
+	}
+	if result.ContentState == nil {
+		return "", nil
+	}
+	return result.ContentState.Name, nil
+}
+
+// SetPageStatus sets the page status to one of the statuses available in the
+// page's space, matched by name. An empty name clears the status. Confluence
+// records a status change as a new page version.
+func (c *Client) SetPageStatus(ctx context.Context, pageID, name string) error {
+	if name == "" {
+		if err := c.doJSON(ctx, http.MethodDelete, c.pageStatePath(pageID), nil, nil); err != nil {
+			return fmt.Errorf("clear page status: %w", err)
+		}
+		return nil
+	}
+
+	var available struct {
+		SpaceContentStates  []ContentState `json:"spaceContentStates"`
+		CustomContentStates []ContentState `json:"customContentStates"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, "/rest/api/content/"+url.PathEscape(pageID)+"/state/available", nil, &available); err != nil {
+		return fmt.Errorf("list available page statuses: %w", err)
+	}
+	var state *ContentState
+	for _, candidate := range append(available.SpaceContentStates, available.CustomContentStates...) {
+		if strings.EqualFold(candidate.Name, name) {
+			state = &candidate
+			break
+		}
+	}
+	if state == nil {
+		return fmt.Errorf("page status %q is not available in the page's space", name)
+	}
+	input := ContentState{Name: state.Name, Color: state.Color}
+	if err := c.doJSON(ctx, http.MethodPut, c.pageStatePath(pageID), input, nil); err != nil {
+		return fmt.Errorf("set page status: %w", err)
+	}
+	return nil
+}
+
+// GetPageWidth returns the published page width, such as "full-width", or an
+// empty string when the page uses the space default.
+func (c *Client) GetPageWidth(ctx context.Context, pageID string) (string, error) {
+	property, err := c.getContentProperty(ctx, pageID, pageWidthProperties[1])
+	if err != nil || property == nil {
+		return "", err
+	}
+	var width string
+	if err := json.Unmarshal(property.Value, &width); err != nil {
+		return "", fmt.Errorf("decode page width: %w", err)
+	}
+	return width, nil
+}
+
+// SetPageWidth sets the width of both the published page and its editor draft.
+func (c *Client) SetPageWidth(ctx context.Context, pageID, width string) error {
+	value, err := json.Marshal(width)
+	if err != nil {
+		return fmt.Errorf("encode page width: %w", err)
+	}
+	for _, key := range pageWidthProperties {
+		property, err := c.getContentProperty(ctx, pageID, key)
+		if err != nil {
+			return err
+		}
+		update := contentProperty{Key: key, Value: value}
+		path := "/rest/api/content/" + url.PathEscape(pageID) + "/property"
+		method := http.MethodPost
+		if property != nil {
+			update.Version.Number = property.Version.Number + 1
+			path += "/" + url.PathEscape(key)
+			method = http.MethodPut
+		}
+		if err := c.doJSON(ctx, method, path, update, nil); err != nil {
+			return fmt.Errorf("set content property %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) getContentProperty(ctx context.Context, pageID, key string) (*contentProperty, error) {
+	var property contentProperty
+	err := c.doJSON(ctx, http.MethodGet, "/rest/api/content/"+url.PathEscape(pageID)+"/property/"+url.PathEscape(key), nil, &property)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get content property %q: %w", key, err)
+	}
+	return &property, nil
+}
+
+func (c *Client) pageStatePath(pageID string) string {
+	return "/rest/api/content/" + url.PathEscape(pageID) + "/state?status=current"
+}
+
+// doJSON sends body, when present, as JSON to a path under the base URL and
+// decodes a successful response into result, when present.
+func (c *Client) doJSON(ctx context.Context, method, path string, body, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.doAuthenticated(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return responseError(resp)
+	}
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
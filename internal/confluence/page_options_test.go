@@ -0,0 +1,125 @@
+package confluence
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetPageStatusReadsCurrentState(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/content/123/state" || r.URL.Query().Get("status") != "current" {
+			t.Fatalf("unexpected request: %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"contentState":{"id":7,"name":"In progress","color":"#2684ff"}}`)
+	}))
+	defer server.Close()
+
+	client := NewWithHTTPClient(server.URL, "user", "token", nil, server.Client())
+	status, err := client.GetPageStatus(context.Background(), "123")
+	if err != nil || status != "In progress" {
+		t.Fatalf("status = %q, error = %v", status, err)
+	}
+}
+
+func TestSetPageStatusUsesAvailableStateByName(t *testing.T) {
+	var put ContentState
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/rest/api/content/123/state/available":
+			_, _ = io.WriteString(w, `{"spaceContentStates":[{"id":1,"name":"Rough draft","color":"#ffc400"}],"customContentStates":[{"id":9,"name":"Verified","color":"#57d9a3"}]}`)
+		case r.Method == http.MethodPut && r.URL.Path == "/rest/api/content/123/state" && r.URL.Query().Get("status") == "current":
+			if err := json.NewDecoder(r.Body).Decode(&put); err != nil {
+				t.Fatalf("decode request: %v", err)
+			}
+			_, _ = io.WriteString(w, `{"contentState":{"id":9,"name":"Verified","color":"#57d9a3"}}`)
+		default:
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL)
+		}
+	}))
+	defer server.Close()
+
+	client := NewWithHTTPClient(server.URL, "user", "token", nil, server.Client())
+	if err := client.SetPageStatus(context.Background(), "123", "verified"); err != nil {
+		t.Fatalf("SetPageStatus returned error: %v", err)
+	}
+	if put.Name != "Verified" || put.Color != "#57d9a3" {
+		t.Fatalf("state = %#v", put)
+	}
+	err := client.SetPageStatus(context.Background(), "123", "Shipped")
+	if err == nil || !strings.Contains(err.Error(), "not available") {
+		t.Fatalf("error = %v, want unavailable status", err)
+	}
+}
+
+func TestSetPageStatusClearsEmptyStatus(t *testing.T) {
+	deleted := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.URL.Path != "/rest/api/content/123/state" {
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL)
+		}
+		deleted = true
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewWithHTTPClient(server.URL, "user", "token", nil, server.Client())
+	if err := client.SetPageStatus(context.Background(), "123", ""); err != nil || !deleted {
+		t.Fatalf("deleted = %v, error = %v", deleted, err)
+	}
+}
+
+func TestPageWidthProperties(t *testing.T) {
+	writes := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method + " " + r.URL.Path {
+		case "GET /rest/api/content/123/property/content-appearance-published":
+			_, _ = io.WriteString(w, `{"key":"content-appearance-published","value":"full-width","version":{"number":3}}`)
+		case "GET /rest/api/content/123/property/content-appearance-draft":
+			http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+		case "POST /rest/api/content/123/property", "PUT /rest/api/content/123/property/content-appearance-published":
+			var property contentProperty
+			if err := json.NewDecoder(r.Body).Decode(&property); err != nil {
+				t.Fatalf("decode request: %v", err)
+			}
+			writes[r.Method+" "+property.Key] = fmt.Sprintf("%s@%d", property.Value, property.Version.Number)
+			_, _ = io.WriteString(w, `{}`)
+		default:
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL)
+		}
+	}))
+	defer server.Close()
+
+	client := NewWithHTTPClient(server.URL, "user", "token", nil, server.Client())
+	width, err := client.GetPageWidth(context.Background(), "123")
+	if err != nil || width != "full-width" {
+		t.Fatalf("width = %q, error = %v", width, err)
+	}
+	if err := client.SetPageWidth(context.Background(), "123", "default"); err != nil {
+		t.Fatalf("SetPageWidth returned error: %v", err)
+	}
+	if writes["POST content-appearance-draft"] != `"default"@0` || writes["PUT content-appearance-published"] != `"default"@4` {
+		t.Fatalf("writes = %v", writes)
+	}
+}
+
+func TestGetPageWidthReportsUndecodableValue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"key":"content-appearance-published","value":{"width":"full-width"},"version":{"number":3}}`)
+	}))
+	defer server.Close()
+
+	client := NewWithHTTPClient(server.URL, "user", "token", nil, server.Client())
+	if _, err := client.GetPageWidth(context.Background(), "123"); err == nil || !strings.Contains(err.Error(), "decode page width") {
+		t.Fatalf("error = %v, want decode error", err)
+	}
+}
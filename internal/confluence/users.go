@@ -0,0 +1,41 @@
+package confluence
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+type User struct {
+	AccountID   string `json:"accountId"`
+	DisplayName string `json:"displayName"`
+	PublicName  string `json:"publicName"`
+}
+
+// GetUser looks up a user by Atlassian account ID, as referenced by user
+// mentions in page storage.
+func (c *Client) GetUser(ctx context.Context, accountID string) (*User, error) {
+	params := url.Values{}
+	params.Set("accountId", accountID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/rest/api/user?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create user request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.doAuthenticated(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+	var user User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, fmt.Errorf("decode user response: %w", err)
+	}
+	return &user, nil
+}
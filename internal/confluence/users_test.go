@@ -0,0 +1,41 @@
+package confluence
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetUserLooksUpAccountID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/user" || r.URL.Query().Get("accountId") != "557058:abc" {
+			t.Fatalf("unexpected request: %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"accountId":"557058:abc","displayName":"Ana Lima","publicName":"ana"}`)
+	}))
+	defer server.Close()
+
+	client := NewWithHTTPClient(server.URL, "user", "token", nil, server.Client())
+	user, err := client.GetUser(context.Background(), "557058:abc")
+	if err != nil {
+		t.Fatalf("GetUser returned error: %v", err)
+	}
+	if user.AccountID != "557058:abc" || user.DisplayName != "Ana Lima" {
+		t.Fatalf("user = %#v", user)
+	}
+}
+
+func TestGetUserReturnsNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"No user found"}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewWithHTTPClient(server.URL, "user", "token", nil, server.Client())
+	if _, err := client.GetUser(context.Background(), "missing"); !IsNotFound(err) {
+		t.Fatalf("error = %v, want not found", err)
+	}
+}
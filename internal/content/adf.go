@@ -0,0 +1,736 @@
+package content
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// adfNode is a node of an Atlas Document Format document. Nodes decoded from
+// a page keep their JSON, so that nodes without a Markdown form are preserved
+// exactly; a node holding JSON is encoded as that JSON.
+type adfNode struct {
+	Type    string         `json:"type"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+	Content []adfNode      `json:"content,omitempty"`
+	Marks   []adfMark      `json:"marks,omitempty"`
+	Text    string         `json:"text,omitempty"`
+
+	raw json.RawMessage
+}
+
+type adfMark struct {
+	Type  string         `json:"type"`
+	Attrs map[string]any `json:"attrs,omitempty"`
+}
+
+// adfDocument is the root of a document, the only node with a version.
+type adfDocument struct {
+	Version int       `json:"version"`
+	Type    string    `json:"type"`
+	Content []adfNode `json:"content"`
+}
+
+func (n *adfNode) UnmarshalJSON(data []byte) error {
+	type plain adfNode
+	var node plain
+	if err := json.Unmarshal(data, &node); err != nil {
+		return err
+	}
+	*n = adfNode(node)
+	n.raw = bytes.Clone(data)
+	return nil
+}
+
+func (n adfNode) MarshalJSON() ([]byte, error) {
+	if n.raw != nil {
+		return n.raw, nil
+	}
+	type plain adfNode
+	return json.Marshal(plain(n))
+}
+
+func (n adfNode) stringAttr(key string) string {
+	value, _ := n.Attrs[key].(string)
+	return value
+}
+
+// intAttr returns a whole-number attribute, reporting whether it is set.
+func (n adfNode) intAttr(key string) (int, bool, error) {
+	value, exists := n.Attrs[key]
+	if !exists || value == nil {
+		return 0, false, nil
+	}
+	number, ok := value.(float64)
+	if !ok || number != float64(int(number)) {
+		return 0, true, fmt.Errorf("attribute %q is not a whole number", key)
+	}
+	return int(number), true, nil
+}
+
+// hasOnlyAttrs reports whether a node sets no attributes other than keys and
+// its local ID, which Confluence assigns and regenerates.
+func (n adfNode) hasOnlyAttrs(keys ...string) bool {
+	for key, value := range n.Attrs {
+		if key != "localId" && value != nil && !slices.Contains(keys, key) {
+			return false
+		}
+	}
+	return true
+}
+
+// isADFFragment reports whether a preserved fragment holds one ADF node.
+func isADFFragment(fragment string) bool {
+	var node adfNode
+	return json.Unmarshal([]byte(fragment), &node) == nil && node.Type != ""
+}
+
+// ADFPage is a page whose body is an Atlas Document Format document, as the
+// Confluence REST API returns it for expand=body.atlas_doc_format.
+type ADFPage struct {
+	ID          string
+	SpaceKey    string
+	Title       string
+	BaseVersion int
+	// Document is the page body as ADF JSON.
+	Document    string
+	Attachments []AttachmentMetadata
+	// Users maps the account IDs of mentioned users to display names, as for
+	// StoragePage.
+	Users map[string]string
+}
+
+// adfPanelKinds maps ADF panel types to the alert labels of the storage
+// panel macros that Confluence converts them to and from, so an alert looks
+// the same whichever representation a page uses.
+var adfPanelKinds = map[string]string{
+	"info":    "INFO",
+	"warning": "NOTE",
+	"success": "TIP",
+	"error":   "WARNING",
+}
+
+// adfStatusColours maps ADF status colours to those written in
+// {status:colour|title}.
+var adfStatusColours = map[string]string{
+	"neutral": "grey", "red": "red", "yellow": "yellow", "green": "green", "blue": "blue", "purple": "purple",
+}
+
+// RenderADF renders an Atlas Document Format page as an editable artifact.
+// Nodes without a Markdown form are kept in metadata as JSON fragments, and
+// the metadata records that the page is pushed back as ADF.
+func RenderADF(page ADFPage) (EditableArtifact, error) {
+	if err := validatePage("ADF", page.ID, page.SpaceKey, page.Title, page.BaseVersion); err != nil {
+		return EditableArtifact{}, err
+	}
+	var document adfNode
+	if err := json.Unmarshal([]byte(page.Document), &document); err != nil {
+		return EditableArtifact{}, fmt.Errorf("decode ADF document: %w", err)
+	}
+	if document.Type != "doc" {
+		return EditableArtifact{}, fmt.Errorf("ADF document has type %q; expected doc", document.Type)
+	}
+
+	metadata := Metadata{
+		SchemaVersion: SchemaVersion,
+		Page: PageMetadata{
+			ID:             page.ID,
+			SpaceKey:       page.SpaceKey,
+			Title:          page.Title,
+			BaseVersion:    page.BaseVersion,
+			Representation: RepresentationADF,
+		},
+		PreservedFragments: make(map[string]string),
+		Attachments:        append([]AttachmentMetadata(nil), page.Attachments...),
+	}
+	renderer := adfRenderer{&storageRenderer{metadata: &metadata, users: page.Users}}
+	markdown, err := renderer.blocks(document.Content)
+	if err != nil {
+		return EditableArtifact{}, err
+	}
+	if markdown != "" {
+		markdown += "\n"
+	}
+	if _, err := ValidateArtifact(markdown, &metadata); err != nil {
+		return EditableArtifact{}, fmt.Errorf("validate rendered artifact: %w", err)
+	}
+
+	// Uploaded media stay preserved, but the files they show are downloaded so
+	// that a changed copy is uploaded on push, as for storage pages.
+	attachments := make(map[string]AttachmentMetadata, len(page.Attachments))
+	for _, attachment := range page.Attachments {
+		attachments[attachment.Filename] = attachment
+	}
+	var downloads []AttachmentDownload
+	for _, id := range slices.Sorted(maps.Keys(metadata.PreservedFragments)) {
+		for _, filename := range adfMediaFilenames(metadata.PreservedFragments[id]) {
+			if attachment, exists := attachments[filename]; exists {
+				downloads = appendDownload(downloads, attachment)
+			}
+		}
+	}
+	return EditableArtifact{Markdown: markdown, Metadata: metadata, Downloads: downloads}, nil
+}
+
+// adfMediaFilenames returns the attachment filenames that the media nodes of
+// a preserved fragment name. Confluence records the filename of an uploaded
+// file in the __fileName attribute.
+func adfMediaFilenames(fragment string) []string {
+	var root adfNode
+	if json.Unmarshal([]byte(fragment), &root) != nil {
+		return nil
+	}
+	var filenames []string
+	var walk func(node adfNode)
+	walk = func(node adfNode) {
+		if filename := node.stringAttr("__fileName"); node.Type == "media" && node.stringAttr("type") == "file" && filename != "" {
+			filenames = append(filenames, filename)
+		}
+		for _, child := range node.Content {
+			walk(child)
+		}
+	}
+	walk(root)
+	return filenames
+}
+
+// ADFMentionedAccountIDs returns the account IDs of users mentioned in an ADF
+// document, so their display names can be looked up before rendering.
+func ADFMentionedAccountIDs(document string) []string {
+	var root adfNode
+	if json.Unmarshal([]byte(document), &root) != nil {
+		return nil
+	}
+	var accountIDs []string
+	var walk func(node adfNode)
+	walk = func(node adfNode) {
+		if node.Type == "mention" && node.stringAttr("id") != "" {
+			accountIDs = append(accountIDs, node.stringAttr("id"))
+		}
+		for _, child := range node.Content {
+			walk(child)
+		}
+	}
+	walk(root)
+	return deduplicateStrings(accountIDs)
+}
+
+// adfRenderer renders ADF nodes with the fragment numbering and metadata of a
+// storageRenderer.
+type adfRenderer struct {
+	*storageRenderer
+}
+
+func (r adfRenderer) blocks(nodes []adfNode) (string, error) {
+	parts := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		restore := r.checkpoint()
+		markdown, ok, err := r.block(node)
+		if err != nil {
+			return "", fmt.Errorf("convert ADF %s node: %w", node.Type, err)
+		}
+		if !ok {
+			restore()
+			if markdown, err = r.preserveNode(node, PreservationMarker); err != nil {
+				return "", err
+			}
+		}
+		parts = append(parts, markdown)
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// preserveNode keeps a node behind a marker as compact JSON.
+func (r adfRenderer) preserveNode(node adfNode, marker func(string) (string, error)) (string, error) {
+	var fragment bytes.Buffer
+	if err := json.Compact(&fragment, node.raw); err != nil {
+		return "", fmt.Errorf("compact ADF %s node: %w", node.Type, err)
+	}
+	return r.preserveWith(fragment.String(), marker)
+}
+
+// block converts a block node, reporting false when it has no Markdown form.
+// Block marks, such as alignment and indentation, have none.
+func (r adfRenderer) block(node adfNode) (string, bool, error) {
+	if len(node.Marks) > 0 {
+		return "", false, nil
+	}
+	switch node.Type {
+	case "paragraph":
+		if !node.hasOnlyAttrs() {
+			return "", false, nil
+		}
+		if len(node.Content) == 0 {
+			return "<br>", true, nil
+		}
+		markdown, err := r.inline(node.Content)
+		return markdown, err == nil, err
+	case "heading":
+		level, _, err := node.intAttr("level")
+		if err != nil || level < 1 || level > 6 || !node.hasOnlyAttrs("level") || len(node.Content) == 0 {
+			return "", false, nil
+		}
+		markdown, err := r.inline(node.Content)
+		return strings.Repeat("#", level) + " " + markdown, err == nil, err
+	case "bulletList", "orderedList":
+		return r.list(node)
+	case "codeBlock":
+		return codeBlockMarkdown(node)
+	case "blockquote":
+		if !node.hasOnlyAttrs() {
+			return "", false, nil
+		}
+		body, err := r.blocks(node.Content)
+		return strings.Join(quotedLines(body), "\n"), err == nil, err
+	case "rule":
+		return "---", true, nil
+	case "panel":
+		label, ok := adfPanelKinds[node.stringAttr("panelType")]
+		if !ok || !node.hasOnlyAttrs("panelType") {
+			return "", false, nil
+		}
+		body, err := r.blocks(node.Content)
+		return strings.Join(append([]string{"> [!" + label + "]"}, quotedLines(body)...), "\n"), err == nil, err
+	case "expand":
+		title, hasTitle := node.Attrs["title"].(string)
+		if !node.hasOnlyAttrs("title") || strings.ContainsAny(title, "\r\n") {
+			return "", false, nil
+		}
+		body, err := r.blocks(node.Content)
+		if !hasTitle || title == "" {
+			return detailsMarkdown(nil, nil, body), err == nil, err
+		}
+		return detailsMarkdown(&title, nil, body), err == nil, err
+	case "table":
+		return r.table(node)
+	case "mediaSingle":
+		markdown, ok := externalImageMarkdown(node)
+		return markdown, ok, nil
+	}
+	return "", false, nil
+}
+
+func (r adfRenderer) list(node adfNode) (string, bool, error) {
+	number := 1
+	if node.Type == "orderedList" {
+		order, set, err := node.intAttr("order")
+		if err != nil || order < 0 || order > 999999999 || !node.hasOnlyAttrs("order") {
+			return "", false, nil
+		}
+		if set {
+			number = order
+		}
+	} else if !node.hasOnlyAttrs() {
+		return "", false, nil
+	}
+
+	loose := false
+	items := make([]string, 0, len(node.Content))
+	for _, item := range node.Content {
+		if item.Type != "listItem" || !item.hasOnlyAttrs() || len(item.Marks) > 0 {
+			return "", false, nil
+		}
+		var body strings.Builder
+		for index, child := range item.Content {
+			markdown, err := r.blocks([]adfNode{child})
+			if err != nil {
+				return "", false, err
+			}
+			switch {
+			case index == 0:
+			case child.Type == "bulletList" || child.Type == "orderedList":
+				body.WriteString("\n")
+			default:
+				body.WriteString("\n\n")
+				loose = true
+			}
+			body.WriteString(markdown)
+		}
+		marker := "-"
+		if node.Type == "orderedList" {
+			marker = strconv.Itoa(number+len(items)) + "."
+		}
+		items = append(items, listItemMarkdown(marker, body.String()))
+	}
+	if len(items) == 0 {
+		return "", false, nil
+	}
+	if loose {
+		return strings.Join(items, "\n\n"), true, nil
+	}
+	return strings.Join(items, "\n"), true, nil
+}
+
+// codeBlockMarkdown writes a code block as fenced code with its language as
+// the info string.
+func codeBlockMarkdown(node adfNode) (string, bool, error) {
+	language := node.stringAttr("language")
+	if !node.hasOnlyAttrs("language") ||
+		(language != "" && (!codeLanguage.MatchString(language) || language == "noformat")) {
+		return "", false, nil
+	}
+	var code strings.Builder
+	for _, child := range node.Content {
+		if child.Type != "text" || len(child.Marks) > 0 {
+			return "", false, nil
+		}
+		code.WriteString(child.Text)
+	}
+	return fencedCode(code.String(), language), true, nil
+}
+
+// externalImageMarkdown writes a centred image of an external URL. Images of
+// uploaded files are referenced by media IDs that have no Markdown form.
+func externalImageMarkdown(node adfNode) (string, bool) {
+	if layout := node.stringAttr("layout"); !node.hasOnlyAttrs("layout") || (layout != "" && layout != "center") || len(node.Content) != 1 {
+		return "", false
+	}
+	media := node.Content[0]
+	url, alt := media.stringAttr("url"), media.stringAttr("alt")
+	if media.Type != "media" || media.stringAttr("type") != "external" || !media.hasOnlyAttrs("type", "url", "alt") || len(media.Marks) > 0 ||
+		!imageURL.MatchString(url) || attachmentFilename(url) != "" || strings.ContainsAny(alt, "]\r\n") {
+		return "", false
+	}
+	return "![" + alt + "](" + url + ")", true
+}
+
+// table writes a table in the same forms as a storage table. Column widths
+// are dropped; cell backgrounds and other attributes have no Markdown form.
+func (r adfRenderer) table(node adfNode) (string, bool, error) {
+	table := storageTable{tableOptions: tableOptions{Layout: "default"}}
+	for key, value := range node.Attrs {
+		switch key {
+		case "localId":
+		case "layout":
+			layout, _ := value.(string)
+			if !tableLayouts[layout] {
+				return "", false, nil
+			}
+			table.Layout = layout
+		case "isNumberColumnEnabled":
+			numbered, ok := value.(bool)
+			if !ok {
+				return "", false, nil
+			}
+			table.Numbered = numbered
+		case "width":
+			width, _, err := node.intAttr("width")
+			if err != nil || width < 1 {
+				return "", false, nil
+			}
+			table.Width = width
+		default:
+			return "", false, nil
+		}
+	}
+
+	var grid tableGrid
+	var contents [][][]adfNode
+	for _, row := range node.Content {
+		if row.Type != "tableRow" || !row.hasOnlyAttrs() || len(row.Content) == 0 {
+			return "", false, nil
+		}
+		cells := make([]storageTableCell, len(row.Content))
+		options := make([]tableCellOptions, len(row.Content))
+		rowContents := make([][]adfNode, len(row.Content))
+		for index, cell := range row.Content {
+			if (cell.Type != "tableCell" && cell.Type != "tableHeader") || !cell.hasOnlyAttrs("colspan", "rowspan", "colwidth") {
+				return "", false, nil
+			}
+			options[index] = tableCellOptions{RowSpan: 1, ColSpan: 1}
+			for _, span := range []struct {
+				key   string
+				value *int
+			}{{"colspan", &options[index].ColSpan}, {"rowspan", &options[index].RowSpan}} {
+				value, set, err := cell.intAttr(span.key)
+				if err != nil || (set && value < 1) {
+					return "", false, nil
+				}
+				if set {
+					*span.value = value
+				}
+			}
+			cells[index] = storageTableCell{tableCellOptions: options[index], Header: cell.Type == "tableHeader"}
+			rowContents[index] = cell.Content
+		}
+		starts, err := grid.place(options)
+		if err != nil {
+			return "", false, nil
+		}
+		for index := range cells {
+			cells[index].Start = starts[index]
+		}
+		table.Rows = append(table.Rows, cells)
+		contents = append(contents, rowContents)
+	}
+	if len(table.Rows) == 0 || grid.finish() != nil {
+		return "", false, nil
+	}
+	table.Columns = grid.columns
+	header, ok := tableHeader(table.Rows)
+	if !ok {
+		return "", false, nil
+	}
+	table.Header = header
+
+	markdown, err := r.tableForms(table, func(row, index int) (string, bool, error) {
+		return r.inlineCell(contents[row][index])
+	}, func(row, index int) (string, error) {
+		return r.blocks(contents[row][index])
+	})
+	return markdown, err == nil, err
+}
+
+// inlineCell converts a cell holding at most one paragraph to a single line,
+// writing its line breaks as <br>.
+func (r adfRenderer) inlineCell(content []adfNode) (string, bool, error) {
+	if len(content) == 0 {
+		return "", true, nil
+	}
+	paragraph := content[0]
+	if len(content) > 1 || paragraph.Type != "paragraph" || !paragraph.hasOnlyAttrs() || len(paragraph.Marks) > 0 {
+		return "", false, nil
+	}
+	parts := [][]adfNode{nil}
+	for _, node := range paragraph.Content {
+		if node.Type == "hardBreak" {
+			parts = append(parts, nil)
+			continue
+		}
+		parts[len(parts)-1] = append(parts[len(parts)-1], node)
+	}
+	lines := make([]string, len(parts))
+	for index, part := range parts {
+		markdown, err := r.inline(part)
+		if err != nil || strings.Contains(markdown, "\n") {
+			return "", false, err
+		}
+		lines[index] = markdown
+	}
+	return strings.Join(lines, "<br>"), true, nil
+}
+
+// inline converts a run of inline nodes to Markdown through the HTML
+// conversion used for storage, so text is escaped and formatted the same way.
+// Mentions, dates, statuses, and emoji are swapped for placeholders that are
+// replaced by their Markdown forms afterwards, and inline nodes without a
+// Markdown form are preserved behind inline markers.
+func (r adfRenderer) inline(nodes []adfNode) (string, error) {
+	placeholder := "confluxinline"
+	for _, node := range nodes {
+		for strings.Contains(string(node.raw), placeholder) {
+			placeholder += "x"
+		}
+	}
+	converter := adfInlineConverter{renderer: r, placeholder: placeholder}
+	if err := converter.write(nodes, 0, false); err != nil {
+		return "", err
+	}
+	markdown, err := storageMarkdownConverter.ConvertString("<p>" + converter.html.String() + "</p>")
+	if err != nil {
+		return "", fmt.Errorf("convert ADF inline content: %w", err)
+	}
+	markdown = strings.TrimSpace(markdown)
+	for index, substitution := range converter.substitutions {
+		markdown = strings.Replace(markdown, placeholder+strconv.Itoa(index)+"z", substitution, 1)
+	}
+	return markdown, nil
+}
+
+type adfInlineConverter struct {
+	renderer      adfRenderer
+	placeholder   string
+	html          strings.Builder
+	substitutions []string
+}
+
+func (c *adfInlineConverter) substitute(markdown string) {
+	c.html.WriteString(c.placeholder + strconv.Itoa(len(c.substitutions)) + "z")
+	c.substitutions = append(c.substitutions, markdown)
+}
+
+// write writes nodes as HTML, nesting the runs of nodes that share their mark
+// at depth inside that mark's element. Nodes with a mark that has no HTML
+// form are preserved whole.
+func (c *adfInlineConverter) write(nodes []adfNode, depth int, code bool) error {
+	for index := 0; index < len(nodes); {
+		node := nodes[index]
+		if !knownMarks(node) {
+			if err := c.preserve(node); err != nil {
+				return err
+			}
+			index++
+			continue
+		}
+		if len(node.Marks) <= depth {
+			if err := c.leaf(node, code); err != nil {
+				return err
+			}
+			index++
+			continue
+		}
+		mark := node.Marks[depth]
+		end := index + 1
+		for end < len(nodes) && knownMarks(nodes[end]) && len(nodes[end].Marks) > depth && marksEqual(nodes[end].Marks[depth], mark) {
+			end++
+		}
+		open, close, _ := markHTML(mark)
+		c.html.WriteString(open)
+		if err := c.write(nodes[index:end], depth+1, code || mark.Type == "code"); err != nil {
+			return err
+		}
+		c.html.WriteString(close)
+		index = end
+	}
+	return nil
+}
+
+func (c *adfInlineConverter) leaf(node adfNode, code bool) error {
+	switch node.Type {
+	case "text":
+		c.text(node.Text, code)
+		return nil
+	case "hardBreak":
+		c.html.WriteString("<br />")
+		return nil
+	case "mention":
+		accountID := node.stringAttr("id")
+		if mentionAccountID.MatchString(accountID) {
+			name := c.renderer.users[accountID]
+			if name == "" {
+				name = strings.TrimPrefix(node.stringAttr("text"), "@")
+			}
+			c.substitute(mentionMarkdown(accountID, name))
+			return nil
+		}
+	case "emoji":
+		name := strings.Trim(node.stringAttr("shortName"), ":")
+		character := emoji[name]
+		if character != "" && node.stringAttr("shortName") == ":"+name+":" && node.hasOnlyAttrs("shortName", "id", "text") &&
+			node.stringAttr("id") == emojiID(character) && node.stringAttr("text") == character {
+			c.substitute(":" + name + ":")
+			return nil
+		}
+	case "date":
+		if date, ok := adfDate(node); ok {
+			c.substitute("{date:" + date + "}")
+			return nil
+		}
+	case "status":
+		colour, known := adfStatusColours[node.stringAttr("color")]
+		title := node.stringAttr("text")
+		if known && node.hasOnlyAttrs("text", "color", "style") && node.stringAttr("style") == "" && !strings.ContainsAny(title, "|}\\\r\n") {
+			c.substitute("{status:" + colour + "|" + title + "}")
+			return nil
+		}
+	}
+	return c.preserve(node)
+}
+
+func (c *adfInlineConverter) preserve(node adfNode) error {
+	marker, err := c.renderer.preserveNode(node, InlineMarker)
+	if err != nil {
+		return err
+	}
+	c.substitute(marker)
+	return nil
+}
+
+// text writes escaped text. Outside code, text that push would read as a
+// date, status, or emoticon has its first character escaped.
+func (c *adfInlineConverter) text(value string, code bool) {
+	last := 0
+	if !code {
+		for _, match := range inlineSyntaxLiteral.FindAllStringIndex(value, -1) {
+			literal := value[match[0]:match[1]]
+			if literal[0] == ':' && !isEmoticonName(strings.Trim(literal, ":")) {
+				continue
+			}
+			c.html.WriteString(html.EscapeString(value[last:match[0]]))
+			c.substitute(`\` + literal[:1])
+			last = match[0] + 1
+		}
+	}
+	c.html.WriteString(html.EscapeString(value[last:]))
+}
+
+// adfDate returns the day of a date node, which Confluence stores as the
+// milliseconds of midnight UTC.
+func adfDate(node adfNode) (string, bool) {
+	milliseconds, err := strconv.ParseInt(node.stringAttr("timestamp"), 10, 64)
+	if err != nil || !node.hasOnlyAttrs("timestamp") {
+		return "", false
+	}
+	date := time.UnixMilli(milliseconds).UTC()
+	if !date.Equal(date.Truncate(24 * time.Hour)) {
+		return "", false
+	}
+	return date.Format(time.DateOnly), true
+}
+
+func knownMarks(node adfNode) bool {
+	for _, mark := range node.Marks {
+		if _, _, ok := markHTML(mark); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func marksEqual(a, b adfMark) bool {
+	open, _, _ := markHTML(a)
+	other, _, _ := markHTML(b)
+	return a.Type == b.Type && open == other
+}
+
+// markHTML returns the HTML elements that open and close a mark, reporting
+// false for marks without a Markdown form, such as annotations.
+func markHTML(mark adfMark) (string, string, bool) {
+	only := func(keys ...string) bool {
+		return adfNode{Attrs: mark.Attrs}.hasOnlyAttrs(keys...)
+	}
+	switch mark.Type {
+	case "strong", "em", "code", "underline":
+		tag := map[string]string{"strong": "strong", "em": "em", "code": "code", "underline": "u"}[mark.Type]
+		return "<" + tag + ">", "</" + tag + ">", len(mark.Attrs) == 0
+	case "strike":
+		return "<del>", "</del>", len(mark.Attrs) == 0
+	case "subsup":
+		kind, _ := mark.Attrs["type"].(string)
+		return "<" + kind + ">", "</" + kind + ">", only("type") && (kind == "sub" || kind == "sup")
+	case "textColor":
+		color, _ := mark.Attrs["color"].(string)
+		return `<span style="color: ` + html.EscapeString(color) + `;">`, "</span>", only("color") && adfTextColor.MatchString(color)
+	case "link":
+		href, _ := mark.Attrs["href"].(string)
+		title, _ := mark.Attrs["title"].(string)
+		open := `<a href="` + html.EscapeString(href) + `"`
+		if title != "" {
+			open += ` title="` + html.EscapeString(title) + `"`
+		}
+		return open + ">", "</a>", only("href", "title") && href != "" && !strings.ContainsAny(href, " \t\r\n") && !isConfluxDestination(href)
+	}
+	return "", "", false
+}
+
+// adfTextColor matches the text colours ADF accepts.
+var adfTextColor = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// isConfluxDestination reports whether push reads a link destination as a
+// Confluence link rather than a URL, so an ADF link with that href cannot be
+// written as a Markdown link.
+func isConfluxDestination(destination string) bool {
+	for _, prefix := range []string{"jira:", "confluence:", "user:", "#"} {
+		if strings.HasPrefix(destination, prefix) {
+			return true
+		}
+	}
+	return attachmentFilename(destination) != ""
+}
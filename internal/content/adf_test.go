@@ -0,0 +1,241 @@
+package content
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const adfTestDocument = `{"version":1,"type":"doc","content":[
+{"type":"heading","attrs":{"level":2},"content":[{"type":"text","text":"Deploy"}]},
+{"type":"paragraph","content":[
+	{"type":"text","text":"Run "},
+	{"type":"text","text":"make","marks":[{"type":"code"}]},
+	{"type":"text","text":" with "},
+	{"type":"text","text":"care","marks":[{"type":"em"},{"type":"strong"}]},
+	{"type":"text","text":", see "},
+	{"type":"text","text":"docs","marks":[{"type":"link","attrs":{"href":"https://example.com/docs"}}]},
+	{"type":"text","text":" and ask "},
+	{"type":"mention","attrs":{"id":"557058:abc"}},
+	{"type":"hardBreak"},
+	{"type":"text","text":"by "},
+	{"type":"date","attrs":{"timestamp":"1767225600000"}},
+	{"type":"text","text":" "},
+	{"type":"status","attrs":{"text":"DONE","color":"green"}},
+	{"type":"text","text":" "},
+	{"type":"emoji","attrs":{"shortName":":rocket:","id":"1f680","text":"🚀"}},
+	{"type":"text","text":" "},
+	{"type":"text","text":"red","marks":[{"type":"textColor","attrs":{"color":"#ff0000"}}]},
+	{"type":"text","text":" *literal*"}
+]},
+{"type":"bulletList","content":[
+	{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"one"}]}]},
+	{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"two"}]}]}
+]},
+{"type":"orderedList","attrs":{"order":3},"content":[
+	{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"three"}]}]}
+]},
+{"type":"codeBlock","attrs":{"language":"go"},"content":[{"type":"text","text":"fmt.Println(1)"}]},
+{"type":"blockquote","content":[{"type":"paragraph","content":[{"type":"text","text":"quoted"}]}]},
+{"type":"rule"},
+{"type":"panel","attrs":{"panelType":"warning"},"content":[{"type":"paragraph","content":[{"type":"text","text":"careful"}]}]},
+{"type":"expand","attrs":{"title":"More"},"content":[{"type":"paragraph","content":[{"type":"text","text":"hidden"}]}]},
+{"type":"table","attrs":{"layout":"default","isNumberColumnEnabled":false},"content":[
+	{"type":"tableRow","content":[
+		{"type":"tableHeader","content":[{"type":"paragraph","content":[{"type":"text","text":"Name"}]}]},
+		{"type":"tableHeader","content":[{"type":"paragraph","content":[{"type":"text","text":"Value"}]}]}
+	]},
+	{"type":"tableRow","content":[
+		{"type":"tableCell","content":[{"type":"paragraph","content":[{"type":"text","text":"a"}]}]},
+		{"type":"tableCell","content":[{"type":"paragraph","content":[{"type":"text","text":"b"}]}]}
+	]}
+]},
+{"type":"mediaSingle","attrs":{"layout":"center"},"content":[{"type":"media","attrs":{"type":"external","url":"https://example.com/a.png","alt":"diagram"}}]},
+{"type":"decisionList","attrs":{"localId":"d1"},"content":[{"type":"decisionItem","attrs":{"localId":"d2","state":"DECIDED"},"content":[{"type":"text","text":"Ship it"}]}]},
+{"type":"paragraph","content":[
+	{"type":"text","text":"see "},
+	{"type":"inlineCard","attrs":{"url":"https://example.com/card"}},
+	{"type":"text","text":"noted","marks":[{"type":"annotation","attrs":{"id":"c1","annotationType":"inlineComment"}}]}
+]}
+]}`
+
+func adfTestPage(document string) ADFPage {
+	return ADFPage{
+		ID: "123", SpaceKey: "DOCS", Title: "Deployment", BaseVersion: 4, Document: document,
+		Users: map[string]string{"557058:abc": "Ada Lovelace"},
+	}
+}
+
+func TestRenderADFWritesMarkdownAndPreservesUnsupportedNodes(t *testing.T) {
+	artifact, err := RenderADF(adfTestPage(adfTestDocument))
+	if err != nil {
+		t.Fatalf("RenderADF returned error: %v", err)
+	}
+	for _, expected := range []string{
+		"## Deploy",
+		"Run `make` with ***care***, see [docs](https://example.com/docs) and ask @[Ada Lovelace](user:557058:abc)",
+		"{date:2026-01-01}", "{status:green|DONE}", ":rocket:", "\\*literal*",
+		"- one\n- two", "3. three", "```go\nfmt.Println(1)\n```", "> quoted", "---",
+		"> [!NOTE]\n> careful", "<details>", "| Name | Value |", "![diagram](https://example.com/a.png)",
+		`<!-- conflux:preserved id="fragment-0001" -->`, `<!-- conflux:inline id="fragment-0002" -->`,
+	} {
+		if !strings.Contains(artifact.Markdown, expected) {
+			t.Fatalf("markdown does not contain %q:\n%s", expected, artifact.Markdown)
+		}
+	}
+	if artifact.Metadata.Page.Representation != RepresentationADF {
+		t.Fatalf("representation = %q", artifact.Metadata.Page.Representation)
+	}
+	if fragment := artifact.Metadata.PreservedFragments["fragment-0001"]; !strings.HasPrefix(fragment, `{"type":"decisionList"`) {
+		t.Fatalf("decision list fragment = %s", fragment)
+	}
+	if len(artifact.Metadata.PreservedFragments) != 3 {
+		t.Fatalf("fragments = %#v", artifact.Metadata.PreservedFragments)
+	}
+}
+
+func TestRenderArtifactPushesADFPageBackUnchanged(t *testing.T) {
+	artifact, err := RenderADF(adfTestPage(adfTestDocument))
+	if err != nil {
+		t.Fatalf("RenderADF returned error: %v", err)
+	}
+	pushed, err := RenderArtifact(artifact.Markdown, artifact.Metadata, nil)
+	if err != nil {
+		t.Fatalf("RenderArtifact returned error: %v", err)
+	}
+	if pushed.Storage != "" || pushed.BaseVersion != 4 {
+		t.Fatalf("unexpected artifact: %#v", pushed)
+	}
+	assertSameADF(t, pushed.ADF, adfTestDocument)
+}
+
+func TestRenderArtifactWritesEditedADF(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.Page.Representation = RepresentationADF
+	metadata.PreservedFragments = map[string]string{}
+	markdown := "> [!TIP]\n> Use ~~old~~ ~2~ [new]{.underline} [go]{color=#00ff00} <https://example.com>\n"
+
+	pushed, err := RenderArtifact(markdown, metadata, nil)
+	if err != nil {
+		t.Fatalf("RenderArtifact returned error: %v", err)
+	}
+	assertSameADF(t, pushed.ADF, `{"version":1,"type":"doc","content":[{"type":"panel","attrs":{"panelType":"success"},"content":[{"type":"paragraph","content":[
+		{"type":"text","text":"Use "},
+		{"type":"text","text":"old","marks":[{"type":"strike"}]},
+		{"type":"text","text":" "},
+		{"type":"text","text":"2","marks":[{"type":"subsup","attrs":{"type":"sub"}}]},
+		{"type":"text","text":" "},
+		{"type":"text","text":"new","marks":[{"type":"underline"}]},
+		{"type":"text","text":" "},
+		{"type":"text","text":"go","marks":[{"type":"textColor","attrs":{"color":"#00ff00"}}]},
+		{"type":"text","text":" "},
+		{"type":"text","text":"https://example.com","marks":[{"type":"link","attrs":{"href":"https://example.com"}}]}
+	]}]}]}`)
+}
+
+func TestRenderArtifactUploadsChangedMediaOfADFPage(t *testing.T) {
+	document := `{"version":1,"type":"doc","content":[{"type":"mediaSingle","attrs":{"layout":"center"},"content":[` +
+		`{"type":"media","attrs":{"type":"file","id":"a1b2","collection":"contentId-123","__fileName":"diagram.png"}}]}]}`
+	digest := sha256.Sum256([]byte("image"))
+	page := adfTestPage(document)
+	page.Attachments = []AttachmentMetadata{{ID: "att-1", Filename: "diagram.png", MediaType: "image/png", SHA256: hex.EncodeToString(digest[:])}}
+	pulled, err := RenderADF(page)
+	if err != nil {
+		t.Fatalf("RenderADF returned error: %v", err)
+	}
+	if len(pulled.Downloads) != 1 || pulled.Downloads[0].Filename != "diagram.png" {
+		t.Fatalf("downloads = %#v, want diagram.png", pulled.Downloads)
+	}
+	tests := []struct {
+		name    string
+		local   []LocalAttachment
+		uploads int
+	}{
+		{name: "unchanged", local: []LocalAttachment{{Filename: "diagram.png", MediaType: "image/png", Content: []byte("image")}}},
+		{name: "changed", local: []LocalAttachment{{Filename: "diagram.png", MediaType: "image/png", Content: []byte("new image")}}, uploads: 1},
+		{name: "not downloaded"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pushed, err := RenderArtifact(pulled.Markdown, pulled.Metadata, test.local)
+			if err != nil {
+				t.Fatalf("RenderArtifact returned error: %v", err)
+			}
+			if len(pushed.Uploads) != test.uploads {
+				t.Fatalf("uploads = %#v, want %d", pushed.Uploads, test.uploads)
+			}
+			assertSameADF(t, pushed.ADF, document)
+		})
+	}
+}
+
+func TestRenderArtifactReadsEscapedPipeInADFTableCode(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.Page.Representation = RepresentationADF
+	metadata.PreservedFragments = map[string]string{}
+	pushed, err := RenderArtifact("| Command |\n| --- |\n| `a\\|b` |\n", metadata, nil)
+	if err != nil {
+		t.Fatalf("RenderArtifact returned error: %v", err)
+	}
+	if !strings.Contains(pushed.ADF, `{"type":"text","marks":[{"type":"code"}],"text":"a|b"}`) {
+		t.Fatalf("ADF = %s", pushed.ADF)
+	}
+}
+
+func TestRenderArtifactRejectsStorageOnlyMarkdownInADFPages(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.Page.Representation = RepresentationADF
+	metadata.PreservedFragments = map[string]string{}
+	tests := map[string]string{
+		"attachment image": "![diagram](page.attachments/diagram.png)\n",
+		"page link":        "[Home](confluence:DOCS/Home)\n",
+		"task list":        "- [ ] todo\n",
+		"directive":        "<!-- conflux:toc -->\n",
+		"legacy emoticon":  ":tick:\n",
+		"subtle status":    "{status:green|Done|subtle}\n",
+	}
+	for name, markdown := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := RenderArtifact(markdown, metadata, nil)
+			if err == nil || !strings.Contains(err.Error(), "Atlas Document Format") {
+				t.Fatalf("error = %v", err)
+			}
+		})
+	}
+}
+
+func TestMetadataValidateRejectsNonADFFragmentsInADFPages(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.Page.Representation = RepresentationADF
+	if err := metadata.Validate(); err == nil || !strings.Contains(err.Error(), "not an ADF node") {
+		t.Fatalf("error = %v", err)
+	}
+	metadata.Page.Representation = "wiki"
+	if err := metadata.Validate(); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Fatalf("error = %v", err)
+	}
+}
+
+func TestADFMentionedAccountIDs(t *testing.T) {
+	ids := ADFMentionedAccountIDs(adfTestDocument)
+	if !reflect.DeepEqual(ids, []string{"557058:abc"}) {
+		t.Fatalf("ids = %v", ids)
+	}
+}
+
+func assertSameADF(t *testing.T, got, want string) {
+	t.Helper()
+	var gotValue, wantValue any
+	if err := json.Unmarshal([]byte(got), &gotValue); err != nil {
+		t.Fatalf("decode ADF %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("decode expected ADF: %v", err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Fatalf("ADF = %s\nwant %s", got, want)
+	}
+}
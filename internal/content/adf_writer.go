@@ -0,0 +1,492 @@
+package content
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// adfPanelTypes maps the admonition macros to ADF panel types; see
+// adfPanelKinds.
+var adfPanelTypes = map[string]string{
+	"info":    "info",
+	"note":    "warning",
+	"tip":     "success",
+	"warning": "error",
+}
+
+// adfBlockNames describes the Conflux blocks that have no ADF form, for
+// errors.
+var adfBlockNames = map[ast.NodeKind]string{
+	kindDirectiveBlock: "Conflux directive",
+	kindMacroEnvelope:  "macro marker",
+	kindLayoutSection:  "layout section",
+}
+
+// adfWriter builds an Atlas Document Format document from parsed Markdown.
+// Preserved fragments are restored as the ADF nodes they hold. Markdown that
+// only has a storage form, such as attachment links and macro directives, is
+// reported as an error rather than dropped.
+type adfWriter struct {
+	source    []byte
+	fragments map[string]string
+	restored  map[string]bool
+}
+
+// markdownToADF renders Markdown as an ADF document. It also returns the
+// attachments shown by the media in restored fragments, as markdownToStorage
+// returns the attachments that links and images reference.
+func markdownToADF(markdown string, fragments map[string]string) (string, []string, error) {
+	source := []byte(strings.ReplaceAll(markdown, "\r\n", "\n"))
+	document := newMarkdownParser().Parse(text.NewReader(source))
+	writer := &adfWriter{source: source, fragments: fragments, restored: make(map[string]bool, len(fragments))}
+	content, err := writer.blocks(document)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := checkRestored(markdown, fragments, writer.restored); err != nil {
+		return "", nil, err
+	}
+	if content == nil {
+		content = []adfNode{}
+	}
+	data, err := json.Marshal(adfDocument{Version: 1, Type: "doc", Content: content})
+	if err != nil {
+		return "", nil, fmt.Errorf("encode ADF document: %w", err)
+	}
+	var references []string
+	for _, id := range slices.Sorted(maps.Keys(writer.restored)) {
+		references = append(references, adfMediaFilenames(fragments[id])...)
+	}
+	return string(data), deduplicateStrings(references), nil
+}
+
+func unsupportedInADF(format string, args ...any) error {
+	return fmt.Errorf(format+" is not supported in Atlas Document Format pages", args...)
+}
+
+func (w *adfWriter) blocks(parent ast.Node) ([]adfNode, error) {
+	var nodes []adfNode
+	for child := parent.FirstChild(); child != nil; child = child.NextSibling() {
+		node, err := w.block(child)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+func (w *adfWriter) block(node ast.Node) (adfNode, error) {
+	switch value := node.(type) {
+	case *ast.Paragraph, *ast.TextBlock:
+		if image, ok := soleImage(node); ok {
+			return w.image(image)
+		}
+		content, err := w.inlines(node, nil)
+		return adfNode{Type: "paragraph", Content: content}, err
+	case *ast.Heading:
+		content, err := w.inlines(node, nil)
+		return adfNode{Type: "heading", Attrs: map[string]any{"level": value.Level}, Content: content}, err
+	case *ast.Blockquote:
+		content, err := w.blocks(node)
+		return adfNode{Type: "blockquote", Content: content}, err
+	case *ast.List:
+		return w.list(value)
+	case *ast.ThematicBreak:
+		return adfNode{Type: "rule"}, nil
+	case *ast.FencedCodeBlock, *ast.CodeBlock:
+		return w.codeBlock(node)
+	case *ast.HTMLBlock:
+		raw := value.Lines().Value(w.source)
+		if value.HasClosure() {
+			raw = append(raw, value.ClosureLine.Value(w.source)...)
+		}
+		if lineBreakHTML.Match(bytes.TrimSpace(raw)) {
+			return adfNode{Type: "paragraph"}, nil
+		}
+		return adfNode{Type: "paragraph", Content: []adfNode{adfText(strings.TrimSpace(string(raw)), nil)}}, nil
+	case *preservedBlock:
+		if value.HasParameters {
+			return adfNode{}, unsupportedInADF("parameter block after preservation marker %q", value.ID)
+		}
+		w.restored[value.ID] = true
+		return adfNode{raw: json.RawMessage(w.fragments[value.ID])}, nil
+	case *admonitionBlock:
+		if value.Err != nil {
+			return adfNode{}, value.Err
+		}
+		panelType, ok := adfPanelTypes[value.Macro]
+		if !ok {
+			return adfNode{}, unsupportedInADF("%s admonition", admonitionKinds[value.Macro])
+		}
+		if attributes := slices.Concat(value.Parameters, value.MacroAttributes); len(attributes) > 0 {
+			return adfNode{}, unsupportedInADF("admonition attribute block %s", formatAttributeBlock(attributes))
+		}
+		content, err := w.blocks(node)
+		return adfNode{Type: "panel", Attrs: map[string]any{"panelType": panelType}, Content: content}, err
+	case *detailsBlock:
+		if !value.Closed {
+			return adfNode{}, fmt.Errorf("details block is missing its closing </details> line")
+		}
+		if len(value.MacroAttributes) > 0 {
+			return adfNode{}, unsupportedInADF("details attribute %s", value.MacroAttributes[0].Key)
+		}
+		expand := adfNode{Type: "expand", Attrs: map[string]any{"title": value.Title}}
+		if inTableCell(node) {
+			expand.Type = "nestedExpand"
+		}
+		content, err := w.blocks(node)
+		expand.Content = content
+		return expand, err
+	case *tableBlock:
+		return w.table(value)
+	}
+	if name, ok := adfBlockNames[node.Kind()]; ok {
+		return adfNode{}, unsupportedInADF("%s", name)
+	}
+	return adfNode{}, unsupportedInADF("Markdown %s", node.Kind())
+}
+
+// soleImage returns the image that a paragraph holds on its own. ADF images
+// are blocks, so an image among text has no ADF form.
+func soleImage(paragraph ast.Node) (*ast.Image, bool) {
+	if paragraph.ChildCount() == 1 {
+		image, ok := paragraph.FirstChild().(*ast.Image)
+		return image, ok
+	}
+	if paragraph.ChildCount() == 2 && paragraph.LastChild().Kind() == kindLinkAttributes {
+		image, ok := paragraph.FirstChild().(*ast.Image)
+		return image, ok
+	}
+	return nil, false
+}
+
+func inTableCell(node ast.Node) bool {
+	for parent := node.Parent(); parent != nil; parent = parent.Parent() {
+		if parent.Kind() == kindTableCell {
+			return true
+		}
+	}
+	return false
+}
+
+// image writes an external image. Attachment images have no ADF form until
+// the file has been uploaded and given a media ID.
+func (w *adfWriter) image(image *ast.Image) (adfNode, error) {
+	destination := string(image.Destination)
+	if attachmentFilename(destination) != "" {
+		return adfNode{}, unsupportedInADF("attachment image %q", destination)
+	}
+	if attributes := attributesOf(image); len(attributes) > 0 {
+		return adfNode{}, unsupportedInADF("image attribute block %s", formatAttributeBlock(attributes))
+	}
+	attrs := map[string]any{"type": "external", "url": string(util.URLEscape(image.Destination, true))}
+	if alt := plainText(image, w.source); alt != "" {
+		attrs["alt"] = alt
+	}
+	return adfNode{Type: "mediaSingle", Attrs: map[string]any{"layout": "center"}, Content: []adfNode{{Type: "media", Attrs: attrs}}}, nil
+}
+
+func (w *adfWriter) list(list *ast.List) (adfNode, error) {
+	if isTaskList(list) {
+		return adfNode{}, unsupportedInADF("task list")
+	}
+	node := adfNode{Type: "bulletList"}
+	if list.IsOrdered() {
+		node.Type = "orderedList"
+		if list.Start != 1 {
+			node.Attrs = map[string]any{"order": list.Start}
+		}
+	}
+	for item := list.FirstChild(); item != nil; item = item.NextSibling() {
+		content, err := w.blocks(item)
+		if err != nil {
+			return adfNode{}, err
+		}
+		if len(content) == 0 {
+			content = []adfNode{{Type: "paragraph"}}
+		}
+		node.Content = append(node.Content, adfNode{Type: "listItem", Content: content})
+	}
+	return node, nil
+}
+
+// codeBlock writes fenced code with its language. The other code macro
+// options written in the info string have no ADF form.
+func (w *adfWriter) codeBlock(node ast.Node) (adfNode, error) {
+	info := ""
+	if fenced, ok := node.(*ast.FencedCodeBlock); ok {
+		if closed, _ := fenced.AttributeString(closedFenceAttribute); closed != true {
+			return adfNode{}, fmt.Errorf("markdown contains an unclosed fenced code block")
+		}
+		if fenced.Info != nil {
+			info = string(fenced.Info.Segment.Value(w.source))
+		}
+	}
+	if isJiraTableInfo(info) {
+		return adfNode{}, unsupportedInADF("Jira issue table")
+	}
+	_, parameters, err := parseCodeInfo(info)
+	if err != nil {
+		return adfNode{}, err
+	}
+	block := adfNode{Type: "codeBlock"}
+	for _, parameter := range parameters {
+		if parameter.Name != "language" {
+			return adfNode{}, unsupportedInADF("code block option %q", parameter.Name)
+		}
+		block.Attrs = map[string]any{"language": parameter.Value}
+	}
+	if code := strings.TrimSuffix(string(node.Lines().Value(w.source)), "\n"); code != "" {
+		block.Content = []adfNode{adfText(code, nil)}
+	}
+	return block, nil
+}
+
+func (w *adfWriter) table(table *tableBlock) (adfNode, error) {
+	if table.Err != nil {
+		return adfNode{}, table.Err
+	}
+	if len(table.ColumnWidths) > 0 {
+		return adfNode{}, unsupportedInADF("table column widths")
+	}
+	attrs := map[string]any{"layout": table.Layout, "isNumberColumnEnabled": table.Numbered}
+	if table.Width > 0 {
+		attrs["width"] = table.Width
+	}
+	node := adfNode{Type: "table", Attrs: attrs}
+	for row := table.FirstChild(); row != nil; row = row.NextSibling() {
+		adfRow := adfNode{Type: "tableRow"}
+		for child := row.FirstChild(); child != nil; child = child.NextSibling() {
+			cell := child.(*tableCell)
+			if cell.Align != "" {
+				return adfNode{}, unsupportedInADF("table cell alignment")
+			}
+			adfCell := adfNode{Type: "tableCell", Attrs: map[string]any{}}
+			if cell.Header {
+				adfCell.Type = "tableHeader"
+			}
+			if cell.ColSpan > 1 {
+				adfCell.Attrs["colspan"] = cell.ColSpan
+			}
+			if cell.RowSpan > 1 {
+				adfCell.Attrs["rowspan"] = cell.RowSpan
+			}
+			var err error
+			if cell.Blocks {
+				adfCell.Content, err = w.blocks(cell)
+			} else {
+				var content []adfNode
+				content, err = w.inlines(cell, nil)
+				adfCell.Content = []adfNode{{Type: "paragraph", Content: content}}
+			}
+			if err != nil {
+				return adfNode{}, err
+			}
+			if len(adfCell.Content) == 0 {
+				adfCell.Content = []adfNode{{Type: "paragraph"}}
+			}
+			adfRow.Content = append(adfRow.Content, adfCell)
+		}
+		node.Content = append(node.Content, adfRow)
+	}
+	return node, nil
+}
+
+func adfText(value string, marks []adfMark) adfNode {
+	return adfNode{Type: "text", Text: value, Marks: marks}
+}
+
+func withMark(marks []adfMark, mark adfMark) []adfMark {
+	return append(slices.Clone(marks), mark)
+}
+
+// appendInline appends an inline node, joining text to a previous text node
+// with the same marks.
+func appendInline(nodes []adfNode, node adfNode) []adfNode {
+	if node.Type == "text" && node.Text == "" {
+		return nodes
+	}
+	if len(nodes) > 0 {
+		last := &nodes[len(nodes)-1]
+		if node.Type == "text" && last.Type == "text" && last.raw == nil && reflect.DeepEqual(last.Marks, node.Marks) {
+			last.Text += node.Text
+			return nodes
+		}
+	}
+	return append(nodes, node)
+}
+
+func (w *adfWriter) inlines(parent ast.Node, marks []adfMark) ([]adfNode, error) {
+	var nodes []adfNode
+	for child := parent.FirstChild(); child != nil; child = child.NextSibling() {
+		inline, err := w.inline(child, marks)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range inline {
+			nodes = appendInline(nodes, node)
+		}
+	}
+	return nodes, nil
+}
+
+func (w *adfWriter) inline(node ast.Node, marks []adfMark) ([]adfNode, error) {
+	switch value := node.(type) {
+	case *ast.Text:
+		segment := value.Segment.Value(w.source)
+		if _, mention := mentionAccount(node.NextSibling()); mention {
+			segment = bytes.TrimSuffix(segment, []byte("@"))
+		}
+		if !value.IsRaw() {
+			segment = util.ResolveEntityNames(util.ResolveNumericReferences(util.UnescapePunctuations(segment)))
+		}
+		nodes := []adfNode{adfText(string(segment), marks)}
+		switch {
+		case value.HardLineBreak():
+			nodes = append(nodes, adfNode{Type: "hardBreak"})
+		case value.SoftLineBreak():
+			nodes = append(nodes, adfText(" ", marks))
+		}
+		return nodes, nil
+	case *ast.String:
+		return []adfNode{adfText(string(value.Value), marks)}, nil
+	case *ast.Emphasis:
+		mark := adfMark{Type: "em"}
+		if value.Level == 2 {
+			mark.Type = "strong"
+		}
+		return w.inlines(node, withMark(marks, mark))
+	case *ast.CodeSpan:
+		var code []byte
+		for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+			if textNode, ok := child.(*ast.Text); ok {
+				code = append(code, textNode.Segment.Value(w.source)...)
+			}
+		}
+		if inPipeTableCell(node) {
+			code = bytes.ReplaceAll(code, []byte(`\|`), []byte("|"))
+		}
+		// ADF code can only also be a link.
+		codeMarks := slices.DeleteFunc(slices.Clone(marks), func(mark adfMark) bool { return mark.Type != "link" })
+		return []adfNode{adfText(string(bytes.ReplaceAll(code, []byte("\n"), []byte(" "))), withMark(codeMarks, adfMark{Type: "code"}))}, nil
+	case *ast.Link:
+		return w.link(value, marks)
+	case *ast.AutoLink:
+		destination := value.URL(w.source)
+		if value.AutoLinkType == ast.AutoLinkEmail && !bytes.HasPrefix(bytes.ToLower(destination), []byte("mailto:")) {
+			destination = append([]byte("mailto:"), destination...)
+		}
+		link := adfMark{Type: "link", Attrs: map[string]any{"href": string(util.URLEscape(destination, true))}}
+		return []adfNode{adfText(string(value.Label(w.source)), withMark(marks, link))}, nil
+	case *ast.Image:
+		return nil, fmt.Errorf("image %q must be in a paragraph of its own in Atlas Document Format pages", value.Destination)
+	case *ast.RawHTML:
+		return w.rawHTML(value, marks)
+	case *linkAttributes:
+		return nil, nil
+	case *dateNode:
+		date, err := time.Parse(time.DateOnly, value.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q: %w", value.Value, err)
+		}
+		return []adfNode{{Type: "date", Attrs: map[string]any{"timestamp": strconv.FormatInt(date.UnixMilli(), 10)}}}, nil
+	case *statusNode:
+		if value.Subtle {
+			return nil, unsupportedInADF("subtle status")
+		}
+		colour := "neutral"
+		for adfColour, markdownColour := range adfStatusColours {
+			if strings.EqualFold(value.Colour, markdownColour) {
+				colour = adfColour
+			}
+		}
+		title := ""
+		if value.Title != nil {
+			title = *value.Title
+		}
+		return []adfNode{{Type: "status", Attrs: map[string]any{"text": title, "color": colour}}}, nil
+	case *emoticonNode:
+		character := emoji[value.Name]
+		if character == "" {
+			return nil, unsupportedInADF("emoticon :%s:", value.Name)
+		}
+		return []adfNode{{Type: "emoji", Attrs: map[string]any{"shortName": ":" + value.Name + ":", "id": emojiID(character), "text": character}}}, nil
+	case *formattedText:
+		mark := adfMark{}
+		switch value.Tag {
+		case "del":
+			mark.Type = "strike"
+		case "u":
+			mark.Type = "underline"
+		case "sub", "sup":
+			mark = adfMark{Type: "subsup", Attrs: map[string]any{"type": value.Tag}}
+		case "span":
+			if !adfTextColor.MatchString(value.Color) {
+				return nil, fmt.Errorf("text colour %q must be a #rrggbb colour in Atlas Document Format pages", value.Color)
+			}
+			mark = adfMark{Type: "textColor", Attrs: map[string]any{"color": strings.ToLower(value.Color)}}
+		}
+		return w.inlines(node, withMark(marks, mark))
+	case *extast.TaskCheckBox:
+		return nil, unsupportedInADF("task checkbox")
+	}
+	return nil, unsupportedInADF("Markdown %s", node.Kind())
+}
+
+// link writes a mention or a link mark over the link text. Links to pages,
+// anchors, attachments, and Jira issues only have storage forms.
+func (w *adfWriter) link(link *ast.Link, marks []adfMark) ([]adfNode, error) {
+	if accountID, ok := mentionAccount(link); ok {
+		return []adfNode{{Type: "mention", Attrs: map[string]any{"id": accountID}}}, nil
+	}
+	destination := string(link.Destination)
+	if isConfluxDestination(destination) {
+		return nil, unsupportedInADF("link to %q", destination)
+	}
+	if attributes := attributesOf(link); len(attributes) > 0 {
+		return nil, fmt.Errorf("link to %q does not support attributes %s", destination, formatAttributeBlock(attributes))
+	}
+	mark := adfMark{Type: "link", Attrs: map[string]any{"href": string(util.URLEscape(link.Destination, true))}}
+	if len(link.Title) > 0 {
+		mark.Attrs["title"] = string(link.Title)
+	}
+	return w.inlines(link, withMark(marks, mark))
+}
+
+// rawHTML restores the fragment behind an inline marker and writes a <br> as
+// a hard break. Other inline HTML stays visible text, as it does in storage.
+func (w *adfWriter) rawHTML(node *ast.RawHTML, marks []adfMark) ([]adfNode, error) {
+	switch {
+	case isTaskMarker(node, w.source):
+		return nil, unsupportedInADF("task marker")
+	case isCommentMarker(node, w.source):
+		return nil, unsupportedInADF("inline comment marker")
+	}
+	value := rawHTMLValue(node, w.source)
+	if anchorDirectiveStart.Match(value) {
+		return nil, unsupportedInADF("anchor directive")
+	}
+	if lineBreakHTML.Match(value) {
+		return []adfNode{{Type: "hardBreak"}}, nil
+	}
+	if match := inlineMarker.FindSubmatch(value); match != nil && len(match[0]) == len(value) {
+		if fragment, ok := w.fragments[string(match[1])]; ok {
+			w.restored[string(match[1])] = true
+			return []adfNode{{raw: json.RawMessage(fragment)}}, nil
+		}
+	}
+	return []adfNode{adfText(string(value), marks)}, nil
+}
@@ -0,0 +1,203 @@
+package content
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// admonitionKinds maps Confluence panel macros to the GitHub-style alert label
+// used in artifact Markdown. The Confluence name is kept as the label so that
+// info and note panels stay distinguishable after a round trip.
+var admonitionKinds = map[string]string{
+	"info":    "INFO",
+	"note":    "NOTE",
+	"tip":     "TIP",
+	"warning": "WARNING",
+	"panel":   "PANEL",
+}
+
+var admonitionLine = regexp.MustCompile(`^[ \t]*>[ \t]?\[!([A-Za-z]+)][ \t]*(\{.*})?[ \t]*$`)
+
+// paragraphInterrupt matches the start of a block that ends a paragraph
+// rather than continuing it lazily: an ATX heading, blockquote, code fence,
+// HTML block or Conflux directive, list item, or thematic break.
+var paragraphInterrupt = regexp.MustCompile(`^(?:#{1,6}(?:[ \t]|$)|>|` + "```" + `|~~~|<|[-+*][ \t]+\S|1[.)][ \t]+\S|(?:\*[ \t]*){3,}$|(?:-[ \t]*){3,}$|(?:_[ \t]*){3,}$)`)
+
+func parseAdmonitionMacro(node storageNode) (storageMacro, bool) {
+	if !isStructuredMacro(node) {
+		return storageMacro{}, false
+	}
+	macro, ok := parseStructuredMacro(node.Raw)
+	if !ok || macro.BodyKind != richTextBody {
+		return storageMacro{}, false
+	}
+	if _, supported := admonitionKinds[macro.Name]; !supported {
+		return storageMacro{}, false
+	}
+	if _, ok := macroElementAttributes(macro); !ok {
+		return storageMacro{}, false
+	}
+	for _, parameter := range macro.Parameters {
+		if !attributeKey.MatchString(parameter.Name) || strings.HasPrefix(parameter.Name, macroAttributePrefix) || strings.ContainsAny(parameter.Value, "\r\n") {
+			return storageMacro{}, false
+		}
+	}
+	return macro, true
+}
+
+func (r *storageRenderer) admonitionMarkdown(macro storageMacro) (string, error) {
+	body, err := r.renderBlocks(macro.Body)
+	if err != nil {
+		return "", fmt.Errorf("render %s macro body: %w", macro.Name, err)
+	}
+	header := "> [!" + admonitionKinds[macro.Name] + "]"
+	attributes := make([]attribute, 0, len(macro.Parameters))
+	for _, parameter := range macro.Parameters {
+		attributes = append(attributes, attribute{Key: parameter.Name, Value: parameter.Value})
+	}
+	element, _ := macroElementAttributes(macro)
+	if attributes = append(attributes, element...); len(attributes) > 0 {
+		header += formatAttributeBlock(attributes)
+	}
+	return strings.Join(append([]string{header}, quotedLines(body)...), "\n"), nil
+}
+
+// quotedLines prefixes each line of a Markdown body with a blockquote marker.
+func quotedLines(body string) []string {
+	if body == "" {
+		return nil
+	}
+	lines := strings.Split(body, "\n")
+	for index, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			lines[index] = ">"
+			continue
+		}
+		lines[index] = "> " + line
+	}
+	return lines
+}
+
+func admonitionMacroName(label string) (string, bool) {
+	for name, kind := range admonitionKinds {
+		if strings.EqualFold(kind, label) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+var kindAdmonition = ast.NewNodeKind("ConfluxAdmonition")
+
+// admonitionBlock is a blockquote that starts with a [!KIND] alert line. Its
+// children form the rich-text body of the macro named Macro. MacroAttributes are
+// the conflux- attributes of the macro element.
+type admonitionBlock struct {
+	ast.BaseBlock
+	Macro           string
+	Parameters      []attribute
+	MacroAttributes []attribute
+	Err             error
+	// afterMarker is set while the alert line is the last line read. As in a
+	// GitHub alert, where that line is the blockquote's first paragraph, an
+	// unquoted line after it continues the admonition lazily.
+	afterMarker bool
+}
+
+func (n *admonitionBlock) Kind() ast.NodeKind { return kindAdmonition }
+
+func (n *admonitionBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Macro": n.Macro}, nil)
+}
+
+type admonitionParser struct{}
+
+func (admonitionParser) Trigger() []byte { return []byte{'>'} }
+
+func (admonitionParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, _ := reader.PeekLine()
+	match := admonitionLine.FindStringSubmatch(strings.TrimRight(string(line), "\r\n"))
+	if match == nil {
+		return nil, parser.NoChildren
+	}
+	name, ok := admonitionMacroName(match[1])
+	if !ok {
+		return nil, parser.NoChildren
+	}
+	node := &admonitionBlock{Macro: name, afterMarker: true}
+	if match[2] != "" {
+		attributes, err := parseAttributeBlock(match[2])
+		if err == nil {
+			node.Parameters, node.MacroAttributes, err = splitMacroAttributes(attributes)
+		}
+		if err != nil {
+			node.Err = fmt.Errorf("invalid %s admonition attributes: %w", match[1], err)
+		}
+	}
+	for _, attribute := range node.Parameters {
+		if strings.HasPrefix(attribute.Key, ".") && node.Err == nil {
+			node.Err = fmt.Errorf("admonition attributes do not support classes: %q", attribute.Key)
+		}
+	}
+	reader.AdvanceToEOL()
+	return node, parser.HasChildren
+}
+
+// Continue accepts the quoted lines of the body the same way as a blockquote.
+// Lazy continuation lines of a paragraph in the body are left to goldmark,
+// which keeps them in the open paragraph; one directly after the alert line
+// has no paragraph to continue and is taken here instead.
+func (admonitionParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	admonition := node.(*admonitionBlock)
+	afterMarker := admonition.afterMarker
+	admonition.afterMarker = false
+	line, _ := reader.PeekLine()
+	width, position := util.IndentWidth(line, reader.LineOffset())
+	if afterMarker && !util.IsBlank(line) && (width > 3 || !paragraphInterrupt.Match(util.TrimRightSpace(line[position:]))) {
+		reader.Advance(position)
+		return parser.Continue | parser.HasChildren
+	}
+	if width > 3 || position >= len(line) || line[position] != '>' {
+		return parser.Close
+	}
+	position++
+	reader.Advance(position)
+	if position < len(line) && (line[position] == ' ' || line[position] == '\t') {
+		padding := 0
+		if line[position] == '\t' {
+			padding = util.TabWidth(reader.LineOffset()) - 1
+		}
+		reader.AdvanceAndSetPadding(1, padding)
+	}
+	return parser.Continue | parser.HasChildren
+}
+
+func (admonitionParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (admonitionParser) CanInterruptParagraph() bool { return true }
+
+func (admonitionParser) CanAcceptIndentedLine() bool { return false }
+
+func (w *storageWriter) renderAdmonition(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	admonition := node.(*admonitionBlock)
+	if admonition.Err != nil {
+		return ast.WalkStop, admonition.Err
+	}
+	if !entering {
+		_, _ = writer.WriteString(`</ac:rich-text-body></ac:structured-macro>`)
+		return ast.WalkContinue, nil
+	}
+	_, _ = writer.WriteString(`<ac:structured-macro ac:name="` + admonition.Macro + `" ac:schema-version="1"` + macroElementStorage(admonition.MacroAttributes) + `>`)
+	for _, attribute := range admonition.Parameters {
+		_, _ = writer.WriteString(`<ac:parameter ac:name="` + html.EscapeString(attribute.Key) + `">` + html.EscapeString(attribute.Value) + `</ac:parameter>`)
+	}
+	_, _ = writer.WriteString(`<ac:rich-text-body>`)
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,144 @@
+package content
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenderStorageRendersPanelMacroAsAdmonition(t *testing.T) {
+	storage := `<ac:structured-macro ac:name="info" ac:schema-version="1"><ac:parameter ac:name="title">Window</ac:parameter><ac:rich-text-body><p>First.</p><p>Second.</p></ac:rich-text-body></ac:structured-macro>`
+	artifact, err := RenderStorage(testStoragePage(storage))
+	if err != nil {
+		t.Fatalf("RenderStorage returned error: %v", err)
+	}
+	want := "> [!INFO]{title=Window}\n> First.\n>\n> Second.\n"
+	if artifact.Markdown != want {
+		t.Fatalf("Markdown = %q, want %q", artifact.Markdown, want)
+	}
+	if len(artifact.Metadata.PreservedFragments) != 0 {
+		t.Fatalf("panel was preserved opaquely: %#v", artifact.Metadata.PreservedFragments)
+	}
+}
+
+func TestRenderStoragePreservesPanelWithRichParameter(t *testing.T) {
+	storage := `<ac:structured-macro ac:name="panel"><ac:parameter ac:name="title"><ri:page ri:content-title="Home" /></ac:parameter><ac:rich-text-body><p>Body</p></ac:rich-text-body></ac:structured-macro>`
+	artifact, err := RenderStorage(testStoragePage(storage))
+	if err != nil {
+		t.Fatalf("RenderStorage returned error: %v", err)
+	}
+	if artifact.Metadata.PreservedFragments["fragment-0001"] != storage {
+		t.Fatalf("panel with rich parameter was not preserved: %#v", artifact.Metadata.PreservedFragments)
+	}
+}
+
+func TestRenderStoragePreservesPanelWithForeignAttribute(t *testing.T) {
+	storage := `<ac:structured-macro ac:name="info" ri:version-at-save="3"><ac:rich-text-body><p>Body</p></ac:rich-text-body></ac:structured-macro>`
+	artifact, err := RenderStorage(testStoragePage(storage))
+	if err != nil {
+		t.Fatalf("RenderStorage returned error: %v", err)
+	}
+	if strings.Contains(artifact.Markdown, "[!INFO]") || !strings.Contains(artifact.Metadata.PreservedFragments["fragment-0001"], `ri:version-at-save="3"`) {
+		t.Fatalf("panel with foreign attribute was converted:\n%s", artifact.Markdown)
+	}
+}
+
+func TestRenderArtifactConvertsEditedAdmonition(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	markdown := "> [!warning]{title=\"Read \\\"this\\\"\"}\n> Stop the **workers**.\n>\n> - Drain\n> - Restart\n\nAfter.\n"
+	artifact, err := RenderArtifact(markdown, metadata, nil)
+	if err != nil {
+		t.Fatalf("RenderArtifact returned error: %v", err)
+	}
+	want := `<ac:structured-macro ac:name="warning" ac:schema-version="1"><ac:parameter ac:name="title">Read &#34;this&#34;</ac:parameter>` +
+		`<ac:rich-text-body><p>Stop the <strong>workers</strong>.</p><ul><li>Drain</li><li>Restart</li></ul></ac:rich-text-body></ac:structured-macro><p>After.</p>`
+	if artifact.Storage != want {
+		t.Fatalf("storage = %q, want %q", artifact.Storage, want)
+	}
+}
+
+func TestRenderArtifactKeepsLazyContinuationLinesInAdmonition(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	const open = `<ac:structured-macro ac:name="note" ac:schema-version="1"><ac:rich-text-body>`
+	const close = `</ac:rich-text-body></ac:structured-macro>`
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{name: "after alert line", markdown: "> [!NOTE]\nStop the\nworkers.\n", want: open + "<p>Stop the workers.</p>" + close},
+		{name: "after quoted line", markdown: "> [!NOTE]\n> Stop the\nworkers.\n", want: open + "<p>Stop the workers.</p>" + close},
+		{name: "ended by blank quote", markdown: "> [!NOTE]\n>\nAfter.\n", want: open + close + "<p>After.</p>"},
+		{name: "ended by heading", markdown: "> [!NOTE]\n# After\n", want: open + close + "<h1>After</h1>"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			artifact, err := RenderArtifact(test.markdown, metadata, nil)
+			if err != nil {
+				t.Fatalf("RenderArtifact returned error: %v", err)
+			}
+			if artifact.Storage != test.want {
+				t.Fatalf("storage = %s, want %s", artifact.Storage, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderArtifactKeepsUnknownAlertAsBlockquote(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	artifact, err := RenderArtifact("> [!QUESTION]\n", metadata, nil)
+	if err != nil {
+		t.Fatalf("RenderArtifact returned error: %v", err)
+	}
+	if strings.Contains(artifact.Storage, "ac:structured-macro") || !strings.Contains(artifact.Storage, "<blockquote>") {
+		t.Fatalf("unknown alert was converted to a macro: %s", artifact.Storage)
+	}
+}
+
+func TestRenderArtifactRejectsMalformedAdmonitionAttributes(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	_, err := RenderArtifact("> [!NOTE]{title=\"unterminated}\n> Body\n", metadata, nil)
+	if err == nil || !strings.Contains(err.Error(), "admonition attributes") {
+		t.Fatalf("error = %v, want attribute failure", err)
+	}
+}
+
+func TestRenderArtifactRejectsInvalidAdmonitionMacroAttribute(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	_, err := RenderArtifact("> [!NOTE]{conflux-macro.id=1}\n> Body\n", metadata, nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid macro attribute") {
+		t.Fatalf("error = %v, want macro attribute failure", err)
+	}
+}
+
+func TestRenderArtifactRestoresMarkerInsideAdmonition(t *testing.T) {
+	metadata := pushMetadata()
+	markdown := "> [!TIP]\n> <!-- conflux:preserved id=\"fragment-0001\" -->\n"
+	artifact, err := RenderArtifact(markdown, metadata, nil)
+	if err != nil {
+		t.Fatalf("RenderArtifact returned error: %v", err)
+	}
+	if !strings.Contains(artifact.Storage, `<ac:rich-text-body>`+metadata.PreservedFragments["fragment-0001"]+`</ac:rich-text-body>`) {
+		t.Fatalf("fragment was not restored inside the admonition: %s", artifact.Storage)
+	}
+}
+
+func TestAttributeBlockRoundTrip(t *testing.T) {
+	attributes := []attribute{{Key: "title", Value: `Say "hi" \ now`}, {Key: "icon", Value: "false"}, {Key: ".underline"}}
+	formatted := formatAttributeBlock(attributes)
+	if formatted != `{title="Say \"hi\" \\ now" icon=false .underline}` {
+		t.Fatalf("formatted = %s", formatted)
+	}
+	parsed, err := parseAttributeBlock(formatted)
+	if err != nil {
+		t.Fatalf("parseAttributeBlock returned error: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, attributes) {
+		t.Fatalf("parsed = %#v, want %#v", parsed, attributes)
+	}
+}
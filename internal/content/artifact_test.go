@@ -156,6 +156,8 @@ func TestMetadataValidation(t *testing.T) {
 			metadata.PreservedFragments = map[string]string{"fragment 1": "<p>fragment</p>"}
 		},
 		"empty attachment filename": func(metadata *Metadata) { metadata.Attachments[0].Filename = "" },
+		"invalid label":             func(metadata *Metadata) { metadata.Page.Labels = []string{"release notes"} },
+		"duplicate label":           func(metadata *Metadata) { metadata.Page.Labels = []string{"ops", "ops"} },
 	}
 
 	for name, mutate := range tests {
@@ -311,6 +313,7 @@ func validMetadata() Metadata {
 			SpaceKey:    "DOCS",
 			Title:       "Deployment",
 			BaseVersion: 17,
+			Labels:      []string{"ops", "runbook"},
 		},
 		PreservedFragments: map[string]string{
 			"fragment-0001": `<ac:structured-macro ac:name="status" />`,
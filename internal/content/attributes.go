@@ -0,0 +1,113 @@
+package content
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// attribute is one entry of a Conflux attribute block such as
+// {title="Heads up" icon=false}. Keys starting with "." are bare classes.
+type attribute struct {
+	Key   string
+	Value string
+}
+
+var (
+	attributeKey        = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.:-]*$`)
+	bareAttributeValue  = regexp.MustCompile(`^[A-Za-z0-9_.:/#%+-]+$`)
+	attributeClassToken = regexp.MustCompile(`^\.[A-Za-z_][A-Za-z0-9_-]*$`)
+)
+
+func parseAttributeBlock(block string) ([]attribute, error) {
+	block = strings.TrimSpace(block)
+	if len(block) < 2 || block[0] != '{' || block[len(block)-1] != '}' {
+		return nil, fmt.Errorf("attribute block must be enclosed in braces: %q", block)
+	}
+	body := block[1 : len(block)-1]
+	var attributes []attribute
+	for index := 0; index < len(body); {
+		if body[index] == ' ' || body[index] == '\t' {
+			index++
+			continue
+		}
+		end := index
+		for end < len(body) && body[end] != '=' && body[end] != ' ' && body[end] != '\t' {
+			end++
+		}
+		key := body[index:end]
+		if attributeClassToken.MatchString(key) {
+			attributes = append(attributes, attribute{Key: key})
+			index = end
+			continue
+		}
+		if !attributeKey.MatchString(key) || end >= len(body) || body[end] != '=' {
+			return nil, fmt.Errorf("invalid attribute %q", key)
+		}
+		value, next, err := parseAttributeValue(body, end+1)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %q: %w", key, err)
+		}
+		if next < len(body) && body[next] != ' ' && body[next] != '\t' {
+			return nil, fmt.Errorf("attribute %q must be followed by whitespace", key)
+		}
+		attributes = append(attributes, attribute{Key: key, Value: value})
+		index = next
+	}
+	return attributes, nil
+}
+
+func parseAttributeValue(body string, start int) (string, int, error) {
+	if start >= len(body) {
+		return "", start, fmt.Errorf("missing value")
+	}
+	if body[start] != '"' {
+		end := start
+		for end < len(body) && body[end] != ' ' && body[end] != '\t' {
+			end++
+		}
+		if !bareAttributeValue.MatchString(body[start:end]) {
+			return "", start, fmt.Errorf("unquoted value %q contains unsupported characters", body[start:end])
+		}
+		return body[start:end], end, nil
+	}
+	var value strings.Builder
+	for index := start + 1; index < len(body); index++ {
+		switch body[index] {
+		case '\\':
+			if index+1 < len(body) && (body[index+1] == '"' || body[index+1] == '\\') {
+				index++
+			}
+			value.WriteByte(body[index])
+		case '"':
+			return value.String(), index + 1, nil
+		default:
+			value.WriteByte(body[index])
+		}
+	}
+	return "", start, fmt.Errorf("unterminated quoted value")
+}
+
+func formatAttributeBlock(attributes []attribute) string {
+	parts := make([]string, 0, len(attributes))
+	for _, attribute := range attributes {
+		if strings.HasPrefix(attribute.Key, ".") {
+			parts = append(parts, attribute.Key)
+			continue
+		}
+		parts = append(parts, attribute.Key+"="+formatAttributeValue(attribute.Value))
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
+
+func formatAttributeValue(value string) string {
+	if bareAttributeValue.MatchString(value) {
+		return value
+	}
+	return quoteAttributeValue(value)
+}
+
+func quoteAttributeValue(value string) string {
+	escaped := strings.ReplaceAll(value, `\`, `\\`)
+	return `"` + strings.ReplaceAll(escaped, `"`, `\"`) + `"`
+}
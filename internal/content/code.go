@@ -0,0 +1,127 @@
+package content
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	codeLanguage      = regexp.MustCompile(`^[A-Za-z0-9_+#.-]+$`)
+	codeFirstLine     = regexp.MustCompile(`^[0-9]+$`)
+	codeBooleanValues = map[string]bool{"true": true, "false": true}
+)
+
+// checkCodeParameter reports whether a parameter of a code or noformat macro
+// has a Markdown form, and so is written to the fence info string.
+func checkCodeParameter(macro, name, value string) error {
+	switch {
+	case macro == "code" && (name == "language" || name == "title" || name == "theme"):
+		return nil
+	case macro == "code" && (name == "linenumbers" || name == "collapse"), macro == "noformat" && name == "nopanel":
+		if !codeBooleanValues[value] {
+			return fmt.Errorf("%s parameter %q must be true or false", macro, name)
+		}
+		return nil
+	case macro == "code" && name == "firstline":
+		if !codeFirstLine.MatchString(value) {
+			return fmt.Errorf("code parameter firstline must be a line number")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported %s parameter %q", macro, name)
+}
+
+// codeMacroMarkdown renders a code or noformat macro as fenced code. The info
+// string starts with the language, or with noformat for a noformat macro, and
+// continues with the other parameters as key=value pairs.
+func codeMacroMarkdown(node storageNode) (string, bool) {
+	if !isStructuredMacro(node) {
+		return "", false
+	}
+	macro, ok := parseStructuredMacro(node.Raw)
+	if !ok || (macro.Name != "code" && macro.Name != "noformat") || macro.BodyKind != plainTextBody || macro.Body == "" {
+		return "", false
+	}
+	var words []string
+	if macro.Name == "noformat" {
+		words = append(words, "noformat")
+	}
+	var attributes []attribute
+	seen := make(map[string]bool)
+	for _, parameter := range macro.Parameters {
+		if seen[parameter.Name] || checkCodeParameter(macro.Name, parameter.Name, parameter.Value) != nil {
+			return "", false
+		}
+		seen[parameter.Name] = true
+		if parameter.Name == "language" && codeLanguage.MatchString(parameter.Value) && parameter.Value != "noformat" {
+			words = append(words, parameter.Value)
+			continue
+		}
+		attributes = append(attributes, attribute{Key: parameter.Name, Value: parameter.Value})
+	}
+	if len(attributes) > 0 {
+		if len(words) == 0 {
+			// A space keeps the first pair from reading as a language.
+			words = append(words, "")
+		}
+		block := formatAttributeBlock(attributes)
+		words = append(words, block[1:len(block)-1])
+	}
+	return fencedCode(macro.Body, strings.Join(words, " ")), true
+}
+
+// parseCodeInfo reads a fence info string into the macro name and its
+// parameters. The first word is the language unless it is noformat or a
+// key=value pair.
+func parseCodeInfo(info string) (string, []macroParameter, error) {
+	name := "code"
+	var parameters []macroParameter
+	rest := strings.TrimSpace(info)
+	word, remainder := rest, ""
+	if index := strings.IndexAny(rest, " \t"); index >= 0 {
+		word, remainder = rest[:index], rest[index:]
+	}
+	if word != "" && !strings.Contains(word, "=") {
+		if word == "noformat" {
+			name = "noformat"
+		} else {
+			parameters = append(parameters, macroParameter{Name: "language", Value: word})
+		}
+		rest = strings.TrimSpace(remainder)
+	}
+	if rest != "" {
+		attributes, err := parseAttributeBlock("{" + rest + "}")
+		if err != nil {
+			return "", nil, fmt.Errorf("code block info %q: %w", info, err)
+		}
+		for _, attribute := range attributes {
+			parameters = append(parameters, macroParameter{Name: attribute.Key, Value: attribute.Value})
+		}
+	}
+	seen := make(map[string]bool)
+	for _, parameter := range parameters {
+		if seen[parameter.Name] {
+			return "", nil, fmt.Errorf("code block info %q repeats %q", info, parameter.Name)
+		}
+		seen[parameter.Name] = true
+		if err := checkCodeParameter(name, parameter.Name, parameter.Value); err != nil {
+			return "", nil, fmt.Errorf("code block info %q: %w", info, err)
+		}
+	}
+	return name, parameters, nil
+}
+
+// fencedCode writes code in a fence longer than any run of fence characters
+// in it. Tildes are used when the info string holds a backtick, which a
+// backtick fence cannot.
+func fencedCode(code, info string) string {
+	fence := "```"
+	if strings.Contains(info, "`") {
+		fence = "~~~"
+	}
+	for strings.Contains(code, fence) {
+		fence += fence[:1]
+	}
+	return fence + info + "\n" + code + "\n" + fence
+}
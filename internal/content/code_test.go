@@ -0,0 +1,87 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderStorageWritesCodeParametersToInfoString(t *testing.T) {
+	tests := []struct {
+		name    string
+		storage string
+		want    string
+	}{
+		{
+			name:    "title without language",
+			storage: `<ac:structured-macro ac:name="code" ac:schema-version="1"><ac:parameter ac:name="title">Deploy script</ac:parameter><ac:plain-text-body><![CDATA[make deploy]]></ac:plain-text-body></ac:structured-macro>`,
+			want:    "``` title=\"Deploy script\"\nmake deploy\n```\n",
+		},
+		{
+			name:    "language that is not a word",
+			storage: `<ac:structured-macro ac:name="code" ac:schema-version="1"><ac:parameter ac:name="language">noformat</ac:parameter><ac:plain-text-body><![CDATA[text]]></ac:plain-text-body></ac:structured-macro>`,
+			want:    "``` language=noformat\ntext\n```\n",
+		},
+		{
+			name:    "backtick in title",
+			storage: "<ac:structured-macro ac:name=\"code\" ac:schema-version=\"1\"><ac:parameter ac:name=\"title\">Run `make`</ac:parameter><ac:plain-text-body><![CDATA[make]]></ac:plain-text-body></ac:structured-macro>",
+			want:    "~~~ title=\"Run `make`\"\nmake\n~~~\n",
+		},
+		{
+			name:    "noformat without parameters",
+			storage: `<ac:structured-macro ac:name="noformat" ac:schema-version="1"><ac:plain-text-body><![CDATA[plain]]></ac:plain-text-body></ac:structured-macro>`,
+			want:    "```noformat\nplain\n```\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pulled, err := RenderStorage(testStoragePage(test.storage))
+			if err != nil {
+				t.Fatalf("RenderStorage returned error: %v", err)
+			}
+			if pulled.Markdown != test.want {
+				t.Fatalf("Markdown = %q, want %q", pulled.Markdown, test.want)
+			}
+			pushed, err := RenderArtifact(pulled.Markdown, pulled.Metadata, nil)
+			if err != nil {
+				t.Fatalf("RenderArtifact returned error: %v", err)
+			}
+			if pushed.Storage != test.storage {
+				t.Fatalf("storage = %s, want %s", pushed.Storage, test.storage)
+			}
+		})
+	}
+}
+
+func TestRenderStoragePreservesCodeMacroWithInvalidParameterValues(t *testing.T) {
+	storage := `<ac:structured-macro ac:name="code"><ac:parameter ac:name="linenumbers">yes</ac:parameter><ac:plain-text-body><![CDATA[echo hello]]></ac:plain-text-body></ac:structured-macro>`
+	artifact, err := RenderStorage(testStoragePage(storage))
+	if err != nil {
+		t.Fatalf("RenderStorage returned error: %v", err)
+	}
+	if artifact.Metadata.PreservedFragments["fragment-0001"] != storage {
+		t.Fatalf("code macro was not preserved: %q", artifact.Markdown)
+	}
+}
+
+func TestRenderArtifactRejectsInvalidCodeInfo(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	for name, test := range map[string]struct {
+		markdown string
+		want     string
+	}{
+		"unknown parameter": {"```go wrap=true\nx\n```\n", `unsupported code parameter "wrap"`},
+		"bad boolean":       {"```go linenumbers=yes\nx\n```\n", "must be true or false"},
+		"bad first line":    {"```go firstline=ten\nx\n```\n", "firstline must be a line number"},
+		"repeated language": {"```go language=sh\nx\n```\n", `repeats "language"`},
+		"noformat language": {"```noformat language=sh\nx\n```\n", `unsupported noformat parameter "language"`},
+		"not an attribute":  {"```go {.numbered}\nx\n```\n", "code block info"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := RenderArtifact(test.markdown, metadata, nil)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("error = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
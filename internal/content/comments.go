@@ -0,0 +1,126 @@
+package content
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	commentOpenMarker      = regexp.MustCompile(`^<!--\s*conflux:comment\s+ref="([A-Za-z0-9._:-]+)"\s*-->$`)
+	commentCloseMarker     = regexp.MustCompile(`^<!--\s*/conflux:comment\s*-->$`)
+	commentMarkerCandidate = regexp.MustCompile(`^<!--\s*/?conflux:comment\b`)
+	commentRef             = regexp.MustCompile(`^[A-Za-z0-9._:-]+$`)
+	anchoredComment        = regexp.MustCompile(`(?s)<!--\s*conflux:comment\s+ref="([A-Za-z0-9._:-]+)"\s*-->(.*?)<!--\s*/conflux:comment\s*-->`)
+)
+
+const commentCloseMarkerText = "<!-- /conflux:comment -->"
+
+// inlineCommentMarkdown renders the text anchoring a reviewer comment between
+// a pair of comment markers, keeping that text editable. Whitespace at the
+// edges of the anchored text moves outside the markers.
+func (r *storageRenderer) inlineCommentMarkdown(start xml.StartElement, inner string) (string, bool, error) {
+	ref := acAttribute(start, "ref")
+	if len(start.Attr) != 1 || !commentRef.MatchString(ref) {
+		return "", false, nil
+	}
+	markdown, ok, err := r.inlineMarkdown(inner)
+	if err != nil || !ok {
+		return "", ok, err
+	}
+	if markdown == "" || strings.Contains(markdown, "\n") {
+		return "", false, nil
+	}
+	var leading, trailing string
+	if strings.IndexFunc(inner, unicode.IsSpace) == 0 {
+		leading = " "
+	}
+	if strings.TrimRightFunc(inner, unicode.IsSpace) != inner {
+		trailing = " "
+	}
+	if !slices.Contains(r.metadata.InlineComments, ref) {
+		r.metadata.InlineComments = append(r.metadata.InlineComments, ref)
+	}
+	return leading + `<!-- conflux:comment ref="` + ref + `" -->` + markdown + commentCloseMarkerText + trailing, true, nil
+}
+
+// inlineCommentWarnings reports the pulled comments whose anchored text no
+// longer appears in the Markdown. Confluence keeps such comments but can no
+// longer show them beside the text.
+func inlineCommentWarnings(markdown string, refs []string) []string {
+	anchored := make(map[string]bool)
+	for _, match := range anchoredComment.FindAllStringSubmatch(markdownOutsideCode(markdown), -1) {
+		if strings.TrimSpace(match[2]) != "" {
+			anchored[match[1]] = true
+		}
+	}
+	var warnings []string
+	for _, ref := range refs {
+		if !anchored[ref] {
+			warnings = append(warnings, fmt.Sprintf("inline comment %q no longer anchors any text and will be detached", ref))
+		}
+	}
+	sort.Strings(warnings)
+	return warnings
+}
+
+func isCommentMarker(node ast.Node, source []byte) bool {
+	raw, ok := node.(*ast.RawHTML)
+	return ok && commentMarkerCandidate.Match(rawHTMLValue(raw, source))
+}
+
+// renderCommentMarker writes the ac:inline-comment-marker for a pair of
+// comment markers. Both markers must sit in the same run of text so the
+// storage element nests correctly. A pair left with no text between them is
+// dropped.
+func (w *storageWriter) renderCommentMarker(writer util.BufWriter, source []byte, node ast.Node) error {
+	value := rawHTMLValue(node.(*ast.RawHTML), source)
+	if commentCloseMarker.Match(value) {
+		if !w.comment {
+			return fmt.Errorf("inline comment closing marker has no opening marker before it")
+		}
+		if !w.emptyComment {
+			_, _ = writer.WriteString("</ac:inline-comment-marker>")
+		}
+		w.comment = false
+		return nil
+	}
+	match := commentOpenMarker.FindSubmatch(value)
+	if match == nil {
+		return fmt.Errorf("invalid inline comment marker %q", value)
+	}
+	empty := true
+	closed := false
+	for sibling := node.NextSibling(); sibling != nil && !closed; sibling = sibling.NextSibling() {
+		switch {
+		case isCommentMarker(sibling, source):
+			if !commentCloseMarker.Match(rawHTMLValue(sibling.(*ast.RawHTML), source)) {
+				return fmt.Errorf("inline comment markers must not overlap")
+			}
+			closed = true
+		case !isBlankText(sibling, source):
+			empty = false
+		}
+	}
+	if !closed {
+		return fmt.Errorf("inline comment %q must close within the same run of text", match[1])
+	}
+	w.comment, w.emptyComment = true, empty
+	if !empty {
+		_, _ = writer.WriteString(`<ac:inline-comment-marker ac:ref="` + html.EscapeString(string(match[1])) + `">`)
+	}
+	return nil
+}
+
+func isBlankText(node ast.Node, source []byte) bool {
+	text, ok := node.(*ast.Text)
+	return ok && strings.TrimSpace(string(text.Segment.Value(source))) == ""
+}
@@ -0,0 +1,73 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+const commentParagraphFixture = `<p>Please <ac:inline-comment-marker ac:ref="c-1">review <em>this</em> step</ac:inline-comment-marker> today.</p>`
+
+func TestRenderStorageKeepsCommentedParagraphsEditable(t *testing.T) {
+	pulled, err := RenderStorage(testStoragePage(commentParagraphFixture))
+	if err != nil {
+		t.Fatalf("RenderStorage returned error: %v", err)
+	}
+	want := `Please <!-- conflux:comment ref="c-1" -->review *this* step<!-- /conflux:comment --> today.` + "\n"
+	if pulled.Markdown != want {
+		t.Fatalf("Markdown = %q, want %q", pulled.Markdown, want)
+	}
+	if len(pulled.Metadata.PreservedFragments) != 0 || len(pulled.Metadata.InlineComments) != 1 {
+		t.Fatalf("metadata = %#v", pulled.Metadata)
+	}
+
+	edited := strings.Replace(pulled.Markdown, "review", "double-check", 1)
+	pushed, err := RenderArtifact(edited, pulled.Metadata, nil)
+	if err != nil {
+		t.Fatalf("RenderArtifact returned error: %v", err)
+	}
+	wantStorage := `<p>Please <ac:inline-comment-marker ac:ref="c-1">double-check <em>this</em> step</ac:inline-comment-marker> today.</p>`
+	if pushed.Storage != wantStorage || len(pushed.Warnings) != 0 {
+		t.Fatalf("storage = %s, warnings = %v, want %s", pushed.Storage, pushed.Warnings, wantStorage)
+	}
+}
+
+func TestRenderArtifactWarnsWhenCommentedTextIsRemoved(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	metadata.InlineComments = []string{"c-1"}
+	for name, markdown := range map[string]string{
+		"markers kept":    "Please <!-- conflux:comment ref=\"c-1\" --><!-- /conflux:comment --> today.\n",
+		"markers removed": "Please come today.\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			pushed, err := RenderArtifact(markdown, metadata, nil)
+			if err != nil {
+				t.Fatalf("RenderArtifact returned error: %v", err)
+			}
+			if strings.Contains(pushed.Storage, "inline-comment-marker") {
+				t.Fatalf("empty comment anchor was written: %s", pushed.Storage)
+			}
+			if len(pushed.Warnings) != 1 || !strings.Contains(pushed.Warnings[0], `"c-1"`) {
+				t.Fatalf("warnings = %v", pushed.Warnings)
+			}
+		})
+	}
+}
+
+func TestRenderArtifactRejectsMisplacedCommentMarkers(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	for name, markdown := range map[string]string{
+		"unclosed":        "Please <!-- conflux:comment ref=\"c-1\" -->review today.\n",
+		"across emphasis": "Please <!-- conflux:comment ref=\"c-1\" -->*review<!-- /conflux:comment -->* today.\n",
+		"close only":      "Please review<!-- /conflux:comment --> today.\n",
+		"overlapping":     "<!-- conflux:comment ref=\"c-1\" -->a <!-- conflux:comment ref=\"c-2\" -->b<!-- /conflux:comment --> c<!-- /conflux:comment -->\n",
+		"malformed":       "Please <!-- conflux:comment c-1 -->review<!-- /conflux:comment --> today.\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := RenderArtifact(markdown, metadata, nil); err == nil {
+				t.Fatal("RenderArtifact unexpectedly succeeded")
+			}
+		})
+	}
+}
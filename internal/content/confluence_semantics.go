@@ -1,247 +1,188 @@
 package content
 
 import (
-	"encoding/xml"
 	"fmt"
 	"html"
-	"io"
 	"regexp"
-	"strconv"
 	"strings"
-
-	htmldoc "github.com/JohannesKaufmann/html-to-markdown/v2"
 )
 
+// jiraMacro is a Jira macro that has a Markdown form: a single issue shown
+// inline, as a card, or embedded, or a table of the issues matching a JQL
+// query. Options holds the table's other parameters in storage order.
 type jiraMacro struct {
 	Key      string
 	Server   string
 	ServerID string
+	Display  string
+	Query    string
+	Options  []attribute
 }
 
 var (
-	tableDirectivePattern   = regexp.MustCompile(`^<!--\s*conflux:table\s+layout="(default|center|wide|full-width)"(?:\s+width="([0-9]+)")?\s*-->$`)
-	tableDirectiveCandidate = regexp.MustCompile(`^<!--\s*conflux:table\b`)
-	jiraDirectivePattern    = regexp.MustCompile(`^\[([^]]+)]\(jira:([A-Za-z][A-Za-z0-9]+-[0-9]+)\)\{conflux-display=inline\s+jira-server="([^"]+)"\s+jira-server-id="([^"]+)"}$`)
-	tableRowPattern         = regexp.MustCompile(`(?s)<tr\b[^>]*>(.*?)</tr>`)
-	tableCellPattern        = regexp.MustCompile(`(?s)<(th|td)\b[^>]*>(.*?)</(?:th|td)>`)
+	jiraKeyPattern    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]+-[0-9]+$`)
+	jiraMaximumIssues = regexp.MustCompile(`^[0-9]+$`)
+	jiraIssueDisplays = map[string]bool{"inline": true, "card": true, "embed": true}
+	jiraTableOptions  = map[string]bool{"columns": true, "columnIds": true, "maximumIssues": true}
 )
 
+const jiraTableInfo = "jira"
+
 func parseJiraMacro(node storageNode) (jiraMacro, bool) {
-	if node.Name.Space != "urn:conflux:ac" || node.Name.Local != "structured-macro" || !strings.Contains(node.Raw, `ac:name="jira"`) {
+	if !isStructuredMacro(node) {
+		return jiraMacro{}, false
+	}
+	macro, ok := parseStructuredMacro(node.Raw)
+	if !ok || macro.Name != "jira" || macro.BodyKind != "" {
 		return jiraMacro{}, false
 	}
-	decoder := storageNodeDecoder(node.Raw)
 	result := jiraMacro{}
-	var parameter string
-	for {
-		token, err := decoder.Token()
-		if err != nil {
-			return result, err == io.EOF && result.Key != "" && result.Server != "" && result.ServerID != ""
-		}
-		switch value := token.(type) {
-		case xml.StartElement:
-			if value.Name.Space == "urn:conflux:ac" && value.Name.Local == "parameter" {
-				for _, attribute := range value.Attr {
-					if attribute.Name.Space == "urn:conflux:ac" && attribute.Name.Local == "name" {
-						parameter = attribute.Value
-					}
-				}
-			}
-		case xml.EndElement:
-			if value.Name.Space == "urn:conflux:ac" && value.Name.Local == "parameter" {
-				parameter = ""
-			}
-		case xml.CharData:
-			switch parameter {
-			case "key":
-				result.Key += string(value)
-			case "server":
-				result.Server += string(value)
-			case "serverId":
-				result.ServerID += string(value)
+	appearance := ""
+	seen := make(map[string]bool, len(macro.Parameters))
+	for _, parameter := range macro.Parameters {
+		if seen[parameter.Name] {
+			return jiraMacro{}, false
+		}
+		seen[parameter.Name] = true
+		switch parameter.Name {
+		case "key":
+			result.Key = parameter.Value
+		case "server":
+			result.Server = parameter.Value
+		case "serverId":
+			result.ServerID = parameter.Value
+		case "appearance":
+			appearance = parameter.Value
+		case "jqlQuery":
+			result.Query = parameter.Value
+		default:
+			if checkJiraTableOption(parameter.Name, parameter.Value) != nil {
+				return jiraMacro{}, false
 			}
+			result.Options = append(result.Options, attribute{Key: parameter.Name, Value: parameter.Value})
 		}
 	}
+	if result.Server == "" || result.ServerID == "" {
+		return jiraMacro{}, false
+	}
+	switch {
+	case result.Key != "" && result.Query == "" && len(result.Options) == 0 && (appearance == "card" || appearance == "embed"):
+		result.Display = appearance
+	case result.Key != "" && result.Query == "" && len(result.Options) == 0 && !seen["appearance"]:
+		result.Display = "inline"
+	case result.Key == "" && strings.TrimSpace(result.Query) != "" && !seen["appearance"]:
+		result.Display = "table"
+	default:
+		return jiraMacro{}, false
+	}
+	if result.Display != "table" && !jiraKeyPattern.MatchString(result.Key) {
+		return jiraMacro{}, false
+	}
+	return result, true
 }
 
-func jiraMarkdown(jira jiraMacro) string {
-	return fmt.Sprintf(`[%s](jira:%s){conflux-display=inline jira-server="%s" jira-server-id="%s"}`,
-		jira.Key, jira.Key, jira.Server, jira.ServerID)
-}
-
-func jiraStorage(line string) (string, bool) {
-	match := jiraDirectivePattern.FindStringSubmatch(line)
-	if match == nil {
-		return "", false
+func checkJiraTableOption(name, value string) error {
+	if !jiraTableOptions[name] {
+		return fmt.Errorf("unsupported Jira table parameter %q", name)
 	}
-	return `<ac:structured-macro ac:name="jira" ac:schema-version="1">` +
-		`<ac:parameter ac:name="key">` + html.EscapeString(match[2]) + `</ac:parameter>` +
-		`<ac:parameter ac:name="serverId">` + html.EscapeString(match[4]) + `</ac:parameter>` +
-		`<ac:parameter ac:name="server">` + html.EscapeString(match[3]) + `</ac:parameter>` +
-		`</ac:structured-macro>`, true
+	if name == "maximumIssues" && !jiraMaximumIssues.MatchString(value) {
+		return fmt.Errorf("jira table parameter maximumIssues must be a number")
+	}
+	return nil
 }
 
-func tableStorageOptions(raw string) (string, int) {
-	decoder := storageNodeDecoder(raw)
-	for {
-		token, err := decoder.Token()
-		if err != nil {
-			return "default", 0
-		}
-		start, ok := token.(xml.StartElement)
-		if !ok || start.Name.Local != "table" {
-			continue
-		}
-		layout := "default"
-		width := 0
-		for _, attribute := range start.Attr {
-			switch attribute.Name.Local {
-			case "data-layout":
-				layout = attribute.Value
-			case "data-table-width":
-				width, _ = strconv.Atoi(attribute.Value)
-			}
-		}
-		return layout, width
+// jiraMarkdown renders a single issue as a jira: link and a table as a fence
+// whose info string is jira and an attribute block, and whose body is the JQL
+// query.
+func jiraMarkdown(jira jiraMacro) string {
+	if jira.Display == "table" {
+		attributes := append([]attribute{{Key: "jira-server", Value: jira.Server}, {Key: "jira-server-id", Value: jira.ServerID}}, jira.Options...)
+		return fencedCode(jira.Query, jiraTableInfo+" "+formatAttributeBlock(attributes))
 	}
+	return fmt.Sprintf(`[%s](jira:%s){conflux-display=%s jira-server=%s jira-server-id=%s}`,
+		jira.Key, jira.Key, jira.Display, quoteAttributeValue(jira.Server), quoteAttributeValue(jira.ServerID))
 }
 
-func tableDirective(layout string, width int) string {
-	if width > 0 {
-		return fmt.Sprintf(`<!-- conflux:table layout="%s" width="%d" -->`, layout, width)
+// jiraStorage renders a jira: link and its attribute block as a Jira macro
+// showing one issue.
+func jiraStorage(destination string, attributes []attribute) (string, error) {
+	key := strings.TrimPrefix(destination, "jira:")
+	if !jiraKeyPattern.MatchString(key) {
+		return "", fmt.Errorf("invalid Jira issue key %q", key)
+	}
+	var server, serverID string
+	display := ""
+	for _, attribute := range attributes {
+		switch attribute.Key {
+		case "conflux-display":
+			display = attribute.Value
+		case "jira-server":
+			server = attribute.Value
+		case "jira-server-id":
+			serverID = attribute.Value
+		default:
+			return "", fmt.Errorf("unsupported Jira link attribute %q", attribute.Key)
+		}
+	}
+	if !jiraIssueDisplays[display] || server == "" || serverID == "" {
+		return "", fmt.Errorf("jira link %s requires conflux-display=inline, card, or embed, and jira-server and jira-server-id attributes", key)
+	}
+	appearance := ""
+	if display != "inline" {
+		appearance = `<ac:parameter ac:name="appearance">` + display + `</ac:parameter>`
 	}
-	return fmt.Sprintf(`<!-- conflux:table layout="%s" -->`, layout)
+	return `<ac:structured-macro ac:name="jira" ac:schema-version="1">` +
+		`<ac:parameter ac:name="key">` + html.EscapeString(key) + `</ac:parameter>` +
+		`<ac:parameter ac:name="serverId">` + html.EscapeString(serverID) + `</ac:parameter>` +
+		`<ac:parameter ac:name="server">` + html.EscapeString(server) + `</ac:parameter>` +
+		appearance + `</ac:structured-macro>`, nil
 }
 
-func storageTableMarkdown(raw string) (string, error) {
-	rows := tableRowPattern.FindAllStringSubmatch(raw, -1)
-	if len(rows) == 0 {
-		return "", fmt.Errorf("confluence table has no rows")
-	}
-	var markdown strings.Builder
-	columnCount := 0
-	for rowIndex, row := range rows {
-		cells := tableCellPattern.FindAllStringSubmatch(row[1], -1)
-		if rowIndex == 0 {
-			columnCount = len(cells)
-		}
-		if len(cells) == 0 || len(cells) != columnCount {
-			return "", fmt.Errorf("confluence table row %d has inconsistent cells", rowIndex+1)
-		}
-		markdown.WriteString("|")
-		for _, cell := range cells {
-			value, err := htmldoc.ConvertString(cell[2])
-			if err != nil {
-				return "", fmt.Errorf("convert Confluence table cell: %w", err)
-			}
-			value = strings.ReplaceAll(strings.TrimSpace(value), "|", `\|`)
-			value = strings.Join(strings.Fields(value), " ")
-			markdown.WriteString(" " + value + " |")
-		}
-		markdown.WriteByte('\n')
-		if rowIndex == 0 {
-			markdown.WriteString("|")
-			for range cells {
-				markdown.WriteString(" --- |")
-			}
-			markdown.WriteByte('\n')
-		}
-	}
-	return markdown.String(), nil
+// isJiraTableInfo reports whether a fence info string is jira followed by an
+// attribute block, which makes the fence a Jira issue table. A jira fence
+// without one is code in that language.
+func isJiraTableInfo(info string) bool {
+	word, rest, _ := strings.Cut(strings.TrimSpace(info), " ")
+	rest = strings.TrimSpace(rest)
+	return word == jiraTableInfo && strings.HasPrefix(rest, "{") && strings.HasSuffix(rest, "}")
 }
 
-func markdownTableToStorage(lines []string, start int, layout, widthValue string, references *[]string) (string, int, error) {
-	if start+1 >= len(lines) {
-		return "", start, fmt.Errorf("table directive must be followed by a Markdown table")
-	}
-	header := splitTableRow(lines[start])
-	delimiter := splitTableRow(lines[start+1])
-	if len(header) == 0 || len(header) != len(delimiter) || !isTableDelimiter(delimiter) {
-		return "", start, fmt.Errorf("table directive must be followed immediately by a valid Markdown table")
-	}
-	width := 0
-	if widthValue != "" {
-		var err error
-		width, err = strconv.Atoi(widthValue)
-		if err != nil {
-			return "", start, fmt.Errorf("invalid table width %q: %w", widthValue, err)
-		}
-		if width < 1 {
-			return "", start, fmt.Errorf("table width must be positive")
-		}
+// jiraTableStorage renders a jira fence as a Jira macro listing the issues
+// that match the fenced JQL query. The server and the other parameters follow
+// the info string order, and the query comes last.
+func jiraTableStorage(info, query string) (string, error) {
+	rest := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(info), jiraTableInfo))
+	if strings.TrimSpace(query) == "" {
+		return "", fmt.Errorf("jira table has no JQL query")
+	}
+	attributes, err := parseAttributeBlock(rest)
+	if err != nil {
+		return "", fmt.Errorf("jira table info %q: %w", info, err)
 	}
-
 	var storage strings.Builder
-	storage.WriteString(`<table data-layout="` + layout + `"`)
-	if width > 0 {
-		storage.WriteString(` data-table-width="` + strconv.Itoa(width) + `"`)
-	}
-	storage.WriteString(`><tbody><tr>`)
-	for _, cell := range header {
-		storage.WriteString("<th><p>" + convertInline(cell, references) + "</p></th>")
-	}
-	storage.WriteString("</tr>")
-	last := start + 1
-	for index := start + 2; index < len(lines); index++ {
-		cells := splitTableRow(lines[index])
-		if len(cells) == 0 {
-			break
-		}
-		if len(cells) != len(header) {
-			return "", index, fmt.Errorf("table data row %d has %d cells; expected %d", index-start-1, len(cells), len(header))
-		}
-		storage.WriteString("<tr>")
-		for _, cell := range cells {
-			storage.WriteString("<td><p>" + convertInline(cell, references) + "</p></td>")
-		}
-		storage.WriteString("</tr>")
-		last = index
-	}
-	storage.WriteString("</tbody></table>")
-	return storage.String(), last, nil
-}
-
-func splitTableRow(line string) []string {
-	trimmed := strings.TrimSpace(line)
-	if len(trimmed) < 2 || trimmed[0] != '|' || trimmed[len(trimmed)-1] != '|' {
-		return nil
-	}
-	var result []string
-	var cell strings.Builder
-	escaped := false
-	for _, character := range trimmed[1 : len(trimmed)-1] {
-		if escaped {
-			if character != '|' && character != '\\' {
-				cell.WriteByte('\\')
+	storage.WriteString(`<ac:structured-macro ac:name="jira" ac:schema-version="1">`)
+	seen := make(map[string]bool, len(attributes))
+	for _, attribute := range attributes {
+		if seen[attribute.Key] {
+			return "", fmt.Errorf("jira table info %q repeats %q", info, attribute.Key)
+		}
+		seen[attribute.Key] = true
+		name := attribute.Key
+		switch name {
+		case "jira-server":
+			name = "server"
+		case "jira-server-id":
+			name = "serverId"
+		default:
+			if err := checkJiraTableOption(name, attribute.Value); err != nil {
+				return "", fmt.Errorf("jira table info %q: %w", info, err)
 			}
-			cell.WriteRune(character)
-			escaped = false
-			continue
-		}
-		if character == '\\' {
-			escaped = true
-			continue
 		}
-		if character == '|' {
-			result = append(result, strings.TrimSpace(cell.String()))
-			cell.Reset()
-			continue
-		}
-		cell.WriteRune(character)
-	}
-	if escaped {
-		cell.WriteByte('\\')
+		storage.WriteString(`<ac:parameter ac:name="` + name + `">` + html.EscapeString(attribute.Value) + `</ac:parameter>`)
 	}
-	result = append(result, strings.TrimSpace(cell.String()))
-	return result
-}
-
-func isTableDelimiter(cells []string) bool {
-	for _, cell := range cells {
-		cell = strings.Trim(cell, ":")
-		if len(cell) < 3 || strings.Trim(cell, "-") != "" {
-			return false
-		}
+	if !seen["jira-server"] || !seen["jira-server-id"] {
+		return "", fmt.Errorf("jira table requires jira-server and jira-server-id attributes")
 	}
-	return true
+	storage.WriteString(`<ac:parameter ac:name="jqlQuery">` + html.EscapeString(query) + `</ac:parameter></ac:structured-macro>`)
+	return storage.String(), nil
 }
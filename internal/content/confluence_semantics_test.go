@@ -1,6 +1,7 @@
 package content
 
 import (
+	"slices"
 	"strings"
 	"testing"
 )
@@ -38,6 +39,94 @@ func TestRenderArtifactRestoresTenantJiraInlineMacro(t *testing.T) {
 	}
 }
 
+func TestRenderArtifactWritesJiraAppearancesAndTables(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{
+			name:     "card",
+			markdown: `[PSS-1](jira:PSS-1){conflux-display=card jira-server="Jira" jira-server-id="abc"}`,
+			want:     `<ac:structured-macro ac:name="jira" ac:schema-version="1"><ac:parameter ac:name="key">PSS-1</ac:parameter><ac:parameter ac:name="serverId">abc</ac:parameter><ac:parameter ac:name="server">Jira</ac:parameter><ac:parameter ac:name="appearance">card</ac:parameter></ac:structured-macro>`,
+		},
+		{
+			name:     "code in the jira language",
+			markdown: "```jira\nh1. Release notes\n```",
+			want:     `<ac:structured-macro ac:name="code" ac:schema-version="1"><ac:parameter ac:name="language">jira</ac:parameter><ac:plain-text-body><![CDATA[h1. Release notes]]></ac:plain-text-body></ac:structured-macro>`,
+		},
+		{
+			name:     "table",
+			markdown: "```jira {jira-server=Jira jira-server-id=abc maximumIssues=5}\nproject = OPS AND labels = \"on-call\" & more\n```",
+			want:     `<ac:structured-macro ac:name="jira" ac:schema-version="1"><ac:parameter ac:name="server">Jira</ac:parameter><ac:parameter ac:name="serverId">abc</ac:parameter><ac:parameter ac:name="maximumIssues">5</ac:parameter><ac:parameter ac:name="jqlQuery">project = OPS AND labels = &#34;on-call&#34; &amp; more</ac:parameter></ac:structured-macro>`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			artifact, err := RenderArtifact(test.markdown+"\n", metadata, nil)
+			if err != nil {
+				t.Fatalf("RenderArtifact returned error: %v", err)
+			}
+			if artifact.Storage != test.want {
+				t.Fatalf("storage = %s, want %s", artifact.Storage, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderArtifactRejectsInvalidJiraForms(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	tests := map[string]struct {
+		markdown string
+		want     string
+	}{
+		"unknown display":  {`[PSS-1](jira:PSS-1){conflux-display=list jira-server=Jira jira-server-id=abc}`, "requires conflux-display"},
+		"table no server":  {"```jira {maximumIssues=5}\nproject = OPS\n```", "requires jira-server"},
+		"table no query":   {"```jira {jira-server=Jira jira-server-id=abc}\n\n```", "no JQL query"},
+		"table bad limit":  {"```jira {jira-server=Jira jira-server-id=abc maximumIssues=all}\nproject = OPS\n```", "must be a number"},
+		"table bad option": {"```jira {jira-server=Jira jira-server-id=abc title=Bugs}\nproject = OPS\n```", `unsupported Jira table parameter "title"`},
+		"table repeats":    {"```jira {jira-server=Jira jira-server-id=abc jira-server=Other}\nproject = OPS\n```", "repeats"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := RenderArtifact(test.markdown+"\n", metadata, nil)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("RenderArtifact error = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderStoragePreservesJiraMacrosWithoutMarkdownForm(t *testing.T) {
+	for _, storage := range []string{
+		`<ac:structured-macro ac:name="jira"><ac:parameter ac:name="key">PSS-1</ac:parameter><ac:parameter ac:name="server">Jira</ac:parameter><ac:parameter ac:name="serverId">abc</ac:parameter><ac:parameter ac:name="showSummary">false</ac:parameter></ac:structured-macro>`,
+		`<ac:structured-macro ac:name="jira"><ac:parameter ac:name="key">PSS-1</ac:parameter><ac:parameter ac:name="server">Jira</ac:parameter><ac:parameter ac:name="serverId">abc</ac:parameter><ac:parameter ac:name="appearance">list</ac:parameter></ac:structured-macro>`,
+		`<ac:structured-macro ac:name="jira"><ac:parameter ac:name="jqlQuery">project = OPS</ac:parameter><ac:parameter ac:name="server">Jira</ac:parameter></ac:structured-macro>`,
+		`<ac:structured-macro ac:name="jira"><ac:parameter ac:name="key">PSS-1</ac:parameter><ac:parameter ac:name="jqlQuery">project = OPS</ac:parameter><ac:parameter ac:name="server">Jira</ac:parameter><ac:parameter ac:name="serverId">abc</ac:parameter></ac:structured-macro>`,
+	} {
+		artifact, err := RenderStorage(testStoragePage(storage))
+		if err != nil {
+			t.Fatalf("RenderStorage returned error: %v", err)
+		}
+		if artifact.Metadata.PreservedFragments["fragment-0001"] != storage {
+			t.Fatalf("Jira macro was not preserved:\n%s", artifact.Markdown)
+		}
+	}
+}
+
+func TestRenderStorageWritesJiraCodeLanguageAsFenceWord(t *testing.T) {
+	artifact, err := RenderStorage(testStoragePage(`<ac:structured-macro ac:name="code" ac:schema-version="1"><ac:parameter ac:name="language">jira</ac:parameter><ac:plain-text-body><![CDATA[text]]></ac:plain-text-body></ac:structured-macro>`))
+	if err != nil {
+		t.Fatalf("RenderStorage returned error: %v", err)
+	}
+	if want := "```jira\ntext\n```\n"; artifact.Markdown != want {
+		t.Fatalf("Markdown = %q, want %q", artifact.Markdown, want)
+	}
+}
+
 func TestRenderStorageExposesTenantExpandedTable(t *testing.T) {
 	artifact, err := RenderStorage(testStoragePage(tenantExpandedTableFixture))
 	if err != nil {
@@ -118,9 +207,11 @@ func TestRenderArtifactReportsDataRowNumber(t *testing.T) {
 	}
 }
 
-func TestMarkdownTableRejectsOverflowingWidth(t *testing.T) {
-	lines := []string{"| A |", "| --- |"}
-	_, _, err := markdownTableToStorage(lines, 0, "center", "999999999999999999999999999999", nil)
+func TestRenderArtifactRejectsOverflowingTableWidth(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	markdown := "<!-- conflux:table layout=\"center\" width=\"999999999999999999999999999999\" -->\n| A |\n| --- |\n"
+	_, err := RenderArtifact(markdown, metadata, nil)
 	if err == nil || !strings.Contains(err.Error(), "invalid table width") {
 		t.Fatalf("error = %v, want invalid width", err)
 	}
@@ -132,3 +223,20 @@ func TestSplitTableRowPreservesEscapes(t *testing.T) {
 		t.Fatalf("cells = %#v", cells)
 	}
 }
+
+func TestSplitTableRowAcceptsRowsWithoutOuterPipes(t *testing.T) {
+	for line, want := range map[string][]string{
+		"a | b":    {"a", "b"},
+		"| a | b":  {"a", "b"},
+		"a | b |":  {"a", "b"},
+		"|-|:-:|":  {"-", ":-:"},
+		`a \| b`:   nil,
+		"no pipes": nil,
+		"| |":      {""},
+		`a | b \|`: {"a", "b |"},
+	} {
+		if cells := splitTableRow(line); !slices.Equal(cells, want) {
+			t.Errorf("splitTableRow(%q) = %#v, want %#v", line, cells, want)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package content
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// directiveMacro describes a structural macro written as a conflux directive
+// whose options are the macro parameters. Default names the option holding
+// the macro's unnamed parameter, and Body says that the directive wraps
+// Markdown up to a closing directive.
+type directiveMacro struct {
+	SchemaVersion string
+	Default       string
+	Required      bool
+	Body          bool
+	Inline        bool
+}
+
+var directiveMacros = map[string]directiveMacro{
+	"toc":             {SchemaVersion: "1"},
+	"children":        {SchemaVersion: "2"},
+	"excerpt":         {SchemaVersion: "1", Body: true},
+	"excerpt-include": {SchemaVersion: "1", Default: "page", Required: true},
+	"anchor":          {SchemaVersion: "1", Default: "name", Required: true, Inline: true},
+}
+
+var (
+	blockDirectivePattern   = regexp.MustCompile(`^<!--\s*conflux:(toc|children|excerpt-include|excerpt)(\s.*?)?\s*-->$`)
+	blockDirectiveClose     = regexp.MustCompile(`^<!--\s*/conflux:excerpt\s*-->$`)
+	blockDirectiveCandidate = regexp.MustCompile(`^<!--\s*/?conflux:(?:toc|children|excerpt-include|excerpt)\b`)
+	anchorDirective         = regexp.MustCompile(`^<!--\s*conflux:anchor(\s.*?)?\s*-->$`)
+	anchorDirectiveStart    = regexp.MustCompile(`^<!--\s*conflux:anchor\b`)
+)
+
+const excerptCloseDirective = "<!-- /conflux:excerpt -->"
+
+// parseDirectiveMacro reads a macro that has a directive form into its name,
+// its parameters as directive options, and its rich-text body. Parameters must
+// be plain text or a link to a page, which is written as a confluence: target.
+func parseDirectiveMacro(raw string) (string, []attribute, string, bool) {
+	start, inner, ok := splitStorageElement(raw)
+	if !ok || start.Name.Space != "urn:conflux:ac" || start.Name.Local != "structured-macro" {
+		return "", nil, "", false
+	}
+	name := acAttribute(start, "name")
+	macro, known := directiveMacros[name]
+	if version := acAttribute(start, "schema-version"); !known || (version != "" && version != macro.SchemaVersion) {
+		return "", nil, "", false
+	}
+	children, err := tokenizeStorage(inner)
+	if err != nil {
+		return "", nil, "", false
+	}
+	var options []attribute
+	var body string
+	seen := make(map[string]bool)
+	hasBody := false
+	for _, child := range children {
+		if strings.TrimSpace(child.Raw) == "" {
+			continue
+		}
+		childStart, childInner, ok := splitStorageElement(child.Raw)
+		if !ok || child.Name.Space != "urn:conflux:ac" {
+			return "", nil, "", false
+		}
+		switch {
+		case child.Name.Local == richTextBody && macro.Body && !hasBody && len(childStart.Attr) == 0:
+			body, hasBody = childInner, true
+		case child.Name.Local == "parameter" && len(childStart.Attr) == 1:
+			key := acAttribute(childStart, "name")
+			if key == "" {
+				key = macro.Default
+			}
+			value, ok := directiveParameterValue(childInner)
+			if !ok || !attributeKey.MatchString(key) || seen[key] {
+				return "", nil, "", false
+			}
+			seen[key] = true
+			options = append(options, attribute{Key: key, Value: value})
+		default:
+			return "", nil, "", false
+		}
+	}
+	if macro.Required && !seen[macro.Default] {
+		return "", nil, "", false
+	}
+	return name, options, body, true
+}
+
+// directiveParameterValue returns a parameter value as written in a
+// directive. Values that would end the comment or span lines are rejected.
+func directiveParameterValue(raw string) (string, bool) {
+	value, ok := plainTextContent(raw)
+	if ok {
+		if strings.HasPrefix(value, "confluence:") {
+			return "", false
+		}
+	} else {
+		link, isLink := parseStorageLink(strings.TrimSpace(raw))
+		target, isPage := storagePageTarget(link)
+		if !isLink || !isPage || link.BodyKind != "" || link.Anchor != "" {
+			return "", false
+		}
+		value = formatPageTarget(target)
+	}
+	if strings.Contains(value, "--") || strings.ContainsAny(value, "\r\n") {
+		return "", false
+	}
+	return value, true
+}
+
+func formatDirective(name string, options []attribute) string {
+	directive := "<!-- conflux:" + name
+	if len(options) > 0 {
+		block := formatAttributeBlock(options)
+		directive += " " + block[1:len(block)-1]
+	}
+	return directive + " -->"
+}
+
+func parseDirectiveOptions(name, options string) ([]attribute, error) {
+	attributes, err := parseAttributeBlock("{" + options + "}")
+	if err != nil {
+		return nil, fmt.Errorf("%s directive: %w", name, err)
+	}
+	macro := directiveMacros[name]
+	seen := make(map[string]bool, len(attributes))
+	for _, attribute := range attributes {
+		if strings.HasPrefix(attribute.Key, ".") {
+			return nil, fmt.Errorf("%s directive does not support class %q", name, attribute.Key)
+		}
+		if seen[attribute.Key] {
+			return nil, fmt.Errorf("%s directive repeats %q", name, attribute.Key)
+		}
+		seen[attribute.Key] = true
+		if attribute.Key == macro.Default && strings.TrimSpace(attribute.Value) == "" {
+			return nil, fmt.Errorf("%s directive requires a non-empty %s", name, macro.Default)
+		}
+	}
+	if macro.Required && !seen[macro.Default] {
+		return nil, fmt.Errorf("%s directive requires %s", name, macro.Default)
+	}
+	return attributes, nil
+}
+
+// directiveMacroStorage opens the macro for a directive, writing its
+// parameters. The caller closes the macro, after its body if it has one.
+func directiveMacroStorage(name string, options []attribute) (string, error) {
+	macro := directiveMacros[name]
+	var storage strings.Builder
+	storage.WriteString(`<ac:structured-macro ac:name="` + name + `" ac:schema-version="` + macro.SchemaVersion + `">`)
+	for _, option := range options {
+		key := option.Key
+		if key == macro.Default {
+			key = ""
+		}
+		storage.WriteString(`<ac:parameter ac:name="` + html.EscapeString(key) + `">`)
+		if strings.HasPrefix(option.Value, "confluence:") {
+			target, err := parsePageTarget(option.Value)
+			if err != nil {
+				return "", fmt.Errorf("%s directive %s: %w", name, option.Key, err)
+			}
+			storage.WriteString(pageLinkStorage(target) + "</ac:link>")
+		} else {
+			storage.WriteString(html.EscapeString(option.Value))
+		}
+		storage.WriteString(`</ac:parameter>`)
+	}
+	return storage.String(), nil
+}
+
+// directiveMarkdown renders a structural macro as its directive. The body of
+// an excerpt follows as Markdown up to a closing directive.
+func (r *storageRenderer) directiveMarkdown(node storageNode) (string, bool, error) {
+	if !isStructuredMacro(node) {
+		return "", false, nil
+	}
+	name, options, body, ok := parseDirectiveMacro(node.Raw)
+	if !ok || directiveMacros[name].Inline {
+		return "", false, nil
+	}
+	directive := formatDirective(name, options)
+	if !directiveMacros[name].Body {
+		return directive, true, nil
+	}
+	markdown, err := r.renderBlocks(body)
+	if err != nil {
+		return "", false, fmt.Errorf("render %s macro body: %w", name, err)
+	}
+	lines := []string{directive}
+	if markdown != "" {
+		lines = append(lines, "", markdown, "")
+	}
+	return strings.Join(append(lines, excerptCloseDirective), "\n"), true, nil
+}
+
+// anchorMarkdown renders an anchor macro inside a line of text as an inline
+// anchor directive.
+func anchorMarkdown(raw string) (string, bool) {
+	name, options, _, ok := parseDirectiveMacro(raw)
+	if !ok || name != "anchor" || len(options) != 1 {
+		return "", false
+	}
+	return formatDirective(name, options), true
+}
+
+var kindDirectiveBlock = ast.NewNodeKind("ConfluxDirective")
+
+// directiveBlock is a standalone toc, children, excerpt-include, or excerpt
+// directive. An excerpt holds the blocks up to its closing directive.
+// Problems are recorded in Err and reported when rendering.
+type directiveBlock struct {
+	ast.BaseBlock
+	Name    string
+	Options []attribute
+	Closed  bool
+	Err     error
+}
+
+func (n *directiveBlock) Kind() ast.NodeKind { return kindDirectiveBlock }
+
+func (n *directiveBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Name": n.Name}, nil)
+}
+
+type directiveParser struct{}
+
+func (directiveParser) Trigger() []byte { return []byte{'<'} }
+
+func (directiveParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, _ := reader.PeekLine()
+	trimmed := string(bytes.TrimSpace(line))
+	if !blockDirectiveCandidate.MatchString(trimmed) {
+		return nil, parser.NoChildren
+	}
+	reader.AdvanceToEOL()
+	if blockDirectiveClose.MatchString(trimmed) {
+		return &directiveBlock{Err: fmt.Errorf("excerpt closing directive has no opening directive")}, parser.NoChildren
+	}
+	match := blockDirectivePattern.FindStringSubmatch(trimmed)
+	if match == nil {
+		return &directiveBlock{Err: fmt.Errorf("invalid Conflux directive %q", trimmed)}, parser.NoChildren
+	}
+	options, err := parseDirectiveOptions(match[1], match[2])
+	block := &directiveBlock{Name: match[1], Options: options, Err: err}
+	if directiveMacros[block.Name].Body {
+		return block, parser.HasChildren
+	}
+	return block, parser.NoChildren
+}
+
+// Continue ends an excerpt at its closing directive unless a nested excerpt
+// is still open. Other directives are a single line.
+func (directiveParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	block := node.(*directiveBlock)
+	if block.Err != nil || !directiveMacros[block.Name].Body {
+		return parser.Close
+	}
+	line, _ := reader.PeekLine()
+	if blockDirectiveClose.Match(bytes.TrimSpace(line)) && !hasOpenBlockInside(node, pc) {
+		block.Closed = true
+		reader.AdvanceToEOL()
+		return parser.Close
+	}
+	return parser.Continue | parser.HasChildren
+}
+
+func (directiveParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (directiveParser) CanInterruptParagraph() bool { return true }
+
+func (directiveParser) CanAcceptIndentedLine() bool { return false }
+
+func (w *storageWriter) renderDirectiveBlock(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	block := node.(*directiveBlock)
+	if block.Err != nil {
+		return ast.WalkStop, block.Err
+	}
+	body := directiveMacros[block.Name].Body
+	if body && !block.Closed {
+		return ast.WalkStop, fmt.Errorf("%s directive is missing its closing %s directive", block.Name, excerptCloseDirective)
+	}
+	if !entering {
+		if body {
+			_, _ = writer.WriteString("</ac:rich-text-body>")
+		}
+		_, _ = writer.WriteString("</ac:structured-macro>")
+		return ast.WalkContinue, nil
+	}
+	storage, err := directiveMacroStorage(block.Name, block.Options)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	_, _ = writer.WriteString(storage)
+	if body {
+		_, _ = writer.WriteString("<ac:rich-text-body>")
+	}
+	return ast.WalkContinue, nil
+}
+
+// renderAnchorDirective writes an inline anchor directive as an anchor macro.
+func (w *storageWriter) renderAnchorDirective(writer util.BufWriter, value []byte) error {
+	match := anchorDirective.FindSubmatch(value)
+	if match == nil {
+		return fmt.Errorf("invalid Conflux directive %q", value)
+	}
+	options, err := parseDirectiveOptions("anchor", string(match[1]))
+	if err != nil {
+		return err
+	}
+	if len(options) != 1 {
+		return fmt.Errorf("anchor directive takes only a name")
+	}
+	storage, err := directiveMacroStorage("anchor", options)
+	if err != nil {
+		return err
+	}
+	_, _ = writer.WriteString(storage + "</ac:structured-macro>")
+	return nil
+}
@@ -0,0 +1,98 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderArtifactConvertsStructureDirectives(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{
+			name:     "toc",
+			markdown: "<!-- conflux:toc maxLevel=2 style=none -->\n",
+			want:     `<ac:structured-macro ac:name="toc" ac:schema-version="1"><ac:parameter ac:name="maxLevel">2</ac:parameter><ac:parameter ac:name="style">none</ac:parameter></ac:structured-macro>`,
+		},
+		{
+			name:     "heading anchor and link",
+			markdown: "## <!-- conflux:anchor name=\"setup\" -->Setup\n\nJump to [setup](#setup) or [the notes](#release%20notes).\n",
+			want: `<h2><ac:structured-macro ac:name="anchor" ac:schema-version="1"><ac:parameter ac:name="">setup</ac:parameter></ac:structured-macro>Setup</h2>` +
+				`<p>Jump to <ac:link ac:anchor="setup"></ac:link> or <ac:link ac:anchor="release notes"><ac:plain-text-link-body><![CDATA[the notes]]></ac:plain-text-link-body></ac:link>.</p>`,
+		},
+		{
+			name:     "anchor starting a paragraph",
+			markdown: "<!-- conflux:anchor name=top -->Top of the page.\n",
+			want:     `<p><ac:structured-macro ac:name="anchor" ac:schema-version="1"><ac:parameter ac:name="">top</ac:parameter></ac:structured-macro>Top of the page.</p>`,
+		},
+		{
+			name:     "excerpt",
+			markdown: "<!-- conflux:excerpt -->\nShort *summary*.\n<!-- /conflux:excerpt -->\n\nAfter.\n",
+			want:     `<ac:structured-macro ac:name="excerpt" ac:schema-version="1"><ac:rich-text-body><p>Short <em>summary</em>.</p></ac:rich-text-body></ac:structured-macro><p>After.</p>`,
+		},
+		{
+			name:     "excerpt include",
+			markdown: "<!-- conflux:excerpt-include page=confluence:id:123 -->\n",
+			want:     `<ac:structured-macro ac:name="excerpt-include" ac:schema-version="1"><ac:parameter ac:name=""><ac:link><ri:page ri:content-id="123" /></ac:link></ac:parameter></ac:structured-macro>`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			artifact, err := RenderArtifact(test.markdown, metadata, nil)
+			if err != nil {
+				t.Fatalf("RenderArtifact returned error: %v", err)
+			}
+			if artifact.Storage != test.want {
+				t.Fatalf("storage = %s, want %s", artifact.Storage, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderArtifactRejectsInvalidStructureDirectives(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{name: "unclosed excerpt", markdown: "<!-- conflux:excerpt -->\nText.\n", want: "missing its closing"},
+		{name: "stray close", markdown: "<!-- /conflux:excerpt -->\n", want: "no opening directive"},
+		{name: "repeated option", markdown: "<!-- conflux:toc maxLevel=2 maxLevel=3 -->\n", want: "repeats"},
+		{name: "invalid option", markdown: "<!-- conflux:toc maxLevel -->\n", want: "invalid attribute"},
+		{name: "missing page", markdown: "<!-- conflux:excerpt-include nopanel=true -->\n", want: "requires page"},
+		{name: "invalid page", markdown: "<!-- conflux:excerpt-include page=confluence:id:abc -->\n", want: "invalid Confluence page ID"},
+		{name: "anchor without name", markdown: "Text <!-- conflux:anchor --> here.\n", want: "requires name"},
+		{name: "anchor with other options", markdown: "Text <!-- conflux:anchor name=a extra=b --> here.\n", want: "takes only a name"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := RenderArtifact(test.markdown, metadata, nil)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("RenderArtifact error = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderStoragePreservesStructureMacrosWithoutDirectiveForm(t *testing.T) {
+	for _, storage := range []string{
+		`<ac:structured-macro ac:name="children" ac:schema-version="1"><ac:parameter ac:name="depth">2</ac:parameter></ac:structured-macro>`,
+		`<ac:structured-macro ac:name="toc"><ac:parameter ac:name="exclude">a--b</ac:parameter></ac:structured-macro>`,
+		`<ac:structured-macro ac:name="excerpt-include"><ac:parameter ac:name="nopanel">true</ac:parameter></ac:structured-macro>`,
+		`<ac:structured-macro ac:name="toc"><ac:parameter ac:name="page"><ac:link><ri:user ri:account-id="557058:1" /></ac:link></ac:parameter></ac:structured-macro>`,
+	} {
+		artifact, err := RenderStorage(testStoragePage(storage))
+		if err != nil {
+			t.Fatalf("RenderStorage returned error: %v", err)
+		}
+		if artifact.Metadata.PreservedFragments["fragment-0001"] != storage {
+			t.Fatalf("macro was not preserved:\n%s", artifact.Markdown)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package content
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// legacyEmoticons are the emoticons Confluence stores by name alone. Their
+// names take precedence over emoji shortnames.
+var legacyEmoticons = map[string]bool{
+	"smile": true, "sad": true, "cheeky": true, "laugh": true, "wink": true,
+	"thumbs-up": true, "thumbs-down": true, "information": true, "tick": true, "cross": true,
+	"warning": true, "plus": true, "minus": true, "question": true, "light-on": true,
+	"light-off": true, "yellow-star": true, "red-star": true, "green-star": true, "blue-star": true,
+}
+
+// emoji are the Unicode emoji written as :shortname:. Confluence stores them
+// as emoticons carrying the shortname, the code points, and the character.
+var emoji = map[string]string{
+	"white_check_mark": "✅", "heavy_check_mark": "✔️", "x": "❌", "negative_squared_cross_mark": "❎",
+	"exclamation": "❗", "grey_exclamation": "❕", "bangbang": "‼️", "grey_question": "❔",
+	"red_circle": "🔴", "orange_circle": "🟠", "yellow_circle": "🟡", "green_circle": "🟢",
+	"large_blue_circle": "🔵", "purple_circle": "🟣", "white_circle": "⚪", "black_circle": "⚫",
+	"rocket": "🚀", "tada": "🎉", "fire": "🔥", "bug": "🐛", "memo": "📝", "calendar": "📆",
+	"hourglass": "⌛", "hourglass_flowing_sand": "⏳", "construction": "🚧", "stop_sign": "🛑",
+	"no_entry": "⛔", "lock": "🔒", "key": "🔑", "bulb": "💡", "star": "⭐", "eyes": "👀",
+	"thumbsup": "👍", "thumbsdown": "👎", "clap": "👏", "pray": "🙏", "raised_hands": "🙌",
+	"100": "💯", "grinning": "😀", "smiley": "😃", "slight_smile": "🙂", "slight_frown": "🙁",
+	"thinking": "🤔", "chart_with_upwards_trend": "📈", "chart_with_downwards_trend": "📉",
+	"link": "🔗", "pushpin": "📌", "point_right": "👉", "arrow_right": "➡️", "heart": "❤️",
+	"zap": "⚡", "wrench": "🔧", "gear": "⚙️", "mag": "🔍", "bell": "🔔", "package": "📦",
+}
+
+// emoticonSyntax matches :name:. Only known names are emoticons; other text
+// between colons, such as times, stays text.
+var emoticonSyntax = regexp.MustCompile(`^:([a-z0-9_+-]+):`)
+
+// emojiID returns the code points Confluence records for an emoji, without
+// variation selectors.
+func emojiID(character string) string {
+	var points []string
+	for _, point := range character {
+		if point != '\ufe0f' {
+			points = append(points, fmt.Sprintf("%x", point))
+		}
+	}
+	return strings.Join(points, "-")
+}
+
+// emoticonMarkdown renders a legacy emoticon or a known emoji as :name:.
+func emoticonMarkdown(raw string) (string, bool) {
+	start, inner, ok := splitStorageElement(raw)
+	if !ok || strings.TrimSpace(inner) != "" {
+		return "", false
+	}
+	values := make(map[string]string, len(start.Attr))
+	for _, attribute := range start.Attr {
+		if attribute.Name.Space != "urn:conflux:ac" {
+			return "", false
+		}
+		values[attribute.Name.Local] = attribute.Value
+	}
+	name := values["name"]
+	if len(values) == 1 && legacyEmoticons[name] {
+		return ":" + name + ":", true
+	}
+	shortname := strings.Trim(values["emoji-shortname"], ":")
+	character := emoji[shortname]
+	if len(values) != 4 || name != "blue-star" || character == "" || values["emoji-shortname"] != ":"+shortname+":" ||
+		values["emoji-id"] != emojiID(character) || values["emoji-fallback"] != character {
+		return "", false
+	}
+	return ":" + shortname + ":", true
+}
+
+var kindEmoticon = ast.NewNodeKind("ConfluxEmoticon")
+
+// emoticonNode is a :name: emoticon or emoji.
+type emoticonNode struct {
+	ast.BaseInline
+	Name string
+}
+
+func (n *emoticonNode) Kind() ast.NodeKind { return kindEmoticon }
+
+func (n *emoticonNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Name": n.Name}, nil)
+}
+
+type emoticonParser struct{}
+
+func (emoticonParser) Trigger() []byte { return []byte{':'} }
+
+func (emoticonParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	match := emoticonSyntax.FindSubmatch(line)
+	if match == nil || !isEmoticonName(string(match[1])) {
+		return nil
+	}
+	block.Advance(len(match[0]))
+	return &emoticonNode{Name: string(match[1])}
+}
+
+func isEmoticonName(name string) bool {
+	return legacyEmoticons[name] || emoji[name] != ""
+}
+
+func (w *storageWriter) renderEmoticon(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	name := node.(*emoticonNode).Name
+	if legacyEmoticons[name] {
+		_, _ = writer.WriteString(`<ac:emoticon ac:name="` + name + `" />`)
+		return ast.WalkSkipChildren, nil
+	}
+	character := emoji[name]
+	_, _ = writer.WriteString(`<ac:emoticon ac:name="blue-star" ac:emoji-shortname=":` + name + `:" ac:emoji-id="` +
+		emojiID(character) + `" ac:emoji-fallback="` + html.EscapeString(character) + `" />`)
+	return ast.WalkSkipChildren, nil
+}
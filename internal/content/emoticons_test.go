@@ -0,0 +1,50 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderArtifactConvertsEmoticons(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	tests := []struct {
+		markdown string
+		want     string
+	}{
+		{"Done :tick:", `<p>Done <ac:emoticon ac:name="tick" /></p>`},
+		{"Shipped :rocket:", `<p>Shipped <ac:emoticon ac:name="blue-star" ac:emoji-shortname=":rocket:" ac:emoji-id="1f680" ac:emoji-fallback="🚀" /></p>`},
+		{"See :gear:", `<p>See <ac:emoticon ac:name="blue-star" ac:emoji-shortname=":gear:" ac:emoji-id="2699" ac:emoji-fallback="⚙️" /></p>`},
+		{"At 10:30:00 :unknown:", `<p>At 10:30:00 :unknown:</p>`},
+		{`Literal \:tick: and ` + "`:tick:`", `<p>Literal :tick: and <code>:tick:</code></p>`},
+	}
+	for _, test := range tests {
+		t.Run(test.markdown, func(t *testing.T) {
+			artifact, err := RenderArtifact(test.markdown+"\n", metadata, nil)
+			if err != nil {
+				t.Fatalf("RenderArtifact returned error: %v", err)
+			}
+			if artifact.Storage != test.want {
+				t.Fatalf("storage = %s, want %s", artifact.Storage, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderStorageKeepsUnknownEmojiBehindMarkers(t *testing.T) {
+	for name, emoticon := range map[string]string{
+		"unknown shortname": `<ac:emoticon ac:name="blue-star" ac:emoji-shortname=":unicorn:" ac:emoji-id="1f984" ac:emoji-fallback="🦄" />`,
+		"mismatched id":     `<ac:emoticon ac:name="blue-star" ac:emoji-shortname=":rocket:" ac:emoji-id="1f681" ac:emoji-fallback="🚀" />`,
+		"unknown name":      `<ac:emoticon ac:name="heart" />`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			artifact, err := RenderStorage(testStoragePage("<p>Status " + emoticon + "</p>"))
+			if err != nil {
+				t.Fatalf("RenderStorage returned error: %v", err)
+			}
+			if !strings.Contains(artifact.Markdown, `<!-- conflux:inline id="fragment-0001" -->`) || artifact.Metadata.PreservedFragments["fragment-0001"] != emoticon {
+				t.Fatalf("emoticon was not preserved inline:\n%s", artifact.Markdown)
+			}
+		})
+	}
+}
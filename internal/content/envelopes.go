@@ -0,0 +1,125 @@
+package content
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+const (
+	macroCloseMarkerText = "<!-- /conflux:macro -->"
+	emptyRichTextBody    = "<ac:rich-text-body></ac:rich-text-body>"
+)
+
+// parseMacroEnvelope splits a macro that Conflux does not model but that has
+// a rich-text body into that body and the frame around it: the macro with an
+// empty body, which keeps its name, parameters, and attributes.
+func parseMacroEnvelope(node storageNode) (string, string, bool) {
+	if !isStructuredMacro(node) {
+		return "", "", false
+	}
+	macro, ok := parseStructuredMacro(node.Raw)
+	if !ok || macro.BodyKind != richTextBody {
+		return "", "", false
+	}
+	body := "<ac:rich-text-body>" + macro.Body + "</ac:rich-text-body>"
+	index := strings.Index(node.Raw, body)
+	if index < 0 {
+		return "", "", false
+	}
+	return macro.Body, node.Raw[:index] + emptyRichTextBody + node.Raw[index+len(body):], true
+}
+
+// envelopeMarkdown preserves the frame of a macro and renders its body as
+// Markdown between an opening and a closing macro marker.
+func (r *storageRenderer) envelopeMarkdown(body, frame string) (string, error) {
+	marker, err := r.preserveWith(frame, MacroMarker)
+	if err != nil {
+		return "", err
+	}
+	markdown, err := r.renderBlocks(body)
+	if err != nil {
+		return "", fmt.Errorf("render macro body: %w", err)
+	}
+	lines := []string{marker}
+	if markdown != "" {
+		lines = append(lines, "", markdown, "")
+	}
+	lines = append(lines, macroCloseMarkerText)
+	return strings.Join(lines, "\n"), nil
+}
+
+var kindMacroEnvelope = ast.NewNodeKind("ConfluxMacroEnvelope")
+
+// macroEnvelope is the editable body of a preserved macro frame, from an
+// opening macro marker to the matching closing marker.
+type macroEnvelope struct {
+	ast.BaseBlock
+	ID     string
+	Closed bool
+}
+
+func (n *macroEnvelope) Kind() ast.NodeKind { return kindMacroEnvelope }
+
+func (n *macroEnvelope) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"ID": n.ID}, nil)
+}
+
+type macroEnvelopeParser struct{}
+
+func (macroEnvelopeParser) Trigger() []byte { return []byte{'<'} }
+
+func (macroEnvelopeParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, _ := reader.PeekLine()
+	trimmed := bytes.TrimSpace(line)
+	match := macroMarker.FindSubmatch(trimmed)
+	if match == nil || len(match[0]) != len(trimmed) {
+		return nil, parser.NoChildren
+	}
+	reader.AdvanceToEOL()
+	return &macroEnvelope{ID: string(match[1])}, parser.HasChildren
+}
+
+// Continue closes on a closing macro marker unless a nested envelope is still
+// open.
+func (macroEnvelopeParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	line, _ := reader.PeekLine()
+	trimmed := bytes.TrimSpace(line)
+	if match := macroCloseMarker.Find(trimmed); match != nil && len(match) == len(trimmed) && !hasOpenBlockInside(node, pc) {
+		node.(*macroEnvelope).Closed = true
+		reader.AdvanceToEOL()
+		return parser.Close
+	}
+	return parser.Continue | parser.HasChildren
+}
+
+func (macroEnvelopeParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (macroEnvelopeParser) CanInterruptParagraph() bool { return true }
+
+func (macroEnvelopeParser) CanAcceptIndentedLine() bool { return false }
+
+func (w *storageWriter) renderMacroEnvelope(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	envelope := node.(*macroEnvelope)
+	if !envelope.Closed {
+		return ast.WalkStop, fmt.Errorf("macro marker %q is missing its closing %s marker", envelope.ID, macroCloseMarkerText)
+	}
+	frame := w.fragments[envelope.ID]
+	index := strings.Index(frame, emptyRichTextBody)
+	if index < 0 {
+		return ast.WalkStop, fmt.Errorf("preserved fragment %q is not a macro with a rich-text body", envelope.ID)
+	}
+	index += len("<ac:rich-text-body>")
+	if !entering {
+		_, _ = writer.WriteString(frame[index:])
+		return ast.WalkContinue, nil
+	}
+	w.restored[envelope.ID] = true
+	_, _ = writer.WriteString(frame[:index])
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,62 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderArtifactRestoresMacroFrameAroundEditedBody(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{
+		"fragment-0001": `<ac:structured-macro ac:name="panelbox" ac:schema-version="1"><ac:parameter ac:name="name">blue</ac:parameter><ac:rich-text-body></ac:rich-text-body></ac:structured-macro>`,
+		"fragment-0002": `<ac:structured-macro ac:name="column"><ac:rich-text-body></ac:rich-text-body></ac:structured-macro>`,
+	}
+	markdown := "<!-- conflux:macro id=\"fragment-0001\" -->\n\nEdited *body*.\n\n<!-- conflux:macro id=\"fragment-0002\" -->\n- New item\n<!-- /conflux:macro -->\n<!-- /conflux:macro -->\n\nAfter.\n"
+	artifact, err := RenderArtifact(markdown, metadata, nil)
+	if err != nil {
+		t.Fatalf("RenderArtifact returned error: %v", err)
+	}
+	want := `<ac:structured-macro ac:name="panelbox" ac:schema-version="1"><ac:parameter ac:name="name">blue</ac:parameter><ac:rich-text-body>` +
+		`<p>Edited <em>body</em>.</p><ac:structured-macro ac:name="column"><ac:rich-text-body><ul><li>New item</li></ul></ac:rich-text-body></ac:structured-macro>` +
+		`</ac:rich-text-body></ac:structured-macro><p>After.</p>`
+	if artifact.Storage != want {
+		t.Fatalf("storage = %s, want %s", artifact.Storage, want)
+	}
+}
+
+func TestRenderArtifactRejectsInvalidMacroMarkers(t *testing.T) {
+	frame := `<ac:structured-macro ac:name="column"><ac:rich-text-body></ac:rich-text-body></ac:structured-macro>`
+	tests := []struct {
+		name     string
+		fragment string
+		markdown string
+		want     string
+	}{
+		{name: "unclosed", fragment: frame, markdown: "<!-- conflux:macro id=\"fragment-0001\" -->\nBody.\n", want: "missing its closing"},
+		{name: "malformed", fragment: frame, markdown: "<!-- conflux:macro id=fragment-0001 -->\nBody.\n<!-- /conflux:macro -->\n", want: "malformed macro marker"},
+		{name: "not on its own line", fragment: frame, markdown: "Body <!-- conflux:macro id=\"fragment-0001\" -->\n<!-- /conflux:macro -->\n", want: "own line"},
+		{name: "not a frame", fragment: `<ac:structured-macro ac:name="toc" />`, markdown: "<!-- conflux:macro id=\"fragment-0001\" -->\n<!-- /conflux:macro -->\n", want: "not a macro with a rich-text body"},
+		{name: "removed", fragment: frame, markdown: "Body.\n", want: "not referenced"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			metadata := pushMetadata()
+			metadata.PreservedFragments = map[string]string{"fragment-0001": test.fragment}
+			_, err := RenderArtifact(test.markdown, metadata, nil)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("RenderArtifact error = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderStoragePreservesMacrosWithoutRichTextBody(t *testing.T) {
+	storage := `<ac:structured-macro ac:name="widget"><ac:parameter ac:name="url">https://example.com</ac:parameter></ac:structured-macro>`
+	artifact, err := RenderStorage(testStoragePage(storage))
+	if err != nil {
+		t.Fatalf("RenderStorage returned error: %v", err)
+	}
+	if strings.Contains(artifact.Markdown, "conflux:macro") || artifact.Metadata.PreservedFragments["fragment-0001"] != storage {
+		t.Fatalf("macro was not preserved whole:\n%s", artifact.Markdown)
+	}
+}
@@ -0,0 +1,175 @@
+package content
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+const expandMacro = "expand"
+
+var (
+	detailsOpenLine  = regexp.MustCompile(`^<details((?:[ \t]+conflux-[A-Za-z_][A-Za-z0-9_-]*="[^"]*")*)>[ \t]*(?:<summary>(.*)</summary>)?[ \t]*$`)
+	detailsAttribute = regexp.MustCompile(`(conflux-[A-Za-z_][A-Za-z0-9_-]*)="([^"]*)"`)
+	detailsCloseLine = regexp.MustCompile(`^</details>[ \t]*$`)
+	summaryLine      = regexp.MustCompile(`^<summary>(.*)</summary>[ \t]*$`)
+)
+
+// parseExpandMacro reads an expand macro with a rich-text body and at most a
+// title parameter. Its ac: attributes are written on the details tag.
+func parseExpandMacro(node storageNode) (storageMacro, bool) {
+	if !isStructuredMacro(node) {
+		return storageMacro{}, false
+	}
+	macro, ok := parseStructuredMacro(node.Raw)
+	if !ok || macro.Name != expandMacro || macro.BodyKind != richTextBody {
+		return storageMacro{}, false
+	}
+	if _, ok := macroElementAttributes(macro); !ok {
+		return storageMacro{}, false
+	}
+	for _, parameter := range macro.Parameters {
+		if parameter.Name != "title" || strings.ContainsAny(parameter.Value, "\r\n") {
+			return storageMacro{}, false
+		}
+	}
+	return macro, true
+}
+
+// expandMarkdown renders an expand macro as an HTML details block whose body
+// is ordinary Markdown between the summary and the closing tag.
+func (r *storageRenderer) expandMarkdown(macro storageMacro) (string, error) {
+	body, err := r.renderBlocks(macro.Body)
+	if err != nil {
+		return "", fmt.Errorf("render expand macro body: %w", err)
+	}
+	var title *string
+	for _, parameter := range macro.Parameters {
+		title = &parameter.Value
+	}
+	attributes, _ := macroElementAttributes(macro)
+	return detailsMarkdown(title, attributes, body), nil
+}
+
+// detailsMarkdown writes a details block with an optional summary around a
+// Markdown body. The macro element's conflux- attributes go on the details
+// tag.
+func detailsMarkdown(title *string, attributes []attribute, body string) string {
+	opening := "<details"
+	for _, attribute := range attributes {
+		opening += " " + attribute.Key + `="` + html.EscapeString(attribute.Value) + `"`
+	}
+	lines := []string{opening + ">"}
+	if title != nil {
+		lines = append(lines, "<summary>"+html.EscapeString(*title)+"</summary>")
+	}
+	if body != "" {
+		lines = append(lines, "", body, "")
+	}
+	lines = append(lines, "</details>")
+	return strings.Join(lines, "\n")
+}
+
+var kindDetails = ast.NewNodeKind("ConfluxDetails")
+
+// detailsBlock is a <details> block. Its children, up to the matching
+// </details> line, form the rich-text body of an expand macro.
+type detailsBlock struct {
+	ast.BaseBlock
+	Title           string
+	HasTitle        bool
+	MacroAttributes []attribute
+	Closed          bool
+}
+
+func (n *detailsBlock) Kind() ast.NodeKind { return kindDetails }
+
+func (n *detailsBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Title": n.Title}, nil)
+}
+
+type detailsParser struct{}
+
+func (detailsParser) Trigger() []byte { return []byte{'<'} }
+
+func (detailsParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, _ := reader.PeekLine()
+	match := detailsOpenLine.FindSubmatch(bytes.TrimSpace(line))
+	if match == nil {
+		return nil, parser.NoChildren
+	}
+	node := &detailsBlock{}
+	for _, value := range detailsAttribute.FindAllSubmatch(match[1], -1) {
+		node.MacroAttributes = append(node.MacroAttributes, attribute{Key: string(value[1]), Value: html.UnescapeString(string(value[2]))})
+	}
+	if match[2] != nil {
+		node.Title, node.HasTitle = html.UnescapeString(string(match[2])), true
+	}
+	reader.AdvanceToEOL()
+	return node, parser.HasChildren
+}
+
+// Continue takes a summary line directly after the opening tag as the title
+// and closes on a </details> line unless a nested details block is still open.
+func (detailsParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	details := node.(*detailsBlock)
+	line, _ := reader.PeekLine()
+	trimmed := bytes.TrimSpace(line)
+	if match := summaryLine.FindSubmatch(trimmed); match != nil && !details.HasTitle && !details.HasChildren() {
+		details.Title, details.HasTitle = html.UnescapeString(string(match[1])), true
+		reader.AdvanceToEOL()
+		return parser.Continue | parser.NoChildren
+	}
+	if detailsCloseLine.Match(trimmed) && !hasOpenBlockInside(node, pc) {
+		details.Closed = true
+		reader.AdvanceToEOL()
+		return parser.Close
+	}
+	return parser.Continue | parser.HasChildren
+}
+
+func (detailsParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (detailsParser) CanInterruptParagraph() bool { return true }
+
+func (detailsParser) CanAcceptIndentedLine() bool { return false }
+
+// hasOpenBlockInside reports whether a block of the same kind as node is still
+// open inside it, so a closing line belongs to that nested block.
+func hasOpenBlockInside(node ast.Node, pc parser.Context) bool {
+	inside := false
+	for _, block := range pc.OpenedBlocks() {
+		if block.Node == node {
+			inside = true
+			continue
+		}
+		if inside && block.Node.Kind() == node.Kind() {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *storageWriter) renderDetails(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	details := node.(*detailsBlock)
+	if !details.Closed {
+		return ast.WalkStop, fmt.Errorf("details block is missing its closing </details> line")
+	}
+	if !entering {
+		_, _ = writer.WriteString(`</ac:rich-text-body></ac:structured-macro>`)
+		return ast.WalkContinue, nil
+	}
+	_, _ = writer.WriteString(`<ac:structured-macro ac:name="expand" ac:schema-version="1"` + macroElementStorage(details.MacroAttributes) + `>`)
+	if details.HasTitle {
+		_, _ = writer.WriteString(`<ac:parameter ac:name="title">` + html.EscapeString(details.Title) + `</ac:parameter>`)
+	}
+	_, _ = writer.WriteString(`<ac:rich-text-body>`)
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,70 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderArtifactConvertsDetailsToExpandMacros(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{
+			name:     "summary on opening line",
+			markdown: "<details><summary>FAQ</summary>\nShort *answer*.\n</details>\n",
+			want:     `<ac:structured-macro ac:name="expand" ac:schema-version="1"><ac:parameter ac:name="title">FAQ</ac:parameter><ac:rich-text-body><p>Short <em>answer</em>.</p></ac:rich-text-body></ac:structured-macro>`,
+		},
+		{
+			name:     "nested",
+			markdown: "<details>\n<summary>Outer</summary>\n\n<details>\n<summary>Inner</summary>\n\nDeep.\n\n</details>\n\nAfter.\n\n</details>\n",
+			want:     `<ac:structured-macro ac:name="expand" ac:schema-version="1"><ac:parameter ac:name="title">Outer</ac:parameter><ac:rich-text-body><ac:structured-macro ac:name="expand" ac:schema-version="1"><ac:parameter ac:name="title">Inner</ac:parameter><ac:rich-text-body><p>Deep.</p></ac:rich-text-body></ac:structured-macro><p>After.</p></ac:rich-text-body></ac:structured-macro>`,
+		},
+		{
+			name:     "macro attributes",
+			markdown: "<details conflux-macro-id=\"b7c2e0f4\"><summary>FAQ</summary>\nShort.\n</details>\n",
+			want:     `<ac:structured-macro ac:name="expand" ac:schema-version="1" ac:macro-id="b7c2e0f4"><ac:parameter ac:name="title">FAQ</ac:parameter><ac:rich-text-body><p>Short.</p></ac:rich-text-body></ac:structured-macro>`,
+		},
+		{
+			name:     "inside list item",
+			markdown: "- Step\n\n  <details>\n  <summary>Why</summary>\n\n  Because.\n\n  </details>\n",
+			want:     `<ul><li><p>Step</p><ac:structured-macro ac:name="expand" ac:schema-version="1"><ac:parameter ac:name="title">Why</ac:parameter><ac:rich-text-body><p>Because.</p></ac:rich-text-body></ac:structured-macro></li></ul>`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			artifact, err := RenderArtifact(test.markdown, metadata, nil)
+			if err != nil {
+				t.Fatalf("RenderArtifact returned error: %v", err)
+			}
+			if artifact.Storage != test.want {
+				t.Fatalf("storage = %s, want %s", artifact.Storage, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderArtifactRejectsUnclosedDetails(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	if _, err := RenderArtifact("<details>\n<summary>FAQ</summary>\n\nAnswer.\n", metadata, nil); err == nil {
+		t.Fatal("RenderArtifact unexpectedly succeeded")
+	}
+}
+
+func TestRenderStorageWrapsExpandWithUnknownParameters(t *testing.T) {
+	storage := `<ac:structured-macro ac:name="expand"><ac:parameter ac:name="breakoutMode">wide</ac:parameter><ac:rich-text-body><p>Body</p></ac:rich-text-body></ac:structured-macro>`
+	artifact, err := RenderStorage(testStoragePage(storage))
+	if err != nil {
+		t.Fatalf("RenderStorage returned error: %v", err)
+	}
+	if strings.Contains(artifact.Markdown, "<details>") || !strings.Contains(artifact.Markdown, "\nBody\n") {
+		t.Fatalf("expand macro body was not wrapped:\n%s", artifact.Markdown)
+	}
+	if artifact.Metadata.PreservedFragments["fragment-0001"] != strings.Replace(storage, "<p>Body</p>", "", 1) {
+		t.Fatalf("expand macro frame was not preserved: %#v", artifact.Metadata.PreservedFragments)
+	}
+}
@@ -27,12 +27,26 @@ func TestEditableArtifactFidelityFixtures(t *testing.T) {
 		wantOpaqueTableMacro bool
 	}{
 		{name: "plain", wantMarkdown: []string{"# Deployment Guide", "**release**", "1. Build", "> Verify production."}},
-		{name: "attachments", attachments: fixtureAttachmentMetadata(attachmentBody), wantMarkdown: []string{"page.attachments/diagram.png"}, wantPreserved: 2, wantDownloads: 2},
-		{name: "code", wantMarkdown: []string{"```go", `fmt.Println("deploy")`}},
-		{name: "macros", wantMarkdown: []string{"Before.", "Between.", "After."}, wantPreserved: 2},
-		{name: "layout-namespaces", wantPreserved: 3},
-		{name: "tables", wantMarkdown: []string{`conflux:table layout="full-width" width="1200"`, "| Service | Owner |"}, wantPreserved: 1, wantEditableTable: true, wantOpaqueTableMacro: true},
-		{name: "jira", wantMarkdown: []string{`[PSS-3369](jira:PSS-3369){conflux-display=inline`}},
+		{name: "attachments", attachments: fixtureAttachmentMetadata(attachmentBody), wantMarkdown: []string{"![Architecture](page.attachments/diagram.png)", "![diagram.png](page.attachments/diagram.png){width=640}", `![Request flow](page.attachments/diagram.png){align=center layout=center width=600 border=true caption="Figure 1: request flow"}`, "Status badge ![](https://example.com/badge.svg){height=20} in a sentence.", "[runbook.pdf](page.attachments/runbook.pdf){conflux-display=embed}", "Download [the runbook](page.attachments/runbook.pdf) or open [runbook.pdf](page.attachments/runbook.pdf)."}, wantPreserved: 1, wantDownloads: 2},
+		{name: "code", wantMarkdown: []string{"```go\nfmt.Println(\"deploy\")", "```go title=main.go linenumbers=true firstline=10 collapse=false theme=Midnight\nfunc main() {", "```noformat nopanel=true\n2024-03-15 12:00 deploy started\n```"}},
+		{name: "macros", wantMarkdown: []string{"Before.", "Between.\n\n<!-- conflux:toc -->\n\nAfter."}, wantPreserved: 1},
+		{name: "layout-namespaces", wantMarkdown: []string{"<!-- conflux:layout-section type=\"two_equal\" -->\n<!-- conflux:layout-cell -->\n\nLeft\n\n<!-- conflux:layout-cell -->\n\nRight\n\n<!-- /conflux:layout-section -->"}, wantPreserved: 2},
+		{name: "layouts", wantMarkdown: []string{"<!-- conflux:layout-section type=\"single\" breakout-mode=\"wide\" -->\n<!-- conflux:layout-cell -->\n\n# Overview", "<!-- conflux:layout-section type=\"three_with_sidebars\" -->\n<!-- conflux:layout-cell -->\n\n<!-- conflux:layout-cell -->\n\n- Build\n- **Deploy**", "> [!NOTE]\n> Check the dashboard first.\n\n<!-- conflux:layout-cell -->\n\n<!-- conflux:preserved id=\"fragment-0001\" -->\n<!-- conflux:parameters\nmax: 5\n-->\n\n<!-- /conflux:layout-section -->\n\nAfter the layout."}, wantPreserved: 1},
+		{name: "tables", wantMarkdown: []string{`conflux:table layout="full-width" width="1200"`, "| Service | Owner |", `| Ready: <!-- conflux:inline id="fragment-0001" --> |`}, wantPreserved: 1, wantEditableTable: true, wantOpaqueTableMacro: true},
+		{name: "rich-tables", wantMarkdown: []string{"<!-- conflux:table layout=\"default\" header=\"both\" numbered=\"true\" -->\n| Region | {colspan=2} Status |\n| --- | :---: | --- |\n| {rowspan=2} EU | Up<br>since Monday | See [EU Runbook](confluence:EU%20Runbook) |", "<!-- conflux:table layout=\"wide\" -->\n- - Step\n  - Details\n- - Deploy\n  - Run the script.\n\n    - Check logs", "- - {colspan=2}\n    Done.\n\n    Close the **ticket**."}, wantPreserved: 1},
+		{name: "inline-macros", wantMarkdown: []string{"Build {status:green|Done} on {date:2026-10-16} :tick:", "Reviewed :white_check_mark: with one :heavy_check_mark: left.", "Literal \\{status:green|Done}, \\{date:2026-10-16} and \\:tick: stay text at 10:30:00, as does `:tick:`.", "| API | {status:red\\|Blocked} |", "| Web | {status:grey\\|Paused\\|subtle} |", "Draft {status:yellow|In review|subtle} since Monday."}},
+		{name: "macro-envelopes", wantMarkdown: []string{"<!-- conflux:macro id=\"fragment-0001\" -->\n\n<!-- conflux:macro id=\"fragment-0002\" -->\n\nLeft **column**.\n\n<!-- /conflux:macro -->\n\n<!-- conflux:macro id=\"fragment-0003\" -->\n\n- One\n- Two\n\n<!-- conflux:preserved id=\"fragment-0004\" -->\n\n<!-- /conflux:macro -->\n\n<!-- /conflux:macro -->", "- Step\n\n  <!-- conflux:macro id=\"fragment-0005\" -->\n\n  Tabbed content.\n\n  <!-- /conflux:macro -->", "<!-- conflux:macro id=\"fragment-0006\" -->\n<!-- /conflux:macro -->\n\nAfter."}, wantPreserved: 6},
+		{name: "macro-parameters", wantMarkdown: []string{"<!-- conflux:preserved id=\"fragment-0001\" -->\n<!-- conflux:parameters\nmax: 10\ntheme: concise\n-->\n\nOpen issues:", "jqlQuery: project = PSS AND status = \"In Progress\"\nmaximumIssues: 20\n-->", "- Team pages\n\n  <!-- conflux:preserved id=\"fragment-0003\" -->\n  <!-- conflux:parameters\n  root: \" Home \"\n  -->"}, wantPreserved: 3},
+		{name: "structure-macros", wantMarkdown: []string{`<!-- conflux:toc maxLevel=3 exclude="^(Intro|Appendix)$" -->`, "## <!-- conflux:anchor name=setup -->Setup", "See [the setup steps](#setup) or [rollback plan](#rollback%20plan) first.", `<!-- conflux:anchor name="rollback plan" -->Roll back with the script.`, "<!-- conflux:excerpt hidden=true -->\n\nDeploys run **weekly**.\n\n<!-- /conflux:excerpt -->", "<!-- conflux:excerpt-include nopanel=true page=confluence:OPS/Release%20Notes -->", "<!-- conflux:children depth=2 page=confluence:Runbooks -->"}},
+		{name: "formatting", wantMarkdown: []string{"Old plan ~~retired~~, [underlined **note**]{.underline}, H~2~O and 10^3^ requests.", `Status [red text]{color="rgb(255,86,48)"} and [green]{color=#00875a}.`, "First line  \nsecond line  \n<br>after a gap\n\n---\n\n## Release<br>notes\n\n<br>\n\nTrailing break<br>", `Literal 2\^10, a\~b and \\\[x]{.underline} stay text.`}},
+		{name: "jira", wantMarkdown: []string{`[PSS-3369](jira:PSS-3369){conflux-display=inline`, `[PSS-3520](jira:PSS-3520){conflux-display=card jira-server="System Jira"`, `[PSS-3521](jira:PSS-3521){conflux-display=embed`, "```jira {jira-server=\"System Jira\" jira-server-id=4a67abd8-f396-3524-919a-398ffb606bf7 columns=\"key,summary,status\" maximumIssues=20}\nproject = PSS AND sprint in openSprints() AND status != \"Done\"\n```"}},
+		{name: "lists", attachments: fixtureAttachmentMetadata(attachmentBody), wantMarkdown: []string{"- Prepare\n  - Check **dashboards**\n  - Notify on-call\n    1. Page primary", "4. Run the migration.\n\n   It takes about ten minutes.", "   ```bash", "   ![diagram](page.attachments/diagram.png)"}, wantDownloads: 1},
+		{name: "adjacent-lists", wantMarkdown: []string{"- Build\n- Test\n\n* Deploy\n\n- Announce\n\n- Monitor\n\n1. Drain\n\n2) Restart", "- Steps\n  1. One\n  5) Five"}},
+		{name: "tasks", wantMarkdown: []string{"- [x] Draft the **release notes** <!-- conflux:task id=\"1\" uuid=\"0a3c7e4e-1d1e-4b51-9d7e-62c1f3a1e001\" -->", "- [ ] @[557058:f1a2b3c4](user:557058:f1a2b3c4) to confirm the rollout by {date:2024-03-15} <!-- conflux:task id=\"2\" -->\n  - [ ] Check the dashboards <!-- conflux:task id=\"3\" -->", "* [ ] Book the review <!-- conflux:task id=\"4\" -->\n\n- Not a task\n\n* [x] Close the ticket <!-- conflux:task id=\"5\" -->"}},
+		{name: "page-links", wantMarkdown: []string{"[Release Checklist](confluence:OPS/Release%20Checklist)", "[the rollback steps](confluence:Runbook%3A%20API%20%28v2%29#rollback)", "[the **design** doc](confluence:id:5911379971)", "Escalate through [On-call Rota](confluence:OPS/On-call%20Rota)."}},
+		{name: "inline-comments", wantMarkdown: []string{"## Rollout <!-- conflux:comment ref=\"8f0c2a61-4c1e-4d8b-9a57-2b1c6de0a001\" -->plan<!-- /conflux:comment -->", "Deploy <!-- conflux:comment ref=\"8f0c2a61-4c1e-4d8b-9a57-2b1c6de0a002\" -->during the **maintenance** window<!-- /conflux:comment --> and notify support.", "- <!-- conflux:comment ref=\"8f0c2a61-4c1e-4d8b-9a57-2b1c6de0a003\" -->Check the dashboards<!-- /conflux:comment --> first"}, wantPreserved: 1},
+		{name: "expand", wantMarkdown: []string{"<details>\n<summary>How do I request access?</summary>\n\nOpen a ticket with the **platform** team.\n\n- Include your manager", "<summary>Rollback &amp; recovery</summary>", "<details>\n<summary>Script details</summary>\n\n```bash\n./rollback.sh --now\n```\n\n</details>\n\nThen tell support.\n\n</details>", "<details conflux-local-id=\"3e9a51c07b2d\" conflux-macro-id=\"b7c2e0f4-81d6-4a3b-a5e9-0c1f2d3e4b55\">\n\nUntitled details.\n\n</details>"}},
+		{name: "panels", wantMarkdown: []string{"> [!INFO]\n> Deploy during the **maintenance** window.", `> [!NOTE]{title="Heads up" icon=false conflux-macro-id=6f0d8c2e-5b1a-4f3e-9c7d-2a4b6e8f0a11}`, "> ```bash", `> [!PANEL]{bgColor=#DEEBFF borderColor=#0052CC title="On-call contacts"}`, "> Use the staging runbook.\n>\n> <!-- conflux:toc -->"}},
 	}
 
 	for _, test := range tests {
@@ -70,7 +84,7 @@ func TestEditableArtifactFidelityFixtures(t *testing.T) {
 				t.Fatal("editable table layout was not restored")
 			}
 			if test.wantOpaqueTableMacro && !strings.Contains(pushed.Storage, `ac:name="status"`) {
-				t.Fatal("macro-bearing table was not preserved")
+				t.Fatal("macro in table cell was not restored")
 			}
 		})
 	}
@@ -136,6 +150,11 @@ func normalizedStorageTokens(t *testing.T, fragment string) []string {
 			}
 			attributes := make([]string, 0, len(value.Attr))
 			for _, attribute := range value.Attr {
+				// Confluence sets the version a page link was saved at on
+				// every save, so pull drops it.
+				if attribute.Name.Local == "version-at-save" {
+					continue
+				}
 				attributes = append(attributes, normalizedXMLName(attribute.Name)+"="+attribute.Value)
 			}
 			sort.Strings(attributes)
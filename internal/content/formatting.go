@@ -0,0 +1,397 @@
+package content
+
+import (
+	"bytes"
+	"encoding/xml"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/JohannesKaufmann/html-to-markdown/v2/converter"
+	"github.com/JohannesKaufmann/html-to-markdown/v2/plugin/base"
+	"github.com/JohannesKaufmann/html-to-markdown/v2/plugin/commonmark"
+	"github.com/JohannesKaufmann/html-to-markdown/v2/plugin/strikethrough"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	xhtml "golang.org/x/net/html"
+)
+
+var (
+	textColorStyle = regexp.MustCompile(`^\s*color\s*:\s*([^;]+?)\s*;?\s*$`)
+	textColorValue = regexp.MustCompile(`^(?:#[0-9A-Fa-f]{3,8}|rgba?\([0-9., %]+\)|[A-Za-z]+)$`)
+	lineBreakHTML  = regexp.MustCompile(`(?i)^<br\s*/?>$`)
+	lineThrough    = regexp.MustCompile(`^\s*text-decoration\s*:\s*line-through\s*;?\s*$`)
+	strikeElement  = regexp.MustCompile(`(?i)<(?:(del|s)\b[^>]*|span\s+style="(\s*text-decoration\s*:\s*line-through\s*;?\s*)")>`)
+)
+
+const (
+	underlineClass  = ".underline"
+	lineThroughSpan = `<span style="text-decoration: line-through;">`
+)
+
+// Storage forms of struck-through text that PageMetadata.Strikethrough
+// records. The empty form is <del>, which Markdown strikethrough writes on
+// pages that use neither.
+const (
+	strikethroughS    = "s"
+	strikethroughSpan = "span"
+)
+
+// strikethroughForm returns the form of the first struck-through text in a
+// page's storage, so that a push writes the page's strikethrough as it was.
+func strikethroughForm(storage string) string {
+	match := strikeElement.FindStringSubmatch(storage)
+	switch {
+	case match == nil:
+		return ""
+	case match[2] != "":
+		return strikethroughSpan
+	case strings.EqualFold(match[1], "s"):
+		return strikethroughS
+	}
+	return ""
+}
+
+// textColor returns the colour set by the style of a span, or false when the
+// style holds anything other than one colour declaration.
+func textColor(style string) (string, bool) {
+	match := textColorStyle.FindStringSubmatch(style)
+	if match == nil || !textColorValue.MatchString(match[1]) {
+		return "", false
+	}
+	return match[1], true
+}
+
+// isUnsupportedSpan reports whether a span sets anything other than its text
+// colour or a line through it, which has no Markdown form.
+func isUnsupportedSpan(start xml.StartElement) bool {
+	if start.Name.Space != "" || !strings.EqualFold(start.Name.Local, "span") || len(start.Attr) == 0 {
+		return false
+	}
+	if len(start.Attr) > 1 || start.Attr[0].Name.Space != "" || start.Attr[0].Name.Local != "style" {
+		return true
+	}
+	_, ok := textColor(start.Attr[0].Value)
+	return !ok && !lineThrough.MatchString(start.Attr[0].Value)
+}
+
+// storageMarkdownConverter converts storage HTML with the CommonMark forms and
+// the Conflux forms for strikethrough and other text formatting.
+var storageMarkdownConverter = converter.NewConverter(converter.WithPlugins(
+	base.NewBasePlugin(),
+	commonmark.NewCommonmarkPlugin(),
+	strikethrough.NewStrikethroughPlugin(),
+	formattingPlugin{},
+))
+
+// formattingPlugin converts the text formatting that CommonMark has no syntax
+// for: underline and colour become bracketed spans, subscript and superscript
+// are wrapped in ~ and ^, and line-through spans in ~~.
+type formattingPlugin struct{}
+
+func (formattingPlugin) Name() string { return "conflux-formatting" }
+
+func (p formattingPlugin) Init(conv *converter.Converter) error {
+	conv.Register.EscapedChar('^')
+	conv.Register.UnEscaper(unescapeCaret, converter.PriorityStandard)
+	for _, tag := range []string{"u", "sub", "sup", "span"} {
+		conv.Register.RendererFor(tag, converter.TagTypeInline, p.render, converter.PriorityEarly)
+	}
+	conv.Register.RendererFor("br", converter.TagTypeInline, renderLineBreak, converter.PriorityEarly)
+	return nil
+}
+
+// renderLineBreak writes <br> for a line break that a Markdown hard break
+// cannot express: one in a heading, at the start or end of a block, or
+// directly after another break. Other breaks use the CommonMark form.
+func renderLineBreak(ctx converter.Context, w converter.Writer, n *xhtml.Node) converter.RenderStatus {
+	previous := adjacentContent(n, func(node *xhtml.Node) *xhtml.Node { return node.PrevSibling })
+	next := adjacentContent(n, func(node *xhtml.Node) *xhtml.Node { return node.NextSibling })
+	if previous != nil && previous.Data != "br" && next != nil && !inHeading(n) {
+		return converter.RenderTryNext
+	}
+	_, _ = w.WriteString("<br>")
+	return converter.RenderSuccess
+}
+
+// adjacentContent returns the nearest node in one direction within the same
+// block that holds text or is an element, skipping whitespace.
+func adjacentContent(n *xhtml.Node, step func(*xhtml.Node) *xhtml.Node) *xhtml.Node {
+	for node := n; node != nil && !textBlockElements[node.Data]; node = node.Parent {
+		for sibling := step(node); sibling != nil; sibling = step(sibling) {
+			if sibling.Type != xhtml.TextNode || strings.TrimSpace(sibling.Data) != "" {
+				return sibling
+			}
+		}
+	}
+	return nil
+}
+
+func inHeading(n *xhtml.Node) bool {
+	for node := n.Parent; node != nil; node = node.Parent {
+		switch node.Data {
+		case "h1", "h2", "h3", "h4", "h5", "h6":
+			return true
+		}
+	}
+	return false
+}
+
+// textBlockElements are the elements whose content is one block of text.
+var textBlockElements = map[string]bool{
+	"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"li": true, "td": true, "th": true, "blockquote": true, "div": true, "body": true,
+}
+
+// unescapeCaret keeps the escape of a caret that could open or close a
+// superscript.
+func unescapeCaret(chars []byte, index int) int {
+	if chars[index] != '^' {
+		return -1
+	}
+	if index+1 < len(chars) && (chars[index+1] == ' ' || chars[index+1] == '\n') {
+		return -1
+	}
+	return 1
+}
+
+func (formattingPlugin) render(ctx converter.Context, w converter.Writer, n *xhtml.Node) converter.RenderStatus {
+	var open, close string
+	switch n.Data {
+	case "u":
+		open, close = "[", "]{"+underlineClass+"}"
+	case "sub":
+		open, close = "~", "~"
+	case "sup":
+		open, close = "^", "^"
+	case "span":
+		style := nodeAttribute(n, "style")
+		if lineThrough.MatchString(style) {
+			open, close = "~~", "~~"
+			break
+		}
+		color, ok := textColor(style)
+		if !ok {
+			return converter.RenderTryNext
+		}
+		open, close = "[", "]"+formatAttributeBlock([]attribute{{Key: "color", Value: color}})
+	}
+	var content bytes.Buffer
+	ctx.RenderChildNodes(ctx, &content, n)
+	// Breaks stay inside the span as <br> rather than splitting it in two.
+	_, _ = w.WriteString(wrapEveryLine(strings.ReplaceAll(content.String(), "  \n", "<br>"), open, close))
+	return converter.RenderSuccess
+}
+
+func nodeAttribute(n *xhtml.Node, key string) string {
+	for _, attribute := range n.Attr {
+		if attribute.Key == key {
+			return attribute.Val
+		}
+	}
+	return ""
+}
+
+// wrapEveryLine wraps each line of formatted text in its delimiters, keeping
+// surrounding spaces outside them so that the delimiters are recognised.
+func wrapEveryLine(content, open, close string) string {
+	lines := strings.Split(content, "\n")
+	for index, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		start := strings.Index(line, trimmed)
+		lines[index] = line[:start] + open + trimmed + close + line[start+len(trimmed):]
+	}
+	return strings.Join(lines, "\n")
+}
+
+var kindFormattedText = ast.NewNodeKind("ConfluxFormattedText")
+
+// formattedText is struck-through, underlined, subscript, superscript, or
+// coloured text. Tag is the storage element that holds it.
+type formattedText struct {
+	ast.BaseInline
+	Tag   string
+	Color string
+}
+
+func (n *formattedText) Kind() ast.NodeKind { return kindFormattedText }
+
+func (n *formattedText) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Tag": n.Tag, "Color": n.Color}, nil)
+}
+
+// formattingDelimiterProcessor matches ~~strikethrough~~, ~subscript~, and
+// ^superscript^.
+type formattingDelimiterProcessor struct {
+	char byte
+}
+
+func (p formattingDelimiterProcessor) IsDelimiter(b byte) bool { return b == p.char }
+
+func (p formattingDelimiterProcessor) CanOpenCloser(opener, closer *parser.Delimiter) bool {
+	return opener.Char == closer.Char
+}
+
+func (p formattingDelimiterProcessor) OnMatch(consumes int) ast.Node {
+	switch {
+	case p.char == '^':
+		return &formattedText{Tag: "sup"}
+	case consumes == 2:
+		return &formattedText{Tag: "del"}
+	}
+	return &formattedText{Tag: "sub"}
+}
+
+type formattingDelimiterParser struct {
+	char      byte
+	maxLength int
+}
+
+func (p formattingDelimiterParser) Trigger() []byte { return []byte{p.char} }
+
+func (p formattingDelimiterParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	before := block.PrecendingCharacter()
+	line, segment := block.PeekLine()
+	node := parser.ScanDelimiter(line, before, 1, formattingDelimiterProcessor{char: p.char})
+	if node == nil || node.OriginalLength > p.maxLength || before == rune(p.char) {
+		return nil
+	}
+	node.Segment = segment.WithStop(segment.Start + node.OriginalLength)
+	block.Advance(node.OriginalLength)
+	pc.PushDelimiter(node)
+	return node
+}
+
+// spanAttributes returns the formatting set by the attribute block of a
+// bracketed span, or false when the block is not {.underline} or {color=...}.
+func spanAttributes(attributes []attribute) (*formattedText, bool) {
+	if len(attributes) != 1 {
+		return nil, false
+	}
+	switch attributes[0].Key {
+	case underlineClass:
+		return &formattedText{Tag: "u"}, true
+	case "color":
+		if !textColorValue.MatchString(attributes[0].Value) {
+			return nil, false
+		}
+		return &formattedText{Tag: "span", Color: attributes[0].Value}, true
+	}
+	return nil, false
+}
+
+// parseBracketedSpan turns the inline nodes between an unescaped [ and the ]
+// that ends the previous text into a formatted span. The attribute block has
+// already been read. It reports false, leaving the nodes as they were, when
+// there is no matching [ among the text before the ].
+func parseBracketedSpan(parent ast.Node, span *formattedText, source []byte, pc parser.Context) bool {
+	last, ok := parent.LastChild().(*ast.Text)
+	if !ok || last.Segment.Len() == 0 || source[last.Segment.Stop-1] != ']' || escapedAt(source, last.Segment.Stop-1) {
+		return false
+	}
+	opener, offset := findSpanOpener(last, source)
+	if opener == nil {
+		return false
+	}
+	last.Segment = last.Segment.WithStop(last.Segment.Stop - 1)
+	rest := ast.NewTextSegment(text.NewSegment(offset+1, opener.Segment.Stop))
+	rest.SetSoftLineBreak(opener.SoftLineBreak())
+	rest.SetHardLineBreak(opener.HardLineBreak())
+	opener.SetSoftLineBreak(false)
+	opener.SetHardLineBreak(false)
+	opener.Segment = opener.Segment.WithStop(offset)
+	parent.InsertAfter(parent, opener, span)
+	parent.InsertAfter(parent, span, rest)
+
+	// Emphasis and other delimiters inside the span are matched before it is
+	// closed, as for link text; those before it are left for later.
+	var bottom ast.Node
+	for child := span.PreviousSibling(); child != nil && bottom == nil; child = child.PreviousSibling() {
+		if delimiter, ok := child.(*parser.Delimiter); ok {
+			bottom = delimiter
+		}
+	}
+	for child := span.NextSibling(); child != nil; child = child.NextSibling() {
+		if _, ok := child.(*parser.Delimiter); ok {
+			parser.ProcessDelimiters(bottom, pc)
+			break
+		}
+	}
+	for child := span.NextSibling(); child != nil; {
+		next := child.NextSibling()
+		if textNode, ok := child.(*ast.Text); !ok || textNode.Segment.Len() > 0 {
+			span.AppendChild(span, child)
+		} else {
+			parent.RemoveChild(parent, child)
+		}
+		child = next
+	}
+	if opener.Segment.Len() == 0 {
+		parent.RemoveChild(parent, opener)
+	}
+	parent.RemoveChild(parent, span)
+	return true
+}
+
+// findSpanOpener scans the text before the closing ] for the [ that matches
+// it, skipping escaped and nested brackets.
+func findSpanOpener(last *ast.Text, source []byte) (*ast.Text, int) {
+	depth := 0
+	stop := last.Segment.Stop - 1
+	for node := ast.Node(last); node != nil; node = node.PreviousSibling() {
+		textNode, ok := node.(*ast.Text)
+		if !ok {
+			continue
+		}
+		if node != ast.Node(last) {
+			stop = textNode.Segment.Stop
+		}
+		for index := stop - 1; index >= textNode.Segment.Start; index-- {
+			if escapedAt(source, index) {
+				continue
+			}
+			switch source[index] {
+			case ']':
+				depth++
+			case '[':
+				if depth == 0 {
+					return textNode, index
+				}
+				depth--
+			}
+		}
+	}
+	return nil, 0
+}
+
+func escapedAt(source []byte, index int) bool {
+	backslashes := 0
+	for index--; index >= 0 && source[index] == '\\'; index-- {
+		backslashes++
+	}
+	return backslashes%2 == 1
+}
+
+func (w *storageWriter) renderFormattedText(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	formatted := node.(*formattedText)
+	open, close := "<"+formatted.Tag+">", "</"+formatted.Tag+">"
+	switch {
+	case formatted.Color != "":
+		open = `<span style="color: ` + html.EscapeString(formatted.Color) + `;">`
+	case formatted.Tag == "del" && w.strikethrough == strikethroughS:
+		open, close = "<s>", "</s>"
+	case formatted.Tag == "del" && w.strikethrough == strikethroughSpan:
+		open, close = lineThroughSpan, "</span>"
+	}
+	if entering {
+		_, _ = writer.WriteString(open)
+	} else {
+		_, _ = writer.WriteString(close)
+	}
+	return ast.WalkContinue, nil
+}
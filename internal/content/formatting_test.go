@@ -0,0 +1,95 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderArtifactWritesTextFormatting(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{name: "strikethrough", markdown: "~~gone~~ and a~~b~~c", want: "<p><del>gone</del> and a<del>b</del>c</p>"},
+		{name: "subscript and superscript", markdown: "H~2~O and x^2^", want: "<p>H<sub>2</sub>O and x<sup>2</sup></p>"},
+		{name: "underline", markdown: "[under **bold**]{.underline}", want: "<p><u>under <strong>bold</strong></u></p>"},
+		{name: "colour", markdown: `[red]{color="rgb(255,86,48)"} [blue]{color=#0052cc}`, want: `<p><span style="color: rgb(255,86,48);">red</span> <span style="color: #0052cc;">blue</span></p>`},
+		{name: "nested spans", markdown: "[a [b]{.underline} c]{color=red}", want: `<p><span style="color: red;">a <u>b</u> c</span></p>`},
+		{name: "span in link", markdown: "[[docs]{.underline}](https://example.com)", want: `<p><a href="https://example.com"><u>docs</u></a></p>`},
+		{name: "emphasis around span", markdown: "*x [y]{.underline} z*", want: "<p><em>x <u>y</u> z</em></p>"},
+		{name: "literal brackets", markdown: `\[x]{.underline} [y\]{.underline} [z]{.other} [w]{color="red;"}`, want: "<p>[x]{.underline} [y]{.underline} [z]{.other} [w]{color=&quot;red;&quot;}</p>"},
+		{name: "literal delimiters", markdown: `2\^10 a\~b ~~~x~~~`, want: "<p>2^10 a~b ~~~x~~~</p>"},
+		{name: "hard breaks", markdown: "one\\\ntwo<br><br>three<br>", want: "<p>one<br />two<br /><br />three<br /></p>"},
+		{name: "break in heading", markdown: "## One<br>two", want: "<h2>One<br />two</h2>"},
+		{name: "empty line", markdown: "<br>", want: "<p><br /></p>"},
+		{name: "rule", markdown: "Before\n\n---\n\nAfter", want: "<p>Before</p><hr /><p>After</p>"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			artifact, err := RenderArtifact(test.markdown+"\n", metadata, nil)
+			if err != nil {
+				t.Fatalf("RenderArtifact returned error: %v", err)
+			}
+			if artifact.Storage != test.want {
+				t.Fatalf("storage = %s, want %s", artifact.Storage, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderStorageKeepsBreaksInsideFormattedText(t *testing.T) {
+	artifact, err := RenderStorage(testStoragePage(`<p><u>one<br />two</u> and <span style="color: red;">three<br />four</span></p>`))
+	if err != nil {
+		t.Fatalf("RenderStorage returned error: %v", err)
+	}
+	if want := "[one<br>two]{.underline} and [three<br>four]{color=red}\n"; artifact.Markdown != want {
+		t.Fatalf("Markdown = %q, want %q", artifact.Markdown, want)
+	}
+}
+
+func TestRenderStorageRoundTripsStrikethroughForms(t *testing.T) {
+	tests := map[string]struct{ open, close string }{
+		"del":  {open: "<del>", close: "</del>"},
+		"s":    {open: "<s>", close: "</s>"},
+		"span": {open: `<span style="text-decoration: line-through;">`, close: "</span>"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			storage := "<p>Old " + test.open + "plan" + test.close + "</p>"
+			artifact, err := RenderStorage(testStoragePage(storage))
+			if err != nil {
+				t.Fatalf("RenderStorage returned error: %v", err)
+			}
+			if want := "Old ~~plan~~\n"; artifact.Markdown != want {
+				t.Fatalf("Markdown = %q, want %q", artifact.Markdown, want)
+			}
+			pushed, err := RenderArtifact(artifact.Markdown+"\nNew ~~line~~\n", artifact.Metadata, nil)
+			if err != nil {
+				t.Fatalf("RenderArtifact returned error: %v", err)
+			}
+			if want := storage + "<p>New " + test.open + "line" + test.close + "</p>"; pushed.Storage != want {
+				t.Fatalf("storage = %s, want %s", pushed.Storage, want)
+			}
+		})
+	}
+}
+
+func TestRenderStoragePreservesUnsupportedSpans(t *testing.T) {
+	for _, storage := range []string{
+		`<p>Text <span style="background-color: rgb(255,240,179);">highlighted</span>.</p>`,
+		`<p>Text <span style="color: red; font-size: 12px;">styled</span>.</p>`,
+		`<p>Text <span class="legacy">classed</span>.</p>`,
+		`<hr class="divider" />`,
+	} {
+		artifact, err := RenderStorage(testStoragePage(storage))
+		if err != nil {
+			t.Fatalf("RenderStorage returned error: %v", err)
+		}
+		if artifact.Metadata.PreservedFragments["fragment-0001"] != storage || strings.Contains(artifact.Markdown, "]{") {
+			t.Fatalf("span was not preserved:\n%s", artifact.Markdown)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package content
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+const frontMatterDelimiter = "---"
+
+// Confluence rejects labels containing whitespace or any of these characters.
+const invalidLabelCharacters = "!#&()*,.:;<>?@[]^"
+
+var pageWidths = []string{"default", "fixed", "full-width"}
+
+// FrontMatter holds the page options an artifact sets in a YAML block at the
+// top of its Markdown. Options left out keep the page's current value; nil
+// Labels and Status are left out, while an empty list or status clears them.
+type FrontMatter struct {
+	Title  string   `yaml:"title,omitempty"`
+	Parent string   `yaml:"parent,omitempty"`
+	Labels []string `yaml:"labels,omitempty,flow"`
+	Status *string  `yaml:"status,omitempty"`
+	Width  string   `yaml:"width,omitempty"`
+}
+
+// SplitFrontMatter separates an artifact's front matter from its Markdown
+// body. Markdown that does not start with a "---" line directly followed by
+// YAML has no front matter and is returned unchanged.
+func SplitFrontMatter(markdown string) (FrontMatter, string, error) {
+	first, rest, found := strings.Cut(markdown, "\n")
+	if !found || strings.TrimSuffix(first, "\r") != frontMatterDelimiter || strings.TrimSpace(strings.SplitN(rest, "\n", 2)[0]) == "" {
+		return FrontMatter{}, markdown, nil
+	}
+
+	var block strings.Builder
+	for rest != "" {
+		var line string
+		line, rest, _ = strings.Cut(rest, "\n")
+		if strings.TrimSuffix(line, "\r") == frontMatterDelimiter {
+			front, err := decodeFrontMatter(block.String())
+			if err != nil {
+				return FrontMatter{}, "", err
+			}
+			return front, strings.TrimLeft(rest, "\r\n"), nil
+		}
+		block.WriteString(line)
+		block.WriteByte('\n')
+	}
+	return FrontMatter{}, "", fmt.Errorf("front matter is missing its closing %s line", frontMatterDelimiter)
+}
+
+// WithFrontMatter prefixes markdown with front matter holding the set options.
+func WithFrontMatter(markdown string, front FrontMatter) (string, error) {
+	if err := front.Validate(); err != nil {
+		return "", err
+	}
+	var block bytes.Buffer
+	encoder := yaml.NewEncoder(&block)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(front); err != nil {
+		return "", fmt.Errorf("encode front matter: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("encode front matter: %w", err)
+	}
+	if strings.TrimSpace(block.String()) == "{}" {
+		return markdown, nil
+	}
+	result := frontMatterDelimiter + "\n" + block.String() + frontMatterDelimiter + "\n"
+	if markdown != "" {
+		result += "\n" + markdown
+	}
+	return result, nil
+}
+
+func (f FrontMatter) Validate() error {
+	if f.Title != strings.TrimSpace(f.Title) {
+		return fmt.Errorf("front matter title must not start or end with whitespace")
+	}
+	if f.Parent != strings.TrimSpace(f.Parent) {
+		return fmt.Errorf("front matter parent must not start or end with whitespace")
+	}
+	if err := validateLabels(f.Labels); err != nil {
+		return fmt.Errorf("front matter %w", err)
+	}
+	if f.Status != nil && *f.Status != strings.TrimSpace(*f.Status) {
+		return fmt.Errorf("front matter status must not start or end with whitespace")
+	}
+	if f.Width != "" && !IsPageWidth(f.Width) {
+		return fmt.Errorf("front matter width %q is not one of %s", f.Width, strings.Join(pageWidths, ", "))
+	}
+	return nil
+}
+
+// IsPageWidth reports whether width is a page width front matter can set.
+func IsPageWidth(width string) bool {
+	for _, candidate := range pageWidths {
+		if width == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateLabel reports whether Confluence accepts label as a page label.
+// Confluence stores labels in lowercase, so uppercase letters are rejected
+// rather than silently changed.
+func ValidateLabel(label string) error {
+	if label == "" || strings.ContainsAny(label, invalidLabelCharacters) || strings.IndexFunc(label, unicode.IsSpace) >= 0 {
+		return fmt.Errorf("label %q is invalid", label)
+	}
+	if label != strings.ToLower(label) {
+		return fmt.Errorf("label %q must be lowercase", label)
+	}
+	return nil
+}
+
+func validateLabels(labels []string) error {
+	seen := make(map[string]struct{}, len(labels))
+	for _, label := range labels {
+		if err := ValidateLabel(label); err != nil {
+			return err
+		}
+		if _, duplicate := seen[label]; duplicate {
+			return fmt.Errorf("labels repeat %q", label)
+		}
+		seen[label] = struct{}{}
+	}
+	return nil
+}
+
+func decodeFrontMatter(block string) (FrontMatter, error) {
+	var front FrontMatter
+	decoder := yaml.NewDecoder(strings.NewReader(block))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&front); err != nil && !errors.Is(err, io.EOF) {
+		return FrontMatter{}, fmt.Errorf("decode front matter: %w", err)
+	}
+	if err := front.Validate(); err != nil {
+		return FrontMatter{}, err
+	}
+	return front, nil
+}
@@ -0,0 +1,107 @@
+package content
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	status := "In progress"
+	tests := []struct {
+		name     string
+		markdown string
+		front    FrontMatter
+		body     string
+	}{
+		{name: "none", markdown: "Body.\n", body: "Body.\n"},
+		{name: "leading rule", markdown: "---\n\nBody.\n\n---\n", body: "---\n\nBody.\n\n---\n"},
+		{
+			name:     "all options",
+			markdown: "---\ntitle: Release notes\nparent: \"5911379971\"\nlabels: [ops, runbook]\nstatus: In progress\nwidth: full-width\n---\n\nBody.\n",
+			front:    FrontMatter{Title: "Release notes", Parent: "5911379971", Labels: []string{"ops", "runbook"}, Status: &status, Width: "full-width"},
+			body:     "Body.\n",
+		},
+		{name: "cleared options", markdown: "---\nlabels: []\nstatus: \"\"\n---\nBody.\n", front: FrontMatter{Labels: []string{}, Status: new(string)}, body: "Body.\n"},
+		{name: "windows line endings", markdown: "---\r\ntitle: Page\r\n---\r\n\r\nBody.\r\n", front: FrontMatter{Title: "Page"}, body: "Body.\r\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			front, body, err := SplitFrontMatter(test.markdown)
+			if err != nil {
+				t.Fatalf("SplitFrontMatter returned error: %v", err)
+			}
+			if !reflect.DeepEqual(front, test.front) || body != test.body {
+				t.Fatalf("front = %+v body = %q, want %+v and %q", front, body, test.front, test.body)
+			}
+		})
+	}
+}
+
+func TestSplitFrontMatterRejectsInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{name: "unclosed", markdown: "---\ntitle: Page\n\nBody.\n", want: "missing its closing"},
+		{name: "unknown field", markdown: "---\nowner: ana\n---\n", want: "field owner not found"},
+		{name: "not a mapping", markdown: "---\n- title\n---\n", want: "decode front matter"},
+		{name: "label with space", markdown: "---\nlabels: [release notes]\n---\n", want: "is invalid"},
+		{name: "uppercase label", markdown: "---\nlabels: [Ops]\n---\n", want: "must be lowercase"},
+		{name: "repeated label", markdown: "---\nlabels: [ops, ops]\n---\n", want: "repeat"},
+		{name: "unknown width", markdown: "---\nwidth: wide\n---\n", want: "not one of default, fixed, full-width"},
+		{name: "padded title", markdown: "---\ntitle: \" Page\"\n---\n", want: "whitespace"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := SplitFrontMatter(test.markdown)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("error = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestWithFrontMatterRoundTrips(t *testing.T) {
+	status := "Verified"
+	front := FrontMatter{Title: "Runbook: deploys", Parent: "Operations", Labels: []string{"ops"}, Status: &status, Width: "fixed"}
+	markdown, err := WithFrontMatter("Body.\n", front)
+	if err != nil {
+		t.Fatalf("WithFrontMatter returned error: %v", err)
+	}
+	want := "---\ntitle: 'Runbook: deploys'\nparent: Operations\nlabels: [ops]\nstatus: Verified\nwidth: fixed\n---\n\nBody.\n"
+	if markdown != want {
+		t.Fatalf("markdown = %q, want %q", markdown, want)
+	}
+	parsed, body, err := SplitFrontMatter(markdown)
+	if err != nil || !reflect.DeepEqual(parsed, front) || body != "Body.\n" {
+		t.Fatalf("parsed = %+v body = %q err = %v", parsed, body, err)
+	}
+	if unchanged, _ := WithFrontMatter("Body.\n", FrontMatter{}); unchanged != "Body.\n" {
+		t.Fatalf("empty front matter changed Markdown to %q", unchanged)
+	}
+}
+
+func TestRenderArtifactReadsFrontMatter(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	artifact, err := RenderArtifact("---\ntitle: Renamed\nparent: Operations\nlabels: []\nwidth: full-width\n---\n\n# Heading\n", metadata, nil)
+	if err != nil {
+		t.Fatalf("RenderArtifact returned error: %v", err)
+	}
+	if artifact.Storage != "<h1>Heading</h1>" || artifact.Title != "Renamed" || artifact.Parent != "Operations" {
+		t.Fatalf("artifact = %+v", artifact)
+	}
+	if artifact.Labels == nil || len(artifact.Labels) != 0 || artifact.Status != nil || artifact.Width != "full-width" {
+		t.Fatalf("options labels = %#v status = %v width = %q", artifact.Labels, artifact.Status, artifact.Width)
+	}
+
+	untitled, err := RenderArtifact("Body.\n", metadata, nil)
+	if err != nil {
+		t.Fatalf("RenderArtifact returned error: %v", err)
+	}
+	if untitled.Title != metadata.Page.Title || untitled.Labels != nil {
+		t.Fatalf("artifact without front matter = %+v", untitled)
+	}
+}
@@ -0,0 +1,190 @@
+package content
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	imageNumberAttributes  = map[string]bool{"width": true, "height": true, "vspace": true, "hspace": true, "original-width": true, "original-height": true}
+	imageBooleanAttributes = map[string]bool{"border": true, "thumbnail": true, "custom-width": true}
+	imageTextAttributes    = map[string]bool{"align": true, "layout": true, "title": true}
+	imageNumber            = regexp.MustCompile(`^[0-9]+$`)
+	imageURL               = regexp.MustCompile(`^[^\s()<>]+$`)
+)
+
+// storageImage is an ac:image whose source and attributes have a Markdown
+// form. Attributes keeps the ac: attributes other than alt in storage order,
+// followed by a plain-text caption.
+type storageImage struct {
+	Alt        string
+	Filename   string
+	URL        string
+	Attributes []attribute
+}
+
+func checkImageAttribute(key, value string) error {
+	switch {
+	case imageNumberAttributes[key]:
+		if !imageNumber.MatchString(value) {
+			return fmt.Errorf("image attribute %q must be a whole number", key)
+		}
+	case imageBooleanAttributes[key]:
+		if value != "true" && value != "false" {
+			return fmt.Errorf("image attribute %q must be true or false", key)
+		}
+	case imageTextAttributes[key], key == "caption":
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("image attribute %q must be a single line", key)
+		}
+	default:
+		return fmt.Errorf("unsupported image attribute %q", key)
+	}
+	return nil
+}
+
+// parseStorageImage reads an image of an attachment or external URL. Images
+// with other sources, unknown attributes, or a formatted caption have no
+// Markdown form.
+func parseStorageImage(raw string) (storageImage, bool) {
+	start, inner, ok := splitStorageElement(raw)
+	if !ok || start.Name.Space != "urn:conflux:ac" || start.Name.Local != "image" {
+		return storageImage{}, false
+	}
+	var image storageImage
+	for _, value := range start.Attr {
+		if value.Name.Space != "urn:conflux:ac" {
+			return storageImage{}, false
+		}
+		if value.Name.Local == "alt" {
+			image.Alt = value.Value
+			continue
+		}
+		if checkImageAttribute(value.Name.Local, value.Value) != nil || value.Name.Local == "caption" {
+			return storageImage{}, false
+		}
+		image.Attributes = append(image.Attributes, attribute{Key: value.Name.Local, Value: value.Value})
+	}
+	if strings.ContainsAny(image.Alt, "]\r\n") {
+		return storageImage{}, false
+	}
+	children, err := tokenizeStorage(inner)
+	if err != nil {
+		return storageImage{}, false
+	}
+	caption := false
+	for _, child := range children {
+		if strings.TrimSpace(child.Raw) == "" {
+			continue
+		}
+		childStart, childInner, ok := splitStorageElement(child.Raw)
+		if !ok {
+			return storageImage{}, false
+		}
+		switch {
+		case child.Name.Space == "urn:conflux:ri" && (child.Name.Local == "attachment" || child.Name.Local == "url"):
+			values, ok := resourceAttributes(childStart)
+			if !ok || len(values) != 1 || strings.TrimSpace(childInner) != "" || image.Filename != "" || image.URL != "" {
+				return storageImage{}, false
+			}
+			if child.Name.Local == "attachment" {
+				image.Filename = values["filename"]
+			} else {
+				image.URL = values["value"]
+				if !imageURL.MatchString(image.URL) || attachmentFilename(image.URL) != "" {
+					return storageImage{}, false
+				}
+			}
+		case child.Name.Space == "urn:conflux:ac" && child.Name.Local == "caption" && !caption:
+			text, ok := imageCaption(childInner)
+			if !ok {
+				return storageImage{}, false
+			}
+			caption = true
+			image.Attributes = append(image.Attributes, attribute{Key: "caption", Value: text})
+		default:
+			return storageImage{}, false
+		}
+	}
+	return image, image.Filename != "" || image.URL != ""
+}
+
+// imageCaption returns the text of a caption holding one plain paragraph.
+func imageCaption(raw string) (string, bool) {
+	children, err := tokenizeStorage(raw)
+	if err != nil {
+		return "", false
+	}
+	var text string
+	found := false
+	for _, child := range children {
+		if strings.TrimSpace(child.Raw) == "" {
+			continue
+		}
+		start, inner, ok := splitStorageElement(child.Raw)
+		if !ok || found || start.Name.Space != "" || start.Name.Local != "p" || len(start.Attr) != 0 {
+			return "", false
+		}
+		if text, ok = plainTextContent(inner); !ok || strings.ContainsAny(text, "\r\n") {
+			return "", false
+		}
+		found = true
+	}
+	return text, found
+}
+
+// imageMarkdown renders an image with its attributes in an attribute block.
+// Attachment images without alt text use the filename, which push leaves out
+// again.
+func (r *storageRenderer) imageMarkdown(raw string) (string, bool) {
+	image, ok := parseStorageImage(raw)
+	if !ok {
+		return "", false
+	}
+	destination := image.URL
+	if image.Filename != "" {
+		destination = attachmentMarkdownPath(r.attachmentDirectory, image.Filename)
+		if image.Alt == "" {
+			image.Alt = image.Filename
+		}
+	}
+	markdown := "![" + image.Alt + "](" + destination + ")"
+	if len(image.Attributes) > 0 {
+		markdown += formatAttributeBlock(image.Attributes)
+	}
+	return markdown, true
+}
+
+// imageStorage renders a Markdown image and its attribute block. resource is
+// the ri:attachment or ri:url element.
+func imageStorage(alt, filename, resource string, attributes []attribute) (string, error) {
+	var storage strings.Builder
+	storage.WriteString("<ac:image")
+	if alt != "" && alt != filename {
+		storage.WriteString(` ac:alt="` + html.EscapeString(alt) + `"`)
+	}
+	caption := ""
+	seen := make(map[string]bool)
+	for _, attribute := range attributes {
+		if seen[attribute.Key] {
+			return "", fmt.Errorf("image attribute %q is repeated", attribute.Key)
+		}
+		seen[attribute.Key] = true
+		if err := checkImageAttribute(attribute.Key, attribute.Value); err != nil {
+			return "", err
+		}
+		if attribute.Key == "caption" {
+			caption = attribute.Value
+			continue
+		}
+		storage.WriteString(` ac:` + attribute.Key + `="` + html.EscapeString(attribute.Value) + `"`)
+	}
+	storage.WriteString(">" + resource)
+	if seen["caption"] {
+		storage.WriteString("<ac:caption><p>" + html.EscapeString(caption) + "</p></ac:caption>")
+	}
+	storage.WriteString("</ac:image>")
+	return storage.String(), nil
+}
@@ -0,0 +1,85 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderArtifactWritesImageAttributes(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{
+			name:     "sized attachment",
+			markdown: "![diagram.png](page.attachments/diagram.png){width=600 align=center}\n",
+			want:     `<ac:image ac:width="600" ac:align="center"><ri:attachment ri:filename="diagram.png" /></ac:image>`,
+		},
+		{
+			name:     "external image with caption",
+			markdown: "![Logo](https://example.com/logo.png){height=40 caption=\"Our <new> logo\"}\n",
+			want:     `<ac:image ac:alt="Logo" ac:height="40"><ri:url ri:value="https://example.com/logo.png" /><ac:caption><p>Our &lt;new&gt; logo</p></ac:caption></ac:image>`,
+		},
+		{
+			name:     "inline image",
+			markdown: "Build ![](https://example.com/badge.svg){height=20} today\n",
+			want:     `<p>Build <ac:image ac:height="20"><ri:url ri:value="https://example.com/badge.svg" /></ac:image> today</p>`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			artifact, err := RenderArtifact(test.markdown, metadata, []LocalAttachment{{Filename: "diagram.png", Content: []byte("image")}})
+			if err != nil {
+				t.Fatalf("RenderArtifact returned error: %v", err)
+			}
+			if artifact.Storage != test.want {
+				t.Fatalf("storage = %s, want %s", artifact.Storage, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderArtifactRejectsInvalidImageAttributes(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	for name, test := range map[string]struct {
+		markdown string
+		want     string
+	}{
+		"unknown":  {"![x](https://example.com/x.png){color=red}\n", `unsupported image attribute "color"`},
+		"width":    {"![x](https://example.com/x.png){width=wide}\n", `"width" must be a whole number`},
+		"border":   {"![x](https://example.com/x.png){border=yes}\n", `"border" must be true or false`},
+		"repeated": {"![x](https://example.com/x.png){width=1 width=2}\n", `"width" is repeated`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := RenderArtifact(test.markdown, metadata, nil)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("error = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderStoragePreservesImagesWithoutMarkdownForm(t *testing.T) {
+	for name, storage := range map[string]string{
+		"unknown attribute": `<ac:image ac:style="max-height: 250px;"><ri:attachment ri:filename="diagram.png" /></ac:image>`,
+		"versioned":         `<ac:image><ri:attachment ri:filename="diagram.png" ri:version-at-save="2" /></ac:image>`,
+		"page attachment":   `<ac:image><ri:attachment ri:filename="diagram.png"><ri:page ri:content-title="Other" /></ri:attachment></ac:image>`,
+		"url with spaces":   `<ac:image><ri:url ri:value="https://example.com/a b.png" /></ac:image>`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			page := testStoragePage(storage)
+			page.Attachments = []AttachmentMetadata{{ID: "att-1", Filename: "diagram.png"}}
+			artifact, err := RenderStorage(page)
+			if err != nil {
+				t.Fatalf("RenderStorage returned error: %v", err)
+			}
+			if artifact.Metadata.PreservedFragments["fragment-0001"] != storage {
+				t.Fatalf("image was not preserved: %q", artifact.Markdown)
+			}
+		})
+	}
+}
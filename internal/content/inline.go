@@ -0,0 +1,322 @@
+package content
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+var (
+	// inlineSyntaxLiteral matches text that push would read as a date, status,
+	// or emoticon. Its first character is escaped.
+	inlineSyntaxLiteral = regexp.MustCompile(`\{(?:date|status):|:[a-z0-9_+-]+:`)
+)
+
+// inlineMarkdown converts a run of inline storage to Markdown. It reports
+// false when the run cannot be converted without changing it.
+func (r *storageRenderer) inlineMarkdown(raw string) (string, bool, error) {
+	return r.htmlMarkdown("<p>" + raw + "</p>")
+}
+
+// htmlMarkdown converts HTML storage to Markdown. Confluence elements are
+// swapped for placeholders while the surrounding HTML is converted: those with
+// an editable Markdown form, such as user mentions and dates, become that
+// form, and the rest are preserved behind inline markers. It reports false when
+// a Confluence element sits inside code, where a marker would be literal text,
+// or outside a line of text, where it could be a block.
+// Callers that fall back to preserving the whole block must discard the inline
+// fragments through a checkpoint.
+func (r *storageRenderer) htmlMarkdown(raw string) (string, bool, error) {
+	const prefix = `<conflux-root xmlns:ac="urn:conflux:ac" xmlns:ri="urn:conflux:ri">`
+	source := prefix + raw + `</conflux-root>`
+	decoder := xml.NewDecoder(strings.NewReader(source))
+	decoder.Strict = false
+
+	placeholder := "confluxinline"
+	for strings.Contains(raw, placeholder) {
+		placeholder += "x"
+	}
+	var replaced strings.Builder
+	var substitutions []string
+	last := len(prefix)
+	codeDepth := 0
+	var parents []string
+	for {
+		before := int(decoder.InputOffset())
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", false, nil
+		}
+		switch value := token.(type) {
+		case xml.EndElement:
+			if isCodeElement(value.Name) {
+				codeDepth--
+			}
+			if len(parents) > 0 {
+				parents = parents[:len(parents)-1]
+			}
+			continue
+		case xml.StartElement:
+			if isCodeElement(value.Name) {
+				codeDepth++
+			}
+			if isUnsupportedSpan(value) {
+				return "", false, nil
+			}
+			if !isReplaceableInline(value) && !isConfluenceElement(value.Name) {
+				parents = append(parents, strings.ToLower(value.Name.Local))
+				continue
+			}
+		case xml.CharData:
+			if codeDepth > 0 {
+				continue
+			}
+			end := int(decoder.InputOffset())
+			for _, match := range inlineSyntaxLiteral.FindAllStringIndex(source[before:end], -1) {
+				literal := source[before+match[0] : before+match[1]]
+				if literal[0] == ':' && !isEmoticonName(strings.Trim(literal, ":")) {
+					continue
+				}
+				replaced.WriteString(source[last : before+match[0]])
+				replaced.WriteString(placeholder + strconv.Itoa(len(substitutions)) + "z")
+				substitutions = append(substitutions, `\`+literal[:1])
+				last = before + match[0] + 1
+			}
+			continue
+		default:
+			continue
+		}
+		if err := decoder.Skip(); err != nil || codeDepth > 0 {
+			return "", false, nil
+		}
+		end := int(decoder.InputOffset())
+		markdown, ok, err := r.inlineElementMarkdown(source[before:end])
+		if err != nil {
+			return "", false, err
+		}
+		if !ok {
+			if len(parents) == 0 || !phrasingParents[parents[len(parents)-1]] {
+				return "", false, nil
+			}
+			if markdown, err = r.preserveInline(source[before:end]); err != nil {
+				return "", false, err
+			}
+		}
+		replaced.WriteString(source[last:before])
+		replaced.WriteString(placeholder + strconv.Itoa(len(substitutions)) + "z")
+		substitutions = append(substitutions, markdown)
+		last = end
+	}
+	replaced.WriteString(source[last : len(source)-len(`</conflux-root>`)])
+
+	converted := replaced.String()
+	if containsConfluenceNamespace(converted) || hasNamespacedAttributes(converted) {
+		return "", false, nil
+	}
+	markdown, err := storageMarkdownConverter.ConvertString(converted)
+	if err != nil {
+		return "", false, fmt.Errorf("convert storage HTML: %w", err)
+	}
+	markdown = strings.TrimSpace(markdown)
+	for index, substitution := range substitutions {
+		markdown = strings.Replace(markdown, placeholder+strconv.Itoa(index)+"z", substitution, 1)
+	}
+	return markdown, true, nil
+}
+
+// phrasingParents are the elements whose content is a line of text, where an
+// inline marker can stand in for an element.
+var phrasingParents = map[string]bool{
+	"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"a": true, "b": true, "del": true, "em": true, "i": true, "ins": true, "s": true,
+	"span": true, "strong": true, "sub": true, "sup": true, "u": true,
+}
+
+func isConfluenceElement(name xml.Name) bool {
+	return name.Space == "urn:conflux:ac" || name.Space == "urn:conflux:ri"
+}
+
+func isCodeElement(name xml.Name) bool {
+	return name.Space == "" && (strings.EqualFold(name.Local, "pre") || strings.EqualFold(name.Local, "code"))
+}
+
+func isReplaceableInline(start xml.StartElement) bool {
+	switch start.Name.Space {
+	case "":
+		return strings.EqualFold(start.Name.Local, "time") || isLinkWithoutDestination(start)
+	case "urn:conflux:ac":
+		return start.Name.Local == "link"
+	}
+	return false
+}
+
+// isLinkWithoutDestination reports whether an HTML a element is a named anchor
+// or otherwise has no href. Markdown links always have a destination, so these
+// are preserved.
+func isLinkWithoutDestination(start xml.StartElement) bool {
+	return strings.EqualFold(start.Name.Local, "a") && strings.TrimSpace(htmlAttribute(start, "href")) == ""
+}
+
+// inlineElementMarkdown returns the Markdown for a single replaceable inline
+// element.
+func (r *storageRenderer) inlineElementMarkdown(raw string) (string, bool, error) {
+	start, inner, ok := splitStorageElement(raw)
+	if !ok {
+		return "", false, nil
+	}
+	if start.Name.Space == "" {
+		value := htmlAttribute(start, "datetime")
+		if !strings.EqualFold(start.Name.Local, "time") || len(start.Attr) != 1 || !isCalendarDate(value) || strings.TrimSpace(inner) != "" {
+			return "", false, nil
+		}
+		return "{date:" + value + "}", true, nil
+	}
+	if start.Name.Space == "urn:conflux:ac" && start.Name.Local == "inline-comment-marker" {
+		return r.inlineCommentMarkdown(start, inner)
+	}
+	if start.Name.Space == "urn:conflux:ac" {
+		switch start.Name.Local {
+		case "image":
+			markdown, ok := r.imageMarkdown(raw)
+			return markdown, ok, nil
+		case "structured-macro":
+			if markdown, ok := anchorMarkdown(raw); ok {
+				return markdown, true, nil
+			}
+			markdown, ok := statusMarkdown(raw)
+			return markdown, ok, nil
+		case "emoticon":
+			markdown, ok := emoticonMarkdown(raw)
+			return markdown, ok, nil
+		}
+	}
+	link, ok := parseStorageLink(raw)
+	if !ok {
+		return "", false, nil
+	}
+	switch link.Resource.Name.Local {
+	case "user":
+		values, ok := resourceAttributes(link.Resource)
+		accountID := values["account-id"]
+		if !ok || len(values) != 1 || link.Anchor != "" || link.BodyKind != "" || !mentionAccountID.MatchString(accountID) {
+			return "", false, nil
+		}
+		return mentionMarkdown(accountID, r.users[accountID]), true, nil
+	case "page":
+		return r.pageLinkMarkdown(link)
+	case "attachment":
+		return r.attachmentLinkMarkdown(link)
+	case "":
+		return r.anchorLinkMarkdown(link)
+	}
+	return "", false, nil
+}
+
+var (
+	mentionAccountID    = regexp.MustCompile(`^[A-Za-z0-9:_-]+$`)
+	markdownLabelEscape = strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`, "*", `\*`, "_", `\_`, "`", "\\`", "<", `\<`)
+)
+
+// mentionMarkdown writes a mention labelled with the user's display name, or
+// the account ID when the name is unknown. Only the account ID is pushed back;
+// the label is for reading.
+func mentionMarkdown(accountID, displayName string) string {
+	label := strings.Join(strings.Fields(displayName), " ")
+	if label == "" {
+		label = accountID
+	}
+	return "@[" + markdownLabelEscape.Replace(label) + "](user:" + accountID + ")"
+}
+
+// MentionedAccountIDs returns the account IDs of users mentioned in storage,
+// so their display names can be looked up before rendering.
+func MentionedAccountIDs(storage string) []string {
+	decoder := storageNodeDecoder(storage)
+	var accountIDs []string
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return deduplicateStrings(accountIDs)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Space != "urn:conflux:ri" || start.Name.Local != "user" {
+			continue
+		}
+		for _, attribute := range start.Attr {
+			if attribute.Name.Space == "urn:conflux:ri" && attribute.Name.Local == "account-id" {
+				accountIDs = append(accountIDs, attribute.Value)
+			}
+		}
+	}
+}
+
+// mentionAccount returns the account ID of a user:ID link destination.
+func mentionAccount(node ast.Node) (string, bool) {
+	link, ok := node.(*ast.Link)
+	if !ok {
+		return "", false
+	}
+	accountID, ok := strings.CutPrefix(string(link.Destination), "user:")
+	return accountID, ok && mentionAccountID.MatchString(accountID)
+}
+
+func mentionStorage(accountID string) string {
+	return `<ac:link><ri:user ri:account-id="` + html.EscapeString(accountID) + `" /></ac:link>`
+}
+
+var kindDate = ast.NewNodeKind("ConfluxDate")
+
+// dateNode is a {date:YYYY-MM-DD} date, stored by Confluence as a time element.
+type dateNode struct {
+	ast.BaseInline
+	Value string
+}
+
+func (n *dateNode) Kind() ast.NodeKind { return kindDate }
+
+func (n *dateNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Value": n.Value}, nil)
+}
+
+var dateSyntax = regexp.MustCompile(`^\{date:([0-9]{4}-[0-9]{2}-[0-9]{2})\}`)
+
+// matchDate returns the {date:...} form at the start of value, or nil when
+// there is none or it does not name a real day, such as {date:2026-02-30}.
+func matchDate(value []byte) [][]byte {
+	match := dateSyntax.FindSubmatch(value)
+	if match == nil || !isCalendarDate(string(match[1])) {
+		return nil
+	}
+	return match
+}
+
+func isCalendarDate(value string) bool {
+	_, err := time.Parse(time.DateOnly, value)
+	return err == nil
+}
+
+type dateParser struct{}
+
+func (dateParser) Trigger() []byte { return []byte{'{'} }
+
+func (dateParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	match := matchDate(line)
+	if match == nil {
+		return nil
+	}
+	block.Advance(len(match[0]))
+	return &dateNode{Value: string(match[1])}
+}
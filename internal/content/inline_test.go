@@ -0,0 +1,150 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+const mentionParagraphFixture = `<p>Ask <ac:link><ri:user ri:account-id="557058:abc" /></ac:link> or <ac:link><ri:user ri:account-id="557058:def" /></ac:link> about <strong>it</strong>.</p>`
+
+func TestRenderStorageRendersMentionsInline(t *testing.T) {
+	page := testStoragePage(mentionParagraphFixture)
+	page.Users = map[string]string{"557058:abc": "Ana [Ops] Lima"}
+	artifact, err := RenderStorage(page)
+	if err != nil {
+		t.Fatalf("RenderStorage returned error: %v", err)
+	}
+	want := `Ask @[Ana \[Ops\] Lima](user:557058:abc) or @[557058:def](user:557058:def) about **it**.` + "\n"
+	if artifact.Markdown != want {
+		t.Fatalf("Markdown = %q, want %q", artifact.Markdown, want)
+	}
+	if len(artifact.Metadata.PreservedFragments) != 0 {
+		t.Fatalf("paragraph remained opaque: %#v", artifact.Metadata.PreservedFragments)
+	}
+}
+
+func TestRenderArtifactRestoresMentions(t *testing.T) {
+	page := testStoragePage(mentionParagraphFixture)
+	page.Users = map[string]string{"557058:abc": "Ana Lima"}
+	pulled, err := RenderStorage(page)
+	if err != nil {
+		t.Fatalf("RenderStorage returned error: %v", err)
+	}
+	edited := strings.Replace(pulled.Markdown, "Ask", "Please ask", 1)
+	pushed, err := RenderArtifact(edited, pulled.Metadata, nil)
+	if err != nil {
+		t.Fatalf("RenderArtifact returned error: %v", err)
+	}
+	want := `<p>Please ask <ac:link><ri:user ri:account-id="557058:abc" /></ac:link> or <ac:link><ri:user ri:account-id="557058:def" /></ac:link> about <strong>it</strong>.</p>`
+	if pushed.Storage != want {
+		t.Fatalf("storage = %s, want %s", pushed.Storage, want)
+	}
+}
+
+func TestRenderStoragePreservesMentionsInsideCode(t *testing.T) {
+	storage := `<p><code>owner: <ac:link><ri:user ri:account-id="557058:abc" /></ac:link></code></p>`
+	artifact, err := RenderStorage(testStoragePage(storage))
+	if err != nil {
+		t.Fatalf("RenderStorage returned error: %v", err)
+	}
+	if artifact.Metadata.PreservedFragments["fragment-0001"] != storage {
+		t.Fatalf("paragraph was not preserved:\n%s", artifact.Markdown)
+	}
+}
+
+func TestMentionedAccountIDs(t *testing.T) {
+	got := MentionedAccountIDs(mentionParagraphFixture + `<p><ac:link><ri:user ri:account-id="557058:abc" /></ac:link></p>`)
+	if strings.Join(got, ",") != "557058:abc,557058:def" {
+		t.Fatalf("account IDs = %#v", got)
+	}
+}
+
+const statusMacroFixture = `<ac:structured-macro ac:name="status"><ac:parameter ac:name="colour">green</ac:parameter><ac:parameter ac:name="title">READY</ac:parameter></ac:structured-macro>`
+
+func TestRenderStoragePreservesUnsupportedInlineElements(t *testing.T) {
+	tests := []struct {
+		name     string
+		storage  string
+		markdown string
+		pushed   string
+	}{
+		{
+			name:     "paragraph",
+			storage:  "<p>Build is " + statusMacroFixture + " for <strong>release</strong>.</p>",
+			markdown: `Build is <!-- conflux:inline id="fragment-0001" --> for **release**.`,
+			pushed:   "<p>Build is now " + statusMacroFixture + " for <strong>release</strong>.</p>",
+		},
+		{
+			name:     "start of line",
+			storage:  "<p>" + statusMacroFixture + " Build is ready.</p>",
+			markdown: `<!-- conflux:inline id="fragment-0001" --> Build is ready.`,
+			pushed:   "<p>" + statusMacroFixture + " Build is now ready.</p>",
+		},
+		{
+			name:     "list item",
+			storage:  "<ul><li>Build is " + statusMacroFixture + "</li></ul>",
+			markdown: `- Build is <!-- conflux:inline id="fragment-0001" -->`,
+			pushed:   "<ul><li>Build is now " + statusMacroFixture + "</li></ul>",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pulled, err := RenderStorage(testStoragePage(test.storage))
+			if err != nil {
+				t.Fatalf("RenderStorage returned error: %v", err)
+			}
+			if pulled.Markdown != test.markdown+"\n" {
+				t.Fatalf("Markdown = %q, want %q", pulled.Markdown, test.markdown+"\n")
+			}
+			if pulled.Metadata.PreservedFragments["fragment-0001"] != statusMacroFixture {
+				t.Fatalf("macro was not preserved: %#v", pulled.Metadata.PreservedFragments)
+			}
+			unchanged, err := RenderArtifact(pulled.Markdown, pulled.Metadata, nil)
+			if err != nil {
+				t.Fatalf("RenderArtifact returned error: %v", err)
+			}
+			if unchanged.Storage != test.storage {
+				t.Fatalf("storage = %s, want %s", unchanged.Storage, test.storage)
+			}
+			edited := strings.Replace(pulled.Markdown, "is", "is now", 1)
+			pushed, err := RenderArtifact(edited, pulled.Metadata, nil)
+			if err != nil {
+				t.Fatalf("RenderArtifact returned error: %v", err)
+			}
+			if pushed.Storage != test.pushed {
+				t.Fatalf("storage = %s, want %s", pushed.Storage, test.pushed)
+			}
+		})
+	}
+}
+
+func TestRenderStorageDiscardsInlineFragmentsOfPreservedBlocks(t *testing.T) {
+	storage := "<ul><li>Build is " + statusMacroFixture + "</li><li>Parent<ul><li>Child</li></ul>Trailing text</li></ul>"
+	artifact, err := RenderStorage(testStoragePage(storage))
+	if err != nil {
+		t.Fatalf("RenderStorage returned error: %v", err)
+	}
+	if len(artifact.Metadata.PreservedFragments) != 1 || artifact.Metadata.PreservedFragments["fragment-0001"] != storage {
+		t.Fatalf("list was not preserved whole: %#v", artifact.Metadata.PreservedFragments)
+	}
+	if _, err := RenderArtifact(artifact.Markdown, artifact.Metadata, nil); err != nil {
+		t.Fatalf("RenderArtifact returned error: %v", err)
+	}
+}
+
+func TestRenderArtifactRejectsMisplacedInlineMarkers(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{"fragment-0001": statusMacroFixture}
+	for name, markdown := range map[string]string{
+		"code span": "Build is `<!-- conflux:inline id=\"fragment-0001\" -->`.\n",
+		"malformed": "Build is <!-- conflux:inline fragment-0001 -->.\n",
+		"duplicate": "Build is <!-- conflux:inline id=\"fragment-0001\" --> and <!-- conflux:inline id=\"fragment-0001\" -->.\n",
+		"removed":   "Build is ready.\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := RenderArtifact(markdown, metadata, nil); err == nil {
+				t.Fatal("RenderArtifact unexpectedly succeeded")
+			}
+		})
+	}
+}
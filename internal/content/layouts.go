@@ -0,0 +1,335 @@
+package content
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	layoutSectionPattern     = regexp.MustCompile(`^<!--\s*conflux:layout-section((?:\s+[a-z-]+="[^"]*")*)\s*-->$`)
+	layoutSectionClose       = regexp.MustCompile(`^<!--\s*/conflux:layout-section\s*-->$`)
+	layoutCellPattern        = regexp.MustCompile(`^<!--\s*conflux:layout-cell\s*-->$`)
+	layoutDirectiveCandidate = regexp.MustCompile(`^<!--\s*/?conflux:layout-(?:section|cell)\b`)
+	layoutBreakoutModes      = map[string]bool{"default": true, "wide": true, "full-width": true}
+	// layoutSectionCells maps each Confluence section type to its column count.
+	layoutSectionCells = map[string]int{
+		"single":               1,
+		"two_equal":            2,
+		"two_left_sidebar":     2,
+		"two_right_sidebar":    2,
+		"three_equal":          3,
+		"three_with_sidebars":  3,
+		"three_left_sidebars":  3,
+		"three_right_sidebars": 3,
+		"four_equal":           4,
+		"five_equal":           5,
+	}
+)
+
+const (
+	layoutCellDirective         = "<!-- conflux:layout-cell -->"
+	layoutSectionCloseDirective = "<!-- /conflux:layout-section -->"
+)
+
+// layoutOptions are the section settings carried by a conflux:layout-section
+// directive.
+type layoutOptions struct {
+	SectionType   string
+	BreakoutMode  string
+	BreakoutWidth int
+}
+
+func layoutSectionDirective(options layoutOptions) string {
+	directive := `<!-- conflux:layout-section type="` + options.SectionType + `"`
+	if options.BreakoutMode != "" {
+		directive += ` breakout-mode="` + options.BreakoutMode + `"`
+	}
+	if options.BreakoutWidth > 0 {
+		directive += ` breakout-width="` + strconv.Itoa(options.BreakoutWidth) + `"`
+	}
+	return directive + " -->"
+}
+
+func parseLayoutSectionDirective(line string) (layoutOptions, error) {
+	match := layoutSectionPattern.FindStringSubmatch(line)
+	if match == nil {
+		return layoutOptions{}, fmt.Errorf("invalid Conflux layout directive %q", line)
+	}
+	var options layoutOptions
+	seen := make(map[string]bool)
+	for _, option := range directiveOption.FindAllStringSubmatch(match[1], -1) {
+		name, value := option[1], option[2]
+		if seen[name] {
+			return layoutOptions{}, fmt.Errorf("layout directive repeats %q", name)
+		}
+		seen[name] = true
+		if err := options.set(name, value); err != nil {
+			return layoutOptions{}, err
+		}
+	}
+	if options.SectionType == "" {
+		return layoutOptions{}, fmt.Errorf("layout directive requires a type")
+	}
+	return options, nil
+}
+
+func (o *layoutOptions) set(name, value string) error {
+	switch name {
+	case "type":
+		if layoutSectionCells[value] == 0 {
+			return fmt.Errorf("invalid layout section type %q", value)
+		}
+		o.SectionType = value
+	case "breakout-mode":
+		if !layoutBreakoutModes[value] {
+			return fmt.Errorf("invalid layout breakout mode %q", value)
+		}
+		o.BreakoutMode = value
+	case "breakout-width":
+		width, err := strconv.Atoi(value)
+		if err != nil || width < 1 {
+			return fmt.Errorf("invalid layout breakout width %q", value)
+		}
+		o.BreakoutWidth = width
+	default:
+		return fmt.Errorf("unsupported layout directive option %q", name)
+	}
+	return nil
+}
+
+// parseStorageLayout reads an ac:layout element into its sections, each a
+// list of the storage held by its cells.
+func parseStorageLayout(raw string) ([]layoutOptions, [][]string, bool) {
+	start, inner, ok := splitStorageElement(raw)
+	if !ok || start.Name.Space != "urn:conflux:ac" || start.Name.Local != "layout" || len(start.Attr) != 0 {
+		return nil, nil, false
+	}
+	sections, ok := storageLayoutChildren(inner, "layout-section")
+	if !ok || len(sections) == 0 {
+		return nil, nil, false
+	}
+	var options []layoutOptions
+	var cells [][]string
+	for _, section := range sections {
+		start, inner, _ := splitStorageElement(section)
+		var sectionOptions layoutOptions
+		for _, attribute := range start.Attr {
+			if attribute.Name.Space != "urn:conflux:ac" || sectionOptions.set(attribute.Name.Local, attribute.Value) != nil {
+				return nil, nil, false
+			}
+		}
+		sectionCells, ok := storageLayoutChildren(inner, "layout-cell")
+		if !ok || sectionOptions.SectionType == "" || len(sectionCells) != layoutSectionCells[sectionOptions.SectionType] {
+			return nil, nil, false
+		}
+		for index, cell := range sectionCells {
+			start, inner, _ := splitStorageElement(cell)
+			if len(start.Attr) != 0 {
+				return nil, nil, false
+			}
+			sectionCells[index] = inner
+		}
+		options = append(options, sectionOptions)
+		cells = append(cells, sectionCells)
+	}
+	return options, cells, true
+}
+
+// storageLayoutChildren returns the ac elements named name that make up
+// storage, which may hold nothing else but whitespace.
+func storageLayoutChildren(storage, name string) ([]string, bool) {
+	nodes, err := tokenizeStorage(storage)
+	if err != nil {
+		return nil, false
+	}
+	var children []string
+	for _, node := range nodes {
+		if strings.TrimSpace(node.Raw) == "" {
+			continue
+		}
+		if node.Name.Space != "urn:conflux:ac" || node.Name.Local != name {
+			return nil, false
+		}
+		children = append(children, node.Raw)
+	}
+	return children, true
+}
+
+// layoutMarkdown renders each section of a layout as a section directive
+// holding a cell directive before the Markdown of each cell.
+func (r *storageRenderer) layoutMarkdown(raw string) (string, bool, error) {
+	options, cells, ok := parseStorageLayout(raw)
+	if !ok {
+		return "", false, nil
+	}
+	var sections []string
+	for index, section := range options {
+		lines := []string{layoutSectionDirective(section)}
+		for _, cell := range cells[index] {
+			body, err := r.renderBlocks(cell)
+			if err != nil {
+				return "", false, fmt.Errorf("render layout cell: %w", err)
+			}
+			if len(lines) > 1 {
+				lines = append(lines, "")
+			}
+			lines = append(lines, layoutCellDirective)
+			if body != "" {
+				lines = append(lines, "", body)
+			}
+		}
+		lines = append(lines, "", layoutSectionCloseDirective)
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+	return strings.Join(sections, "\n\n"), true, nil
+}
+
+var (
+	kindLayoutSection = ast.NewNodeKind("ConfluxLayoutSection")
+	kindLayoutCell    = ast.NewNodeKind("ConfluxLayoutCell")
+)
+
+// layoutSection is a conflux:layout-section directive and the cells up to its
+// closing directive. Consecutive sections share one ac:layout. Structural
+// problems are recorded in Err and reported when rendering.
+type layoutSection struct {
+	ast.BaseBlock
+	layoutOptions
+	Closed bool
+	Err    error
+}
+
+func (n *layoutSection) Kind() ast.NodeKind { return kindLayoutSection }
+
+func (n *layoutSection) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Type": n.SectionType}, nil)
+}
+
+// layoutCell holds the blocks after a conflux:layout-cell directive, up to the
+// next cell directive or the end of its section.
+type layoutCell struct {
+	ast.BaseBlock
+	Err error
+}
+
+func (n *layoutCell) Kind() ast.NodeKind { return kindLayoutCell }
+
+func (n *layoutCell) Dump(source []byte, level int) { ast.DumpHelper(n, source, level, nil, nil) }
+
+type layoutParser struct{}
+
+func (layoutParser) Trigger() []byte { return []byte{'<'} }
+
+func (layoutParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, _ := reader.PeekLine()
+	trimmed := string(bytes.TrimSpace(line))
+	if !layoutDirectiveCandidate.MatchString(trimmed) {
+		return nil, parser.NoChildren
+	}
+	reader.AdvanceToEOL()
+	switch {
+	case layoutCellPattern.MatchString(trimmed):
+		if _, ok := parent.(*layoutSection); !ok {
+			return &layoutCell{Err: fmt.Errorf("layout cell directive must be inside a layout section")}, parser.NoChildren
+		}
+		return &layoutCell{}, parser.HasChildren
+	case layoutSectionClose.MatchString(trimmed):
+		return &layoutSection{Err: fmt.Errorf("layout section closing directive has no opening directive")}, parser.NoChildren
+	}
+	options, err := parseLayoutSectionDirective(trimmed)
+	if err == nil && parent.Kind() != ast.KindDocument {
+		err = fmt.Errorf("layout section directive must be at the top level of the page")
+	}
+	return &layoutSection{layoutOptions: options, Err: err}, parser.HasChildren
+}
+
+// Continue ends a cell at the next cell directive or closing directive and
+// ends a section at its closing directive.
+func (layoutParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	line, _ := reader.PeekLine()
+	trimmed := bytes.TrimSpace(line)
+	switch block := node.(type) {
+	case *layoutCell:
+		if block.Err != nil || layoutCellPattern.Match(trimmed) || layoutSectionClose.Match(trimmed) {
+			return parser.Close
+		}
+	case *layoutSection:
+		if block.Err != nil {
+			return parser.Close
+		}
+		if layoutSectionClose.Match(trimmed) {
+			block.Closed = true
+			reader.AdvanceToEOL()
+			return parser.Close
+		}
+	}
+	return parser.Continue | parser.HasChildren
+}
+
+// Close checks that a section holds only cells, as many as its type has
+// columns.
+func (layoutParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {
+	section, ok := node.(*layoutSection)
+	if !ok || section.Err != nil {
+		return
+	}
+	cells := 0
+	for child := section.FirstChild(); child != nil; child = child.NextSibling() {
+		if _, ok := child.(*layoutCell); !ok {
+			section.Err = fmt.Errorf("layout section content must follow a layout cell directive")
+			return
+		}
+		cells++
+	}
+	if want := layoutSectionCells[section.SectionType]; cells != want {
+		section.Err = fmt.Errorf("layout section %q has %d cells, want %d", section.SectionType, cells, want)
+	}
+}
+
+func (layoutParser) CanInterruptParagraph() bool { return true }
+
+func (layoutParser) CanAcceptIndentedLine() bool { return false }
+
+func (w *storageWriter) renderLayoutSection(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	section := node.(*layoutSection)
+	if section.Err == nil && !section.Closed {
+		return ast.WalkStop, fmt.Errorf("layout section is missing its closing %s directive", layoutSectionCloseDirective)
+	}
+	if section.Err != nil {
+		return ast.WalkStop, section.Err
+	}
+	if !entering {
+		_, _ = writer.WriteString("</ac:layout-section>")
+		if _, ok := node.NextSibling().(*layoutSection); !ok {
+			_, _ = writer.WriteString("</ac:layout>")
+		}
+		return ast.WalkContinue, nil
+	}
+	if _, ok := node.PreviousSibling().(*layoutSection); !ok {
+		_, _ = writer.WriteString("<ac:layout>")
+	}
+	_, _ = writer.WriteString(`<ac:layout-section ac:type="` + section.SectionType + `"`)
+	if section.BreakoutMode != "" {
+		_, _ = writer.WriteString(` ac:breakout-mode="` + section.BreakoutMode + `"`)
+	}
+	if section.BreakoutWidth > 0 {
+		_, _ = writer.WriteString(` ac:breakout-width="` + strconv.Itoa(section.BreakoutWidth) + `"`)
+	}
+	_, _ = writer.WriteString(">")
+	return ast.WalkContinue, nil
+}
+
+func (w *storageWriter) renderLayoutCell(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if cell := node.(*layoutCell); cell.Err != nil {
+		return ast.WalkStop, cell.Err
+	}
+	return w.renderTag("ac:layout-cell")(writer, source, node, entering)
+}
@@ -0,0 +1,73 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderArtifactConvertsLayoutDirectives(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	markdown := "Intro.\n\n" +
+		"<!-- conflux:layout-section type=\"two_left_sidebar\" breakout-width=\"1200\" -->\n" +
+		"<!-- conflux:layout-cell -->\n- One\n- Two\n" +
+		"<!-- conflux:layout-cell -->\n\nEdited *text*.\n\n" +
+		"<!-- /conflux:layout-section -->\n" +
+		"<!-- conflux:layout-section type=\"single\" -->\n<!-- conflux:layout-cell -->\n<!-- /conflux:layout-section -->\n\n" +
+		"Outro.\n"
+	artifact, err := RenderArtifact(markdown, metadata, nil)
+	if err != nil {
+		t.Fatalf("RenderArtifact returned error: %v", err)
+	}
+	want := `<p>Intro.</p><ac:layout>` +
+		`<ac:layout-section ac:type="two_left_sidebar" ac:breakout-width="1200"><ac:layout-cell><ul><li>One</li><li>Two</li></ul></ac:layout-cell><ac:layout-cell><p>Edited <em>text</em>.</p></ac:layout-cell></ac:layout-section>` +
+		`<ac:layout-section ac:type="single"><ac:layout-cell></ac:layout-cell></ac:layout-section>` +
+		`</ac:layout><p>Outro.</p>`
+	if artifact.Storage != want {
+		t.Fatalf("storage = %s, want %s", artifact.Storage, want)
+	}
+}
+
+func TestRenderArtifactRejectsInvalidLayoutDirectives(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{name: "unclosed", markdown: "<!-- conflux:layout-section type=\"single\" -->\n<!-- conflux:layout-cell -->\nText.\n", want: "missing its closing"},
+		{name: "cell count", markdown: "<!-- conflux:layout-section type=\"two_equal\" -->\n<!-- conflux:layout-cell -->\nText.\n<!-- /conflux:layout-section -->\n", want: "has 1 cells, want 2"},
+		{name: "content before cell", markdown: "<!-- conflux:layout-section type=\"single\" -->\nText.\n<!-- conflux:layout-cell -->\n<!-- /conflux:layout-section -->\n", want: "must follow a layout cell directive"},
+		{name: "stray cell", markdown: "<!-- conflux:layout-cell -->\nText.\n", want: "must be inside a layout section"},
+		{name: "stray close", markdown: "Text.\n<!-- /conflux:layout-section -->\n", want: "has no opening directive"},
+		{name: "nested", markdown: "- <!-- conflux:layout-section type=\"single\" -->\n  <!-- conflux:layout-cell -->\n  <!-- /conflux:layout-section -->\n", want: "top level"},
+		{name: "unknown type", markdown: "<!-- conflux:layout-section type=\"seven_equal\" -->\n<!-- conflux:layout-cell -->\n<!-- /conflux:layout-section -->\n", want: "invalid layout section type"},
+		{name: "unknown option", markdown: "<!-- conflux:layout-section type=\"single\" width=\"2\" -->\n<!-- conflux:layout-cell -->\n<!-- /conflux:layout-section -->\n", want: "unsupported layout directive option"},
+		{name: "missing type", markdown: "<!-- conflux:layout-section -->\n<!-- conflux:layout-cell -->\n<!-- /conflux:layout-section -->\n", want: "requires a type"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := RenderArtifact(test.markdown, metadata, nil)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("RenderArtifact error = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderStoragePreservesLayoutsWithUnsupportedAttributes(t *testing.T) {
+	for _, storage := range []string{
+		`<ac:layout><ac:layout-section ac:type="single" data-custom="x"><ac:layout-cell><p>Text</p></ac:layout-cell></ac:layout-section></ac:layout>`,
+		`<ac:layout><ac:layout-section ac:type="two_equal"><ac:layout-cell><p>Only one</p></ac:layout-cell></ac:layout-section></ac:layout>`,
+		`<ac:layout><ac:layout-section ac:type="single"><ac:layout-cell ac:local-id="c1"><p>Text</p></ac:layout-cell></ac:layout-section></ac:layout>`,
+	} {
+		artifact, err := RenderStorage(testStoragePage(storage))
+		if err != nil {
+			t.Fatalf("RenderStorage returned error: %v", err)
+		}
+		if artifact.Metadata.PreservedFragments["fragment-0001"] != storage {
+			t.Fatalf("layout was not preserved:\n%s", artifact.Markdown)
+		}
+	}
+}
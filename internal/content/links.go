@@ -0,0 +1,377 @@
+package content
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// storageLink is an ac:link with its resource identifier and optional body.
+type storageLink struct {
+	Anchor   string
+	Resource xml.StartElement
+	BodyKind string
+	Body     string
+}
+
+const (
+	plainTextLinkBody = "plain-text-link-body"
+	richLinkBody      = "link-body"
+)
+
+func parseStorageLink(raw string) (storageLink, bool) {
+	start, inner, ok := splitStorageElement(raw)
+	if !ok || start.Name.Space != "urn:conflux:ac" || start.Name.Local != "link" {
+		return storageLink{}, false
+	}
+	var link storageLink
+	for _, attribute := range start.Attr {
+		if attribute.Name.Space != "urn:conflux:ac" || attribute.Name.Local != "anchor" {
+			return storageLink{}, false
+		}
+		link.Anchor = attribute.Value
+	}
+	children, err := tokenizeStorage(inner)
+	if err != nil {
+		return storageLink{}, false
+	}
+	for _, child := range children {
+		if strings.TrimSpace(child.Raw) == "" {
+			continue
+		}
+		childStart, childInner, ok := splitStorageElement(child.Raw)
+		if !ok {
+			return storageLink{}, false
+		}
+		switch {
+		case child.Name.Space == "urn:conflux:ri" && link.Resource.Name.Local == "" && strings.TrimSpace(childInner) == "":
+			link.Resource = childStart
+		case child.Name.Space == "urn:conflux:ac" && link.BodyKind == "" && len(childStart.Attr) == 0 &&
+			(child.Name.Local == plainTextLinkBody || child.Name.Local == richLinkBody):
+			link.BodyKind = child.Name.Local
+			link.Body = childInner
+		default:
+			return storageLink{}, false
+		}
+	}
+	if link.BodyKind == plainTextLinkBody {
+		body, ok := plainTextContent(link.Body)
+		if !ok {
+			return storageLink{}, false
+		}
+		link.Body = body
+	}
+	return link, link.Resource.Name.Local != "" || link.Anchor != ""
+}
+
+// plainTextContent returns the character data of storage that holds only
+// text and CDATA sections.
+func plainTextContent(raw string) (string, bool) {
+	decoder := storageNodeDecoder(raw)
+	var value strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return value.String(), err == io.EOF
+		}
+		switch token := token.(type) {
+		case xml.CharData:
+			value.Write(token)
+		case xml.StartElement:
+			if token.Name.Local != "conflux-root" {
+				return "", false
+			}
+		}
+	}
+}
+
+func resourceAttributes(start xml.StartElement) (map[string]string, bool) {
+	values := make(map[string]string, len(start.Attr))
+	for _, attribute := range start.Attr {
+		if attribute.Name.Space != "urn:conflux:ri" {
+			return nil, false
+		}
+		values[attribute.Name.Local] = attribute.Value
+	}
+	return values, true
+}
+
+// pageTarget is the destination of a confluence: link. A page is identified
+// either by ContentID or by Title within SpaceKey, which is empty for pages in
+// the same space.
+type pageTarget struct {
+	SpaceKey  string
+	Title     string
+	ContentID string
+	Anchor    string
+}
+
+var (
+	pageLinkSpaceKey  = regexp.MustCompile(`^~?[A-Za-z0-9_-]+$`)
+	pageLinkContentID = regexp.MustCompile(`^[0-9]+$`)
+	targetEscape      = strings.NewReplacer(":", "%3A", "(", "%28", ")", "%29")
+)
+
+func escapeTargetSegment(value string) string {
+	return targetEscape.Replace(url.PathEscape(value))
+}
+
+func formatPageTarget(target pageTarget) string {
+	destination := "confluence:"
+	switch {
+	case target.ContentID != "":
+		destination += "id:" + target.ContentID
+	case target.SpaceKey != "":
+		destination += escapeTargetSegment(target.SpaceKey) + "/" + escapeTargetSegment(target.Title)
+	default:
+		destination += escapeTargetSegment(target.Title)
+	}
+	if target.Anchor != "" {
+		destination += "#" + escapeTargetSegment(target.Anchor)
+	}
+	return destination
+}
+
+func parsePageTarget(destination string) (pageTarget, error) {
+	value, ok := strings.CutPrefix(destination, "confluence:")
+	if !ok {
+		return pageTarget{}, fmt.Errorf("invalid Confluence page link %q", destination)
+	}
+	var target pageTarget
+	var err error
+	if page, anchor, found := strings.Cut(value, "#"); found {
+		value = page
+		if target.Anchor, err = url.PathUnescape(anchor); err != nil || target.Anchor == "" {
+			return pageTarget{}, fmt.Errorf("invalid Confluence page link anchor %q", anchor)
+		}
+	}
+	if id, found := strings.CutPrefix(value, "id:"); found {
+		if !pageLinkContentID.MatchString(id) {
+			return pageTarget{}, fmt.Errorf("invalid Confluence page ID %q", id)
+		}
+		target.ContentID = id
+		return target, nil
+	}
+	title := value
+	if space, rest, found := strings.Cut(value, "/"); found {
+		if !pageLinkSpaceKey.MatchString(space) {
+			return pageTarget{}, fmt.Errorf("invalid Confluence space key %q", space)
+		}
+		target.SpaceKey, title = space, rest
+	}
+	if target.Title, err = url.PathUnescape(title); err != nil || strings.TrimSpace(target.Title) == "" {
+		return pageTarget{}, fmt.Errorf("invalid Confluence page title %q", title)
+	}
+	return target, nil
+}
+
+// pageLinkMarkdown renders a link to another Confluence page. A link without
+// a body shows the page title, so a title-based link uses the title as its
+// text; an ID-based link without a body has no known text and stays opaque.
+func (r *storageRenderer) pageLinkMarkdown(link storageLink) (string, bool, error) {
+	target, ok := storagePageTarget(link)
+	if !ok || (target.ContentID != "" && link.BodyKind == "") {
+		return "", false, nil
+	}
+	text, ok, err := r.linkText(link, target.Title)
+	if err != nil || !ok {
+		return "", ok, err
+	}
+	return "[" + text + "](" + formatPageTarget(target) + ")", true, nil
+}
+
+// anchorLinkMarkdown renders a link to an anchor on the same page. A link
+// without a body shows the anchor name.
+func (r *storageRenderer) anchorLinkMarkdown(link storageLink) (string, bool, error) {
+	text, ok, err := r.linkText(link, link.Anchor)
+	if err != nil || !ok {
+		return "", ok, err
+	}
+	return "[" + text + "](#" + escapeTargetSegment(link.Anchor) + ")", true, nil
+}
+
+// linkText returns the Markdown text of a link: its body, or defaultText when
+// it has none.
+func (r *storageRenderer) linkText(link storageLink, defaultText string) (string, bool, error) {
+	var text string
+	switch link.BodyKind {
+	case "":
+		text = markdownLabelEscape.Replace(defaultText)
+	case plainTextLinkBody:
+		text = markdownLabelEscape.Replace(link.Body)
+	default:
+		markdown, ok, err := r.inlineMarkdown(link.Body)
+		if err != nil || !ok {
+			return "", ok, err
+		}
+		text = markdown
+	}
+	if strings.TrimSpace(text) == "" || strings.Contains(text, "\n") {
+		return "", false, nil
+	}
+	return text, true, nil
+}
+
+// storagePageTarget reads the page a link to a ri:page resource points at.
+func storagePageTarget(link storageLink) (pageTarget, bool) {
+	values, ok := resourceAttributes(link.Resource)
+	if !ok || link.Resource.Name.Local != "page" {
+		return pageTarget{}, false
+	}
+	target := pageTarget{Anchor: link.Anchor}
+	for name, value := range values {
+		switch name {
+		case "content-title":
+			target.Title = value
+		case "space-key":
+			target.SpaceKey = value
+		case "content-id":
+			target.ContentID = value
+		case "version-at-save":
+			// Confluence records the page version on every save, so the
+			// attribute is dropped rather than kept stale.
+		default:
+			return pageTarget{}, false
+		}
+	}
+	switch {
+	case target.ContentID != "":
+		if target.Title != "" || target.SpaceKey != "" || !pageLinkContentID.MatchString(target.ContentID) {
+			return pageTarget{}, false
+		}
+	case strings.TrimSpace(target.Title) == "":
+		return pageTarget{}, false
+	case target.SpaceKey != "" && !pageLinkSpaceKey.MatchString(target.SpaceKey):
+		return pageTarget{}, false
+	}
+	return target, true
+}
+
+// pageLinkStorage opens an ac:link for a confluence: target. The caller writes
+// the body and closes the link.
+func pageLinkStorage(target pageTarget) string {
+	var storage strings.Builder
+	storage.WriteString("<ac:link")
+	if target.Anchor != "" {
+		storage.WriteString(` ac:anchor="` + html.EscapeString(target.Anchor) + `"`)
+	}
+	storage.WriteString("><ri:page")
+	if target.ContentID != "" {
+		storage.WriteString(` ri:content-id="` + target.ContentID + `"`)
+	}
+	if target.SpaceKey != "" {
+		storage.WriteString(` ri:space-key="` + html.EscapeString(target.SpaceKey) + `"`)
+	}
+	if target.Title != "" {
+		storage.WriteString(` ri:content-title="` + html.EscapeString(target.Title) + `"`)
+	}
+	storage.WriteString(" />")
+	return storage.String()
+}
+
+// attachmentLinkMarkdown renders a link to a file attached to this page. Like
+// page links, a link without a body shows the filename.
+func (r *storageRenderer) attachmentLinkMarkdown(link storageLink) (string, bool, error) {
+	values, ok := resourceAttributes(link.Resource)
+	filename := values["filename"]
+	if !ok || len(values) != 1 || filename == "" || link.Anchor != "" {
+		return "", false, nil
+	}
+	text, ok, err := r.linkText(link, filename)
+	if err != nil || !ok {
+		return "", ok, err
+	}
+	return "[" + text + "](" + attachmentMarkdownPath(r.attachmentDirectory, filename) + ")", true, nil
+}
+
+const viewFileMacro = "view-file"
+
+// parseViewFileMacro reads a view-file macro, which embeds an attachment
+// preview. Parameters other than the attachment name are returned in order.
+func parseViewFileMacro(node storageNode) (string, []attribute, bool) {
+	if !isStructuredMacro(node) {
+		return "", nil, false
+	}
+	start, inner, ok := splitStorageElement(node.Raw)
+	if !ok || acAttribute(start, "name") != viewFileMacro {
+		return "", nil, false
+	}
+	children, err := tokenizeStorage(inner)
+	if err != nil {
+		return "", nil, false
+	}
+	var filename string
+	var parameters []attribute
+	for _, child := range children {
+		if strings.TrimSpace(child.Raw) == "" {
+			continue
+		}
+		if child.Name.Space != "urn:conflux:ac" || child.Name.Local != "parameter" {
+			return "", nil, false
+		}
+		parameterStart, value, ok := splitStorageElement(child.Raw)
+		name := acAttribute(parameterStart, "name")
+		if !ok || !attributeKey.MatchString(name) || name == "conflux-display" {
+			return "", nil, false
+		}
+		if name != "name" {
+			text, ok := plainTextContent(value)
+			if !ok || strings.ContainsAny(text, "\r\n") {
+				return "", nil, false
+			}
+			parameters = append(parameters, attribute{Key: name, Value: text})
+			continue
+		}
+		resource, resourceInner, ok := splitStorageElement(strings.TrimSpace(value))
+		values, valid := resourceAttributes(resource)
+		if !ok || !valid || resource.Name.Space != "urn:conflux:ri" || resource.Name.Local != "attachment" ||
+			len(values) != 1 || values["filename"] == "" || strings.TrimSpace(resourceInner) != "" || filename != "" {
+			return "", nil, false
+		}
+		filename = values["filename"]
+	}
+	return filename, parameters, filename != ""
+}
+
+func (r *storageRenderer) viewFileMarkdown(filename string, parameters []attribute) string {
+	attributes := append([]attribute{{Key: "conflux-display", Value: "embed"}}, parameters...)
+	return "[" + markdownLabelEscape.Replace(filename) + "](" + attachmentMarkdownPath(r.attachmentDirectory, filename) + ")" +
+		formatAttributeBlock(attributes)
+}
+
+// isEmbedLink reports whether a Markdown link embeds an attachment preview
+// rather than linking to it.
+func isEmbedLink(link *ast.Link) bool {
+	if attachmentFilename(string(link.Destination)) == "" {
+		return false
+	}
+	for _, attribute := range attributesOf(link) {
+		if attribute.Key == "conflux-display" && attribute.Value == "embed" {
+			return true
+		}
+	}
+	return false
+}
+
+func viewFileStorage(filename string, attributes []attribute) (string, error) {
+	var storage strings.Builder
+	storage.WriteString(`<ac:structured-macro ac:name="view-file" ac:schema-version="1">`)
+	storage.WriteString(`<ac:parameter ac:name="name"><ri:attachment ri:filename="` + html.EscapeString(filename) + `" /></ac:parameter>`)
+	for _, attribute := range attributes {
+		switch {
+		case attribute.Key == "conflux-display":
+		case strings.HasPrefix(attribute.Key, ".") || attribute.Key == "name":
+			return "", fmt.Errorf("unsupported attachment embed attribute %q", attribute.Key)
+		default:
+			storage.WriteString(`<ac:parameter ac:name="` + html.EscapeString(attribute.Key) + `">` + html.EscapeString(attribute.Value) + `</ac:parameter>`)
+		}
+	}
+	storage.WriteString(`</ac:structured-macro>`)
+	return storage.String(), nil
+}
@@ -0,0 +1,119 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderArtifactConvertsPageLinks(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{
+			name:     "title as text",
+			markdown: "[Guide](confluence:DOCS/Guide)\n",
+			want:     `<p><ac:link><ri:page ri:space-key="DOCS" ri:content-title="Guide" /></ac:link></p>`,
+		},
+		{
+			name:     "edited text and anchor",
+			markdown: "Read [the setup](confluence:Install%20%26%20Run#first-steps).\n",
+			want:     `<p>Read <ac:link ac:anchor="first-steps"><ri:page ri:content-title="Install &amp; Run" /><ac:plain-text-link-body><![CDATA[the setup]]></ac:plain-text-link-body></ac:link>.</p>`,
+		},
+		{
+			name:     "page id with formatted text",
+			markdown: "[*draft* notes](confluence:id:42)\n",
+			want:     `<p><ac:link><ri:page ri:content-id="42" /><ac:link-body><em>draft</em> notes</ac:link-body></ac:link></p>`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			artifact, err := RenderArtifact(test.markdown, metadata, nil)
+			if err != nil {
+				t.Fatalf("RenderArtifact returned error: %v", err)
+			}
+			if artifact.Storage != test.want {
+				t.Fatalf("storage = %s, want %s", artifact.Storage, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderArtifactRejectsInvalidPageLinks(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	for name, markdown := range map[string]string{
+		"empty":       "[x](confluence:)\n",
+		"bad id":      "[x](confluence:id:abc)\n",
+		"bad space":   "[x](confluence:bad.key/Title)\n",
+		"empty title": "[x](confluence:DOCS/)\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := RenderArtifact(markdown, metadata, nil); err == nil {
+				t.Fatal("RenderArtifact unexpectedly succeeded")
+			}
+		})
+	}
+}
+
+func TestRenderStoragePreservesUnrepresentablePageLinks(t *testing.T) {
+	for name, link := range map[string]string{
+		"id without body":    `<ac:link><ri:page ri:content-id="42" /></ac:link>`,
+		"unknown attribute":  `<ac:link><ri:page ri:content-title="Guide" ri:content-type="page" /></ac:link>`,
+		"blog post resource": `<ac:link><ri:blog-post ri:content-title="News" ri:posting-day="2024/01/02" /></ac:link>`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			artifact, err := RenderStorage(testStoragePage("<p>See " + link + "</p>"))
+			if err != nil {
+				t.Fatalf("RenderStorage returned error: %v", err)
+			}
+			if artifact.Metadata.PreservedFragments["fragment-0001"] != link {
+				t.Fatalf("link was not preserved:\n%s", artifact.Markdown)
+			}
+			if !strings.HasPrefix(artifact.Markdown, `See <!-- conflux:inline id="fragment-0001" -->`) {
+				t.Fatalf("paragraph text is not editable:\n%s", artifact.Markdown)
+			}
+		})
+	}
+}
+
+func TestRenderArtifactUploadsNewlyLinkedFiles(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	markdown := "See [*Q3* report](page.attachments/report.pdf).\n\n[sheet.xlsx](page.attachments/sheet.xlsx){conflux-display=embed height=250}\n"
+	local := []LocalAttachment{
+		{Filename: "report.pdf", MediaType: "application/pdf", Content: []byte("pdf")},
+		{Filename: "sheet.xlsx", MediaType: "application/vnd.ms-excel", Content: []byte("xlsx")},
+	}
+	artifact, err := RenderArtifact(markdown, metadata, local)
+	if err != nil {
+		t.Fatalf("RenderArtifact returned error: %v", err)
+	}
+	want := `<p>See <ac:link><ri:attachment ri:filename="report.pdf" /><ac:link-body><em>Q3</em> report</ac:link-body></ac:link>.</p>` +
+		`<ac:structured-macro ac:name="view-file" ac:schema-version="1"><ac:parameter ac:name="name"><ri:attachment ri:filename="sheet.xlsx" /></ac:parameter><ac:parameter ac:name="height">250</ac:parameter></ac:structured-macro>`
+	if artifact.Storage != want {
+		t.Fatalf("storage = %s, want %s", artifact.Storage, want)
+	}
+	if len(artifact.Uploads) != 2 || artifact.Uploads[0].Filename != "report.pdf" || artifact.Uploads[1].Filename != "sheet.xlsx" {
+		t.Fatalf("uploads = %#v", artifact.Uploads)
+	}
+}
+
+func TestRenderArtifactRejectsUnsupportedAttachmentLinkAttributes(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	local := []LocalAttachment{{Filename: "report.pdf", Content: []byte("pdf")}}
+	for name, markdown := range map[string]string{
+		"not embed":   "[report](page.attachments/report.pdf){height=250}\n",
+		"embed class": "[report](page.attachments/report.pdf){conflux-display=embed .wide}\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := RenderArtifact(markdown, metadata, local); err == nil {
+				t.Fatal("RenderArtifact unexpectedly succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,379 @@
+package content
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var inlineStorageElements = map[string]bool{
+	"a": true, "abbr": true, "b": true, "br": true, "cite": true, "code": true, "del": true, "em": true,
+	"i": true, "ins": true, "kbd": true, "mark": true, "s": true, "small": true, "span": true,
+	"strong": true, "sub": true, "sup": true, "time": true, "u": true,
+}
+
+// inlineStorageMacros are structured macros that Confluence renders inside a
+// line of text rather than as their own block.
+var inlineStorageMacros = map[string]bool{"anchor": true, "jira": true, "status": true}
+
+type listBlock struct {
+	nodes  []storageNode
+	inline bool
+}
+
+func isStorageList(node storageNode) bool {
+	if node.Name.Space != "" {
+		return false
+	}
+	name := strings.ToLower(node.Name.Local)
+	return name == "ul" || name == "ol"
+}
+
+// storageListKind returns the Markdown list type of a ul or ol element.
+func storageListKind(node storageNode) string {
+	if strings.EqualFold(node.Name.Local, "ol") {
+		return "ordered"
+	}
+	return "bullet"
+}
+
+// listMarkdown renders a ul or ol element, including nested lists and block
+// content inside items. Alternate selects the second bullet or delimiter, which
+// keeps the list apart from a list of the same type directly before it. It
+// reports false when the list cannot be represented without changing its
+// structure, in which case the caller preserves it.
+func (r *storageRenderer) listMarkdown(node storageNode, alternate bool) (string, bool, error) {
+	start, inner, ok := splitStorageElement(node.Raw)
+	if !ok || hasNamespacedAttribute(start) {
+		return "", false, nil
+	}
+	ordered := strings.EqualFold(start.Name.Local, "ol")
+	number := 1
+	if value := htmlAttribute(start, "start"); ordered && value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 || parsed > 999999999 {
+			return "", false, nil
+		}
+		number = parsed
+	}
+
+	itemNodes, err := tokenizeStorage(inner)
+	if err != nil {
+		return "", false, fmt.Errorf("tokenize list items: %w", err)
+	}
+	var items [][]listBlock
+	loose := false
+	for _, item := range itemNodes {
+		if strings.TrimSpace(item.Raw) == "" {
+			continue
+		}
+		if item.Name.Space != "" || !strings.EqualFold(item.Name.Local, "li") {
+			return "", false, nil
+		}
+		itemStart, itemInner, ok := splitStorageElement(item.Raw)
+		if !ok || hasNamespacedAttribute(itemStart) {
+			return "", false, nil
+		}
+		children, err := tokenizeStorage(itemInner)
+		if err != nil {
+			return "", false, fmt.Errorf("tokenize list item: %w", err)
+		}
+		var blocks []listBlock
+		for _, child := range children {
+			if child.Name.Space == "" && strings.EqualFold(child.Name.Local, "p") {
+				loose = true
+			}
+			inline := isInlineStorageNode(child)
+			if inline && len(blocks) > 0 && blocks[len(blocks)-1].inline {
+				blocks[len(blocks)-1].nodes = append(blocks[len(blocks)-1].nodes, child)
+				continue
+			}
+			blocks = append(blocks, listBlock{nodes: []storageNode{child}, inline: inline})
+		}
+		items = append(items, blocks)
+	}
+	if len(items) == 0 {
+		return "", false, nil
+	}
+
+	separator := "\n"
+	if loose {
+		separator = "\n\n"
+	}
+	renderedItems := make([]string, 0, len(items))
+	for index, blocks := range items {
+		var parts []string
+		var lists adjacentLists
+		previousInline := false
+		for _, block := range blocks {
+			if block.inline {
+				markdown, ok, err := r.inlineRunMarkdown(block.nodes)
+				if err != nil || !ok {
+					return "", ok, err
+				}
+				if markdown == "" {
+					continue
+				}
+				lists.reset()
+				if !loose && len(parts) > 0 && !tightBlockBoundary(parts[len(parts)-1]) {
+					return "", false, nil
+				}
+				parts = append(parts, markdown)
+				previousInline = true
+				continue
+			}
+			node := block.nodes[0]
+			if !loose && previousInline && isStorageList(node) && !listInterruptsParagraph(node) {
+				return "", false, nil
+			}
+			markdown, err := r.renderBlocksAfter(node.Raw, &lists)
+			if err != nil {
+				return "", false, err
+			}
+			if markdown != "" {
+				parts = append(parts, markdown)
+			}
+			previousInline = false
+		}
+
+		marker := bulletMarker(alternate)
+		if ordered {
+			marker = strconv.Itoa(number+index) + "."
+			if alternate {
+				marker = strconv.Itoa(number+index) + ")"
+			}
+		}
+		renderedItems = append(renderedItems, listItemMarkdown(marker, strings.Join(parts, separator)))
+	}
+	return strings.Join(renderedItems, separator), true, nil
+}
+
+func (r *storageRenderer) inlineRunMarkdown(nodes []storageNode) (string, bool, error) {
+	var raw strings.Builder
+	var content []storageNode
+	for _, node := range nodes {
+		raw.WriteString(node.Raw)
+		if strings.TrimSpace(node.Raw) != "" {
+			content = append(content, node)
+		}
+	}
+	if len(content) == 0 {
+		return "", true, nil
+	}
+	if len(content) == 1 && isStorageImage(content[0]) {
+		markdown, err := r.renderBlocks(content[0].Raw)
+		return markdown, err == nil, err
+	}
+	return r.inlineMarkdown(raw.String())
+}
+
+// tightBlockBoundary reports whether text placed on the line after block
+// starts a new paragraph instead of continuing the block.
+func tightBlockBoundary(block string) bool {
+	lines := strings.Split(block, "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	if marker := preservationMarker.FindString(last); marker != "" && marker == last {
+		return true
+	}
+	if marker := macroCloseMarker.FindString(last); (marker != "" && marker == last) || last == parametersBlockClose {
+		return true
+	}
+	if fence, length := fenceMarker(strings.TrimSpace(lines[0])); fence != 0 && len(lines) > 1 {
+		closing, closingLength := fenceMarker(last)
+		return closing == fence && closingLength >= length && strings.Trim(last, string(fence)) == ""
+	}
+	return false
+}
+
+// bulletMarker returns the bullet of a list item. CommonMark starts a new list
+// when the bullet changes, so adjacent lists alternate between - and *.
+func bulletMarker(alternate bool) string {
+	if alternate {
+		return "*"
+	}
+	return "-"
+}
+
+// adjacentLists tracks the lists rendered one after another in a run of
+// blocks, so that each list uses other delimiters than a list of the same
+// type directly before it and does not merge into it.
+type adjacentLists struct {
+	previous  string
+	alternate bool
+}
+
+// next returns whether a list of kind, "bullet" or "ordered", uses the
+// alternate delimiters.
+func (l *adjacentLists) next(kind string) bool {
+	l.alternate = kind == l.previous && !l.alternate
+	l.previous = kind
+	return l.alternate
+}
+
+// reset records a block other than a list.
+func (l *adjacentLists) reset() {
+	l.previous, l.alternate = "", false
+}
+
+func listInterruptsParagraph(node storageNode) bool {
+	start, _, ok := splitStorageElement(node.Raw)
+	if !ok || !strings.EqualFold(start.Name.Local, "ol") {
+		return true
+	}
+	value := htmlAttribute(start, "start")
+	return value == "" || value == "1"
+}
+
+func listItemMarkdown(marker, body string) string {
+	if body == "" {
+		return marker
+	}
+	indent := strings.Repeat(" ", len(marker)+1)
+	lines := strings.Split(body, "\n")
+	lines[0] = marker + " " + lines[0]
+	for index := 1; index < len(lines); index++ {
+		if lines[index] != "" {
+			lines[index] = indent + lines[index]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isInlineStorageNode(node storageNode) bool {
+	switch node.Name.Space {
+	case "":
+		if node.Name.Local == "" {
+			return !strings.HasPrefix(strings.TrimSpace(node.Raw), "<!--")
+		}
+		return inlineStorageElements[strings.ToLower(node.Name.Local)]
+	case "urn:conflux:ac":
+		switch node.Name.Local {
+		case "image", "link", "emoticon", "inline-comment-marker", "placeholder":
+			return true
+		case "structured-macro":
+			start, _, ok := splitStorageElement(node.Raw)
+			return ok && inlineStorageMacros[acAttribute(start, "name")]
+		}
+	}
+	return false
+}
+
+// splitStorageElement returns the start tag and inner storage of a fragment
+// holding exactly one element.
+func splitStorageElement(raw string) (xml.StartElement, string, bool) {
+	const prefix = `<conflux-root xmlns:ac="urn:conflux:ac" xmlns:ri="urn:conflux:ri">`
+	source := prefix + raw + `</conflux-root>`
+	decoder := xml.NewDecoder(strings.NewReader(source))
+	decoder.Strict = false
+
+	depth := 0
+	var start xml.StartElement
+	innerStart := -1
+	for {
+		before := int(decoder.InputOffset())
+		token, err := decoder.Token()
+		if err != nil {
+			return xml.StartElement{}, "", false
+		}
+		switch value := token.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 2 {
+				start = value.Copy()
+				innerStart = int(decoder.InputOffset())
+			}
+		case xml.EndElement:
+			if depth == 2 {
+				return start, source[innerStart:before], true
+			}
+			depth--
+		}
+	}
+}
+
+func htmlAttribute(start xml.StartElement, name string) string {
+	for _, attribute := range start.Attr {
+		if attribute.Name.Space == "" && strings.EqualFold(attribute.Name.Local, name) {
+			return attribute.Value
+		}
+	}
+	return ""
+}
+
+func hasNamespacedAttribute(start xml.StartElement) bool {
+	for _, attribute := range start.Attr {
+		if attribute.Name.Space != "" {
+			return true
+		}
+	}
+	return false
+}
+
+type listMarker struct {
+	ordered       bool
+	delimiter     byte
+	number        int
+	indent        int
+	contentIndent int
+	content       string
+}
+
+// parseListMarker recognizes a CommonMark bullet or ordered list item marker.
+// Indentation is measured in columns with tabs expanded to multiples of four.
+func parseListMarker(line string) (listMarker, bool) {
+	indent, offset := leadingColumns(line)
+	rest := line[offset:]
+	marker := listMarker{indent: indent}
+	width := 0
+	switch {
+	case rest == "":
+		return listMarker{}, false
+	case rest[0] == '-' || rest[0] == '*' || rest[0] == '+':
+		marker.delimiter = rest[0]
+		width = 1
+	default:
+		for width < len(rest) && width < 9 && rest[width] >= '0' && rest[width] <= '9' {
+			width++
+		}
+		if width == 0 || width == len(rest) || (rest[width] != '.' && rest[width] != ')') {
+			return listMarker{}, false
+		}
+		marker.ordered = true
+		marker.delimiter = rest[width]
+		marker.number, _ = strconv.Atoi(rest[:width])
+		width++
+	}
+	after := rest[width:]
+	if after != "" && after[0] != ' ' && after[0] != '\t' {
+		return listMarker{}, false
+	}
+	spacing, spacingOffset := leadingColumnsFrom(after, indent+width)
+	marker.content = strings.TrimRight(after[spacingOffset:], " \t")
+	if marker.content == "" || spacing > 4 {
+		marker.contentIndent = indent + width + 1
+	} else {
+		marker.contentIndent = indent + width + spacing
+	}
+	return marker, true
+}
+
+func leadingColumns(line string) (int, int) {
+	return leadingColumnsFrom(line, 0)
+}
+
+// leadingColumnsFrom measures leading whitespace starting at column start and
+// returns the number of columns it spans and its length in bytes.
+func leadingColumnsFrom(line string, start int) (int, int) {
+	column := start
+	offset := 0
+	for offset < len(line) && (line[offset] == ' ' || line[offset] == '\t') {
+		if line[offset] == '\t' {
+			column += 4 - column%4
+		} else {
+			column++
+		}
+		offset++
+	}
+	return column - start, offset
+}
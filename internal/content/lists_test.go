@@ -0,0 +1,110 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderArtifactConvertsNestedAndLooseLists(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{
+			name:     "nested mixed types",
+			markdown: "- One\n  1. Sub\n  2. Sub two\n     - Deep\n- Two\n",
+			want:     "<ul><li>One<ol><li>Sub</li><li>Sub two<ul><li>Deep</li></ul></li></ol></li><li>Two</li></ul>",
+		},
+		{
+			name:     "start number",
+			markdown: "3) Third\n4) Fourth\n",
+			want:     `<ol start="3"><li>Third</li><li>Fourth</li></ol>`,
+		},
+		{
+			name:     "loose items",
+			markdown: "- First\n\n  More text.\n- Second\n",
+			want:     "<ul><li><p>First</p><p>More text.</p></li><li><p>Second</p></li></ul>",
+		},
+		{
+			name:     "lazy continuation",
+			markdown: "- Wrapped\nline\n- Next\n",
+			want:     "<ul><li>Wrapped line</li><li>Next</li></ul>",
+		},
+		{
+			name:     "code in tight item",
+			markdown: "1. Run\n   ```sh\n   make\n   ```\n2. Done\n",
+			want:     `<ol><li>Run<ac:structured-macro ac:name="code" ac:schema-version="1"><ac:parameter ac:name="language">sh</ac:parameter><ac:plain-text-body><![CDATA[make]]></ac:plain-text-body></ac:structured-macro></li><li>Done</li></ol>`,
+		},
+		{
+			name:     "list type change",
+			markdown: "- Bullet\n1. Number\n",
+			want:     "<ul><li>Bullet</li></ul><ol><li>Number</li></ol>",
+		},
+		{
+			name:     "ordered list does not interrupt paragraph",
+			markdown: "The year was\n2024. It rained.\n",
+			want:     "<p>The year was 2024. It rained.</p>",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			artifact, err := RenderArtifact(test.markdown, metadata, nil)
+			if err != nil {
+				t.Fatalf("RenderArtifact returned error: %v", err)
+			}
+			if artifact.Storage != test.want {
+				t.Fatalf("storage = %s, want %s", artifact.Storage, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderArtifactRestoresMarkerInsideListItem(t *testing.T) {
+	metadata := pushMetadata()
+	markdown := "- Before\n  <!-- conflux:preserved id=\"fragment-0001\" -->\n- <!-- conflux:preserved id=\"fragment-0002\" -->\n"
+	metadata.PreservedFragments["fragment-0002"] = `<ac:structured-macro ac:name="toc" />`
+	artifact, err := RenderArtifact(markdown, metadata, nil)
+	if err != nil {
+		t.Fatalf("RenderArtifact returned error: %v", err)
+	}
+	want := "<ul><li>Before" + metadata.PreservedFragments["fragment-0001"] + "</li><li>" + metadata.PreservedFragments["fragment-0002"] + "</li></ul>"
+	if artifact.Storage != want {
+		t.Fatalf("storage = %s, want %s", artifact.Storage, want)
+	}
+}
+
+func TestRenderStoragePreservesListsThatWouldChangeShape(t *testing.T) {
+	for name, storage := range map[string]string{
+		"text after nested list":  `<ul><li>Parent<ul><li>Child</li></ul>Trailing text</li></ul>`,
+		"macro in code":           `<ul><li>Ticket <code><ac:structured-macro ac:name="status" /></code></li></ul>`,
+		"non-item child":          `<ol><p>Stray</p></ol>`,
+		"interrupting start":      `<ul><li>Steps<ol start="3"><li>Third</li></ol></li></ul>`,
+		"namespaced list element": `<ul ac:local-id="abc"><li>Item</li></ul>`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			artifact, err := RenderStorage(testStoragePage(storage))
+			if err != nil {
+				t.Fatalf("RenderStorage returned error: %v", err)
+			}
+			if artifact.Metadata.PreservedFragments["fragment-0001"] != storage {
+				t.Fatalf("list was not preserved:\n%s", artifact.Markdown)
+			}
+		})
+	}
+}
+
+func TestRenderStorageRendersOrderedListStartAndEmptyItems(t *testing.T) {
+	artifact, err := RenderStorage(testStoragePage(`<ol start="9"><li>Nine</li><li></li><li>Eleven</li></ol>`))
+	if err != nil {
+		t.Fatalf("RenderStorage returned error: %v", err)
+	}
+	if want := "9. Nine\n10.\n11. Eleven\n"; artifact.Markdown != want {
+		t.Fatalf("Markdown = %q, want %q", artifact.Markdown, want)
+	}
+	if strings.Contains(artifact.Markdown, "conflux:preserved") {
+		t.Fatalf("list was preserved: %s", artifact.Markdown)
+	}
+}
@@ -0,0 +1,180 @@
+package content
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const (
+	richTextBody  = "rich-text-body"
+	plainTextBody = "plain-text-body"
+)
+
+type storageMacro struct {
+	Name string
+	// Attributes are the macro element's attributes other than its name and
+	// schema version, such as ac:macro-id.
+	Attributes []xml.Attr
+	Parameters []macroParameter
+	BodyKind   string
+	Body       string
+}
+
+type macroParameter struct {
+	Name  string
+	Value string
+}
+
+// parseStructuredMacro reads a single ac:structured-macro whose parameters are
+// plain text. A rich-text body is returned as raw storage so it can be rendered
+// recursively; a plain-text body is returned as its character data.
+func parseStructuredMacro(raw string) (storageMacro, bool) {
+	const prefix = `<conflux-root xmlns:ac="urn:conflux:ac" xmlns:ri="urn:conflux:ri">`
+	source := prefix + raw + `</conflux-root>`
+	decoder := xml.NewDecoder(strings.NewReader(source))
+	decoder.Strict = false
+
+	var macro storageMacro
+	var parameter *macroParameter
+	var plainBody strings.Builder
+	depth := 0
+	bodyStart := -1
+	for {
+		before := int(decoder.InputOffset())
+		token, err := decoder.Token()
+		if err != nil {
+			if err != io.EOF || macro.Name == "" {
+				return storageMacro{}, false
+			}
+			if macro.BodyKind == plainTextBody {
+				macro.Body = plainBody.String()
+			}
+			return macro, true
+		}
+		switch value := token.(type) {
+		case xml.StartElement:
+			depth++
+			switch {
+			case depth == 1:
+				continue
+			case depth == 2:
+				if value.Name.Space != "urn:conflux:ac" || value.Name.Local != "structured-macro" || macro.Name != "" {
+					return storageMacro{}, false
+				}
+				macro.Name = acAttribute(value, "name")
+				if macro.Name == "" {
+					return storageMacro{}, false
+				}
+				for _, attribute := range value.Attr {
+					if attribute.Name.Space != "urn:conflux:ac" || (attribute.Name.Local != "name" && attribute.Name.Local != "schema-version") {
+						macro.Attributes = append(macro.Attributes, attribute)
+					}
+				}
+			case depth == 3:
+				if value.Name.Space != "urn:conflux:ac" {
+					return storageMacro{}, false
+				}
+				switch value.Name.Local {
+				case "parameter":
+					macro.Parameters = append(macro.Parameters, macroParameter{Name: acAttribute(value, "name")})
+					parameter = &macro.Parameters[len(macro.Parameters)-1]
+				case richTextBody, plainTextBody:
+					if macro.BodyKind != "" {
+						return storageMacro{}, false
+					}
+					macro.BodyKind = value.Name.Local
+					bodyStart = int(decoder.InputOffset())
+				default:
+					return storageMacro{}, false
+				}
+			default:
+				if macro.BodyKind != richTextBody || parameter != nil {
+					return storageMacro{}, false
+				}
+			}
+		case xml.EndElement:
+			if depth == 3 {
+				if value.Name.Local == richTextBody {
+					macro.Body = source[bodyStart:before]
+				}
+				parameter = nil
+				bodyStart = -1
+			}
+			depth--
+		case xml.CharData:
+			switch {
+			case depth == 3 && parameter != nil:
+				parameter.Value += string(value)
+			case depth == 3 && macro.BodyKind == plainTextBody && bodyStart >= 0:
+				plainBody.Write(value)
+			case depth == 2 && strings.TrimSpace(string(value)) != "":
+				return storageMacro{}, false
+			}
+		}
+	}
+}
+
+// macroAttributePrefix marks the attributes that carry a macro element's own
+// ac: attributes, such as conflux-macro-id for ac:macro-id, apart from its
+// parameters.
+const macroAttributePrefix = "conflux-"
+
+var macroAttributeName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// macroElementAttributes returns the macro's element attributes with the
+// conflux- prefix, reporting false for attributes outside the ac namespace,
+// which have no such form.
+func macroElementAttributes(macro storageMacro) ([]attribute, bool) {
+	attributes := make([]attribute, 0, len(macro.Attributes))
+	for _, value := range macro.Attributes {
+		if value.Name.Space != "urn:conflux:ac" || !macroAttributeName.MatchString(value.Name.Local) {
+			return nil, false
+		}
+		attributes = append(attributes, attribute{Key: macroAttributePrefix + value.Name.Local, Value: value.Value})
+	}
+	return attributes, true
+}
+
+// splitMacroAttributes separates the conflux- attributes of a macro element
+// from its parameters.
+func splitMacroAttributes(attributes []attribute) (parameters, element []attribute, err error) {
+	for _, attribute := range attributes {
+		name, ok := strings.CutPrefix(attribute.Key, macroAttributePrefix)
+		switch {
+		case !ok:
+			parameters = append(parameters, attribute)
+		case macroAttributeName.MatchString(name):
+			element = append(element, attribute)
+		default:
+			return nil, nil, fmt.Errorf("invalid macro attribute %q", attribute.Key)
+		}
+	}
+	return parameters, element, nil
+}
+
+// macroElementStorage writes conflux- attributes back as the macro element's
+// ac: attributes, each with a leading space.
+func macroElementStorage(attributes []attribute) string {
+	var storage strings.Builder
+	for _, attribute := range attributes {
+		storage.WriteString(` ac:` + strings.TrimPrefix(attribute.Key, macroAttributePrefix) + `="` + html.EscapeString(attribute.Value) + `"`)
+	}
+	return storage.String()
+}
+
+func acAttribute(start xml.StartElement, name string) string {
+	for _, attribute := range start.Attr {
+		if attribute.Name.Space == "urn:conflux:ac" && attribute.Name.Local == name {
+			return attribute.Value
+		}
+	}
+	return ""
+}
+
+func isStructuredMacro(node storageNode) bool {
+	return node.Name.Space == "urn:conflux:ac" && node.Name.Local == "structured-macro"
+}
@@ -0,0 +1,226 @@
+package content
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// newMarkdownParser returns a CommonMark parser extended with the Conflux
+// round-trip syntax: standalone preservation markers, macro envelopes, tables
+// and their directives, layout sections, admonitions, details blocks, task
+// list checkboxes, dates, statuses, emoticons, and attribute blocks after links
+// and images.
+func newMarkdownParser() parser.Parser {
+	blockParsers := parser.DefaultBlockParsers()
+	for index, blockParser := range blockParsers {
+		switch blockParser.Value {
+		case parser.NewFencedCodeBlockParser():
+			blockParsers[index].Value = closedFenceParser{parser.NewFencedCodeBlockParser()}
+		case parser.NewHTMLBlockParser():
+			blockParsers[index].Value = inlineMarkerLineParser{parser.NewHTMLBlockParser()}
+		}
+	}
+	blockParsers = append(blockParsers,
+		util.Prioritized(preservedBlockParser{}, 50),
+		util.Prioritized(macroEnvelopeParser{}, 55),
+		util.Prioritized(tableDirectiveParser{}, 60),
+		util.Prioritized(cellAttributesParser{}, 70),
+		util.Prioritized(layoutParser{}, 80),
+		util.Prioritized(directiveParser{}, 85),
+		util.Prioritized(admonitionParser{}, 790),
+		util.Prioritized(detailsParser{}, 850),
+	)
+	return parser.NewParser(
+		parser.WithBlockParsers(blockParsers...),
+		parser.WithInlineParsers(append(parser.DefaultInlineParsers(),
+			util.Prioritized(extension.NewTaskCheckBoxParser(), 0),
+			util.Prioritized(linkAttributesParser{}, 900),
+			util.Prioritized(dateParser{}, 910),
+			util.Prioritized(statusParser{}, 920),
+			util.Prioritized(emoticonParser{}, 930),
+			util.Prioritized(formattingDelimiterParser{char: '~', maxLength: 2}, 940),
+			util.Prioritized(formattingDelimiterParser{char: '^', maxLength: 1}, 950),
+		)...),
+		parser.WithParagraphTransformers(append(parser.DefaultParagraphTransformers(),
+			util.Prioritized(pipeTableTransformer{}, 200),
+		)...),
+		parser.WithASTTransformers(util.Prioritized(tableListTransformer{}, 100)),
+	)
+}
+
+var kindPreservedBlock = ast.NewNodeKind("ConfluxPreserved")
+
+// preservedBlock is a standalone preservation marker. It renders as the
+// fragment stored in metadata under ID, with the macro parameters from a
+// parameter block on the following lines applied.
+type preservedBlock struct {
+	ast.BaseBlock
+	ID               string
+	HasParameters    bool
+	ParametersClosed bool
+	ParameterLines   []string
+}
+
+func (n *preservedBlock) Kind() ast.NodeKind { return kindPreservedBlock }
+
+func (n *preservedBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"ID": n.ID}, nil)
+}
+
+type preservedBlockParser struct{}
+
+func (preservedBlockParser) Trigger() []byte { return []byte{'<'} }
+
+func (preservedBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, _ := reader.PeekLine()
+	trimmed := bytes.TrimSpace(line)
+	match := preservationMarker.FindSubmatch(trimmed)
+	if match == nil || len(match[0]) != len(trimmed) {
+		return nil, parser.NoChildren
+	}
+	reader.AdvanceToEOL()
+	return &preservedBlock{ID: string(match[1])}, parser.NoChildren
+}
+
+// Continue takes a parameter block that starts on the line after the marker,
+// up to its closing line.
+func (preservedBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	block := node.(*preservedBlock)
+	line, _ := reader.PeekLine()
+	trimmed := strings.TrimSpace(string(line))
+	switch {
+	case block.ParametersClosed:
+		return parser.Close
+	case !block.HasParameters:
+		if !parametersBlockOpen.MatchString(trimmed) {
+			return parser.Close
+		}
+		block.HasParameters = true
+	case trimmed == parametersBlockClose:
+		block.ParametersClosed = true
+	default:
+		block.ParameterLines = append(block.ParameterLines, string(line))
+	}
+	reader.AdvanceToEOL()
+	return parser.Continue | parser.NoChildren
+}
+
+func (preservedBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (preservedBlockParser) CanInterruptParagraph() bool { return true }
+
+func (preservedBlockParser) CanAcceptIndentedLine() bool { return false }
+
+const closedFenceAttribute = "conflux-closed"
+
+// closedFenceParser records whether a fenced code block ended with a closing
+// fence, because CommonMark silently extends unclosed fences to the end of
+// their container.
+type closedFenceParser struct {
+	parser.BlockParser
+}
+
+func (p closedFenceParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	state := p.BlockParser.Continue(node, reader, pc)
+	if state&parser.Close != 0 {
+		node.SetAttributeString(closedFenceAttribute, true)
+	}
+	return state
+}
+
+// inlineMarkerLineParser keeps a line that starts with an inline or comment
+// marker in a paragraph. CommonMark would otherwise read the comment as an
+// HTML block and the rest of the line as raw HTML.
+type inlineMarkerLineParser struct {
+	parser.BlockParser
+}
+
+func (p inlineMarkerLineParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, _ := reader.PeekLine()
+	trimmed := bytes.TrimSpace(line)
+	if location := inlineMarkerCandidate.FindIndex(trimmed); (location != nil && location[0] == 0) || commentMarkerCandidate.Match(trimmed) || anchorDirectiveStart.Match(trimmed) {
+		return nil, parser.NoChildren
+	}
+	return p.BlockParser.Open(parent, reader, pc)
+}
+
+var kindLinkAttributes = ast.NewNodeKind("ConfluxLinkAttributes")
+
+// linkAttributes is an attribute block such as {conflux-display=inline}
+// written directly after a link or image. It applies to that previous sibling.
+type linkAttributes struct {
+	ast.BaseInline
+	Values []attribute
+}
+
+func (n *linkAttributes) Kind() ast.NodeKind { return kindLinkAttributes }
+
+func (n *linkAttributes) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Attributes": formatAttributeBlock(n.Values)}, nil)
+}
+
+type linkAttributesParser struct{}
+
+func (linkAttributesParser) Trigger() []byte { return []byte{'{'} }
+
+func (linkAttributesParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	end := attributeBlockEnd(line)
+	if end < 0 {
+		return nil
+	}
+	attributes, err := parseAttributeBlock(string(line[:end+1]))
+	if err != nil {
+		return nil
+	}
+	switch parent.LastChild().(type) {
+	case *ast.Link, *ast.Image:
+		block.Advance(end + 1)
+		return &linkAttributes{Values: attributes}
+	case *ast.Text:
+		span, ok := spanAttributes(attributes)
+		if !ok || !parseBracketedSpan(parent, span, block.Source(), pc) {
+			return nil
+		}
+		block.Advance(end + 1)
+		return span
+	}
+	return nil
+}
+
+// attributeBlockEnd returns the index of the brace closing the attribute
+// block that starts line, ignoring braces inside quoted values.
+func attributeBlockEnd(line []byte) int {
+	quoted := false
+	for index := 1; index < len(line); index++ {
+		switch line[index] {
+		case '\\':
+			if quoted {
+				index++
+			}
+		case '"':
+			quoted = !quoted
+		case '}':
+			if !quoted {
+				return index
+			}
+		case '\n':
+			return -1
+		}
+	}
+	return -1
+}
+
+// attributesOf returns the attribute block attached to a link or image.
+func attributesOf(node ast.Node) []attribute {
+	if attributes, ok := node.NextSibling().(*linkAttributes); ok {
+		return attributes.Values
+	}
+	return nil
+}
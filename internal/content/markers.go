@@ -10,6 +10,11 @@ import (
 var (
 	preservationMarker          = regexp.MustCompile(`<!--\s*conflux:preserved\s+id="([A-Za-z0-9._-]+)"\s*-->`)
 	preservationMarkerCandidate = regexp.MustCompile(`<!--\s*conflux:preserved\b`)
+	inlineMarker                = regexp.MustCompile(`<!--\s*conflux:inline\s+id="([A-Za-z0-9._-]+)"\s*-->`)
+	inlineMarkerCandidate       = regexp.MustCompile(`<!--\s*conflux:inline\b`)
+	macroMarker                 = regexp.MustCompile(`<!--\s*conflux:macro\s+id="([A-Za-z0-9._-]+)"\s*-->`)
+	macroMarkerCandidate        = regexp.MustCompile(`<!--\s*conflux:macro\b`)
+	macroCloseMarker            = regexp.MustCompile(`<!--\s*/conflux:macro\s*-->`)
 	preservedFragmentID         = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
 )
 
@@ -23,6 +28,16 @@ func ValidateArtifact(markdown string, metadata *Metadata) (ValidationResult, er
 	if len(preservationMarkerCandidate.FindAllStringIndex(markerSource, -1)) != len(matches) {
 		return ValidationResult{}, fmt.Errorf("markdown contains a malformed preservation marker")
 	}
+	inlineMatches := inlineMarker.FindAllStringSubmatch(markerSource, -1)
+	if len(inlineMarkerCandidate.FindAllStringIndex(markerSource, -1)) != len(inlineMatches) {
+		return ValidationResult{}, fmt.Errorf("markdown contains a malformed inline marker")
+	}
+	macroMatches := macroMarker.FindAllStringSubmatch(markerSource, -1)
+	if len(macroMarkerCandidate.FindAllStringIndex(markerSource, -1)) != len(macroMatches) {
+		return ValidationResult{}, fmt.Errorf("markdown contains a malformed macro marker")
+	}
+	matches = append(matches, inlineMatches...)
+	matches = append(matches, macroMatches...)
 
 	if metadata == nil {
 		if len(matches) > 0 {
@@ -68,7 +83,7 @@ func markdownOutsideCode(markdown string) string {
 	var fenceLength int
 
 	for _, line := range lines {
-		trimmed := strings.TrimLeft(line, " \t")
+		trimmed := strings.TrimLeft(stripContainerPrefixes(line), " \t")
 		marker, length := fenceMarker(trimmed)
 		if fence != 0 {
 			result.WriteString(strings.Repeat(" ", len(strings.TrimSuffix(line, "\n"))))
@@ -95,6 +110,24 @@ func markdownOutsideCode(markdown string) string {
 	return result.String()
 }
 
+// stripContainerPrefixes removes blockquote and list item markers so fences
+// and markers inside admonition bodies and list items are recognized like
+// top-level ones.
+func stripContainerPrefixes(line string) string {
+	for {
+		trimmed := strings.TrimLeft(line, " \t")
+		if strings.HasPrefix(trimmed, ">") {
+			line = trimmed[1:]
+			continue
+		}
+		marker, ok := parseListMarker(trimmed)
+		if !ok || marker.content == "" {
+			return line
+		}
+		line = marker.content
+	}
+}
+
 func fenceMarker(line string) (byte, int) {
 	if len(line) < 3 || (line[0] != '`' && line[0] != '~') {
 		return 0, 0
@@ -135,6 +168,40 @@ func maskInlineCode(line string) string {
 	return string(masked)
 }
 
+func inlineMarkerIDs(markdown string) map[string]bool {
+	ids := make(map[string]bool)
+	for _, match := range inlineMarker.FindAllStringSubmatch(markdown, -1) {
+		ids[match[1]] = true
+	}
+	return ids
+}
+
+func macroMarkerIDs(markdown string) map[string]bool {
+	ids := make(map[string]bool)
+	for _, match := range macroMarker.FindAllStringSubmatch(markdown, -1) {
+		ids[match[1]] = true
+	}
+	return ids
+}
+
+// InlineMarker returns the marker for a preserved fragment that sits inside a
+// line of text.
+func InlineMarker(id string) (string, error) {
+	if !preservedFragmentID.MatchString(id) {
+		return "", fmt.Errorf("invalid preserved fragment id %q", id)
+	}
+	return fmt.Sprintf(`<!-- conflux:inline id="%s" -->`, id), nil
+}
+
+// MacroMarker returns the opening marker of a macro whose frame is preserved
+// while its body stays editable. The body ends at a closing macro marker.
+func MacroMarker(id string) (string, error) {
+	if !preservedFragmentID.MatchString(id) {
+		return "", fmt.Errorf("invalid preserved fragment id %q", id)
+	}
+	return fmt.Sprintf(`<!-- conflux:macro id="%s" -->`, id), nil
+}
+
 func PreservationMarker(id string) (string, error) {
 	if !preservedFragmentID.MatchString(id) {
 		return "", fmt.Errorf("invalid preserved fragment id %q", id)
@@ -12,6 +12,12 @@ import (
 
 const SchemaVersion = 1
 
+// Page body representations, named as in the Confluence API.
+const (
+	RepresentationStorage = "storage"
+	RepresentationADF     = "atlas_doc_format"
+)
+
 var ErrMetadataNotFound = errors.New("artifact metadata not found")
 
 type Metadata struct {
@@ -19,6 +25,7 @@ type Metadata struct {
 	Page               PageMetadata         `json:"page"`
 	PreservedFragments map[string]string    `json:"preserved_fragments"`
 	Attachments        []AttachmentMetadata `json:"attachments"`
+	InlineComments     []string             `json:"inline_comments,omitempty"`
 }
 
 type PageMetadata struct {
@@ -26,6 +33,20 @@ type PageMetadata struct {
 	SpaceKey    string `json:"space_key"`
 	Title       string `json:"title"`
 	BaseVersion int    `json:"base_version"`
+	// Labels are the page's labels when it was pulled or last pushed, so push
+	// can tell labels removed locally from labels added in Confluence since.
+	Labels []string `json:"labels,omitempty"`
+	// Status and Width are the page's status and width when it was pulled or
+	// last pushed, so push only sends the options changed locally.
+	Status string `json:"status,omitempty"`
+	Width  string `json:"width,omitempty"`
+	// Representation is the body format the page was pulled in, and is pushed
+	// back in. Empty means storage.
+	Representation string `json:"representation,omitempty"`
+	// Strikethrough is how the page's storage marks struck-through text: "s"
+	// for <s>, "span" for a line-through span, or empty for <del>. Push
+	// writes Markdown strikethrough in the same form.
+	Strikethrough string `json:"strikethrough,omitempty"`
 }
 
 type AttachmentMetadata struct {
@@ -52,6 +73,23 @@ func (m Metadata) Validate() error {
 		return fmt.Errorf("metadata page base version must be positive")
 	}
 
+	if err := validateLabels(m.Page.Labels); err != nil {
+		return fmt.Errorf("metadata page %w", err)
+	}
+	if m.Page.Width != "" && !IsPageWidth(m.Page.Width) {
+		return fmt.Errorf("metadata page width %q is not supported", m.Page.Width)
+	}
+	switch m.Page.Representation {
+	case "", RepresentationStorage, RepresentationADF:
+	default:
+		return fmt.Errorf("metadata page representation %q is not supported", m.Page.Representation)
+	}
+	switch m.Page.Strikethrough {
+	case "", strikethroughS, strikethroughSpan:
+	default:
+		return fmt.Errorf("metadata page strikethrough %q is not supported", m.Page.Strikethrough)
+	}
+
 	seenFilenames := make(map[string]struct{}, len(m.Attachments))
 	for i, attachment := range m.Attachments {
 		if err := validateAttachmentFilename(attachment.Filename); err != nil {
@@ -64,6 +102,12 @@ func (m Metadata) Validate() error {
 		seenFilenames[key] = struct{}{}
 	}
 
+	for _, ref := range m.InlineComments {
+		if !commentRef.MatchString(ref) {
+			return fmt.Errorf("inline comment ref %q is invalid", ref)
+		}
+	}
+
 	for id, fragment := range m.PreservedFragments {
 		if !preservedFragmentID.MatchString(id) {
 			return fmt.Errorf("preserved fragment id %q is invalid", id)
@@ -71,6 +115,9 @@ func (m Metadata) Validate() error {
 		if strings.TrimSpace(fragment) == "" {
 			return fmt.Errorf("preserved fragment %q is empty", id)
 		}
+		if m.Page.Representation == RepresentationADF && !isADFFragment(fragment) {
+			return fmt.Errorf("preserved fragment %q is not an ADF node", id)
+		}
 	}
 	return nil
 }
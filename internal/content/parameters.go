@@ -0,0 +1,171 @@
+package content
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	parametersBlockOpen = regexp.MustCompile(`^<!--\s*conflux:parameters\s*$`)
+	parameterName       = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]*$`)
+)
+
+const parametersBlockClose = "-->"
+
+// macroParametersMarkdown returns the editable parameter block shown after the
+// preservation marker of a macro, or "" when the fragment is not a single
+// macro with plain-text parameters that can be written as keys.
+func macroParametersMarkdown(raw string) string {
+	if !strings.HasPrefix(strings.TrimSpace(raw), "<ac:structured-macro") {
+		return ""
+	}
+	macro, ok := parseStructuredMacro(raw)
+	if !ok || len(macro.Parameters) == 0 {
+		return ""
+	}
+	lines := []string{"<!-- conflux:parameters"}
+	seen := make(map[string]bool, len(macro.Parameters))
+	for _, parameter := range macro.Parameters {
+		if !parameterName.MatchString(parameter.Name) || seen[parameter.Name] || strings.Contains(parameter.Value, parametersBlockClose) {
+			return ""
+		}
+		seen[parameter.Name] = true
+		lines = append(lines, parameter.Name+": "+formatParameterValue(parameter.Value))
+	}
+	return strings.Join(append(lines, parametersBlockClose), "\n")
+}
+
+// formatParameterValue writes a value as is unless it would not read back the
+// same, in which case it is quoted.
+func formatParameterValue(value string) string {
+	if value != strings.TrimSpace(value) || strings.HasPrefix(value, `"`) || strings.ContainsAny(value, "\r\n") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// parseParametersBlock reads the key: value lines of a parameter block.
+func parseParametersBlock(lines []string) ([]macroParameter, error) {
+	var parameters []macroParameter
+	seen := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || !parameterName.MatchString(name) {
+			return nil, fmt.Errorf("invalid macro parameter line %q", strings.TrimSpace(line))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("macro parameter %q is repeated", name)
+		}
+		seen[name] = true
+		if strings.HasPrefix(value, `"`) {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted value for macro parameter %q: %w", name, err)
+			}
+			value = unquoted
+		}
+		parameters = append(parameters, macroParameter{Name: name, Value: value})
+	}
+	return parameters, nil
+}
+
+// parameterSpan locates an ac:parameter element of a macro fragment. Start and
+// End bound the element and InnerStart and InnerEnd its value.
+type parameterSpan struct {
+	macroParameter
+	Start, InnerStart, InnerEnd, End int
+}
+
+func macroParameterSpans(raw string) ([]parameterSpan, bool) {
+	if _, ok := parseStructuredMacro(raw); !ok {
+		return nil, false
+	}
+	const prefix = `<conflux-root xmlns:ac="urn:conflux:ac" xmlns:ri="urn:conflux:ri">`
+	decoder := xml.NewDecoder(strings.NewReader(prefix + raw + `</conflux-root>`))
+	decoder.Strict = false
+
+	var spans []parameterSpan
+	var span *parameterSpan
+	depth := 0
+	for {
+		before := int(decoder.InputOffset()) - len(prefix)
+		token, err := decoder.Token()
+		if err != nil {
+			return spans, true
+		}
+		switch value := token.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 3 && value.Name.Local == "parameter" {
+				spans = append(spans, parameterSpan{
+					macroParameter: macroParameter{Name: acAttribute(value, "name")},
+					Start:          before,
+					InnerStart:     int(decoder.InputOffset()) - len(prefix),
+				})
+				span = &spans[len(spans)-1]
+			}
+		case xml.EndElement:
+			if depth == 3 && span != nil {
+				span.InnerEnd = before
+				span.End = int(decoder.InputOffset()) - len(prefix)
+				span = nil
+			}
+			depth--
+		case xml.CharData:
+			if span != nil {
+				span.Value += string(value)
+			}
+		}
+	}
+}
+
+// applyMacroParameters rewrites the parameters of a preserved macro to match
+// an edited parameter block. Parameters that did not change keep their exact
+// storage, removed ones are dropped, and new ones follow the existing ones.
+func applyMacroParameters(fragment string, parameters []macroParameter) (string, error) {
+	spans, ok := macroParameterSpans(fragment)
+	if !ok || len(spans) == 0 {
+		return "", fmt.Errorf("fragment is not a macro with parameters")
+	}
+	values := make(map[string]string, len(parameters))
+	for _, parameter := range parameters {
+		values[parameter.Name] = parameter.Value
+	}
+	existing := make(map[string]bool, len(spans))
+	var result strings.Builder
+	last := 0
+	for _, span := range spans {
+		existing[span.Name] = true
+		result.WriteString(fragment[last:span.Start])
+		last = span.End
+		value, kept := values[span.Name]
+		switch {
+		case !kept:
+		case value == span.Value:
+			result.WriteString(fragment[span.Start:span.End])
+		case strings.HasSuffix(fragment[span.Start:span.End], "/>"):
+			result.WriteString(parameterStorage(macroParameter{Name: span.Name, Value: value}))
+		default:
+			result.WriteString(fragment[span.Start:span.InnerStart] + html.EscapeString(value) + fragment[span.InnerEnd:span.End])
+		}
+	}
+	for _, parameter := range parameters {
+		if !existing[parameter.Name] {
+			result.WriteString(parameterStorage(parameter))
+		}
+	}
+	result.WriteString(fragment[last:])
+	return result.String(), nil
+}
+
+func parameterStorage(parameter macroParameter) string {
+	return `<ac:parameter ac:name="` + html.EscapeString(parameter.Name) + `">` + html.EscapeString(parameter.Value) + `</ac:parameter>`
+}
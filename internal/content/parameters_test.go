@@ -0,0 +1,104 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderArtifactAppliesMacroParameterEdits(t *testing.T) {
+	fragment := `<ac:structured-macro ac:name="jira" ac:schema-version="1" ac:macro-id="m1">` +
+		`<ac:parameter ac:name="server">System Jira</ac:parameter>` +
+		`<ac:parameter ac:name="jqlQuery">project = PSS AND status = &quot;Open&quot;</ac:parameter>` +
+		`<ac:parameter ac:name="columns">key,summary</ac:parameter>` +
+		`<ac:parameter ac:name="empty" />` +
+		`</ac:structured-macro>`
+	tests := []struct {
+		name       string
+		parameters string
+		want       string
+	}{
+		{
+			name:       "unchanged",
+			parameters: "server: System Jira\njqlQuery: project = PSS AND status = \"Open\"\ncolumns: key,summary\nempty:\n",
+			want:       fragment,
+		},
+		{
+			name:       "edited",
+			parameters: "server: System Jira\njqlQuery: project = OPS & type = Bug\ncolumns: key,summary\nempty: now set\n",
+			want: `<ac:structured-macro ac:name="jira" ac:schema-version="1" ac:macro-id="m1">` +
+				`<ac:parameter ac:name="server">System Jira</ac:parameter>` +
+				`<ac:parameter ac:name="jqlQuery">project = OPS &amp; type = Bug</ac:parameter>` +
+				`<ac:parameter ac:name="columns">key,summary</ac:parameter>` +
+				`<ac:parameter ac:name="empty">now set</ac:parameter>` +
+				`</ac:structured-macro>`,
+		},
+		{
+			name:       "removed and added",
+			parameters: "server: System Jira\njqlQuery: project = PSS AND status = \"Open\"\nempty:\nmaximumIssues: 20\ntitle: \" Padded \"\n",
+			want: `<ac:structured-macro ac:name="jira" ac:schema-version="1" ac:macro-id="m1">` +
+				`<ac:parameter ac:name="server">System Jira</ac:parameter>` +
+				`<ac:parameter ac:name="jqlQuery">project = PSS AND status = &quot;Open&quot;</ac:parameter>` +
+				`<ac:parameter ac:name="empty" />` +
+				`<ac:parameter ac:name="maximumIssues">20</ac:parameter>` +
+				`<ac:parameter ac:name="title"> Padded </ac:parameter>` +
+				`</ac:structured-macro>`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			metadata := pushMetadata()
+			metadata.PreservedFragments = map[string]string{"fragment-0001": fragment}
+			markdown := "<!-- conflux:preserved id=\"fragment-0001\" -->\n<!-- conflux:parameters\n" + test.parameters + "-->\n\nAfter.\n"
+			artifact, err := RenderArtifact(markdown, metadata, nil)
+			if err != nil {
+				t.Fatalf("RenderArtifact returned error: %v", err)
+			}
+			if artifact.Storage != test.want+"<p>After.</p>" {
+				t.Fatalf("storage = %s, want %s<p>After.</p>", artifact.Storage, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderArtifactRejectsInvalidParameterBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		fragment string
+		block    string
+		want     string
+	}{
+		{name: "unclosed", block: "<!-- conflux:parameters\ndepth: 2\n", want: "missing its closing"},
+		{name: "repeated", block: "<!-- conflux:parameters\ndepth: 2\ndepth: 3\n-->\n", want: "repeated"},
+		{name: "invalid line", block: "<!-- conflux:parameters\ndepth 2\n-->\n", want: "invalid macro parameter line"},
+		{name: "invalid quote", block: "<!-- conflux:parameters\ndepth: \"2\n-->\n", want: "invalid quoted value"},
+		{name: "not a macro", fragment: `<custom:widget xmlns:custom="urn:custom" />`, block: "<!-- conflux:parameters\ndepth: 2\n-->\n", want: "not a macro with parameters"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fragment := test.fragment
+			if fragment == "" {
+				fragment = `<ac:structured-macro ac:name="children"><ac:parameter ac:name="depth">1</ac:parameter></ac:structured-macro>`
+			}
+			metadata := pushMetadata()
+			metadata.PreservedFragments = map[string]string{"fragment-0001": fragment}
+			_, err := RenderArtifact("<!-- conflux:preserved id=\"fragment-0001\" -->\n"+test.block, metadata, nil)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("RenderArtifact error = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestMacroParametersMarkdownSkipsUnrepresentableParameters(t *testing.T) {
+	for _, raw := range []string{
+		`<ac:structured-macro ac:name="toc" />`,
+		`<ac:structured-macro ac:name="include"><ac:parameter ac:name=""><ac:link><ri:page ri:content-title="Home" /></ac:link></ac:parameter></ac:structured-macro>`,
+		`<ac:structured-macro ac:name="widget"><ac:parameter ac:name="">default</ac:parameter></ac:structured-macro>`,
+		`<ac:structured-macro ac:name="widget"><ac:parameter ac:name="a">1</ac:parameter><ac:parameter ac:name="a">2</ac:parameter></ac:structured-macro>`,
+		`<table><tbody><tr><td>Cell</td></tr></tbody></table>`,
+	} {
+		if block := macroParametersMarkdown(raw); block != "" {
+			t.Errorf("macroParametersMarkdown(%s) = %q, want none", raw, block)
+		}
+	}
+}
@@ -5,11 +5,8 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
-	"html"
 	"net/url"
 	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
 )
 
@@ -32,22 +29,24 @@ type PushArtifact struct {
 	Title       string
 	BaseVersion int
 	Storage     string
-	Uploads     []AttachmentUpload
+	// ADF is the Atlas Document Format body, set instead of Storage for pages
+	// pulled in that representation.
+	ADF      string
+	Uploads  []AttachmentUpload
+	Warnings []string
+	// Parent, Labels, Status, and Width are the page options set in front
+	// matter. Empty options leave the page's current value in place.
+	Parent string
+	Labels []string
+	Status *string
+	Width  string
 }
 
-var (
-	headingLine    = regexp.MustCompile(`^(#{1,6})[ \t]+(.+)$`)
-	unorderedLine  = regexp.MustCompile(`^[ \t]*[-*+][ \t]+(.+)$`)
-	orderedLine    = regexp.MustCompile(`^[ \t]*[0-9]+\.[ \t]+(.+)$`)
-	blockquoteLine = regexp.MustCompile(`^[ \t]*>[ \t]?(.*)$`)
-	imageLink      = regexp.MustCompile(`!\[([^]]*)]\(([^)]+)\)`)
-	markdownLink   = regexp.MustCompile(`\[([^]]+)]\(([^)]+)\)`)
-	inlineCode     = regexp.MustCompile("`([^`]+)`")
-	strongText     = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
-	emphasisText   = regexp.MustCompile(`\*([^*]+)\*|_([^_]+)_`)
-)
-
 func RenderArtifact(markdown string, metadata Metadata, localAttachments []LocalAttachment) (PushArtifact, error) {
+	front, markdown, err := SplitFrontMatter(markdown)
+	if err != nil {
+		return PushArtifact{}, fmt.Errorf("read front matter: %w", err)
+	}
 	validation, err := ValidateArtifact(markdown, &metadata)
 	if err != nil {
 		return PushArtifact{}, fmt.Errorf("validate push artifact: %w", err)
@@ -71,7 +70,13 @@ func RenderArtifact(markdown string, metadata Metadata, localAttachments []Local
 		files[key] = attachment
 	}
 
-	storage, references, err := markdownToStorage(markdown, metadata.PreservedFragments)
+	var storage, adf string
+	var references []string
+	if metadata.Page.Representation == RepresentationADF {
+		adf, references, err = markdownToADF(markdown, metadata.PreservedFragments)
+	} else {
+		storage, references, err = markdownToStorage(markdown, metadata.PreservedFragments, metadata.Page.Strikethrough)
+	}
 	if err != nil {
 		return PushArtifact{}, fmt.Errorf("render artifact Markdown: %w", err)
 	}
@@ -83,6 +88,10 @@ func RenderArtifact(markdown string, metadata Metadata, localAttachments []Local
 	var uploads []AttachmentUpload
 	for _, filename := range references {
 		file, exists := files[strings.ToLower(filename)]
+		if !exists && adf != "" {
+			// The preserved media of an ADF page keep showing the page's copy.
+			continue
+		}
 		if !exists {
 			return PushArtifact{}, fmt.Errorf("referenced attachment %q is missing from the local artifact", filename)
 		}
@@ -96,210 +105,28 @@ func RenderArtifact(markdown string, metadata Metadata, localAttachments []Local
 		})
 	}
 
+	title := metadata.Page.Title
+	if front.Title != "" {
+		title = front.Title
+	}
 	return PushArtifact{
-		PageID: metadata.Page.ID, SpaceKey: metadata.Page.SpaceKey, Title: metadata.Page.Title,
-		BaseVersion: metadata.Page.BaseVersion, Storage: storage, Uploads: uploads,
+		PageID: metadata.Page.ID, SpaceKey: metadata.Page.SpaceKey, Title: title,
+		BaseVersion: metadata.Page.BaseVersion, Storage: storage, ADF: adf, Uploads: uploads,
+		Warnings: inlineCommentWarnings(markdown, metadata.InlineComments),
+		Parent:   front.Parent, Labels: front.Labels, Status: front.Status, Width: front.Width,
 	}, nil
 }
 
-func markdownToStorage(markdown string, fragments map[string]string) (string, []string, error) {
-	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
-	var storage strings.Builder
-	var references []string
-	var paragraph []string
-	var listType string
-	var codeLanguage string
-	var codeLines []string
-	inCode := false
-	var codeFence byte
-	var codeFenceLength int
-
-	closeParagraph := func() {
-		if len(paragraph) == 0 {
-			return
-		}
-		storage.WriteString("<p>")
-		storage.WriteString(convertInline(strings.Join(paragraph, " "), &references))
-		storage.WriteString("</p>")
-		paragraph = nil
-	}
-	closeList := func() {
-		if listType != "" {
-			storage.WriteString("</" + listType + ">")
-			listType = ""
-		}
-	}
-
-	for lineIndex := 0; lineIndex < len(lines); lineIndex++ {
-		line := lines[lineIndex]
-		trimmed := strings.TrimSpace(line)
-		fence, fenceLength := fenceMarker(trimmed)
-		if (!inCode && fence != 0) || (inCode && fence == codeFence && fenceLength >= codeFenceLength) {
-			closeParagraph()
-			closeList()
-			if !inCode {
-				inCode = true
-				codeFence = fence
-				codeFenceLength = fenceLength
-				codeLanguage = strings.TrimSpace(trimmed[fenceLength:])
-				codeLines = nil
-			} else {
-				storage.WriteString(`<ac:structured-macro ac:name="code" ac:schema-version="1">`)
-				if codeLanguage != "" {
-					storage.WriteString(`<ac:parameter ac:name="language">` + html.EscapeString(codeLanguage) + `</ac:parameter>`)
-				}
-				storage.WriteString(`<ac:plain-text-body><![CDATA[` + escapeCDATA(strings.Join(codeLines, "\n")) + `]]></ac:plain-text-body></ac:structured-macro>`)
-				inCode = false
-				codeFence = 0
-				codeFenceLength = 0
-			}
-			continue
-		}
-		if inCode {
-			codeLines = append(codeLines, line)
-			continue
-		}
-		if marker := preservationMarker.FindStringSubmatch(trimmed); len(marker) == 2 && marker[0] == trimmed {
-			closeParagraph()
-			closeList()
-			storage.WriteString(fragments[marker[1]])
-			continue
-		}
-		if rendered, ok := jiraStorage(trimmed); ok {
-			closeParagraph()
-			closeList()
-			storage.WriteString(rendered)
-			continue
-		}
-		if directive := tableDirectivePattern.FindStringSubmatch(trimmed); directive != nil {
-			closeParagraph()
-			closeList()
-			table, lastLine, err := markdownTableToStorage(lines, lineIndex+1, directive[1], directive[2], &references)
-			if err != nil {
-				return "", nil, err
-			}
-			storage.WriteString(table)
-			lineIndex = lastLine
-			continue
-		}
-		if tableDirectiveCandidate.MatchString(trimmed) {
-			return "", nil, fmt.Errorf("invalid Conflux table directive %q", trimmed)
-		}
-		if match := headingLine.FindStringSubmatch(line); match != nil {
-			closeParagraph()
-			closeList()
-			level := strconv.Itoa(len(match[1]))
-			storage.WriteString("<h" + level + ">" + convertInline(match[2], &references) + "</h" + level + ">")
-			continue
-		}
-		if match := unorderedLine.FindStringSubmatch(line); match != nil {
-			closeParagraph()
-			if listType != "ul" {
-				closeList()
-				storage.WriteString("<ul>")
-				listType = "ul"
-			}
-			storage.WriteString("<li>" + convertInline(match[1], &references) + "</li>")
-			continue
-		}
-		if match := orderedLine.FindStringSubmatch(line); match != nil {
-			closeParagraph()
-			if listType != "ol" {
-				closeList()
-				storage.WriteString("<ol>")
-				listType = "ol"
-			}
-			storage.WriteString("<li>" + convertInline(match[1], &references) + "</li>")
-			continue
-		}
-		if match := blockquoteLine.FindStringSubmatch(line); match != nil {
-			closeParagraph()
-			closeList()
-			storage.WriteString("<blockquote><p>" + convertInline(match[1], &references) + "</p></blockquote>")
-			continue
-		}
-		if match := imageLink.FindStringSubmatch(trimmed); len(paragraph) == 0 && match != nil && match[0] == trimmed {
-			closeParagraph()
-			closeList()
-			storage.WriteString(convertInline(trimmed, &references))
-			continue
-		}
-		if trimmed == "" {
-			closeParagraph()
-			closeList()
-			continue
-		}
-		closeList()
-		paragraph = append(paragraph, trimmed)
-	}
-	if inCode {
-		return "", nil, fmt.Errorf("markdown contains an unclosed fenced code block")
-	}
-	closeParagraph()
-	closeList()
-	return storage.String(), deduplicateStrings(references), nil
-}
-
-func convertInline(value string, references *[]string) string {
-	escaped := html.EscapeString(value)
-	var protected []string
-	placeholderPrefix := "CONFLUXPROTECTED"
-	for strings.Contains(escaped, placeholderPrefix) {
-		placeholderPrefix += "X"
-	}
-	placeholder := func(index int) string {
-		return fmt.Sprintf("%s%dZ", placeholderPrefix, index)
-	}
-	protect := func(rendered string) string {
-		token := placeholder(len(protected))
-		protected = append(protected, rendered)
-		return token
-	}
-	escaped = imageLink.ReplaceAllStringFunc(escaped, func(match string) string {
-		parts := imageLink.FindStringSubmatch(match)
-		filename := attachmentFilename(parts[2])
-		if filename == "" {
-			return match
-		}
-		*references = append(*references, filename)
-		return protect(`<ac:image ac:alt="` + parts[1] + `"><ri:attachment ri:filename="` + html.EscapeString(filename) + `" /></ac:image>`)
-	})
-	escaped = markdownLink.ReplaceAllStringFunc(escaped, func(match string) string {
-		parts := markdownLink.FindStringSubmatch(match)
-		if filename := attachmentFilename(parts[2]); filename != "" {
-			*references = append(*references, filename)
-			return protect(`<ac:link><ri:attachment ri:filename="` + html.EscapeString(filename) + `" /><ac:link-body>` + formatEmphasis(parts[1]) + `</ac:link-body></ac:link>`)
-		}
-		return protect(`<a href="` + parts[2] + `">` + formatEmphasis(parts[1]) + `</a>`)
-	})
-	escaped = inlineCode.ReplaceAllStringFunc(escaped, func(match string) string {
-		parts := inlineCode.FindStringSubmatch(match)
-		return protect(`<code>` + parts[1] + `</code>`)
-	})
-	escaped = formatEmphasis(escaped)
-	for i, rendered := range protected {
-		escaped = strings.ReplaceAll(escaped, placeholder(i), rendered)
-	}
-	return escaped
-}
-
-func formatEmphasis(value string) string {
-	value = strongText.ReplaceAllStringFunc(value, func(match string) string {
-		parts := strongText.FindStringSubmatch(match)
-		return "<strong>" + firstNonEmpty(parts[1], parts[2]) + "</strong>"
-	})
-	return emphasisText.ReplaceAllStringFunc(value, func(match string) string {
-		parts := emphasisText.FindStringSubmatch(match)
-		return "<em>" + firstNonEmpty(parts[1], parts[2]) + "</em>"
-	})
-}
-
 func validateStandaloneMarkers(markdown string) error {
 	for _, line := range strings.Split(markdownOutsideCode(markdown), "\n") {
-		match := preservationMarker.FindString(strings.TrimSpace(line))
-		if match != "" && match != strings.TrimSpace(line) {
+		line = strings.TrimSpace(stripContainerPrefixes(line))
+		match := preservationMarker.FindString(line)
+		if match != "" && match != line {
 			return fmt.Errorf("preservation marker must appear on its own line")
 		}
+		if match := macroMarker.FindString(line); match != "" && match != line {
+			return fmt.Errorf("macro marker must appear on its own line")
+		}
 	}
 	return nil
 }
@@ -200,7 +200,7 @@ func TestRenderArtifactConvertsBlockquoteAndAttachmentLink(t *testing.T) {
 	if err != nil {
 		t.Fatalf("RenderArtifact returned error: %v", err)
 	}
-	for _, expected := range []string{"<blockquote><p>Important</p></blockquote>", `<ac:link><ri:attachment ri:filename="runbook.pdf" />`, "<ac:link-body>runbook</ac:link-body>"} {
+	for _, expected := range []string{"<blockquote><p>Important</p></blockquote>", `<ac:link><ri:attachment ri:filename="runbook.pdf" />`, "<ac:plain-text-link-body><![CDATA[runbook]]></ac:plain-text-link-body>"} {
 		if !strings.Contains(artifact.Storage, expected) {
 			t.Fatalf("storage does not contain %q: %s", expected, artifact.Storage)
 		}
@@ -292,3 +292,137 @@ func pushMetadata() Metadata {
 		PreservedFragments: map[string]string{"fragment-0001": `<ac:structured-macro ac:name="status" />`},
 	}
 }
+
+func TestRenderArtifactFollowsCommonMarkInlineRules(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{"nested emphasis", "***both*** and *a **b** c*\n", "<p><em><strong>both</strong></em> and <em>a <strong>b</strong> c</em></p>"},
+		{"link with parentheses", "[docs](https://example.com/a_(b))\n", `<p><a href="https://example.com/a_(b)">docs</a></p>`},
+		{"escaped characters", "\\*literal\\* and \\[brackets\\]\n", "<p>*literal* and [brackets]</p>"},
+		{"reference link", "[guide][g]\n\n[g]: https://example.com/guide \"Guide\"\n", `<p><a href="https://example.com/guide" title="Guide">guide</a></p>`},
+		{"autolinks", "<https://example.com/?a=1&b=2> <ops@example.com>\n", `<p><a href="https://example.com/?a=1&amp;b=2">https://example.com/?a=1&amp;b=2</a> <a href="mailto:ops@example.com">ops@example.com</a></p>`},
+		{"raw HTML stays text", "a <b>bold</b> tag\n", "<p>a &lt;b&gt;bold&lt;/b&gt; tag</p>"},
+		{"hard breaks", "one  \ntwo\\\nthree\n", "<p>one<br />two<br />three</p>"},
+		{"external image", "![logo](https://example.com/logo.png)\n", `<ac:image ac:alt="logo"><ri:url ri:value="https://example.com/logo.png" /></ac:image>`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			artifact, err := RenderArtifact(test.markdown, metadata, nil)
+			if err != nil {
+				t.Fatalf("RenderArtifact returned error: %v", err)
+			}
+			if artifact.Storage != test.want {
+				t.Fatalf("storage = %s, want %s", artifact.Storage, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderArtifactConvertsCommonMarkBlocks(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	markdown := "Title\n=====\n\n    indented code\n\n---\n\n> quoted *text*\n> continues\n"
+	artifact, err := RenderArtifact(markdown, metadata, nil)
+	if err != nil {
+		t.Fatalf("RenderArtifact returned error: %v", err)
+	}
+	want := `<h1>Title</h1><ac:structured-macro ac:name="code" ac:schema-version="1"><ac:plain-text-body><![CDATA[indented code]]></ac:plain-text-body></ac:structured-macro>` +
+		`<hr /><blockquote><p>quoted <em>text</em> continues</p></blockquote>`
+	if artifact.Storage != want {
+		t.Fatalf("storage = %s, want %s", artifact.Storage, want)
+	}
+}
+
+func TestRenderArtifactRejectsMarkerSwallowedByHTMLBlock(t *testing.T) {
+	metadata := pushMetadata()
+	markdown := "<div>\n<!-- conflux:preserved id=\"fragment-0001\" -->\n</div>\n"
+	_, err := RenderArtifact(markdown, metadata, nil)
+	if err == nil || !strings.Contains(err.Error(), "standalone block") {
+		t.Fatalf("error = %v, want standalone block failure", err)
+	}
+}
+
+func TestRenderArtifactRejectsUnsupportedLinkAttributes(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	for name, markdown := range map[string]string{
+		"ordinary link":     "[docs](https://example.com){target=blank}\n",
+		"incomplete jira":   "[PSS-1](jira:PSS-1){conflux-display=inline}\n",
+		"jira without attr": "[PSS-1](jira:PSS-1)\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := RenderArtifact(markdown, metadata, nil); err == nil {
+				t.Fatal("RenderArtifact unexpectedly succeeded")
+			}
+		})
+	}
+}
+
+func TestRenderArtifactRendersJiraLinkInsideParagraph(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	markdown := `Tracked in [PSS-1](jira:PSS-1){conflux-display=inline jira-server="Jira" jira-server-id="abc"} today.` + "\n"
+	artifact, err := RenderArtifact(markdown, metadata, nil)
+	if err != nil {
+		t.Fatalf("RenderArtifact returned error: %v", err)
+	}
+	if !strings.HasPrefix(artifact.Storage, `<p>Tracked in <ac:structured-macro ac:name="jira"`) || !strings.HasSuffix(artifact.Storage, "</ac:structured-macro> today.</p>") {
+		t.Fatalf("storage = %s", artifact.Storage)
+	}
+}
+
+func TestRenderStoragePreservesLinksWithoutDestination(t *testing.T) {
+	for name, storage := range map[string]string{
+		"named anchor": `<h1><a name="x"></a>Head</h1>`,
+		"empty href":   `<p>See <a href="">here</a>.</p>`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			pulled, err := RenderStorage(testStoragePage(storage))
+			if err != nil {
+				t.Fatalf("RenderStorage returned error: %v", err)
+			}
+			if strings.Contains(pulled.Markdown, "]()") || len(pulled.Metadata.PreservedFragments) != 1 {
+				t.Fatalf("link was not preserved: %q %#v", pulled.Markdown, pulled.Metadata.PreservedFragments)
+			}
+			pushed, err := RenderArtifact(pulled.Markdown, pulled.Metadata, nil)
+			if err != nil {
+				t.Fatalf("RenderArtifact returned error: %v", err)
+			}
+			if pushed.Storage != storage {
+				t.Fatalf("storage = %s, want %s", pushed.Storage, storage)
+			}
+		})
+	}
+}
+
+func TestRenderArtifactRejectsLinkWithoutDestination(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	_, err := RenderArtifact("See [here]().\n", metadata, nil)
+	if err == nil || !strings.Contains(err.Error(), "no destination") {
+		t.Fatalf("error = %v, want missing destination failure", err)
+	}
+}
+
+func TestRenderStorageRoundTripsPipeInTableCode(t *testing.T) {
+	storage := `<table data-layout="default"><tbody><tr><th><p>Command</p></th></tr><tr><td><p>Run <code>grep a|b</code> or a|b.</p></td></tr></tbody></table>`
+	pulled, err := RenderStorage(testStoragePage(storage))
+	if err != nil {
+		t.Fatalf("RenderStorage returned error: %v", err)
+	}
+	if want := "| Run `grep a\\|b` or a\\|b. |"; !strings.Contains(pulled.Markdown, want) {
+		t.Fatalf("Markdown = %s, want %q", pulled.Markdown, want)
+	}
+	pushed, err := RenderArtifact(pulled.Markdown, pulled.Metadata, nil)
+	if err != nil {
+		t.Fatalf("RenderArtifact returned error: %v", err)
+	}
+	if pushed.Storage != storage {
+		t.Fatalf("storage = %s, want %s", pushed.Storage, storage)
+	}
+}
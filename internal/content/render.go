@@ -7,8 +7,6 @@ import (
 	"net/url"
 	"path/filepath"
 	"strings"
-
-	htmldoc "github.com/JohannesKaufmann/html-to-markdown/v2"
 )
 
 type StoragePage struct {
@@ -22,6 +20,9 @@ type StoragePage struct {
 	// "attachments" for compatibility with legacy exports.
 	AttachmentDirectory string
 	Attachments         []AttachmentMetadata
+	// Users maps the account IDs of mentioned users to display names. Mentions
+	// of users missing from the map are labelled with their account ID.
+	Users map[string]string
 }
 
 type AttachmentDownload struct {
@@ -43,17 +44,8 @@ type storageNode struct {
 }
 
 func RenderStorage(page StoragePage) (EditableArtifact, error) {
-	if strings.TrimSpace(page.ID) == "" {
-		return EditableArtifact{}, fmt.Errorf("storage page id is required")
-	}
-	if strings.TrimSpace(page.SpaceKey) == "" {
-		return EditableArtifact{}, fmt.Errorf("storage page space key is required")
-	}
-	if strings.TrimSpace(page.Title) == "" {
-		return EditableArtifact{}, fmt.Errorf("storage page title is required")
-	}
-	if page.BaseVersion < 1 {
-		return EditableArtifact{}, fmt.Errorf("storage page base version must be positive")
+	if err := validatePage("storage", page.ID, page.SpaceKey, page.Title, page.BaseVersion); err != nil {
+		return EditableArtifact{}, err
 	}
 	attachmentDirectory := page.AttachmentDirectory
 	if attachmentDirectory == "" {
@@ -64,143 +56,281 @@ func RenderStorage(page StoragePage) (EditableArtifact, error) {
 		return EditableArtifact{}, fmt.Errorf("attachment directory must be a relative directory name: %q", attachmentDirectory)
 	}
 
-	nodes, err := tokenizeStorage(page.Storage)
-	if err != nil {
-		return EditableArtifact{}, fmt.Errorf("tokenize Confluence storage: %w", err)
-	}
-
 	metadata := Metadata{
 		SchemaVersion: SchemaVersion,
 		Page: PageMetadata{
-			ID:          page.ID,
-			SpaceKey:    page.SpaceKey,
-			Title:       page.Title,
-			BaseVersion: page.BaseVersion,
+			ID:            page.ID,
+			SpaceKey:      page.SpaceKey,
+			Title:         page.Title,
+			BaseVersion:   page.BaseVersion,
+			Strikethrough: strikethroughForm(page.Storage),
 		},
 		PreservedFragments: make(map[string]string),
 		Attachments:        append([]AttachmentMetadata(nil), page.Attachments...),
 	}
-	attachmentsByFilename := make(map[string]AttachmentMetadata, len(page.Attachments))
+	renderer := &storageRenderer{
+		attachmentDirectory: attachmentDirectory,
+		attachments:         make(map[string]AttachmentMetadata, len(page.Attachments)),
+		metadata:            &metadata,
+		users:               page.Users,
+	}
 	for _, attachment := range page.Attachments {
-		attachmentsByFilename[attachment.Filename] = attachment
+		renderer.attachments[attachment.Filename] = attachment
+	}
+
+	markdown, err := renderer.renderBlocks(page.Storage)
+	if err != nil {
+		return EditableArtifact{}, err
+	}
+	if markdown != "" {
+		markdown += "\n"
+	}
+	if _, err := ValidateArtifact(markdown, &metadata); err != nil {
+		return EditableArtifact{}, fmt.Errorf("validate rendered artifact: %w", err)
+	}
+	return EditableArtifact{Markdown: markdown, Metadata: metadata, Downloads: renderer.downloads}, nil
+}
+
+func validatePage(representation, id, spaceKey, title string, baseVersion int) error {
+	if strings.TrimSpace(id) == "" {
+		return fmt.Errorf("%s page id is required", representation)
+	}
+	if strings.TrimSpace(spaceKey) == "" {
+		return fmt.Errorf("%s page space key is required", representation)
+	}
+	if strings.TrimSpace(title) == "" {
+		return fmt.Errorf("%s page title is required", representation)
+	}
+	if baseVersion < 1 {
+		return fmt.Errorf("%s page base version must be positive", representation)
+	}
+	return nil
+}
+
+// storageRenderer carries the page-level state shared by every block that is
+// rendered, so macro bodies can be rendered recursively with the same
+// fragment numbering, attachment lookups, and download intents.
+type storageRenderer struct {
+	attachmentDirectory string
+	attachments         map[string]AttachmentMetadata
+	metadata            *Metadata
+	users               map[string]string
+	downloads           []AttachmentDownload
+	fragmentNumber      int
+}
+
+func (r *storageRenderer) renderBlocks(storage string) (string, error) {
+	return r.renderBlocksAfter(storage, &adjacentLists{})
+}
+
+// renderBlocksAfter renders storage that follows the lists tracked by lists,
+// as the blocks of a list item do when rendered one at a time.
+func (r *storageRenderer) renderBlocksAfter(storage string, lists *adjacentLists) (string, error) {
+	nodes, err := tokenizeStorage(storage)
+	if err != nil {
+		return "", fmt.Errorf("tokenize Confluence storage: %w", err)
 	}
 
 	var markdownParts []string
-	var downloads []AttachmentDownload
-	fragmentNumber := 0
 	for _, node := range nodes {
 		if strings.TrimSpace(node.Raw) == "" {
 			continue
 		}
+		if !isStorageList(node) && !isStorageTaskList(node) {
+			lists.reset()
+		}
 		for _, filename := range referencedAttachmentFilenames(node.Raw) {
-			attachment, exists := attachmentsByFilename[filename]
+			attachment, exists := r.attachments[filename]
 			if !exists {
-				return EditableArtifact{}, fmt.Errorf("confluence storage references unknown attachment %q", filename)
+				return "", fmt.Errorf("confluence storage references unknown attachment %q", filename)
 			}
-			downloads = appendDownload(downloads, attachment)
+			r.downloads = appendDownload(r.downloads, attachment)
 		}
 
-		if isAttachmentImage(node) {
-			filename, ok := extractAttachmentFilename(node.Raw)
-			if !ok {
-				return EditableArtifact{}, fmt.Errorf("confluence image is missing an attachment filename")
+		if isStorageImage(node) {
+			if markdown, ok := r.imageMarkdown(node.Raw); ok {
+				markdownParts = append(markdownParts, markdown)
+				continue
 			}
-			alt, editable := editableImageAlt(node.Raw)
-			if editable {
-				if alt == "" {
-					alt = filename
+		}
+
+		if isStorageList(node) {
+			restore := r.checkpoint()
+			markdown, ok, err := r.listMarkdown(node, lists.next(storageListKind(node)))
+			if err != nil {
+				return "", fmt.Errorf("convert storage list: %w", err)
+			}
+			if !ok {
+				restore()
+				lists.reset()
+				markdown, err = r.preserve(node.Raw)
+				if err != nil {
+					return "", err
 				}
-				markdownParts = append(markdownParts, fmt.Sprintf("![%s](%s)", alt, attachmentMarkdownPath(attachmentDirectory, filename)))
-				continue
 			}
-			// Keep image sizing, layout, or other unsupported attributes
-			// losslessly instead of emitting incomplete Markdown.
+			markdownParts = append(markdownParts, markdown)
+			continue
+		}
+
+		if isStorageRule(node) {
+			markdownParts = append(markdownParts, "---")
+			continue
 		}
 
-		if isEditableHTMLNode(node) && !containsConfluenceNamespace(node.Raw) && !hasNamespacedAttributes(node.Raw) {
-			markdown, err := htmldoc.ConvertString(node.Raw)
+		if isStorageTaskList(node) {
+			restore := r.checkpoint()
+			markdown, ok, err := r.taskListMarkdown(node, lists.next("bullet"))
 			if err != nil {
-				return EditableArtifact{}, fmt.Errorf("convert supported storage node %s: %w", node.Name.Local, err)
+				return "", fmt.Errorf("convert task list: %w", err)
 			}
-			if trimmed := strings.TrimSpace(markdown); trimmed != "" {
-				markdownParts = append(markdownParts, trimmed)
+			if !ok {
+				restore()
+				lists.reset()
+				markdown, err = r.preserve(node.Raw)
+				if err != nil {
+					return "", err
+				}
 			}
+			markdownParts = append(markdownParts, markdown)
 			continue
 		}
 
-		if isCodeMacro(node) {
-			code, language, ok := parseCodeMacro(node.Raw)
+		if isEditableHTMLNode(node) {
+			restore := r.checkpoint()
+			markdown, ok, err := r.htmlMarkdown(node.Raw)
+			if err != nil {
+				return "", fmt.Errorf("convert supported storage node %s: %w", node.Name.Local, err)
+			}
 			if ok {
-				markdownParts = append(markdownParts, fencedCode(code, language))
+				if markdown != "" {
+					markdownParts = append(markdownParts, markdown)
+				}
 				continue
 			}
+			restore()
+		}
+
+		if markdown, ok := codeMacroMarkdown(node); ok {
+			markdownParts = append(markdownParts, markdown)
+			continue
+		}
+		if filename, parameters, ok := parseViewFileMacro(node); ok {
+			markdownParts = append(markdownParts, r.viewFileMarkdown(filename, parameters))
+			continue
 		}
 		if jira, ok := parseJiraMacro(node); ok {
 			markdownParts = append(markdownParts, jiraMarkdown(jira))
 			continue
 		}
-		if node.Name.Space == "" && node.Name.Local == "table" && !containsConfluenceNamespace(node.Raw) {
-			markdown, err := storageTableMarkdown(node.Raw)
+		if expand, ok := parseExpandMacro(node); ok {
+			markdown, err := r.expandMarkdown(expand)
+			if err != nil {
+				return "", err
+			}
+			markdownParts = append(markdownParts, markdown)
+			continue
+		}
+		if admonition, ok := parseAdmonitionMacro(node); ok {
+			markdown, err := r.admonitionMarkdown(admonition)
 			if err != nil {
-				return EditableArtifact{}, fmt.Errorf("convert Confluence table: %w", err)
+				return "", err
 			}
-			layout, width := tableStorageOptions(node.Raw)
-			markdownParts = append(markdownParts, tableDirective(layout, width)+"\n"+strings.TrimSpace(markdown))
+			markdownParts = append(markdownParts, markdown)
 			continue
 		}
+		if isStorageLayout(node) {
+			restore := r.checkpoint()
+			markdown, ok, err := r.layoutMarkdown(node.Raw)
+			if err != nil {
+				return "", fmt.Errorf("convert Confluence layout: %w", err)
+			}
+			if ok {
+				markdownParts = append(markdownParts, markdown)
+				continue
+			}
+			restore()
+		}
+		if markdown, ok, err := r.directiveMarkdown(node); err != nil || ok {
+			if err != nil {
+				return "", err
+			}
+			markdownParts = append(markdownParts, markdown)
+			continue
+		}
+		if body, frame, ok := parseMacroEnvelope(node); ok {
+			markdown, err := r.envelopeMarkdown(body, frame)
+			if err != nil {
+				return "", err
+			}
+			markdownParts = append(markdownParts, markdown)
+			continue
+		}
+		if node.Name.Space == "" && node.Name.Local == "table" {
+			restore := r.checkpoint()
+			markdown, ok, err := r.tableMarkdown(node.Raw)
+			if err != nil {
+				return "", fmt.Errorf("convert Confluence table: %w", err)
+			}
+			if ok {
+				markdownParts = append(markdownParts, markdown)
+				continue
+			}
+			restore()
+		}
 
-		fragmentNumber++
-		fragmentID := fmt.Sprintf("fragment-%04d", fragmentNumber)
-		marker, err := PreservationMarker(fragmentID)
+		marker, err := r.preserve(node.Raw)
 		if err != nil {
-			return EditableArtifact{}, err
+			return "", err
 		}
-		metadata.PreservedFragments[fragmentID] = node.Raw
 		markdownParts = append(markdownParts, marker)
 	}
+	return strings.Join(markdownParts, "\n\n"), nil
+}
 
-	markdown := strings.Join(markdownParts, "\n\n")
-	if markdown != "" {
-		markdown += "\n"
+// preserve keeps a block behind a preservation marker, followed by an
+// editable parameter block when the block is a macro with parameters.
+func (r *storageRenderer) preserve(raw string) (string, error) {
+	marker, err := r.preserveWith(raw, PreservationMarker)
+	if err != nil {
+		return "", err
 	}
-	if _, err := ValidateArtifact(markdown, &metadata); err != nil {
-		return EditableArtifact{}, fmt.Errorf("validate rendered artifact: %w", err)
+	if parameters := macroParametersMarkdown(raw); parameters != "" {
+		marker += "\n" + parameters
 	}
-	return EditableArtifact{Markdown: markdown, Metadata: metadata, Downloads: downloads}, nil
+	return marker, nil
 }
 
-func editableImageAlt(raw string) (string, bool) {
-	decoder := storageNodeDecoder(raw)
-	var alt string
-	for {
-		token, err := decoder.Token()
-		if err != nil {
-			return alt, err == io.EOF && !strings.ContainsAny(alt, "]\r\n")
-		}
-		start, ok := token.(xml.StartElement)
-		if !ok || start.Name.Local == "conflux-root" {
-			continue
-		}
-		switch {
-		case start.Name.Space == "urn:conflux:ac" && start.Name.Local == "image":
-			for _, attribute := range start.Attr {
-				if attribute.Name.Space != "urn:conflux:ac" || attribute.Name.Local != "alt" {
-					return "", false
-				}
-				alt = attribute.Value
-			}
-		case start.Name.Space == "urn:conflux:ri" && start.Name.Local == "attachment":
-			for _, attribute := range start.Attr {
-				if attribute.Name.Space != "urn:conflux:ri" || attribute.Name.Local != "filename" {
-					return "", false
-				}
-			}
-		default:
-			return "", false
+// preserveInline keeps an unsupported inline element behind an inline marker
+// so the text around it stays editable.
+func (r *storageRenderer) preserveInline(raw string) (string, error) {
+	return r.preserveWith(raw, InlineMarker)
+}
+
+// checkpoint returns a function that discards the fragments and comments
+// recorded since the checkpoint, for use when a partly converted block is
+// preserved whole.
+func (r *storageRenderer) checkpoint() func() {
+	number := r.fragmentNumber
+	comments := len(r.metadata.InlineComments)
+	return func() {
+		r.metadata.InlineComments = r.metadata.InlineComments[:comments]
+		for ; r.fragmentNumber > number; r.fragmentNumber-- {
+			delete(r.metadata.PreservedFragments, fmt.Sprintf("fragment-%04d", r.fragmentNumber))
 		}
 	}
 }
 
+func (r *storageRenderer) preserveWith(raw string, marker func(string) (string, error)) (string, error) {
+	r.fragmentNumber++
+	fragmentID := fmt.Sprintf("fragment-%04d", r.fragmentNumber)
+	markdown, err := marker(fragmentID)
+	if err != nil {
+		return "", err
+	}
+	r.metadata.PreservedFragments[fragmentID] = raw
+	return markdown, nil
+}
+
 func hasNamespacedAttributes(raw string) bool {
 	decoder := storageNodeDecoder(raw)
 	for {
@@ -258,7 +388,7 @@ func tokenizeStorage(storage string) ([]storageNode, error) {
 			}
 			depth--
 		case xml.CharData:
-			if depth == 1 && strings.TrimSpace(string(value)) != "" {
+			if depth == 1 {
 				start := int(before) - len(prefix)
 				end := int(decoder.InputOffset()) - len(prefix)
 				nodes = append(nodes, storageNode{Raw: storage[start:end]})
@@ -290,16 +420,21 @@ func containsConfluenceNamespace(raw string) bool {
 	return strings.Contains(raw, "<ac:") || strings.Contains(raw, "<ri:")
 }
 
-func isAttachmentImage(node storageNode) bool {
+func isStorageImage(node storageNode) bool {
 	return node.Name.Space == "urn:conflux:ac" && node.Name.Local == "image"
 }
 
-func extractAttachmentFilename(raw string) (string, bool) {
-	filenames := referencedAttachmentFilenames(raw)
-	if len(filenames) == 0 {
-		return "", false
+// isStorageRule reports whether a node is a plain horizontal rule.
+func isStorageRule(node storageNode) bool {
+	if node.Name.Space != "" || !strings.EqualFold(node.Name.Local, "hr") {
+		return false
 	}
-	return filenames[0], true
+	start, inner, ok := splitStorageElement(node.Raw)
+	return ok && len(start.Attr) == 0 && inner == ""
+}
+
+func isStorageLayout(node storageNode) bool {
+	return node.Name.Space == "urn:conflux:ac" && node.Name.Local == "layout"
 }
 
 func referencedAttachmentFilenames(raw string) []string {
@@ -335,90 +470,8 @@ func appendDownload(downloads []AttachmentDownload, attachment AttachmentMetadat
 	return append(downloads, AttachmentDownload(attachment))
 }
 
-func isCodeMacro(node storageNode) bool {
-	if node.Name.Space != "urn:conflux:ac" || node.Name.Local != "structured-macro" {
-		return false
-	}
-	decoder := storageNodeDecoder(node.Raw)
-	for {
-		token, err := decoder.Token()
-		if err != nil {
-			return false
-		}
-		start, ok := token.(xml.StartElement)
-		if !ok || start.Name.Space != "urn:conflux:ac" || start.Name.Local != "structured-macro" {
-			continue
-		}
-		for _, attribute := range start.Attr {
-			if attribute.Name.Space == "urn:conflux:ac" && attribute.Name.Local == "name" && attribute.Value == "code" {
-				return true
-			}
-		}
-		return false
-	}
-}
-
-func parseCodeMacro(raw string) (string, string, bool) {
-	decoder := storageNodeDecoder(raw)
-	var language, code strings.Builder
-	var captureLanguage, captureCode bool
-	for {
-		token, err := decoder.Token()
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return "", "", false
-		}
-		switch value := token.(type) {
-		case xml.StartElement:
-			if value.Name.Space == "urn:conflux:ac" && value.Name.Local == "parameter" {
-				parameterName := ""
-				for _, attribute := range value.Attr {
-					if attribute.Name.Space == "urn:conflux:ac" && attribute.Name.Local == "name" {
-						parameterName = attribute.Value
-					}
-				}
-				if parameterName != "language" {
-					return "", "", false
-				}
-				captureLanguage = true
-			}
-			if value.Name.Space == "urn:conflux:ac" && value.Name.Local == "plain-text-body" {
-				captureCode = true
-			}
-		case xml.EndElement:
-			if value.Name.Space == "urn:conflux:ac" && value.Name.Local == "parameter" {
-				captureLanguage = false
-			}
-			if value.Name.Space == "urn:conflux:ac" && value.Name.Local == "plain-text-body" {
-				captureCode = false
-			}
-		case xml.CharData:
-			if captureLanguage {
-				language.Write(value)
-			}
-			if captureCode {
-				code.Write(value)
-			}
-		}
-	}
-	if code.Len() == 0 {
-		return "", "", false
-	}
-	return code.String(), strings.TrimSpace(language.String()), true
-}
-
 func storageNodeDecoder(raw string) *xml.Decoder {
 	decoder := xml.NewDecoder(strings.NewReader(`<conflux-root xmlns:ac="urn:conflux:ac" xmlns:ri="urn:conflux:ri">` + raw + `</conflux-root>`))
 	decoder.Strict = false
 	return decoder
 }
-
-func fencedCode(code, language string) string {
-	fence := "```"
-	for strings.Contains(code, fence) {
-		fence += "`"
-	}
-	return fence + language + "\n" + code + "\n" + fence
-}
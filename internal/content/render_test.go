@@ -40,13 +40,13 @@ func TestRenderStoragePreservesUnknownMacroAtOriginalPosition(t *testing.T) {
 	}
 }
 
-func TestRenderStoragePreservesMixedEditableNodeWhole(t *testing.T) {
-	paragraph := `<p>Before <ac:structured-macro ac:name="status"><ac:parameter ac:name="title">READY</ac:parameter></ac:structured-macro> after.</p>`
+func TestRenderStoragePreservesMacroInsideCodeWithItsNode(t *testing.T) {
+	paragraph := `<p>Before <code><ac:structured-macro ac:name="status"><ac:parameter ac:name="title">READY</ac:parameter></ac:structured-macro></code> after.</p>`
 	artifact, err := RenderStorage(testStoragePage(paragraph))
 	if err != nil {
 		t.Fatalf("RenderStorage returned error: %v", err)
 	}
-	if artifact.Metadata.PreservedFragments["fragment-0001"] != paragraph {
+	if len(artifact.Metadata.PreservedFragments) != 1 || artifact.Metadata.PreservedFragments["fragment-0001"] != paragraph {
 		t.Fatalf("mixed paragraph was partially converted: %#v", artifact.Metadata.PreservedFragments)
 	}
 }
@@ -91,7 +91,7 @@ func TestRenderStorageDeduplicatesAttachmentDownloads(t *testing.T) {
 }
 
 func TestRenderStorageDiscoversAttachmentInPreservedMacro(t *testing.T) {
-	storage := `<ac:structured-macro ac:name="view-file"><ac:parameter ac:name="name"><ri:attachment ri:filename="runbook.pdf" /></ac:parameter></ac:structured-macro>`
+	storage := `<ac:structured-macro ac:name="multimedia"><ac:parameter ac:name="name"><ri:attachment ri:filename="runbook.pdf" /></ac:parameter></ac:structured-macro>`
 	page := testStoragePage(storage)
 	page.Attachments = []AttachmentMetadata{{ID: "att-2", Filename: "runbook.pdf", MediaType: "application/pdf"}}
 
@@ -100,7 +100,7 @@ func TestRenderStorageDiscoversAttachmentInPreservedMacro(t *testing.T) {
 		t.Fatalf("RenderStorage returned error: %v", err)
 	}
 	if artifact.Metadata.PreservedFragments["fragment-0001"] != storage {
-		t.Fatal("view-file macro was not preserved")
+		t.Fatal("multimedia macro was not preserved")
 	}
 	if len(artifact.Downloads) != 1 || artifact.Downloads[0].Filename != "runbook.pdf" {
 		t.Fatalf("attachment was not discovered: %#v", artifact.Downloads)
@@ -122,7 +122,7 @@ func TestRenderStorageConvertsCodeMacro(t *testing.T) {
 }
 
 func TestRenderStoragePreservesCodeMacroWithUnsupportedParameters(t *testing.T) {
-	storage := `<ac:structured-macro ac:name="code"><ac:parameter ac:name="title">Example</ac:parameter><ac:plain-text-body><![CDATA[echo hello]]></ac:plain-text-body></ac:structured-macro>`
+	storage := `<ac:structured-macro ac:name="code"><ac:parameter ac:name="title">Example</ac:parameter><ac:parameter ac:name="breakoutMode">wide</ac:parameter><ac:plain-text-body><![CDATA[echo hello]]></ac:plain-text-body></ac:structured-macro>`
 	artifact, err := RenderStorage(testStoragePage(storage))
 	if err != nil {
 		t.Fatalf("RenderStorage returned error: %v", err)
@@ -132,8 +132,8 @@ func TestRenderStoragePreservesCodeMacroWithUnsupportedParameters(t *testing.T)
 	}
 }
 
-func TestRenderStoragePreservesLayoutsAndUnknownNamespaces(t *testing.T) {
-	storage := `<ac:layout><ac:layout-section ac:type="single"><ac:layout-cell><p>Inside layout</p></ac:layout-cell></ac:layout-section></ac:layout>` +
+func TestRenderStoragePreservesUnsupportedLayoutsAndUnknownNamespaces(t *testing.T) {
+	storage := `<ac:layout><ac:layout-section ac:type="seven_equal"><ac:layout-cell><p>Inside layout</p></ac:layout-cell></ac:layout-section></ac:layout>` +
 		`<custom:widget xmlns:custom="urn:custom"><custom:value>Keep me</custom:value></custom:widget>`
 	artifact, err := RenderStorage(testStoragePage(storage))
 	if err != nil {
@@ -0,0 +1,134 @@
+package content
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// statusColours maps the colours written in {status:colour|title} to the
+// colour parameter Confluence stores.
+var statusColours = map[string]string{
+	"grey": "Grey", "red": "Red", "yellow": "Yellow", "green": "Green", "blue": "Blue", "purple": "Purple",
+}
+
+// statusSyntax matches {status:colour|title}, or {status:colour|title|subtle}
+// for a subtle lozenge. Either part may be empty, and the title may be left
+// out. Pipe tables escape the separators as \|.
+var statusSyntax = regexp.MustCompile(`^\{status:([A-Za-z]*)(?:\\?\|([^|}\\\r\n]*)(?:\\?\|(subtle))?)?\}`)
+
+// isInlineBraceSyntax reports whether value starts with a date or status,
+// rather than an attribute block.
+func isInlineBraceSyntax(value []byte) bool {
+	return matchDate(value) != nil || statusSyntax.Match(value)
+}
+
+// statusMarkdown renders a status macro whose colour, title, and subtle style
+// have a Markdown form. A subtle status needs a title to write the style after.
+func statusMarkdown(raw string) (string, bool) {
+	macro, ok := parseStructuredMacro(raw)
+	if !ok || macro.Name != "status" || macro.BodyKind != "" {
+		return "", false
+	}
+	var colour, title string
+	hasColour, hasTitle, subtle := false, false, false
+	for _, parameter := range macro.Parameters {
+		switch {
+		case parameter.Name == "colour" && !hasColour:
+			colour, hasColour = strings.ToLower(parameter.Value), true
+			if statusColours[colour] != parameter.Value {
+				return "", false
+			}
+		case parameter.Name == "title" && !hasTitle:
+			title, hasTitle = parameter.Value, true
+			if strings.ContainsAny(title, "|}\\\r\n") {
+				return "", false
+			}
+		case parameter.Name == "subtle" && parameter.Value == "true" && !subtle:
+			subtle = true
+		default:
+			return "", false
+		}
+	}
+	if subtle && !hasTitle {
+		return "", false
+	}
+	markdown := "{status:" + colour
+	if hasTitle {
+		markdown += "|" + title
+	}
+	if subtle {
+		markdown += "|subtle"
+	}
+	return markdown + "}", true
+}
+
+var kindStatus = ast.NewNodeKind("ConfluxStatus")
+
+// statusNode is a {status:colour|title} lozenge. An empty Colour or a nil
+// Title leaves that parameter out.
+type statusNode struct {
+	ast.BaseInline
+	Colour string
+	Title  *string
+	Subtle bool
+}
+
+func (n *statusNode) Kind() ast.NodeKind { return kindStatus }
+
+func (n *statusNode) Dump(source []byte, level int) {
+	values := map[string]string{"Colour": n.Colour, "Subtle": strconv.FormatBool(n.Subtle)}
+	if n.Title != nil {
+		values["Title"] = *n.Title
+	}
+	ast.DumpHelper(n, source, level, values, nil)
+}
+
+type statusParser struct{}
+
+func (statusParser) Trigger() []byte { return []byte{'{'} }
+
+func (statusParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	match := statusSyntax.FindSubmatchIndex(line)
+	if match == nil {
+		return nil
+	}
+	colour := strings.ToLower(string(line[match[2]:match[3]]))
+	if colour != "" && statusColours[colour] == "" {
+		return nil
+	}
+	node := &statusNode{Colour: statusColours[colour]}
+	if match[4] >= 0 {
+		title := string(line[match[4]:match[5]])
+		node.Title = &title
+	}
+	node.Subtle = match[6] >= 0
+	block.Advance(match[1])
+	return node
+}
+
+func (w *storageWriter) renderStatus(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	status := node.(*statusNode)
+	_, _ = writer.WriteString(`<ac:structured-macro ac:name="status" ac:schema-version="1">`)
+	if status.Colour != "" {
+		_, _ = writer.WriteString(`<ac:parameter ac:name="colour">` + status.Colour + `</ac:parameter>`)
+	}
+	if status.Title != nil {
+		_, _ = writer.WriteString(`<ac:parameter ac:name="title">` + html.EscapeString(*status.Title) + `</ac:parameter>`)
+	}
+	if status.Subtle {
+		_, _ = writer.WriteString(`<ac:parameter ac:name="subtle">true</ac:parameter>`)
+	}
+	_, _ = writer.WriteString(`</ac:structured-macro>`)
+	return ast.WalkSkipChildren, nil
+}
@@ -0,0 +1,84 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderArtifactConvertsStatuses(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	tests := []struct {
+		markdown string
+		want     string
+	}{
+		{"{status:green|Done}", `<p><ac:structured-macro ac:name="status" ac:schema-version="1"><ac:parameter ac:name="colour">Green</ac:parameter><ac:parameter ac:name="title">Done</ac:parameter></ac:structured-macro></p>`},
+		{"Now {status:RED|At risk & late}.", `<p>Now <ac:structured-macro ac:name="status" ac:schema-version="1"><ac:parameter ac:name="colour">Red</ac:parameter><ac:parameter ac:name="title">At risk &amp; late</ac:parameter></ac:structured-macro>.</p>`},
+		{"{status:|Idle}", `<p><ac:structured-macro ac:name="status" ac:schema-version="1"><ac:parameter ac:name="title">Idle</ac:parameter></ac:structured-macro></p>`},
+		{"{status:blue}", `<p><ac:structured-macro ac:name="status" ac:schema-version="1"><ac:parameter ac:name="colour">Blue</ac:parameter></ac:structured-macro></p>`},
+		{"{status:grey|Draft|subtle}", `<p><ac:structured-macro ac:name="status" ac:schema-version="1"><ac:parameter ac:name="colour">Grey</ac:parameter><ac:parameter ac:name="title">Draft</ac:parameter><ac:parameter ac:name="subtle">true</ac:parameter></ac:structured-macro></p>`},
+		{"{status:pink|Done}", `<p>{status:pink|Done}</p>`},
+		{"{status:green|Done|bold}", `<p>{status:green|Done|bold}</p>`},
+		{"`{status:green|Done}`", `<p><code>{status:green|Done}</code></p>`},
+	}
+	for _, test := range tests {
+		t.Run(test.markdown, func(t *testing.T) {
+			artifact, err := RenderArtifact(test.markdown+"\n", metadata, nil)
+			if err != nil {
+				t.Fatalf("RenderArtifact returned error: %v", err)
+			}
+			if artifact.Storage != test.want {
+				t.Fatalf("storage = %s, want %s", artifact.Storage, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderArtifactConvertsOnlyRealDates(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	tests := map[string]string{
+		"{date:2024-02-29}":                       `<p><time datetime="2024-02-29" /></p>`,
+		"{date:2026-02-30} and {date:2026-13-01}": `<p>{date:2026-02-30} and {date:2026-13-01}</p>`,
+	}
+	for markdown, want := range tests {
+		t.Run(markdown, func(t *testing.T) {
+			artifact, err := RenderArtifact(markdown+"\n", metadata, nil)
+			if err != nil {
+				t.Fatalf("RenderArtifact returned error: %v", err)
+			}
+			if artifact.Storage != want {
+				t.Fatalf("storage = %s, want %s", artifact.Storage, want)
+			}
+		})
+	}
+}
+
+func TestRenderStoragePreservesTimesThatAreNotDates(t *testing.T) {
+	storage := `<p>Due <time datetime="2026-02-30" /></p>`
+	artifact, err := RenderStorage(testStoragePage(storage))
+	if err != nil {
+		t.Fatalf("RenderStorage returned error: %v", err)
+	}
+	if strings.Contains(artifact.Markdown, "{date:") || artifact.Metadata.PreservedFragments["fragment-0001"] != `<time datetime="2026-02-30" />` {
+		t.Fatalf("time was not preserved:\n%s %#v", artifact.Markdown, artifact.Metadata.PreservedFragments)
+	}
+}
+
+func TestRenderStorageKeepsStatusesWithoutMarkdownFormBehindMarkers(t *testing.T) {
+	for name, macro := range map[string]string{
+		"subtle untitled":  `<ac:structured-macro ac:name="status"><ac:parameter ac:name="colour">Green</ac:parameter><ac:parameter ac:name="subtle">true</ac:parameter></ac:structured-macro>`,
+		"lowercase colour": `<ac:structured-macro ac:name="status"><ac:parameter ac:name="colour">green</ac:parameter></ac:structured-macro>`,
+		"pipe in title":    `<ac:structured-macro ac:name="status"><ac:parameter ac:name="title">A|B</ac:parameter></ac:structured-macro>`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			artifact, err := RenderStorage(testStoragePage("<p>State " + macro + "</p>"))
+			if err != nil {
+				t.Fatalf("RenderStorage returned error: %v", err)
+			}
+			if !strings.Contains(artifact.Markdown, `<!-- conflux:inline id="fragment-0001" -->`) || artifact.Metadata.PreservedFragments["fragment-0001"] != macro {
+				t.Fatalf("status was not preserved inline:\n%s", artifact.Markdown)
+			}
+		})
+	}
+}
@@ -0,0 +1,575 @@
+package content
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/renderer"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// storageWriter renders a parsed Markdown document as Confluence storage XML.
+// It restores preserved fragments and records the attachments referenced by
+// links and images.
+type storageWriter struct {
+	fragments  map[string]string
+	restored   map[string]bool
+	references []string
+	tasks      *taskNumbering
+	// strikethrough is the storage form of struck-through text; see
+	// PageMetadata.Strikethrough.
+	strikethrough string
+
+	comment      bool
+	emptyComment bool
+}
+
+func markdownToStorage(markdown string, fragments map[string]string, strikethrough string) (string, []string, error) {
+	source := []byte(strings.ReplaceAll(markdown, "\r\n", "\n"))
+	document := newMarkdownParser().Parse(text.NewReader(source))
+	writer := &storageWriter{
+		fragments:     fragments,
+		restored:      make(map[string]bool, len(fragments)),
+		tasks:         newTaskNumbering(document, source),
+		strikethrough: strikethrough,
+	}
+	var storage bytes.Buffer
+	if err := renderer.NewRenderer(renderer.WithNodeRenderers(util.Prioritized(writer, 100))).Render(&storage, source, document); err != nil {
+		return "", nil, err
+	}
+
+	if err := checkRestored(markdown, fragments, writer.restored); err != nil {
+		return "", nil, err
+	}
+	return storage.String(), deduplicateStrings(writer.references), nil
+}
+
+// checkRestored reports a preserved fragment whose marker was not restored,
+// which happens when the marker sits where Markdown reads it as text.
+func checkRestored(markdown string, fragments map[string]string, restored map[string]bool) error {
+	var missing []string
+	for id := range fragments {
+		if !restored[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	if inlineMarkerIDs(markdown)[missing[0]] {
+		return fmt.Errorf("inline marker %q must be outside code and HTML", missing[0])
+	}
+	if macroMarkerIDs(markdown)[missing[0]] {
+		return fmt.Errorf("macro marker %q must be on its own line outside code and HTML", missing[0])
+	}
+	return fmt.Errorf("preservation marker %q must be a standalone block outside code and HTML", missing[0])
+}
+
+func (w *storageWriter) RegisterFuncs(registerer renderer.NodeRendererFuncRegisterer) {
+	registerer.Register(ast.KindDocument, w.renderChildren)
+	registerer.Register(ast.KindHeading, w.renderHeading)
+	registerer.Register(ast.KindParagraph, w.renderParagraph)
+	registerer.Register(ast.KindTextBlock, w.renderChildren)
+	registerer.Register(ast.KindBlockquote, w.renderTag("blockquote"))
+	registerer.Register(ast.KindList, w.renderList)
+	registerer.Register(ast.KindListItem, w.renderListItem)
+	registerer.Register(ast.KindThematicBreak, w.renderThematicBreak)
+	registerer.Register(ast.KindCodeBlock, w.renderCodeBlock)
+	registerer.Register(ast.KindFencedCodeBlock, w.renderCodeBlock)
+	registerer.Register(ast.KindHTMLBlock, w.renderHTMLBlock)
+
+	registerer.Register(ast.KindText, w.renderText)
+	registerer.Register(ast.KindString, w.renderString)
+	registerer.Register(ast.KindEmphasis, w.renderEmphasis)
+	registerer.Register(ast.KindCodeSpan, w.renderCodeSpan)
+	registerer.Register(ast.KindLink, w.renderLink)
+	registerer.Register(ast.KindAutoLink, w.renderAutoLink)
+	registerer.Register(ast.KindImage, w.renderImage)
+	registerer.Register(ast.KindRawHTML, w.renderRawHTML)
+
+	registerer.Register(kindPreservedBlock, w.renderPreservedBlock)
+	registerer.Register(kindMacroEnvelope, w.renderMacroEnvelope)
+	registerer.Register(kindDirectiveBlock, w.renderDirectiveBlock)
+	registerer.Register(kindLinkAttributes, w.renderLinkAttributes)
+	registerer.Register(kindTable, w.renderTable)
+	registerer.Register(kindTableRow, w.renderTag("tr"))
+	registerer.Register(kindTableCell, w.renderTableCell)
+	registerer.Register(kindCellAttributes, w.renderChildren)
+	registerer.Register(kindAdmonition, w.renderAdmonition)
+	registerer.Register(kindDetails, w.renderDetails)
+	registerer.Register(kindLayoutSection, w.renderLayoutSection)
+	registerer.Register(kindLayoutCell, w.renderLayoutCell)
+	registerer.Register(extast.KindTaskCheckBox, w.renderTaskCheckBox)
+	registerer.Register(kindDate, w.renderDate)
+	registerer.Register(kindStatus, w.renderStatus)
+	registerer.Register(kindEmoticon, w.renderEmoticon)
+	registerer.Register(kindFormattedText, w.renderFormattedText)
+}
+
+func (w *storageWriter) renderChildren(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	return ast.WalkContinue, nil
+}
+
+func (w *storageWriter) renderTag(tag string) renderer.NodeRendererFunc {
+	return func(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			_, _ = writer.WriteString("<" + tag + ">")
+		} else {
+			_, _ = writer.WriteString("</" + tag + ">")
+		}
+		return ast.WalkContinue, nil
+	}
+}
+
+func (w *storageWriter) renderHeading(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	return w.renderTag("h"+strconv.Itoa(node.(*ast.Heading).Level))(writer, source, node, entering)
+}
+
+// renderParagraph writes a paragraph that holds only an image, Jira link, or
+// attachment embed without a <p> wrapper, matching how Confluence stores those at block level.
+// Task bodies are inline content, so task paragraphs are unwrapped too.
+func (w *storageWriter) renderParagraph(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if standaloneInline(node) || isTaskItem(node.Parent()) {
+		return ast.WalkContinue, nil
+	}
+	return w.renderTag("p")(writer, source, node, entering)
+}
+
+func standaloneInline(paragraph ast.Node) bool {
+	var content []ast.Node
+	for child := paragraph.FirstChild(); child != nil; child = child.NextSibling() {
+		if child.Kind() != kindLinkAttributes {
+			content = append(content, child)
+		}
+	}
+	if len(content) != 1 {
+		return false
+	}
+	switch value := content[0].(type) {
+	case *ast.Image:
+		return true
+	case *ast.Link:
+		return strings.HasPrefix(string(value.Destination), "jira:") || isEmbedLink(value)
+	}
+	return false
+}
+
+func (w *storageWriter) renderList(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	list := node.(*ast.List)
+	tag := "ul"
+	if isTaskList(list) {
+		tag = "ac:task-list"
+	} else if list.IsOrdered() {
+		tag = "ol"
+	}
+	switch {
+	case !entering:
+		_, _ = writer.WriteString("</" + tag + ">")
+	case list.IsOrdered() && list.Start != 1:
+		_, _ = writer.WriteString(`<ol start="` + strconv.Itoa(list.Start) + `">`)
+	default:
+		_, _ = writer.WriteString("<" + tag + ">")
+	}
+	return ast.WalkContinue, nil
+}
+
+func (w *storageWriter) renderThematicBreak(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		_, _ = writer.WriteString("<hr />")
+	}
+	return ast.WalkContinue, nil
+}
+
+func (w *storageWriter) renderCodeBlock(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	info := ""
+	if fenced, ok := node.(*ast.FencedCodeBlock); ok {
+		if closed, _ := fenced.AttributeString(closedFenceAttribute); closed != true {
+			return ast.WalkStop, fmt.Errorf("markdown contains an unclosed fenced code block")
+		}
+		if fenced.Info != nil {
+			info = string(fenced.Info.Segment.Value(source))
+		}
+	}
+	code := strings.TrimSuffix(string(node.Lines().Value(source)), "\n")
+	if isJiraTableInfo(info) {
+		storage, err := jiraTableStorage(info, code)
+		if err != nil {
+			return ast.WalkStop, err
+		}
+		_, _ = writer.WriteString(storage)
+		return ast.WalkSkipChildren, nil
+	}
+	name, parameters, err := parseCodeInfo(info)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	_, _ = writer.WriteString(`<ac:structured-macro ac:name="` + name + `" ac:schema-version="1">`)
+	for _, parameter := range parameters {
+		_, _ = writer.WriteString(`<ac:parameter ac:name="` + html.EscapeString(parameter.Name) + `">` + html.EscapeString(parameter.Value) + `</ac:parameter>`)
+	}
+	_, _ = writer.WriteString(`<ac:plain-text-body><![CDATA[` + escapeCDATA(code) + `]]></ac:plain-text-body></ac:structured-macro>`)
+	return ast.WalkSkipChildren, nil
+}
+
+// renderHTMLBlock keeps raw HTML as visible text. Arbitrary HTML is not valid
+// storage, and the Conflux comments that are meaningful have their own nodes.
+// A <br> on its own is an empty line.
+func (w *storageWriter) renderHTMLBlock(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	block := node.(*ast.HTMLBlock)
+	raw := block.Lines().Value(source)
+	if block.HasClosure() {
+		raw = append(raw, block.ClosureLine.Value(source)...)
+	}
+	if lineBreakHTML.Match(bytes.TrimSpace(raw)) {
+		_, _ = writer.WriteString("<p><br /></p>")
+		return ast.WalkSkipChildren, nil
+	}
+	_, _ = writer.WriteString("<p>" + html.EscapeString(strings.TrimSpace(string(raw))) + "</p>")
+	return ast.WalkSkipChildren, nil
+}
+
+func (w *storageWriter) renderText(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	textNode := node.(*ast.Text)
+	value := textNode.Segment.Value(source)
+	if _, mention := mentionAccount(node.NextSibling()); mention {
+		value = bytes.TrimSuffix(value, []byte("@"))
+	}
+	if isTaskMarker(node.NextSibling(), source) {
+		value = bytes.TrimRight(value, " \t")
+	}
+	if textNode.IsRaw() {
+		gmhtml.DefaultWriter.RawWrite(writer, value)
+	} else {
+		gmhtml.DefaultWriter.Write(writer, value)
+	}
+	switch {
+	case textNode.HardLineBreak():
+		_, _ = writer.WriteString("<br />")
+	case textNode.SoftLineBreak():
+		_ = writer.WriteByte(' ')
+	}
+	return ast.WalkContinue, nil
+}
+
+func (w *storageWriter) renderString(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		gmhtml.DefaultWriter.RawWrite(writer, node.(*ast.String).Value)
+	}
+	return ast.WalkContinue, nil
+}
+
+func (w *storageWriter) renderEmphasis(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	tag := "em"
+	if node.(*ast.Emphasis).Level == 2 {
+		tag = "strong"
+	}
+	return w.renderTag(tag)(writer, source, node, entering)
+}
+
+func (w *storageWriter) renderCodeSpan(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	var code []byte
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		if textNode, ok := child.(*ast.Text); ok {
+			code = append(code, textNode.Segment.Value(source)...)
+		}
+	}
+	if inPipeTableCell(node) {
+		code = bytes.ReplaceAll(code, []byte(`\|`), []byte("|"))
+	}
+	_, _ = writer.WriteString("<code>")
+	gmhtml.DefaultWriter.RawWrite(writer, bytes.ReplaceAll(code, []byte("\n"), []byte(" ")))
+	_, _ = writer.WriteString("</code>")
+	return ast.WalkSkipChildren, nil
+}
+
+func (w *storageWriter) renderLink(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	link := node.(*ast.Link)
+	destination := string(link.Destination)
+	attributes := attributesOf(link)
+	if strings.HasPrefix(destination, "jira:") {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		storage, err := jiraStorage(destination, attributes)
+		if err != nil {
+			return ast.WalkStop, err
+		}
+		_, _ = writer.WriteString(storage)
+		return ast.WalkSkipChildren, nil
+	}
+	if filename := attachmentFilename(destination); filename != "" {
+		return w.renderAttachmentLink(writer, source, link, filename, entering)
+	}
+	if len(attributes) > 0 {
+		return ast.WalkStop, fmt.Errorf("link to %q does not support attributes %s", destination, formatAttributeBlock(attributes))
+	}
+	if strings.HasPrefix(destination, "confluence:") {
+		return w.renderPageLink(writer, source, link, entering)
+	}
+	if anchor, ok := strings.CutPrefix(destination, "#"); ok {
+		return w.renderAnchorLink(writer, source, link, anchor, entering)
+	}
+	if accountID, ok := mentionAccount(link); ok {
+		if entering {
+			_, _ = writer.WriteString(mentionStorage(accountID))
+		}
+		return ast.WalkSkipChildren, nil
+	}
+
+	if destination == "" {
+		return ast.WalkStop, fmt.Errorf("link %q has no destination", plainText(link, source))
+	}
+	if !entering {
+		_, _ = writer.WriteString("</a>")
+		return ast.WalkContinue, nil
+	}
+	_, _ = writer.WriteString(`<a href="` + escapeURL(link.Destination) + `"`)
+	if len(link.Title) > 0 {
+		_, _ = writer.WriteString(` title="`)
+		gmhtml.DefaultWriter.Write(writer, link.Title)
+		_ = writer.WriteByte('"')
+	}
+	_ = writer.WriteByte('>')
+	return ast.WalkContinue, nil
+}
+
+// renderPageLink writes a link to another Confluence page.
+func (w *storageWriter) renderPageLink(writer util.BufWriter, source []byte, link *ast.Link, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return w.renderLinkBody(writer, source, link, "", entering)
+	}
+	target, err := parsePageTarget(string(link.Destination))
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	_, _ = writer.WriteString(pageLinkStorage(target))
+	return w.renderLinkBody(writer, source, link, target.Title, entering)
+}
+
+// renderAnchorLink writes a link to an anchor on the same page.
+func (w *storageWriter) renderAnchorLink(writer util.BufWriter, source []byte, link *ast.Link, anchor string, entering bool) (ast.WalkStatus, error) {
+	name, err := url.PathUnescape(anchor)
+	if err != nil || name == "" {
+		return ast.WalkStop, fmt.Errorf("invalid anchor link %q", "#"+anchor)
+	}
+	if entering {
+		_, _ = writer.WriteString(`<ac:link ac:anchor="` + html.EscapeString(name) + `">`)
+	}
+	return w.renderLinkBody(writer, source, link, name, entering)
+}
+
+// renderAttachmentLink writes a link to a page attachment, or a view-file
+// macro when the link is marked {conflux-display=embed}.
+func (w *storageWriter) renderAttachmentLink(writer util.BufWriter, source []byte, link *ast.Link, filename string, entering bool) (ast.WalkStatus, error) {
+	if attributes := attributesOf(link); len(attributes) > 0 {
+		if !isEmbedLink(link) {
+			return ast.WalkStop, fmt.Errorf("link to %q does not support attributes %s", link.Destination, formatAttributeBlock(attributes))
+		}
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		storage, err := viewFileStorage(filename, attributes)
+		if err != nil {
+			return ast.WalkStop, err
+		}
+		w.references = append(w.references, filename)
+		_, _ = writer.WriteString(storage)
+		return ast.WalkSkipChildren, nil
+	}
+	if entering {
+		w.references = append(w.references, filename)
+		_, _ = writer.WriteString(`<ac:link><ri:attachment ri:filename="` + html.EscapeString(filename) + `" />`)
+	}
+	return w.renderLinkBody(writer, source, link, filename, entering)
+}
+
+// renderLinkBody writes the body and closing tag of an ac:link. Plain link
+// text becomes a plain-text body, omitted when it matches the text Confluence
+// shows by default, and formatted text becomes a rich body.
+func (w *storageWriter) renderLinkBody(writer util.BufWriter, source []byte, link *ast.Link, defaultText string, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		if !plainInline(link) {
+			_, _ = writer.WriteString("</ac:link-body></ac:link>")
+		}
+		return ast.WalkContinue, nil
+	}
+	if !plainInline(link) {
+		_, _ = writer.WriteString("<ac:link-body>")
+		return ast.WalkContinue, nil
+	}
+	if text := plainText(link, source); text != defaultText {
+		_, _ = writer.WriteString("<ac:plain-text-link-body><![CDATA[" + escapeCDATA(text) + "]]></ac:plain-text-link-body>")
+	}
+	_, _ = writer.WriteString("</ac:link>")
+	return ast.WalkSkipChildren, nil
+}
+
+// plainInline reports whether node contains only text.
+func plainInline(node ast.Node) bool {
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		if child.Kind() != ast.KindText && child.Kind() != ast.KindString {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *storageWriter) renderAutoLink(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	link := node.(*ast.AutoLink)
+	destination := link.URL(source)
+	if link.AutoLinkType == ast.AutoLinkEmail && !bytes.HasPrefix(bytes.ToLower(destination), []byte("mailto:")) {
+		destination = append([]byte("mailto:"), destination...)
+	}
+	_, _ = writer.WriteString(`<a href="` + escapeURL(destination) + `">`)
+	gmhtml.DefaultWriter.RawWrite(writer, link.Label(source))
+	_, _ = writer.WriteString("</a>")
+	return ast.WalkSkipChildren, nil
+}
+
+func (w *storageWriter) renderImage(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	image := node.(*ast.Image)
+	destination := string(image.Destination)
+	filename := attachmentFilename(destination)
+	var resource string
+	if filename != "" {
+		w.references = append(w.references, filename)
+		resource = `<ri:attachment ri:filename="` + html.EscapeString(filename) + `" />`
+	} else {
+		resource = `<ri:url ri:value="` + escapeURL(image.Destination) + `" />`
+	}
+	storage, err := imageStorage(plainText(image, source), filename, resource, attributesOf(image))
+	if err != nil {
+		return ast.WalkStop, fmt.Errorf("image %q: %w", destination, err)
+	}
+	_, _ = writer.WriteString(storage)
+	return ast.WalkSkipChildren, nil
+}
+
+// renderRawHTML keeps inline HTML as visible text, like renderHTMLBlock, and
+// restores the fragments behind inline markers. Task markers are consumed by
+// their list item, and comment markers become inline comment anchors.
+func (w *storageWriter) renderRawHTML(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkSkipChildren, nil
+	}
+	if isTaskMarker(node, source) {
+		if !isTaskItem(node.Parent().Parent()) || node.Parent() != node.Parent().Parent().FirstChild() {
+			return ast.WalkStop, fmt.Errorf("task marker must follow the text of a task list item")
+		}
+		return ast.WalkSkipChildren, nil
+	}
+	if isCommentMarker(node, source) {
+		if err := w.renderCommentMarker(writer, source, node); err != nil {
+			return ast.WalkStop, err
+		}
+		return ast.WalkSkipChildren, nil
+	}
+	value := rawHTMLValue(node.(*ast.RawHTML), source)
+	if anchorDirectiveStart.Match(value) {
+		if err := w.renderAnchorDirective(writer, value); err != nil {
+			return ast.WalkStop, err
+		}
+		return ast.WalkSkipChildren, nil
+	}
+	if lineBreakHTML.Match(value) {
+		_, _ = writer.WriteString("<br />")
+		return ast.WalkSkipChildren, nil
+	}
+	if match := inlineMarker.FindSubmatch(value); match != nil && len(match[0]) == len(value) {
+		if fragment, ok := w.fragments[string(match[1])]; ok {
+			w.restored[string(match[1])] = true
+			_, _ = writer.WriteString(fragment)
+			return ast.WalkSkipChildren, nil
+		}
+	}
+	gmhtml.DefaultWriter.RawWrite(writer, value)
+	return ast.WalkSkipChildren, nil
+}
+
+func (w *storageWriter) renderDate(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		_, _ = writer.WriteString(`<time datetime="` + node.(*dateNode).Value + `" />`)
+	}
+	return ast.WalkContinue, nil
+}
+
+func (w *storageWriter) renderPreservedBlock(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkSkipChildren, nil
+	}
+	block := node.(*preservedBlock)
+	fragment := w.fragments[block.ID]
+	if block.HasParameters {
+		if !block.ParametersClosed {
+			return ast.WalkStop, fmt.Errorf("parameter block after preservation marker %q is missing its closing %s line", block.ID, parametersBlockClose)
+		}
+		parameters, err := parseParametersBlock(block.ParameterLines)
+		if err == nil {
+			fragment, err = applyMacroParameters(fragment, parameters)
+		}
+		if err != nil {
+			return ast.WalkStop, fmt.Errorf("parameters of preserved fragment %q: %w", block.ID, err)
+		}
+	}
+	w.restored[block.ID] = true
+	_, _ = writer.WriteString(fragment)
+	return ast.WalkSkipChildren, nil
+}
+
+func (w *storageWriter) renderLinkAttributes(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	return ast.WalkSkipChildren, nil
+}
+
+// plainText returns the text content of an inline node, as used for image alt
+// text.
+func plainText(node ast.Node, source []byte) string {
+	var value bytes.Buffer
+	_ = ast.Walk(node, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch textNode := child.(type) {
+		case *ast.Text:
+			value.Write(util.UnescapePunctuations(textNode.Segment.Value(source)))
+			if textNode.SoftLineBreak() || textNode.HardLineBreak() {
+				value.WriteByte(' ')
+			}
+		case *ast.String:
+			value.Write(textNode.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return value.String()
+}
+
+func escapeURL(destination []byte) string {
+	return string(util.EscapeHTML(util.URLEscape(destination, true)))
+}
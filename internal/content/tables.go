@@ -0,0 +1,1200 @@
+package content
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	tableDirectivePattern   = regexp.MustCompile(`^<!--\s*conflux:table((?:\s+[a-z-]+="[^"]*")*)\s*-->$`)
+	tableDirectiveCandidate = regexp.MustCompile(`^<!--\s*conflux:table\b`)
+	directiveOption         = regexp.MustCompile(`([a-z-]+)="([^"]*)"`)
+	tableCellAlignStyle     = regexp.MustCompile(`^\s*text-align:\s*(left|center|right)\s*;?\s*$`)
+	tableColumnWidth        = regexp.MustCompile(`^[0-9]+(?:\.[0-9]+)?$`)
+	tableColumnWidthStyle   = regexp.MustCompile(`^\s*width:\s*([0-9]+(?:\.[0-9]+)?)px\s*;?\s*$`)
+	tableLayouts            = map[string]bool{"default": true, "center": true, "wide": true, "full-width": true}
+	tableHeaders            = map[string]bool{"row": true, "column": true, "both": true, "none": true}
+)
+
+// tableOptions are the table-level settings carried by a conflux:table
+// directive. Header says whether the first row, the first column, both, or
+// neither are header cells. ColumnWidths holds one width in pixels per
+// column, as written in the table's colgroup.
+type tableOptions struct {
+	Layout       string
+	Width        int
+	ColumnWidths []string
+	Header       string
+	Numbered     bool
+}
+
+func (o tableOptions) headerRow() bool    { return o.Header == "row" || o.Header == "both" }
+func (o tableOptions) headerColumn() bool { return o.Header == "column" || o.Header == "both" }
+
+func tableDirective(options tableOptions) string {
+	directive := `<!-- conflux:table layout="` + options.Layout + `"`
+	if options.Width > 0 {
+		directive += ` width="` + strconv.Itoa(options.Width) + `"`
+	}
+	if len(options.ColumnWidths) > 0 {
+		directive += ` column-widths="` + strings.Join(options.ColumnWidths, ",") + `"`
+	}
+	if options.Header != "row" {
+		directive += ` header="` + options.Header + `"`
+	}
+	if options.Numbered {
+		directive += ` numbered="true"`
+	}
+	return directive + " -->"
+}
+
+func parseTableDirective(line string) (tableOptions, error) {
+	match := tableDirectivePattern.FindStringSubmatch(line)
+	if match == nil {
+		return tableOptions{}, fmt.Errorf("invalid Conflux table directive %q", line)
+	}
+	options := tableOptions{Header: "row"}
+	seen := make(map[string]bool)
+	for _, option := range directiveOption.FindAllStringSubmatch(match[1], -1) {
+		name, value := option[1], option[2]
+		if seen[name] {
+			return tableOptions{}, fmt.Errorf("table directive repeats %q", name)
+		}
+		seen[name] = true
+		switch name {
+		case "layout":
+			if !tableLayouts[value] {
+				return tableOptions{}, fmt.Errorf("invalid table layout %q", value)
+			}
+			options.Layout = value
+		case "width":
+			width, err := strconv.Atoi(value)
+			if err != nil {
+				return tableOptions{}, fmt.Errorf("invalid table width %q: %w", value, err)
+			}
+			if width < 1 {
+				return tableOptions{}, fmt.Errorf("table width must be positive")
+			}
+			options.Width = width
+		case "column-widths":
+			for _, width := range strings.Split(value, ",") {
+				if !tableColumnWidth.MatchString(width) {
+					return tableOptions{}, fmt.Errorf("invalid table column width %q", width)
+				}
+				options.ColumnWidths = append(options.ColumnWidths, width)
+			}
+		case "header":
+			if !tableHeaders[value] {
+				return tableOptions{}, fmt.Errorf("invalid table header %q", value)
+			}
+			options.Header = value
+		case "numbered":
+			if value != "true" && value != "false" {
+				return tableOptions{}, fmt.Errorf("invalid table numbered value %q", value)
+			}
+			options.Numbered = value == "true"
+		default:
+			return tableOptions{}, fmt.Errorf("unsupported table directive option %q", name)
+		}
+	}
+	if options.Layout == "" {
+		return tableOptions{}, fmt.Errorf("table directive requires a layout")
+	}
+	return options, nil
+}
+
+// tableCellOptions are the cell attributes written as {colspan=2 align=center}
+// at the start of a cell.
+type tableCellOptions struct {
+	RowSpan int
+	ColSpan int
+	Align   string
+}
+
+func (o tableCellOptions) attributes(columnAlign string) []attribute {
+	var attributes []attribute
+	if o.ColSpan > 1 {
+		attributes = append(attributes, attribute{Key: "colspan", Value: strconv.Itoa(o.ColSpan)})
+	}
+	if o.RowSpan > 1 {
+		attributes = append(attributes, attribute{Key: "rowspan", Value: strconv.Itoa(o.RowSpan)})
+	}
+	if o.Align != columnAlign {
+		attributes = append(attributes, attribute{Key: "align", Value: o.Align})
+	}
+	return attributes
+}
+
+func parseTableCellOptions(attributes []attribute) (tableCellOptions, error) {
+	options := tableCellOptions{RowSpan: 1, ColSpan: 1}
+	for _, attribute := range attributes {
+		switch attribute.Key {
+		case "colspan", "rowspan":
+			span, err := strconv.Atoi(attribute.Value)
+			if err != nil || span < 1 {
+				return tableCellOptions{}, fmt.Errorf("invalid table cell %s %q", attribute.Key, attribute.Value)
+			}
+			if attribute.Key == "colspan" {
+				options.ColSpan = span
+			} else {
+				options.RowSpan = span
+			}
+		case "align":
+			if attribute.Value != "left" && attribute.Value != "center" && attribute.Value != "right" {
+				return tableCellOptions{}, fmt.Errorf("invalid table cell alignment %q", attribute.Value)
+			}
+			options.Align = attribute.Value
+		default:
+			return tableCellOptions{}, fmt.Errorf("unsupported table cell attribute %q", attribute.Key)
+		}
+	}
+	return options, nil
+}
+
+// tableGrid places cells in columns while rows are read, accounting for cells
+// that span rows or columns. The first row sets the column count.
+type tableGrid struct {
+	columns int
+	rows    int
+	pending []int
+}
+
+// place returns the starting column of each cell in the next row. Columns
+// still covered by a cell from an earlier row are skipped.
+func (g *tableGrid) place(cells []tableCellOptions) ([]int, error) {
+	if g.rows == 0 {
+		for _, cell := range cells {
+			g.columns += cell.ColSpan
+		}
+		g.pending = make([]int, g.columns)
+	}
+	g.rows++
+	spans := make([]int, g.columns)
+	covered := make([]bool, g.columns)
+	for column, remaining := range g.pending {
+		if remaining > 0 {
+			covered[column], spans[column] = true, remaining-1
+		}
+	}
+	starts := make([]int, 0, len(cells))
+	column := 0
+	for _, cell := range cells {
+		for column < g.columns && covered[column] {
+			column++
+		}
+		if column+cell.ColSpan > g.columns {
+			return nil, fmt.Errorf("covers more than %d columns", g.columns)
+		}
+		starts = append(starts, column)
+		for span := 0; span < cell.ColSpan; span++ {
+			if covered[column+span] {
+				return nil, fmt.Errorf("overlaps a cell spanning from an earlier row")
+			}
+			covered[column+span] = true
+			spans[column+span] = cell.RowSpan - 1
+		}
+		column += cell.ColSpan
+	}
+	count := 0
+	for _, isCovered := range covered {
+		if isCovered {
+			count++
+		}
+	}
+	if count != g.columns {
+		return nil, fmt.Errorf("covers %d columns; expected %d", count, g.columns)
+	}
+	g.pending = spans
+	return starts, nil
+}
+
+// finish reports an error when a cell spans past the last row.
+func (g *tableGrid) finish() error {
+	for _, remaining := range g.pending {
+		if remaining > 0 {
+			return fmt.Errorf("table cell rowspan extends past the last row")
+		}
+	}
+	return nil
+}
+
+// splitTableRow splits a pipe table row into its unescaped cells. The pipes
+// at the start and end of the row are optional, as in GFM, but a row holds at
+// least one unescaped pipe.
+func splitTableRow(line string) []string {
+	var result []string
+	var cell strings.Builder
+	escaped := false
+	for _, character := range strings.TrimSpace(line) {
+		if escaped {
+			if character != '|' && character != '\\' {
+				cell.WriteByte('\\')
+			}
+			cell.WriteRune(character)
+			escaped = false
+			continue
+		}
+		if character == '\\' {
+			escaped = true
+			continue
+		}
+		if character == '|' {
+			result = append(result, cell.String())
+			cell.Reset()
+			continue
+		}
+		cell.WriteRune(character)
+	}
+	if escaped {
+		cell.WriteByte('\\')
+	}
+	result = append(result, cell.String())
+	if len(result) < 2 {
+		return nil
+	}
+	result = trimOuterTableCells(result, func(cell string) bool { return cell == "" })
+	for index := range result {
+		result[index] = strings.TrimSpace(result[index])
+	}
+	return result
+}
+
+// trimOuterTableCells drops the empty cells before a leading pipe and after a
+// trailing pipe.
+func trimOuterTableCells[T any](cells []T, empty func(T) bool) []T {
+	if empty(cells[0]) {
+		cells = cells[1:]
+	}
+	if len(cells) > 1 && empty(cells[len(cells)-1]) {
+		cells = cells[:len(cells)-1]
+	}
+	return cells
+}
+
+// isTableDelimiter reports whether cells form a delimiter row: one or more
+// dashes per cell, with an optional colon at either end.
+func isTableDelimiter(cells []string) bool {
+	for _, cell := range cells {
+		cell = strings.TrimPrefix(strings.TrimSuffix(cell, ":"), ":")
+		if cell == "" || strings.Trim(cell, "-") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func tableDelimiterAlignment(cell string) string {
+	switch {
+	case strings.HasPrefix(cell, ":") && strings.HasSuffix(cell, ":"):
+		return "center"
+	case strings.HasSuffix(cell, ":"):
+		return "right"
+	case strings.HasPrefix(cell, ":"):
+		return "left"
+	}
+	return ""
+}
+
+func tableDelimiterCell(align string) string {
+	switch align {
+	case "center":
+		return ":---:"
+	case "right":
+		return "---:"
+	case "left":
+		return ":---"
+	}
+	return "---"
+}
+
+var (
+	kindTable          = ast.NewNodeKind("ConfluxTable")
+	kindTableRow       = ast.NewNodeKind("ConfluxTableRow")
+	kindTableCell      = ast.NewNodeKind("ConfluxTableCell")
+	kindCellAttributes = ast.NewNodeKind("ConfluxCellAttributes")
+)
+
+// tableBlock is a Markdown table, optionally introduced by a conflux:table
+// directive. A pipe table follows the directive directly; a table whose cells
+// hold blocks is written after the directive as a list of rows, each a list of
+// cells, and moved into the table by tableListTransformer. Structural problems are recorded in Err and
+// reported when rendering.
+type tableBlock struct {
+	ast.BaseBlock
+	tableOptions
+	Err      error
+	ListForm bool
+	grid     tableGrid
+	aligns   []string
+	lines    int
+	// outerPipes records that the header row starts with a pipe. The data
+	// rows of such a table must too, so that a following paragraph holding a
+	// pipe is not read as a row.
+	outerPipes bool
+}
+
+func (n *tableBlock) Kind() ast.NodeKind { return kindTable }
+
+func (n *tableBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Layout": n.Layout, "Width": strconv.Itoa(n.Width), "Header": n.Header}, nil)
+}
+
+// addRow places a row of cells and marks its header cells.
+func (n *tableBlock) addRow(row *tableRow, cells []*tableCell) error {
+	options := make([]tableCellOptions, len(cells))
+	for index, cell := range cells {
+		options[index] = cell.tableCellOptions
+	}
+	starts, err := n.grid.place(options)
+	if err != nil {
+		return fmt.Errorf("table row %d %w", n.grid.rows, err)
+	}
+	for index, cell := range cells {
+		cell.Header = (n.headerRow() && n.grid.rows == 1) || (n.headerColumn() && starts[index] == 0)
+		cell.column = starts[index]
+		n.alignCell(cell)
+		row.AppendChild(row, cell)
+	}
+	n.AppendChild(n, row)
+	return nil
+}
+
+// alignCell gives a cell without its own alignment that of its column.
+func (n *tableBlock) alignCell(cell *tableCell) {
+	if cell.Align == "" && cell.column < len(n.aligns) {
+		cell.Align = n.aligns[cell.column]
+	}
+}
+
+type tableRow struct {
+	ast.BaseBlock
+}
+
+func (n *tableRow) Kind() ast.NodeKind { return kindTableRow }
+
+func (n *tableRow) Dump(source []byte, level int) { ast.DumpHelper(n, source, level, nil, nil) }
+
+// tableCell is a table cell. Cells of a pipe table hold inline content; cells
+// of a list-form table hold blocks.
+type tableCell struct {
+	ast.BaseBlock
+	tableCellOptions
+	Header bool
+	Blocks bool
+	column int
+}
+
+func (n *tableCell) Kind() ast.NodeKind { return kindTableCell }
+
+func (n *tableCell) Dump(source []byte, level int) { ast.DumpHelper(n, source, level, nil, nil) }
+
+// inPipeTableCell reports whether an inline node is in a cell of a pipe table,
+// where GFM reads \| as a pipe even inside a code span.
+func inPipeTableCell(node ast.Node) bool {
+	for parent := node.Parent(); parent != nil; parent = parent.Parent() {
+		if cell, ok := parent.(*tableCell); ok {
+			return !cell.Blocks
+		}
+	}
+	return false
+}
+
+type tableDirectiveParser struct{}
+
+func (tableDirectiveParser) Trigger() []byte { return []byte{'<'} }
+
+func (tableDirectiveParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, _ := reader.PeekLine()
+	trimmed := strings.TrimSpace(string(line))
+	if !tableDirectiveCandidate.MatchString(trimmed) {
+		return nil, parser.NoChildren
+	}
+	reader.AdvanceToEOL()
+	options, err := parseTableDirective(trimmed)
+	return &tableBlock{tableOptions: options, Err: err}, parser.NoChildren
+}
+
+func (tableDirectiveParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	table := node.(*tableBlock)
+	if table.Err != nil {
+		return parser.Close
+	}
+	line, segment := reader.PeekLine()
+	if table.lines == 0 && isTableListStart(line) {
+		table.ListForm = true
+		return parser.Close
+	}
+	if !table.addLine(line, segment, reader.Source()) {
+		return parser.Close
+	}
+	reader.AdvanceToEOL()
+	return parser.Continue | parser.NoChildren
+}
+
+// addLine adds the next line of a pipe table: the header row, the delimiter
+// row, or a data row. It reports false when the line does not continue the
+// table or holds an error.
+func (table *tableBlock) addLine(line []byte, segment text.Segment, source []byte) bool {
+	cells := splitTableRow(string(line))
+	startsWithPipe := bytes.HasPrefix(bytes.TrimSpace(line), []byte("|"))
+	switch {
+	case cells == nil:
+		return false
+	case table.lines == 0:
+		table.outerPipes = startsWithPipe
+	case table.lines > 1 && table.outerPipes && !startsWithPipe:
+		return false
+	}
+	if table.lines == 1 {
+		if len(cells) != table.grid.columns || !isTableDelimiter(cells) {
+			return false
+		}
+		for _, cell := range cells {
+			table.aligns = append(table.aligns, tableDelimiterAlignment(cell))
+		}
+		for cell := table.FirstChild().FirstChild(); cell != nil; cell = cell.NextSibling() {
+			table.alignCell(cell.(*tableCell))
+		}
+		table.lines++
+		return true
+	}
+
+	var row []*tableCell
+	for _, cellSegment := range tableCellSegments(line, segment) {
+		cell := &tableCell{tableCellOptions: tableCellOptions{RowSpan: 1, ColSpan: 1}}
+		value := cellSegment.Value(source)
+		if end := attributeBlockEnd(value); len(value) > 0 && value[0] == '{' && end > 0 && !isInlineBraceSyntax(value) {
+			attributes, err := parseAttributeBlock(string(value[:end+1]))
+			if err == nil {
+				cell.tableCellOptions, err = parseTableCellOptions(attributes)
+			}
+			if err != nil {
+				table.Err = err
+				return false
+			}
+			cellSegment = cellSegment.WithStart(cellSegment.Start + end + 1)
+			cellSegment = cellSegment.TrimLeftSpace(source)
+		}
+		cell.Lines().Append(cellSegment)
+		row = append(row, cell)
+	}
+	if err := table.addRow(&tableRow{}, row); err != nil {
+		if table.lines == 0 {
+			table.Err = err
+		} else {
+			table.Err = fmt.Errorf("table data row %d: %w", table.lines-1, err)
+		}
+		return false
+	}
+	table.lines++
+	return true
+}
+
+func (tableDirectiveParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {
+	table := node.(*tableBlock)
+	if table.Err != nil || table.ListForm {
+		return
+	}
+	if table.lines < 2 {
+		table.Err = fmt.Errorf("table directive must be followed immediately by a valid Markdown table")
+		return
+	}
+	table.Err = table.grid.finish()
+}
+
+func (tableDirectiveParser) CanInterruptParagraph() bool { return true }
+
+func (tableDirectiveParser) CanAcceptIndentedLine() bool { return false }
+
+func isTableListStart(line []byte) bool {
+	trimmed := bytes.TrimLeft(line, " ")
+	return len(line)-len(trimmed) < 4 && (bytes.HasPrefix(trimmed, []byte("- ")) || bytes.Equal(bytes.TrimSpace(trimmed), []byte("-")))
+}
+
+// tableCellSegments splits a pipe-delimited row the same way as splitTableRow
+// but returns source segments, so cell content is parsed as inline Markdown.
+func tableCellSegments(line []byte, segment text.Segment) []text.Segment {
+	left := util.TrimLeftSpaceLength(line)
+	right := len(line) - util.TrimRightSpaceLength(line)
+	offset := segment.Start - segment.Padding
+	var cells [][2]int
+	cellStart := left
+	for index := left; index < right; index++ {
+		switch line[index] {
+		case '\\':
+			index++
+		case '|':
+			cells = append(cells, [2]int{cellStart, index})
+			cellStart = index + 1
+		}
+	}
+	cells = trimOuterTableCells(append(cells, [2]int{cellStart, right}), func(cell [2]int) bool { return cell[0] >= cell[1] })
+	segments := make([]text.Segment, 0, len(cells))
+	for _, cell := range cells {
+		start, stop := cell[0], cell[1]
+		for start < stop && util.IsSpace(line[start]) {
+			start++
+		}
+		for stop > start && util.IsSpace(line[stop-1]) {
+			stop--
+		}
+		segments = append(segments, text.NewSegment(offset+start, offset+stop))
+	}
+	return segments
+}
+
+// cellAttributes is a line holding only an attribute block at the start of a
+// list-form table cell.
+type cellAttributes struct {
+	ast.BaseBlock
+	Values []attribute
+	Err    error
+}
+
+func (n *cellAttributes) Kind() ast.NodeKind { return kindCellAttributes }
+
+func (n *cellAttributes) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Attributes": formatAttributeBlock(n.Values)}, nil)
+}
+
+type cellAttributesParser struct{}
+
+func (cellAttributesParser) Trigger() []byte { return []byte{'{'} }
+
+func (cellAttributesParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	if parent.HasChildren() || !isTableListCell(parent) {
+		return nil, parser.NoChildren
+	}
+	line, _ := reader.PeekLine()
+	trimmed := bytes.TrimSpace(line)
+	if attributeBlockEnd(trimmed) != len(trimmed)-1 || isInlineBraceSyntax(trimmed) {
+		return nil, parser.NoChildren
+	}
+	attributes, err := parseAttributeBlock(string(trimmed))
+	reader.AdvanceToEOL()
+	return &cellAttributes{Values: attributes, Err: err}, parser.NoChildren
+}
+
+func (cellAttributesParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	return parser.Close
+}
+
+func (cellAttributesParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (cellAttributesParser) CanInterruptParagraph() bool { return false }
+
+func (cellAttributesParser) CanAcceptIndentedLine() bool { return false }
+
+// isTableListCell reports whether a list item is a cell of a list-form table:
+// an item of a list inside an item of the list that follows a table directive.
+func isTableListCell(node ast.Node) bool {
+	if node.Kind() != ast.KindListItem || node.Parent() == nil || node.Parent().Parent() == nil {
+		return false
+	}
+	row := node.Parent().Parent()
+	if row.Kind() != ast.KindListItem || row.Parent() == nil {
+		return false
+	}
+	table, ok := row.Parent().PreviousSibling().(*tableBlock)
+	return ok && table.ListForm
+}
+
+// pipeTableTransformer turns the lines of a paragraph that form a pipe table
+// without a directive into a default-layout table. Lines before the header row
+// stay in the paragraph and lines after the table start a new one, trimmed as
+// the paragraph parser would have trimmed them.
+type pipeTableTransformer struct{}
+
+func (t pipeTableTransformer) Transform(paragraph *ast.Paragraph, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	lines := paragraph.Lines()
+	for header := 0; header+1 < lines.Len(); header++ {
+		next := lines.At(header + 1)
+		delimiter := splitTableRow(string(next.Value(source)))
+		if delimiter == nil || !isTableDelimiter(delimiter) {
+			continue
+		}
+		table := &tableBlock{tableOptions: tableOptions{Layout: "default", Header: "row"}}
+		end := header
+		for ; end < lines.Len() && table.Err == nil; end++ {
+			segment := lines.At(end)
+			if !table.addLine(segment.Value(source), segment, source) {
+				break
+			}
+		}
+		if table.Err == nil && table.lines < 2 {
+			continue
+		}
+		if table.Err == nil {
+			table.Err = table.grid.finish()
+		}
+
+		parent := paragraph.Parent()
+		if end < lines.Len() {
+			rest := ast.NewParagraph()
+			for index := end; index < lines.Len(); index++ {
+				line := lines.At(index)
+				rest.Lines().Append(line.TrimLeftSpace(source))
+			}
+			last := rest.Lines().At(rest.Lines().Len() - 1)
+			rest.Lines().Set(rest.Lines().Len()-1, last.TrimRightSpace(source))
+			parent.InsertAfter(parent, paragraph, rest)
+			t.Transform(rest, reader, pc)
+		}
+		parent.InsertAfter(parent, paragraph, table)
+		if header == 0 {
+			table.SetBlankPreviousLines(paragraph.HasBlankPreviousLines())
+			parent.RemoveChild(parent, paragraph)
+			return
+		}
+		lines.SetSliced(0, header)
+		return
+	}
+}
+
+// tableListTransformer moves the row list following a list-form table
+// directive into the table, turning its items into rows and cells.
+type tableListTransformer struct{}
+
+func (tableListTransformer) Transform(document *ast.Document, reader text.Reader, pc parser.Context) {
+	var tables []*tableBlock
+	_ = ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if table, ok := node.(*tableBlock); entering && ok && table.ListForm && table.Err == nil {
+			tables = append(tables, table)
+		}
+		return ast.WalkContinue, nil
+	})
+	for _, table := range tables {
+		table.Err = buildListTable(table)
+	}
+}
+
+func buildListTable(table *tableBlock) error {
+	list, ok := table.NextSibling().(*ast.List)
+	if !ok || list.IsOrdered() {
+		return fmt.Errorf("table directive must be followed immediately by a valid Markdown table")
+	}
+	table.Parent().RemoveChild(table.Parent(), list)
+	for item := list.FirstChild(); item != nil; {
+		nextItem := item.NextSibling()
+		cellList, ok := item.FirstChild().(*ast.List)
+		if !ok || item.ChildCount() != 1 || cellList.IsOrdered() {
+			return fmt.Errorf("table row %d must be a bullet list of cells", table.grid.rows+1)
+		}
+		var cells []*tableCell
+		for cellItem := cellList.FirstChild(); cellItem != nil; cellItem = cellItem.NextSibling() {
+			cell := &tableCell{tableCellOptions: tableCellOptions{RowSpan: 1, ColSpan: 1}, Blocks: true}
+			for child := cellItem.FirstChild(); child != nil; {
+				nextChild := child.NextSibling()
+				switch value := child.(type) {
+				case *cellAttributes:
+					var err error
+					if err = value.Err; err == nil {
+						cell.tableCellOptions, err = parseTableCellOptions(value.Values)
+					}
+					if err != nil {
+						return err
+					}
+				case *ast.TextBlock:
+					paragraph := ast.NewParagraph()
+					paragraph.SetLines(value.Lines())
+					for inline := value.FirstChild(); inline != nil; {
+						nextInline := inline.NextSibling()
+						paragraph.AppendChild(paragraph, inline)
+						inline = nextInline
+					}
+					cell.AppendChild(cell, paragraph)
+				default:
+					cell.AppendChild(cell, child)
+				}
+				child = nextChild
+			}
+			cells = append(cells, cell)
+		}
+		if err := table.addRow(&tableRow{}, cells); err != nil {
+			return err
+		}
+		item = nextItem
+	}
+	return table.grid.finish()
+}
+
+func (w *storageWriter) renderTable(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	table := node.(*tableBlock)
+	if table.Err != nil {
+		return ast.WalkStop, table.Err
+	}
+	if !entering {
+		_, _ = writer.WriteString("</tbody></table>")
+		return ast.WalkContinue, nil
+	}
+	_, _ = writer.WriteString(`<table data-layout="` + table.Layout + `"`)
+	if table.Width > 0 {
+		_, _ = writer.WriteString(` data-table-width="` + strconv.Itoa(table.Width) + `"`)
+	}
+	if table.Numbered {
+		_, _ = writer.WriteString(` data-number-column="true"`)
+	}
+	_, _ = writer.WriteString(">")
+	if len(table.ColumnWidths) > 0 {
+		if len(table.ColumnWidths) != table.grid.columns {
+			return ast.WalkStop, fmt.Errorf("table has %d columns but %d column widths", table.grid.columns, len(table.ColumnWidths))
+		}
+		_, _ = writer.WriteString("<colgroup>")
+		for _, width := range table.ColumnWidths {
+			_, _ = writer.WriteString(`<col style="width: ` + width + `px;"/>`)
+		}
+		_, _ = writer.WriteString("</colgroup>")
+	}
+	_, _ = writer.WriteString("<tbody>")
+	return ast.WalkContinue, nil
+}
+
+func (w *storageWriter) renderTableCell(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	cell := node.(*tableCell)
+	tag := "td"
+	if cell.Header {
+		tag = "th"
+	}
+	if !entering {
+		if !cell.Blocks {
+			_, _ = writer.WriteString("</p>")
+		}
+		_, _ = writer.WriteString("</" + tag + ">")
+		return ast.WalkContinue, nil
+	}
+	_, _ = writer.WriteString("<" + tag)
+	if cell.RowSpan > 1 {
+		_, _ = writer.WriteString(` rowspan="` + strconv.Itoa(cell.RowSpan) + `"`)
+	}
+	if cell.ColSpan > 1 {
+		_, _ = writer.WriteString(` colspan="` + strconv.Itoa(cell.ColSpan) + `"`)
+	}
+	if cell.Align != "" {
+		_, _ = writer.WriteString(` style="text-align: ` + cell.Align + `;"`)
+	}
+	_, _ = writer.WriteString(">")
+	if !cell.Blocks {
+		_, _ = writer.WriteString("<p>")
+	}
+	return ast.WalkContinue, nil
+}
+
+// storageTable is a Confluence table whose structure has a Markdown form.
+type storageTable struct {
+	tableOptions
+	Columns int
+	Rows    [][]storageTableCell
+}
+
+type storageTableCell struct {
+	tableCellOptions
+	Header bool
+	Start  int
+	Inner  string
+}
+
+// parseStorageTable reads a table, its column widths, its rows, and its
+// cells. Local IDs and cosmetic classes are dropped; any other attribute has
+// no Markdown form.
+func parseStorageTable(raw string) (storageTable, bool) {
+	start, inner, ok := splitStorageElement(raw)
+	if !ok {
+		return storageTable{}, false
+	}
+	table := storageTable{tableOptions: tableOptions{Layout: "default", Header: "row"}}
+	for _, attribute := range start.Attr {
+		switch {
+		case attribute.Name.Space == "urn:conflux:ac" && attribute.Name.Local == "local-id":
+		case attribute.Name.Space != "":
+			return storageTable{}, false
+		case attribute.Name.Local == "data-layout" && tableLayouts[attribute.Value]:
+			table.Layout = attribute.Value
+		case attribute.Name.Local == "data-table-width":
+			width, err := strconv.Atoi(attribute.Value)
+			if err != nil || width < 1 {
+				return storageTable{}, false
+			}
+			table.Width = width
+		case attribute.Name.Local == "data-number-column" && (attribute.Value == "true" || attribute.Value == "false"):
+			table.Numbered = attribute.Value == "true"
+		case attribute.Name.Local == "class" && attribute.Value == "wrapped":
+		default:
+			return storageTable{}, false
+		}
+	}
+	rows, widths, ok := storageTableRows(inner)
+	if !ok || len(rows) == 0 {
+		return storageTable{}, false
+	}
+	table.ColumnWidths = widths
+
+	var grid tableGrid
+	for _, row := range rows {
+		cells, ok := storageTableCells(row)
+		if !ok || len(cells) == 0 {
+			return storageTable{}, false
+		}
+		options := make([]tableCellOptions, len(cells))
+		for index, cell := range cells {
+			options[index] = cell.tableCellOptions
+		}
+		starts, err := grid.place(options)
+		if err != nil {
+			return storageTable{}, false
+		}
+		for index := range cells {
+			cells[index].Start = starts[index]
+		}
+		table.Rows = append(table.Rows, cells)
+	}
+	if grid.finish() != nil {
+		return storageTable{}, false
+	}
+	table.Columns = grid.columns
+	if len(table.ColumnWidths) > 0 && len(table.ColumnWidths) != table.Columns {
+		return storageTable{}, false
+	}
+
+	header, ok := tableHeader(table.Rows)
+	if !ok {
+		return storageTable{}, false
+	}
+	table.Header = header
+	return table, true
+}
+
+// tableHeader returns the header option that marks exactly the header cells
+// of a table, or false when no option does.
+func tableHeader(rows [][]storageTableCell) (string, bool) {
+	headerRow := true
+	for _, cell := range rows[0] {
+		headerRow = headerRow && cell.Header
+	}
+	headerColumn := false
+	for index, row := range rows {
+		if index == 0 && headerRow {
+			continue
+		}
+		for _, cell := range row {
+			if cell.Start == 0 {
+				if !cell.Header {
+					headerColumn = false
+					break
+				}
+				headerColumn = true
+			}
+		}
+		if !headerColumn {
+			break
+		}
+	}
+	options := tableOptions{Header: "row"}
+	switch {
+	case headerRow && headerColumn:
+		options.Header = "both"
+	case headerColumn:
+		options.Header = "column"
+	case !headerRow:
+		options.Header = "none"
+	}
+	for index, row := range rows {
+		for _, cell := range row {
+			if cell.Header != ((options.headerRow() && index == 0) || (options.headerColumn() && cell.Start == 0)) {
+				return "", false
+			}
+		}
+	}
+	return options.Header, true
+}
+
+// storageTableRows returns the rows of a table body and the column widths of
+// its colgroup, if it sets any.
+func storageTableRows(raw string) ([]string, []string, bool) {
+	nodes, err := tokenizeStorage(raw)
+	if err != nil {
+		return nil, nil, false
+	}
+	var rows, widths []string
+	for _, node := range nodes {
+		if strings.TrimSpace(node.Raw) == "" {
+			continue
+		}
+		start, inner, ok := splitStorageElement(node.Raw)
+		if !ok || node.Name.Space != "" || len(start.Attr) != 0 {
+			return nil, nil, false
+		}
+		switch strings.ToLower(node.Name.Local) {
+		case "colgroup":
+			if widths, ok = storageColumnWidths(inner); !ok {
+				return nil, nil, false
+			}
+		case "tbody", "thead":
+			nested, nestedWidths, ok := storageTableRows(inner)
+			if !ok || len(nestedWidths) > 0 {
+				return nil, nil, false
+			}
+			rows = append(rows, nested...)
+		case "tr":
+			rows = append(rows, inner)
+		default:
+			return nil, nil, false
+		}
+	}
+	return rows, widths, true
+}
+
+// storageColumnWidths reads the pixel widths of a colgroup's columns. A
+// colgroup whose columns set nothing has no widths; one that sets only some
+// of them, or sets anything else, has no Markdown form.
+func storageColumnWidths(raw string) ([]string, bool) {
+	nodes, err := tokenizeStorage(raw)
+	if err != nil {
+		return nil, false
+	}
+	var widths []string
+	styled := 0
+	for _, node := range nodes {
+		if strings.TrimSpace(node.Raw) == "" {
+			continue
+		}
+		start, inner, ok := splitStorageElement(node.Raw)
+		if !ok || node.Name.Space != "" || !strings.EqualFold(node.Name.Local, "col") || strings.TrimSpace(inner) != "" {
+			return nil, false
+		}
+		width := ""
+		for _, attribute := range start.Attr {
+			match := tableColumnWidthStyle.FindStringSubmatch(attribute.Value)
+			if attribute.Name.Space != "" || attribute.Name.Local != "style" || match == nil {
+				return nil, false
+			}
+			width = match[1]
+			styled++
+		}
+		widths = append(widths, width)
+	}
+	switch styled {
+	case 0:
+		return nil, true
+	case len(widths):
+		return widths, true
+	}
+	return nil, false
+}
+
+func storageTableCells(raw string) ([]storageTableCell, bool) {
+	nodes, err := tokenizeStorage(raw)
+	if err != nil {
+		return nil, false
+	}
+	var cells []storageTableCell
+	for _, node := range nodes {
+		if strings.TrimSpace(node.Raw) == "" {
+			continue
+		}
+		start, inner, ok := splitStorageElement(node.Raw)
+		name := strings.ToLower(node.Name.Local)
+		if !ok || node.Name.Space != "" || (name != "th" && name != "td") {
+			return nil, false
+		}
+		cell := storageTableCell{tableCellOptions: tableCellOptions{RowSpan: 1, ColSpan: 1}, Header: name == "th", Inner: inner}
+		for _, value := range start.Attr {
+			switch {
+			case value.Name.Space == "urn:conflux:ac" && value.Name.Local == "local-id":
+			case value.Name.Space != "":
+				return nil, false
+			case value.Name.Local == "rowspan" || value.Name.Local == "colspan":
+				options, err := parseTableCellOptions([]attribute{{Key: value.Name.Local, Value: value.Value}})
+				if err != nil {
+					return nil, false
+				}
+				cell.RowSpan, cell.ColSpan = max(cell.RowSpan, options.RowSpan), max(cell.ColSpan, options.ColSpan)
+			case value.Name.Local == "style" && tableCellAlignStyle.MatchString(value.Value):
+				cell.Align = tableCellAlignStyle.FindStringSubmatch(value.Value)[1]
+			case value.Name.Local == "class" && (value.Value == "confluenceTd" || value.Value == "confluenceTh"):
+			default:
+				return nil, false
+			}
+		}
+		cells = append(cells, cell)
+	}
+	return cells, true
+}
+
+// tableMarkdown renders a table after its directive. Tables whose cells hold
+// only inline content become pipe tables; others become a list of rows, each
+// a list of cells holding Markdown blocks.
+func (r *storageRenderer) tableMarkdown(raw string) (string, bool, error) {
+	table, ok := parseStorageTable(raw)
+	if !ok {
+		return "", false, nil
+	}
+	markdown, err := r.tableForms(table, func(row, index int) (string, bool, error) {
+		return r.inlineCellMarkdown(table.Rows[row][index].Inner)
+	}, func(row, index int) (string, error) {
+		inner := table.Rows[row][index].Inner
+		if nodes, err := tokenizeStorage(inner); err == nil && allInlineStorageNodes(nodes) {
+			inner = "<p>" + inner + "</p>"
+		}
+		return r.renderBlocks(inner)
+	})
+	return markdown, err == nil, err
+}
+
+// tableForms writes a table in pipe form when inlineCell converts every cell
+// to one line, and otherwise in list form with blockCell converting the cells.
+func (r *storageRenderer) tableForms(table storageTable, inlineCell func(row, index int) (string, bool, error), blockCell func(row, index int) (string, error)) (string, error) {
+	restore := r.checkpoint()
+	markdown, ok, err := pipeTableMarkdown(table, inlineCell)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		restore()
+		if markdown, err = listTableMarkdown(table, blockCell); err != nil {
+			return "", err
+		}
+	}
+	return tableDirective(table.tableOptions) + "\n" + markdown, nil
+}
+
+func pipeTableMarkdown(table storageTable, inlineCell func(row, index int) (string, bool, error)) (string, bool, error) {
+	aligns := make([]string, table.Columns)
+	seen := make([]bool, table.Columns)
+	mixed := make([]bool, table.Columns)
+	for _, row := range table.Rows {
+		for _, cell := range row {
+			if seen[cell.Start] && aligns[cell.Start] != cell.Align {
+				mixed[cell.Start] = true
+			}
+			seen[cell.Start], aligns[cell.Start] = true, cell.Align
+		}
+	}
+	for column := range aligns {
+		if mixed[column] {
+			aligns[column] = ""
+		}
+	}
+
+	lines := make([]string, 0, len(table.Rows)+1)
+	for rowIndex, row := range table.Rows {
+		values := make([]string, 0, len(row))
+		for index, cell := range row {
+			value, ok, err := inlineCell(rowIndex, index)
+			if err != nil || !ok {
+				return "", ok, err
+			}
+			value = strings.ReplaceAll(value, "|", `\|`)
+			if strings.HasPrefix(value, "{") && !isInlineBraceSyntax([]byte(value)) {
+				value = `\` + value
+			}
+			if attributes := cell.attributes(aligns[cell.Start]); len(attributes) > 0 {
+				value = strings.TrimSpace(formatAttributeBlock(attributes) + " " + value)
+			}
+			values = append(values, value)
+		}
+		lines = append(lines, "| "+strings.Join(values, " | ")+" |")
+		if rowIndex == 0 {
+			delimiters := make([]string, len(aligns))
+			for column, align := range aligns {
+				delimiters[column] = tableDelimiterCell(align)
+			}
+			lines = append(lines, "| "+strings.Join(delimiters, " | ")+" |")
+		}
+	}
+	return strings.Join(lines, "\n"), true, nil
+}
+
+// inlineCellMarkdown converts a cell holding at most one paragraph of inline
+// content. Line breaks become <br>, since a pipe table row is a single line.
+func (r *storageRenderer) inlineCellMarkdown(raw string) (string, bool, error) {
+	nodes, err := tokenizeStorage(raw)
+	if err != nil {
+		return "", false, nil
+	}
+	var significant []storageNode
+	for _, node := range nodes {
+		if strings.TrimSpace(node.Raw) != "" {
+			significant = append(significant, node)
+		}
+	}
+	content := raw
+	switch {
+	case len(significant) == 0:
+		return "", true, nil
+	case len(significant) == 1 && significant[0].Name.Space == "" && strings.EqualFold(significant[0].Name.Local, "p"):
+		start, inner, ok := splitStorageElement(significant[0].Raw)
+		if !ok || len(start.Attr) != 0 {
+			return "", false, nil
+		}
+		content = inner
+	default:
+		for _, node := range significant {
+			if !isInlineStorageNode(node) {
+				return "", false, nil
+			}
+		}
+	}
+
+	nodes, err = tokenizeStorage(content)
+	if err != nil {
+		return "", false, nil
+	}
+	parts := []string{""}
+	for _, node := range nodes {
+		if node.Name.Space == "" && strings.EqualFold(node.Name.Local, "br") {
+			parts = append(parts, "")
+			continue
+		}
+		parts[len(parts)-1] += node.Raw
+	}
+	for index, part := range parts {
+		markdown, ok, err := r.inlineMarkdown(part)
+		if err != nil || !ok || strings.Contains(markdown, "\n") {
+			return "", false, err
+		}
+		parts[index] = markdown
+	}
+	return strings.Join(parts, "<br>"), true, nil
+}
+
+func listTableMarkdown(table storageTable, blockCell func(row, index int) (string, error)) (string, error) {
+	rows := make([]string, 0, len(table.Rows))
+	for rowIndex, row := range table.Rows {
+		cells := make([]string, 0, len(row))
+		for index, cell := range row {
+			body, err := blockCell(rowIndex, index)
+			if err != nil {
+				return "", fmt.Errorf("render table cell: %w", err)
+			}
+			if attributes := cell.attributes(""); len(attributes) > 0 {
+				body = strings.TrimSuffix(formatAttributeBlock(attributes)+"\n"+body, "\n")
+			}
+			cells = append(cells, listItemMarkdown("-", body))
+		}
+		rows = append(rows, listItemMarkdown("-", strings.Join(cells, "\n")))
+	}
+	return strings.Join(rows, "\n"), nil
+}
+
+func allInlineStorageNodes(nodes []storageNode) bool {
+	inline := false
+	for _, node := range nodes {
+		if strings.TrimSpace(node.Raw) == "" {
+			continue
+		}
+		if !isInlineStorageNode(node) {
+			return false
+		}
+		inline = true
+	}
+	return inline
+}
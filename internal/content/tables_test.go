@@ -0,0 +1,160 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderArtifactConvertsRichTables(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{
+			name:     "header column and alignment",
+			markdown: "<!-- conflux:table layout=\"default\" header=\"column\" -->\n| Name | Value |\n| :--- | ---: |\n| Rows | {align=center} 3 |\n",
+			want:     `<table data-layout="default"><tbody><tr><th style="text-align: left;"><p>Name</p></th><td style="text-align: right;"><p>Value</p></td></tr><tr><th style="text-align: left;"><p>Rows</p></th><td style="text-align: center;"><p>3</p></td></tr></tbody></table>`,
+		},
+		{
+			name:     "spans and line breaks",
+			markdown: "<!-- conflux:table layout=\"center\" header=\"none\" -->\n| {rowspan=2} A | B<br>C |\n| --- | --- |\n| D |\n| {colspan=2} E |\n",
+			want:     `<table data-layout="center"><tbody><tr><td rowspan="2"><p>A</p></td><td><p>B<br />C</p></td></tr><tr><td><p>D</p></td></tr><tr><td colspan="2"><p>E</p></td></tr></tbody></table>`,
+		},
+		{
+			name:     "block cells",
+			markdown: "<!-- conflux:table layout=\"default\" numbered=\"true\" -->\n- - Step\n  - Command\n- - Build\n  - Run:\n\n    ```sh\n    make\n    ```\n",
+			want:     `<table data-layout="default" data-number-column="true"><tbody><tr><th><p>Step</p></th><th><p>Command</p></th></tr><tr><td><p>Build</p></td><td><p>Run:</p><ac:structured-macro ac:name="code" ac:schema-version="1"><ac:parameter ac:name="language">sh</ac:parameter><ac:plain-text-body><![CDATA[make]]></ac:plain-text-body></ac:structured-macro></td></tr></tbody></table>`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			artifact, err := RenderArtifact(test.markdown, metadata, nil)
+			if err != nil {
+				t.Fatalf("RenderArtifact returned error: %v", err)
+			}
+			if artifact.Storage != test.want {
+				t.Fatalf("storage = %s, want %s", artifact.Storage, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderStorageRoundTripsTableColumnWidths(t *testing.T) {
+	storage := `<table data-layout="default"><colgroup><col style="width: 100px;"/><col style="width: 226.5px;"/></colgroup><tbody><tr><th><p>Name</p></th><th><p>Value</p></th></tr><tr><td><p>a</p></td><td><p>b</p></td></tr></tbody></table>`
+	artifact, err := RenderStorage(testStoragePage(storage))
+	if err != nil {
+		t.Fatalf("RenderStorage returned error: %v", err)
+	}
+	want := "<!-- conflux:table layout=\"default\" column-widths=\"100,226.5\" -->\n| Name | Value |"
+	if !strings.Contains(artifact.Markdown, want) || len(artifact.Metadata.PreservedFragments) != 0 {
+		t.Fatalf("Markdown = %s, want %q", artifact.Markdown, want)
+	}
+	pushed, err := RenderArtifact(artifact.Markdown, artifact.Metadata, nil)
+	if err != nil {
+		t.Fatalf("RenderArtifact returned error: %v", err)
+	}
+	if pushed.Storage != storage {
+		t.Fatalf("storage = %s, want %s", pushed.Storage, storage)
+	}
+}
+
+func TestRenderArtifactRejectsInvalidRichTables(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	for name, markdown := range map[string]string{
+		"too wide":            "<!-- conflux:table layout=\"default\" -->\n| A | B |\n| --- | --- |\n| {colspan=3} C |\n",
+		"too wide undirected": "| A | B |\n| --- | --- |\n| {colspan=3} C |\n",
+		"rowspan past end":    "<!-- conflux:table layout=\"default\" -->\n| A | B |\n| --- | --- |\n| {rowspan=2} C | D |\n",
+		"unknown attribute":   "<!-- conflux:table layout=\"default\" -->\n| A |\n| --- |\n| {color=red} B |\n",
+		"unknown option":      "<!-- conflux:table layout=\"default\" borders=\"none\" -->\n| A |\n| --- |\n",
+		"row is not a list":   "<!-- conflux:table layout=\"default\" -->\n- A\n- B\n",
+		"bad cell attribute":  "<!-- conflux:table layout=\"default\" -->\n- - {rowspan=0}\n    A\n",
+		"bad column width":    "<!-- conflux:table layout=\"default\" column-widths=\"100px\" -->\n| A |\n| --- |\n",
+		"too few widths":      "<!-- conflux:table layout=\"default\" column-widths=\"100\" -->\n| A | B |\n| --- | --- |\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := RenderArtifact(markdown, metadata, nil); err == nil {
+				t.Fatal("RenderArtifact unexpectedly succeeded")
+			}
+		})
+	}
+}
+
+func TestRenderStoragePreservesTablesWithoutMarkdownForm(t *testing.T) {
+	for name, storage := range map[string]string{
+		"highlighted cell": `<table><tbody><tr><th><p>A</p></th></tr><tr><td data-highlight-colour="#ff0000"><p>B</p></td></tr></tbody></table>`,
+		"ragged rows":      `<table><tbody><tr><th><p>A</p></th><th><p>B</p></th></tr><tr><td><p>C</p></td></tr></tbody></table>`,
+		"partial widths":   `<table><colgroup><col style="width: 100px;"/><col/></colgroup><tbody><tr><th><p>A</p></th><th><p>B</p></th></tr></tbody></table>`,
+		"mixed headers":    `<table><tbody><tr><th><p>A</p></th><td><p>B</p></td></tr><tr><td><p>C</p></td><th><p>D</p></th></tr></tbody></table>`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			artifact, err := RenderStorage(testStoragePage(storage))
+			if err != nil {
+				t.Fatalf("RenderStorage returned error: %v", err)
+			}
+			if artifact.Metadata.PreservedFragments["fragment-0001"] != storage {
+				t.Fatalf("table was not preserved:\n%s", artifact.Markdown)
+			}
+		})
+	}
+}
+
+func TestRenderArtifactConvertsTablesWithoutDirective(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{
+			name:     "new table",
+			markdown: "Owners:\n| Service | Owner |\n| --- | :---: |\n| API | {colspan=1} Platform |\n",
+			want:     `<p>Owners:</p><table data-layout="default"><tbody><tr><th><p>Service</p></th><th style="text-align: center;"><p>Owner</p></th></tr><tr><td><p>API</p></td><td style="text-align: center;"><p>Platform</p></td></tr></tbody></table>`,
+		},
+		{
+			name:     "table in list item",
+			markdown: "- Owners\n\n  | Service |\n  | --- |\n  | API |\n",
+			want:     `<ul><li><p>Owners</p><table data-layout="default"><tbody><tr><th><p>Service</p></th></tr><tr><td><p>API</p></td></tr></tbody></table></li></ul>`,
+		},
+		{
+			name:     "text after table",
+			markdown: "| A |\n| --- |\n| B |\nAfter *this*\n",
+			want:     `<table data-layout="default"><tbody><tr><th><p>A</p></th></tr><tr><td><p>B</p></td></tr></tbody></table><p>After <em>this</em></p>`,
+		},
+		{
+			name:     "short delimiter cells",
+			markdown: "|A|B|\n|-|:-:|\n|1|2|\n",
+			want:     `<table data-layout="default"><tbody><tr><th><p>A</p></th><th style="text-align: center;"><p>B</p></th></tr><tr><td><p>1</p></td><td style="text-align: center;"><p>2</p></td></tr></tbody></table>`,
+		},
+		{
+			name:     "no outer pipes",
+			markdown: "A | B\n--- | ---\n1 | `x\\|y`\n3 | 4\n",
+			want:     `<table data-layout="default"><tbody><tr><th><p>A</p></th><th><p>B</p></th></tr><tr><td><p>1</p></td><td><p><code>x|y</code></p></td></tr><tr><td><p>3</p></td><td><p>4</p></td></tr></tbody></table>`,
+		},
+		{
+			name:     "no delimiter row",
+			markdown: "| not | a table |\n| still | text |\n",
+			want:     `<p>| not | a table | | still | text |</p>`,
+		},
+		{
+			name:     "delimiter width mismatch",
+			markdown: "| A | B |\n| --- |\n",
+			want:     `<p>| A | B | | --- |</p>`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			artifact, err := RenderArtifact(test.markdown, metadata, nil)
+			if err != nil {
+				t.Fatalf("RenderArtifact returned error: %v", err)
+			}
+			if artifact.Storage != test.want {
+				t.Fatalf("storage = %s, want %s", artifact.Storage, test.want)
+			}
+		})
+	}
+}
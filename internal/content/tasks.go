@@ -0,0 +1,295 @@
+package content
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	taskMarker          = regexp.MustCompile(`^<!--\s*conflux:task\s+id="([0-9]{1,9})"(?:\s+uuid="([A-Za-z0-9-]+)")?\s*-->$`)
+	taskMarkerCandidate = regexp.MustCompile(`^<!--\s*conflux:task\b`)
+)
+
+type storageTask struct {
+	ID       string
+	UUID     string
+	Complete bool
+	Body     string
+	Subtasks []storageNode
+}
+
+func isStorageTaskList(node storageNode) bool {
+	return node.Name.Space == "urn:conflux:ac" && node.Name.Local == "task-list"
+}
+
+// taskListMarkdown renders an ac:task-list as a GFM task list. Each item ends
+// with a task marker carrying the Confluence task ID so the task survives
+// reordering and edits. It reports false when a task holds content that has
+// no task list form. Alternate selects the * bullet, as for listMarkdown.
+func (r *storageRenderer) taskListMarkdown(node storageNode, alternate bool) (string, bool, error) {
+	start, inner, ok := splitStorageElement(node.Raw)
+	if !ok || len(start.Attr) != 0 {
+		return "", false, nil
+	}
+	children, err := tokenizeStorage(inner)
+	if err != nil {
+		return "", false, fmt.Errorf("tokenize task list: %w", err)
+	}
+	var items []string
+	for _, child := range children {
+		if strings.TrimSpace(child.Raw) == "" {
+			continue
+		}
+		if child.Name.Space != "urn:conflux:ac" || child.Name.Local != "task" {
+			return "", false, nil
+		}
+		task, ok, err := parseStorageTask(child.Raw)
+		if err != nil || !ok {
+			return "", ok, err
+		}
+		body, ok, err := r.inlineMarkdown(task.Body)
+		if err != nil || !ok {
+			return "", ok, err
+		}
+		checkbox := "[ ]"
+		if task.Complete {
+			checkbox = "[x]"
+		}
+		line := checkbox + " " + body
+		if body == "" {
+			line = checkbox
+		}
+		parts := []string{line + " " + formatTaskMarker(task.ID, task.UUID)}
+		var lists adjacentLists
+		for _, subtasks := range task.Subtasks {
+			markdown, ok, err := r.taskListMarkdown(subtasks, lists.next("bullet"))
+			if err != nil || !ok {
+				return "", ok, err
+			}
+			parts = append(parts, markdown)
+		}
+		items = append(items, listItemMarkdown(bulletMarker(alternate), strings.Join(parts, "\n")))
+	}
+	if len(items) == 0 {
+		return "", false, nil
+	}
+	return strings.Join(items, "\n"), true, nil
+}
+
+func parseStorageTask(raw string) (storageTask, bool, error) {
+	start, inner, ok := splitStorageElement(raw)
+	if !ok || len(start.Attr) != 0 {
+		return storageTask{}, false, nil
+	}
+	children, err := tokenizeStorage(inner)
+	if err != nil {
+		return storageTask{}, false, fmt.Errorf("tokenize task: %w", err)
+	}
+	var task storageTask
+	status := ""
+	hasBody := false
+	for _, child := range children {
+		if strings.TrimSpace(child.Raw) == "" {
+			continue
+		}
+		if child.Name.Space != "urn:conflux:ac" {
+			return storageTask{}, false, nil
+		}
+		childStart, childInner, ok := splitStorageElement(child.Raw)
+		if !ok || len(childStart.Attr) != 0 {
+			return storageTask{}, false, nil
+		}
+		value := html.UnescapeString(strings.TrimSpace(childInner))
+		switch child.Name.Local {
+		case "task-id":
+			task.ID = value
+		case "task-uuid":
+			task.UUID = value
+		case "task-status":
+			status = value
+		case "task-body":
+			hasBody = true
+			body, subtasks, ok, err := splitTaskBody(childInner)
+			if err != nil || !ok {
+				return storageTask{}, ok, err
+			}
+			task.Body, task.Subtasks = body, subtasks
+		default:
+			return storageTask{}, false, nil
+		}
+	}
+	if !hasBody || (status != "complete" && status != "incomplete") {
+		return storageTask{}, false, nil
+	}
+	task.Complete = status == "complete"
+	if formatTaskMarker(task.ID, task.UUID) == "" {
+		return storageTask{}, false, nil
+	}
+	return task, true, nil
+}
+
+// splitTaskBody separates the inline text of a task body from the task lists
+// nested after it.
+func splitTaskBody(raw string) (string, []storageNode, bool, error) {
+	nodes, err := tokenizeStorage(raw)
+	if err != nil {
+		return "", nil, false, fmt.Errorf("tokenize task body: %w", err)
+	}
+	var body strings.Builder
+	var subtasks []storageNode
+	for _, node := range nodes {
+		switch {
+		case isStorageTaskList(node):
+			subtasks = append(subtasks, node)
+		case strings.TrimSpace(node.Raw) == "":
+		case len(subtasks) > 0 || !isInlineStorageNode(node):
+			return "", nil, false, nil
+		default:
+			body.WriteString(node.Raw)
+		}
+	}
+	return body.String(), subtasks, true, nil
+}
+
+func formatTaskMarker(id, uuid string) string {
+	marker := `<!-- conflux:task id="` + id + `"`
+	if uuid != "" {
+		marker += ` uuid="` + uuid + `"`
+	}
+	marker += " -->"
+	if !taskMarker.MatchString(marker) {
+		return ""
+	}
+	return marker
+}
+
+// isTaskList reports whether every item of a bullet list starts with a task
+// checkbox. Lists mixing tasks and ordinary items stay ordinary lists.
+func isTaskList(node ast.Node) bool {
+	list, ok := node.(*ast.List)
+	if !ok || list.IsOrdered() || !list.HasChildren() {
+		return false
+	}
+	for item := list.FirstChild(); item != nil; item = item.NextSibling() {
+		if taskCheckBox(item) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func taskCheckBox(item ast.Node) *extast.TaskCheckBox {
+	if item.FirstChild() == nil {
+		return nil
+	}
+	checkbox, _ := item.FirstChild().FirstChild().(*extast.TaskCheckBox)
+	return checkbox
+}
+
+func isTaskItem(node ast.Node) bool {
+	return node != nil && node.Kind() == ast.KindListItem && isTaskList(node.Parent())
+}
+
+func isTaskMarker(node ast.Node, source []byte) bool {
+	raw, ok := node.(*ast.RawHTML)
+	return ok && taskMarkerCandidate.Match(rawHTMLValue(raw, source))
+}
+
+func rawHTMLValue(node *ast.RawHTML, source []byte) []byte {
+	var value []byte
+	for index := 0; index < node.Segments.Len(); index++ {
+		segment := node.Segments.At(index)
+		value = append(value, segment.Value(source)...)
+	}
+	return value
+}
+
+// taskNumbering hands out Confluence task IDs. Pulled IDs are kept unless a
+// copied line repeats one; new tasks continue after the highest ID in use.
+type taskNumbering struct {
+	used map[string]bool
+	next int
+}
+
+func newTaskNumbering(document ast.Node, source []byte) *taskNumbering {
+	numbering := &taskNumbering{used: make(map[string]bool), next: 1}
+	_ = ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if raw, ok := node.(*ast.RawHTML); entering && ok {
+			if match := taskMarker.FindSubmatch(rawHTMLValue(raw, source)); match != nil {
+				if id, err := strconv.Atoi(string(match[1])); err == nil && id >= numbering.next {
+					numbering.next = id + 1
+				}
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return numbering
+}
+
+func (n *taskNumbering) assign(id string) string {
+	if id == "" || n.used[id] {
+		id = strconv.Itoa(n.next)
+		n.next++
+	}
+	n.used[id] = true
+	return id
+}
+
+func (w *storageWriter) renderListItem(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !isTaskItem(node) {
+		return w.renderTag("li")(writer, source, node, entering)
+	}
+	if !entering {
+		_, _ = writer.WriteString("</ac:task-body></ac:task>")
+		return ast.WalkContinue, nil
+	}
+	var id, uuid string
+	for child := node.FirstChild().FirstChild(); child != nil; child = child.NextSibling() {
+		if !isTaskMarker(child, source) {
+			continue
+		}
+		value := rawHTMLValue(child.(*ast.RawHTML), source)
+		match := taskMarker.FindSubmatch(value)
+		if match == nil {
+			return ast.WalkStop, fmt.Errorf("invalid Conflux task marker %q", value)
+		}
+		if id != "" {
+			return ast.WalkStop, fmt.Errorf("task item has more than one task marker")
+		}
+		id, uuid = string(match[1]), string(match[2])
+	}
+	assigned := w.tasks.assign(id)
+	if assigned != id {
+		uuid = ""
+	}
+	status := "incomplete"
+	if taskCheckBox(node).IsChecked {
+		status = "complete"
+	}
+	_, _ = writer.WriteString("<ac:task><ac:task-id>" + assigned + "</ac:task-id>")
+	if uuid != "" {
+		_, _ = writer.WriteString("<ac:task-uuid>" + uuid + "</ac:task-uuid>")
+	}
+	_, _ = writer.WriteString("<ac:task-status>" + status + "</ac:task-status><ac:task-body>")
+	return ast.WalkContinue, nil
+}
+
+// renderTaskCheckBox writes nothing for task list items, whose state is the
+// task status. A checkbox in an ordinary list item stays visible text.
+func (w *storageWriter) renderTaskCheckBox(writer util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering && !isTaskItem(node.Parent().Parent()) {
+		if node.(*extast.TaskCheckBox).IsChecked {
+			_, _ = writer.WriteString("[x] ")
+		} else {
+			_, _ = writer.WriteString("[ ] ")
+		}
+	}
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,86 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderArtifactConvertsTaskLists(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{
+			name:     "new tasks follow highest id",
+			markdown: "- [x] Existing <!-- conflux:task id=\"7\" -->\n- [ ] New item\n- [X] Another\n",
+			want:     "<ac:task-list><ac:task><ac:task-id>7</ac:task-id><ac:task-status>complete</ac:task-status><ac:task-body>Existing</ac:task-body></ac:task><ac:task><ac:task-id>8</ac:task-id><ac:task-status>incomplete</ac:task-status><ac:task-body>New item</ac:task-body></ac:task><ac:task><ac:task-id>9</ac:task-id><ac:task-status>complete</ac:task-status><ac:task-body>Another</ac:task-body></ac:task></ac:task-list>",
+		},
+		{
+			name:     "copied marker gets a new id",
+			markdown: "- [ ] One <!-- conflux:task id=\"2\" uuid=\"abc-1\" -->\n- [ ] Copy <!-- conflux:task id=\"2\" uuid=\"abc-1\" -->\n",
+			want:     "<ac:task-list><ac:task><ac:task-id>2</ac:task-id><ac:task-uuid>abc-1</ac:task-uuid><ac:task-status>incomplete</ac:task-status><ac:task-body>One</ac:task-body></ac:task><ac:task><ac:task-id>3</ac:task-id><ac:task-status>incomplete</ac:task-status><ac:task-body>Copy</ac:task-body></ac:task></ac:task-list>",
+		},
+		{
+			name:     "mention and due date",
+			markdown: "- [ ] Ask @[Ana](user:557058:abc) by {date:2024-05-01}\n",
+			want:     `<ac:task-list><ac:task><ac:task-id>1</ac:task-id><ac:task-status>incomplete</ac:task-status><ac:task-body>Ask <ac:link><ri:user ri:account-id="557058:abc" /></ac:link> by <time datetime="2024-05-01" /></ac:task-body></ac:task></ac:task-list>`,
+		},
+		{
+			name:     "mixed list stays ordinary",
+			markdown: "- [ ] Task\n- Plain\n",
+			want:     "<ul><li>[ ] Task</li><li>Plain</li></ul>",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			artifact, err := RenderArtifact(test.markdown, metadata, nil)
+			if err != nil {
+				t.Fatalf("RenderArtifact returned error: %v", err)
+			}
+			if artifact.Storage != test.want {
+				t.Fatalf("storage = %s, want %s", artifact.Storage, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderArtifactRejectsMisplacedTaskMarkers(t *testing.T) {
+	metadata := pushMetadata()
+	metadata.PreservedFragments = map[string]string{}
+	for name, markdown := range map[string]string{
+		"outside task":  "Paragraph <!-- conflux:task id=\"1\" -->\n",
+		"malformed":     "- [ ] Task <!-- conflux:task id=\"one\" -->\n",
+		"two markers":   "- [ ] Task <!-- conflux:task id=\"1\" --> <!-- conflux:task id=\"2\" -->\n",
+		"ordinary list": "- Item <!-- conflux:task id=\"1\" -->\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := RenderArtifact(markdown, metadata, nil); err == nil {
+				t.Fatal("RenderArtifact unexpectedly succeeded")
+			}
+		})
+	}
+}
+
+func TestRenderStoragePreservesUnrepresentableTaskLists(t *testing.T) {
+	for name, storage := range map[string]string{
+		"macro in code":  `<ac:task-list><ac:task><ac:task-id>1</ac:task-id><ac:task-status>incomplete</ac:task-status><ac:task-body>Ship <code><ac:structured-macro ac:name="status" /></code></ac:task-body></ac:task></ac:task-list>`,
+		"missing status": `<ac:task-list><ac:task><ac:task-id>1</ac:task-id><ac:task-body>Ship</ac:task-body></ac:task></ac:task-list>`,
+		"block in body":  `<ac:task-list><ac:task><ac:task-id>1</ac:task-id><ac:task-status>incomplete</ac:task-status><ac:task-body><p>Ship</p></ac:task-body></ac:task></ac:task-list>`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			artifact, err := RenderStorage(testStoragePage(storage))
+			if err != nil {
+				t.Fatalf("RenderStorage returned error: %v", err)
+			}
+			if artifact.Metadata.PreservedFragments["fragment-0001"] != storage {
+				t.Fatalf("task list was not preserved:\n%s", artifact.Markdown)
+			}
+			if strings.Contains(artifact.Markdown, "conflux:task") {
+				t.Fatalf("task marker emitted for preserved list:\n%s", artifact.Markdown)
+			}
+		})
+	}
+}
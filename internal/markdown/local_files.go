@@ -0,0 +1,116 @@
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// LocalFile is a file that standalone Markdown references by a relative path.
+// It is uploaded as a page attachment named Filename.
+type LocalFile struct {
+	Path     string
+	Filename string
+}
+
+// converter converts standalone Markdown. With a directory, relative image
+// and file link targets are resolved against it and collected as files to
+// attach to the page.
+type converter struct {
+	dir   string
+	files []LocalFile
+	err   error
+}
+
+// ConvertWithLocalFiles is ConvertToConfluenceFormat for Markdown read from a
+// file in dir. Relative image targets, and relative links to files other than
+// Markdown, become references to page attachments. The files are returned in
+// the order they are first referenced. A missing image, or one outside dir,
+// is an error, while a link that does not name a file in dir stays a plain
+// link.
+func ConvertWithLocalFiles(markdown, dir string) (string, []LocalFile, error) {
+	c := &converter{dir: dir}
+	storage := c.convert(markdown)
+	if c.err != nil {
+		return "", nil, c.err
+	}
+	return storage, c.files, nil
+}
+
+var urlScheme = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*:`)
+
+// attachment returns the attachment filename for a local link target, which
+// arrives HTML-escaped, or false when the target is not a local file.
+func (c *converter) attachment(target string, image bool) (string, bool) {
+	if c.dir == "" || c.err != nil {
+		return "", false
+	}
+	target = html.UnescapeString(target)
+	if target == "" || urlScheme.MatchString(target) || strings.ContainsAny(target, "?#") || strings.HasPrefix(target, "/") {
+		return "", false
+	}
+	decoded, err := url.PathUnescape(target)
+	if err != nil {
+		decoded = target
+	}
+	if !image {
+		switch strings.ToLower(filepath.Ext(decoded)) {
+		case ".md", ".markdown":
+			return "", false
+		}
+	}
+	path := filepath.Join(c.dir, filepath.FromSlash(decoded))
+	if !within(c.dir, path) {
+		if image {
+			c.err = fmt.Errorf("local image %q is outside %s", target, c.dir)
+		}
+		return "", false
+	}
+	// A symbolic link inside dir may still point outside it, so containment
+	// is checked again on the resolved paths.
+	resolved, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		var root string
+		if root, err = filepath.EvalSymlinks(c.dir); err == nil && !within(root, resolved) {
+			if image {
+				c.err = fmt.Errorf("local image %q resolves outside %s", target, c.dir)
+			}
+			return "", false
+		}
+	}
+	if err == nil {
+		var info os.FileInfo
+		if info, err = os.Stat(resolved); err == nil && !info.Mode().IsRegular() {
+			err = fmt.Errorf("not a regular file")
+		}
+	}
+	if err != nil {
+		if image {
+			c.err = fmt.Errorf("local image %q: %w", target, err)
+		}
+		return "", false
+	}
+
+	filename := filepath.Base(path)
+	for _, file := range c.files {
+		if file.Path == path {
+			return file.Filename, true
+		}
+		if strings.EqualFold(file.Filename, filename) {
+			c.err = fmt.Errorf("local files %q and %q would both be attached as %q", file.Path, path, filename)
+			return "", false
+		}
+	}
+	c.files = append(c.files, LocalFile{Path: path, Filename: filename})
+	return filename, true
+}
+
+// within reports whether path is dir or lies below it.
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
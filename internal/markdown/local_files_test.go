@@ -0,0 +1,104 @@
+package markdown
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeLocalFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(name), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConvertWithLocalFilesRewritesRelativeReferences(t *testing.T) {
+	dir := t.TempDir()
+	diagram := writeLocalFile(t, dir, "img/diagram.png")
+	report := writeLocalFile(t, dir, "files/Q3 report.pdf")
+	writeLocalFile(t, dir, "other.md")
+
+	input := "# Release\n\n" +
+		"![diagram](./img/diagram.png)\n\n" +
+		"See [the **report**](files/Q3%20report.pdf) and ![again](img/diagram.png).\n\n" +
+		"![badge](https://ci.example.com/badge.svg) [site](https://example.com) [next](other.md) [missing](notes.txt)\n\n" +
+		"```\n![code](img/diagram.png)\n```"
+	storage, files, err := ConvertWithLocalFiles(input, dir)
+	if err != nil {
+		t.Fatalf("ConvertWithLocalFiles returned error: %v", err)
+	}
+	for _, expected := range []string{
+		`<ac:image ac:alt="diagram"><ri:attachment ri:filename="diagram.png"/></ac:image>`,
+		`<ac:link><ri:attachment ri:filename="Q3 report.pdf"/><ac:link-body>the <strong>report</strong></ac:link-body></ac:link>`,
+		`<ac:image ac:alt="again"><ri:attachment ri:filename="diagram.png"/></ac:image>`,
+		`<ri:url ri:value="https://ci.example.com/badge.svg"/>`,
+		`<a href="https://example.com">site</a>`,
+		`<a href="other.md">next</a>`,
+		`<a href="notes.txt">missing</a>`,
+		`![code](img/diagram.png)`,
+	} {
+		if !strings.Contains(storage, expected) {
+			t.Errorf("expected %q in %s", expected, storage)
+		}
+	}
+	want := []LocalFile{{Path: diagram, Filename: "diagram.png"}, {Path: report, Filename: "Q3 report.pdf"}}
+	if !reflect.DeepEqual(files, want) {
+		t.Fatalf("files = %#v, want %#v", files, want)
+	}
+}
+
+func TestConvertWithLocalFilesKeepsLinksOutsideDirectory(t *testing.T) {
+	root := t.TempDir()
+	writeLocalFile(t, root, "secret.txt")
+	dir := filepath.Join(root, "docs")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "secret.txt"), filepath.Join(dir, "linked.txt")); err != nil {
+		t.Fatal(err)
+	}
+	storage, files, err := ConvertWithLocalFiles("[secret](../secret.txt) [linked](linked.txt)", dir)
+	if err != nil {
+		t.Fatalf("ConvertWithLocalFiles returned error: %v", err)
+	}
+	if len(files) != 0 || !strings.Contains(storage, `<a href="../secret.txt">secret</a>`) || !strings.Contains(storage, `<a href="linked.txt">linked</a>`) {
+		t.Fatalf("storage = %s files = %#v", storage, files)
+	}
+}
+
+func TestConvertWithLocalFilesRejectsUnattachableImages(t *testing.T) {
+	root := t.TempDir()
+	writeLocalFile(t, root, "secret.png")
+	dir := filepath.Join(root, "docs")
+	writeLocalFile(t, dir, "a/diagram.png")
+	writeLocalFile(t, dir, "b/diagram.png")
+	if err := os.Symlink(filepath.Join(root, "secret.png"), filepath.Join(dir, "linked.png")); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		input string
+		want  string
+	}{
+		"missing image":   {input: "![diagram](img/missing.png)", want: `local image "img/missing.png"`},
+		"same attachment": {input: "![a](a/diagram.png) ![b](b/diagram.png)", want: `would both be attached as "diagram.png"`},
+		"outside dir":     {input: "![secret](../secret.png)", want: `local image "../secret.png" is outside`},
+		"escaped outside": {input: "![secret](a/%2E%2E/%2E%2E/secret.png)", want: "is outside"},
+		"symlink outside": {input: "![secret](linked.png)", want: `local image "linked.png" resolves outside`},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := ConvertWithLocalFiles(test.input, dir)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("error = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
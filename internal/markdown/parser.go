@@ -97,6 +97,10 @@ func FindMarkdownFiles(path string, exclude []string) ([]string, error) {
 }
 
 func ConvertToConfluenceFormat(markdown string) string {
+	return (&converter{}).convert(markdown)
+}
+
+func (c *converter) convert(markdown string) string {
 	lines := strings.Split(markdown, "\n")
 	var result []string
 	inCodeBlock := false
@@ -147,7 +151,7 @@ func ConvertToConfluenceFormat(markdown string) string {
 				tableLines = append(tableLines, lines[end])
 				end++
 			}
-			result = append(result, convertTable(tableLines))
+			result = append(result, c.convertTable(tableLines))
 			i = end - 1
 			continue
 		}
@@ -211,7 +215,7 @@ func ConvertToConfluenceFormat(markdown string) string {
 				inUnorderedList = true
 			}
 			content := strings.TrimSpace(line[strings.Index(line, strings.TrimSpace(line))+2:])
-			content = convertInlineFormatting(content)
+			content = c.convertInlineFormatting(content)
 			result = append(result, fmt.Sprintf("<li>%s</li>", content))
 			continue
 		}
@@ -231,7 +235,7 @@ func ConvertToConfluenceFormat(markdown string) string {
 						inOrderedList = true
 					}
 					content := strings.TrimSpace(trimmed[3:])
-					content = convertInlineFormatting(content)
+					content = c.convertInlineFormatting(content)
 					result = append(result, fmt.Sprintf("<li>%s</li>", content))
 					continue
 				}
@@ -247,7 +251,7 @@ func ConvertToConfluenceFormat(markdown string) string {
 
 		// Regular paragraph
 		closeOpenLists(&result, &inUnorderedList, &inOrderedList)
-		content := convertInlineFormatting(line)
+		content := c.convertInlineFormatting(line)
 		result = append(result, fmt.Sprintf("<p>%s</p>", content))
 	}
 
@@ -268,7 +272,7 @@ func closeOpenLists(result *[]string, inUnorderedList *bool, inOrderedList *bool
 	}
 }
 
-func convertInlineFormatting(text string) string {
+func (c *converter) convertInlineFormatting(text string) string {
 	// First escape HTML in the entire text
 	text = escapeHTML(text)
 	// Handle bold (**text** or __text__)
@@ -279,7 +283,7 @@ func convertInlineFormatting(text string) string {
 	// Handle inline code
 	text = convertInlineCodeFromEscaped(text)
 	// Handle links last so formatting inside the link text is already converted
-	text = convertLinksFromEscaped(text)
+	text = c.convertLinksFromEscaped(text)
 	return text
 }
 
@@ -287,10 +291,12 @@ func convertInlineFormatting(text string) string {
 // Bracket matching is done by hand rather than with a regex so that nested
 // forms such as a linked badge - [![alt](image)](target) - survive intact.
 //
-// Images pointing at a remote URL become <ri:url> image macros. Images pointing
-// at a local file remain Markdown; editable artifacts manage their attachment
-// references through the page-specific attachment directory.
-func convertLinksFromEscaped(text string) string {
+// Images pointing at a remote URL become <ri:url> image macros. Images and
+// file links pointing at a local file become attachment references when the
+// converter has a directory to resolve them in, and images otherwise remain
+// Markdown; editable artifacts manage their attachment references through the
+// page-specific attachment directory.
+func (c *converter) convertLinksFromEscaped(text string) string {
 	var b strings.Builder
 
 	for i := 0; i < len(text); {
@@ -318,14 +324,20 @@ func convertLinksFromEscaped(text string) string {
 		label := text[open+1 : labelEnd]
 		target := strings.TrimSpace(text[labelEnd+2 : urlEnd])
 
+		filename, local := c.attachment(target, isImage)
 		switch {
 		case isImage && isRemoteURL(target):
 			fmt.Fprintf(&b, `<ac:image ac:alt="%s"><ri:url ri:value="%s"/></ac:image>`, stripHTMLTags(label), target)
+		case isImage && local:
+			fmt.Fprintf(&b, `<ac:image ac:alt="%s"><ri:attachment ri:filename="%s"/></ac:image>`, stripHTMLTags(label), escapeHTML(filename))
 		case isImage:
-			// Local image - leave the markdown for the attachment processor.
+			// Local image without a directory to resolve it in - leave the
+			// markdown for the attachment processor.
 			b.WriteString(text[start : urlEnd+1])
+		case local:
+			fmt.Fprintf(&b, `<ac:link><ri:attachment ri:filename="%s"/><ac:link-body>%s</ac:link-body></ac:link>`, escapeHTML(filename), c.convertLinksFromEscaped(label))
 		default:
-			fmt.Fprintf(&b, `<a href="%s">%s</a>`, target, convertLinksFromEscaped(label))
+			fmt.Fprintf(&b, `<a href="%s">%s</a>`, target, c.convertLinksFromEscaped(label))
 		}
 		i = urlEnd + 1
 	}
@@ -411,20 +423,20 @@ func splitTableRow(line string) []string {
 // convertTable renders a pipe table (header row, delimiter row, body rows) as
 // Confluence storage format. Header cells use <th>, matching the markup
 // Confluence itself produces.
-func convertTable(tableLines []string) string {
+func (c *converter) convertTable(tableLines []string) string {
 	var b strings.Builder
 	b.WriteString("<table><tbody>")
 
 	b.WriteString("<tr>")
 	for _, cell := range splitTableRow(tableLines[0]) {
-		fmt.Fprintf(&b, "<th>%s</th>", convertInlineFormatting(cell))
+		fmt.Fprintf(&b, "<th>%s</th>", c.convertInlineFormatting(cell))
 	}
 	b.WriteString("</tr>")
 
 	for _, row := range tableLines[1:] {
 		b.WriteString("<tr>")
 		for _, cell := range splitTableRow(row) {
-			fmt.Fprintf(&b, "<td>%s</td>", convertInlineFormatting(cell))
+			fmt.Fprintf(&b, "<td>%s</td>", c.convertInlineFormatting(cell))
 		}
 		b.WriteString("</tr>")
 	}
@@ -422,7 +422,7 @@ func TestConvertInlineFormatting(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			result := convertInlineFormatting(tc.input)
+			result := (&converter{}).convertInlineFormatting(tc.input)
 			if result != tc.expected {
 				t.Errorf("Expected: %s\nGot: %s", tc.expected, result)
 			}
@@ -705,7 +705,7 @@ func TestConvertLinks(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := convertInlineFormatting(tt.input)
+			result := (&converter{}).convertInlineFormatting(tt.input)
 			if !strings.Contains(result, tt.expected) {
 				t.Errorf("Expected %q in %q", tt.expected, result)
 			}
@@ -730,7 +730,7 @@ func TestConvertUnderscoreItalic(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := convertInlineFormatting(tt.input)
+			result := (&converter{}).convertInlineFormatting(tt.input)
 			if result != tt.expected {
 				t.Errorf("Expected %q, got %q", tt.expected, result)
 			}